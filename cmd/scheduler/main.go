@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/statuscache"
@@ -42,11 +43,18 @@ func main() {
 		log.Fatalf("failed to init db: %v", err)
 	}
 
+	// каналы доставки
+	channelConfig, err := channel.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	channels := channel.NewDefaultRegistry(channelConfig)
+
 	// репозиторий
-	repo := repository.NewNotificationRepo(db.Master)
+	repo := repository.NewNotificationRepo(db.Master, channels.Storages())
 
 	// сервис
-	svc := service.NewNotificationService(repo)
+	svc := service.NewNotificationService(repo, channels)
 
 	// подключение к Redis
 	redisAddr := os.Getenv("REDIS_ADDR")
@@ -12,7 +12,10 @@ import (
 	"github.com/wb-go/wbf/ginext"
 	"github.com/wb-go/wbf/redis"
 
+	"github.com/PavelBradnitski/WbTechL3.1/internal/bounce"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/handler"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/importer"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/statuscache"
@@ -69,11 +72,18 @@ func main() {
 		log.Fatalf("failed to init db: %v", err)
 	}
 
+	// каналы доставки; сервер ничего не отправляет, отправители используются только для предпросмотра
+	channelConfig, err := channel.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	channels := channel.NewDefaultRegistry(channelConfig)
+
 	// репозиторий
-	repo := repository.NewNotificationRepo(db.Master)
+	repo := repository.NewNotificationRepo(db.Master, channels.Storages())
 
 	// сервис
-	svc := service.NewNotificationService(repo)
+	svc := service.NewNotificationService(repo, channels)
 
 	// http engine
 	r := ginext.New()
@@ -92,7 +102,41 @@ func main() {
 		log.Fatalf("failed to connect to redis: %v", err)
 	}
 	// хендлеры
-	handler.NewNotificationHandler(r, svc, frontendURL, statusCache)
+	imp := importer.NewImporter(svc, channels, statusCache)
+	handler.NewNotificationHandler(r, svc, frontendURL, statusCache, imp, channels)
+	handler.NewPushHandler(r, repository.NewPushSubscriptionRepo(db.Master), channelConfig.VAPIDPublicKey(), channelConfig.PushTokenSigner())
+
+	// бот Telegram: в режиме webhook обновления принимает сервер и сам регистрирует адрес вебхука
+	var bot *service.TelegramBot
+	if api := channelConfig.TelegramBotAPI(); api != nil && channelConfig.TelegramUpdates == "webhook" {
+		// без секрета вебхук принял бы поддельные обновления
+		if channelConfig.TelegramWebhookSecret == "" {
+			log.Fatal("TELEGRAM_WEBHOOK_SECRET is required when TELEGRAM_UPDATES=webhook")
+		}
+		bot = service.NewTelegramBot(svc, api)
+		if channelConfig.TelegramWebhookURL != "" {
+			if err := api.SetWebhook(ctx, channelConfig.TelegramWebhookURL, channelConfig.TelegramWebhookSecret); err != nil {
+				log.Printf("telegram bot: failed to set webhook: %v", err)
+			}
+		}
+	}
+	handler.NewTelegramHandler(r, svc, bot, channelConfig.TelegramBotUsername, channelConfig.TelegramWebhookSecret)
+
+	// ссылки отписки из заголовка List-Unsubscribe
+	handler.NewUnsubscribeHandler(r, svc, channelConfig.UnsubscribeSigner())
+
+	// сообщения о недоставке писем: вебхук почтового провайдера и SMTP-сервер для адреса возврата
+	bounces := service.NewBounceProcessor(svc, statusCache)
+	handler.NewBounceHandler(r, bounces, os.Getenv("BOUNCE_WEBHOOK_SECRET"))
+	if bounceAddr := os.Getenv("BOUNCE_SMTP_ADDR"); bounceAddr != "" {
+		srv := &bounce.Server{Addr: bounceAddr, Handler: bounces.ProcessAll, Hostname: os.Getenv("BOUNCE_SMTP_HOSTNAME")}
+		go func() {
+			log.Printf("bounce smtp server listening on %s", bounceAddr)
+			if err := srv.ListenAndServe(ctx); err != nil {
+				log.Printf("bounce smtp server stopped: %v", err)
+			}
+		}()
+	}
 
 	// запуск сервера
 	addr := ":8081"
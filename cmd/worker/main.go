@@ -5,11 +5,10 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"time"
 
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
-	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/statuscache"
 	"github.com/joho/godotenv"
@@ -48,11 +47,20 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to init db: %v", err)
 	}
+	// каналы доставки; подписки Web Push и вложения писем отправители читают из БД
+	channelConfig, err := channel.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	channelConfig.PushSubscriptions = repository.NewPushSubscriptionRepo(db.Master)
+	channelConfig.Attachments = repository.NewAttachmentRepo(db.Master)
+	channels := channel.NewDefaultRegistry(channelConfig)
+
 	// репозиторий
-	repo := repository.NewNotificationRepo(db.Master)
+	repo := repository.NewNotificationRepo(db.Master, channels.Storages())
 
 	// сервис
-	svc := service.NewNotificationService(repo)
+	svc := service.NewNotificationService(repo, channels)
 
 	// подключаем Redis
 	redisAddr := os.Getenv("REDIS_ADDR")
@@ -72,23 +80,15 @@ func main() {
 		panic(err)
 	}
 	defer rabbit.Close()
-	channel, err := rabbit.Channel()
+	rabbitChannel, err := rabbit.Channel()
 	if err != nil {
 		panic(err)
 	}
-	defer channel.Close()
-	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
-	emailSender := sender.NewEmailSender(
-		os.Getenv("SMTP_HOST"),
-		port,
-		os.Getenv("SMTP_USER"),
-		os.Getenv("SMTP_PASS"),
-		os.Getenv("SMTP_FROM"),
-	)
-	telegramSender := sender.NewTelegramSender(os.Getenv("TELEGRAM_TOKEN"))
-
-	sender := sender.NewMultiSender(emailSender, telegramSender)
-
-	worker := service.NewWorker(channel, sender, svc, statusCache)
+	defer rabbitChannel.Close()
+	// в режиме polling обновления бота Telegram получает воркер
+	if api := channelConfig.TelegramBotAPI(); api != nil && channelConfig.TelegramUpdates == "polling" {
+		go service.NewTelegramBot(svc, api).Poll(ctx)
+	}
+	worker := service.NewWorker(rabbitChannel, channels.Sender(), svc, statusCache)
 	worker.Start()
 }
@@ -0,0 +1,17 @@
+// Service worker: показывает браузерные push-уведомления и открывает ссылку по клику.
+self.addEventListener('push', event => {
+    const data = event.data ? event.data.json() : {};
+    event.waitUntil(self.registration.showNotification(data.title || 'Notification', {
+        body: data.body || '',
+        tag: data.id,
+        data: { url: data.url }
+    }));
+});
+
+self.addEventListener('notificationclick', event => {
+    event.notification.close();
+    const url = event.notification.data && event.notification.data.url;
+    if (url) {
+        event.waitUntil(clients.openWindow(url));
+    }
+});
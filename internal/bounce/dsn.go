@@ -0,0 +1,127 @@
+// Package bounce разбирает сообщения о недоставке писем (DSN, RFC 3464) и принимает их
+// по SMTP на адрес возврата.
+package bounce
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// ErrNotDSN письмо не является отчетом о доставке (multipart/report; report-type=delivery-status).
+var ErrNotDSN = errors.New("message is not a delivery status notification")
+
+// maxDSNPartSize ограничивает размер части отчета, которая читается целиком.
+const maxDSNPartSize = 1 << 20
+
+// ParseDSN разбирает отчет о доставке и возвращает получателей, доставка которым окончательно
+// не удалась (Action: failed). Ошибка с кодом 5.x.x считается постоянной, 4.x.x — временной,
+// сервер отказался от повторов. Сообщения о задержке (Action: delayed) пропускаются.
+// Message-ID исходного письма берется из вложенного письма или его заголовков.
+func ParseDSN(r io.Reader) ([]models.Bounce, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading message: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		return nil, ErrNotDSN
+	}
+
+	var (
+		recipients []textproto.MIMEHeader
+		messageID  string
+	)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading report part: %w", err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body := io.LimitReader(part, maxDSNPartSize)
+		switch strings.ToLower(partType) {
+		case "message/delivery-status", "message/global-delivery-status":
+			if recipients, err = parseDeliveryStatus(body); err != nil {
+				return nil, err
+			}
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			if original, err := mail.ReadMessage(body); err == nil {
+				messageID = strings.TrimSpace(original.Header.Get("Message-ID"))
+			}
+		}
+	}
+	if recipients == nil {
+		return nil, fmt.Errorf("%w: no delivery-status part", ErrNotDSN)
+	}
+
+	var bounces []models.Bounce
+	for _, h := range recipients {
+		if !strings.EqualFold(strings.TrimSpace(h.Get("Action")), "failed") {
+			continue
+		}
+		recipient := fieldValue(h.Get("Final-Recipient"))
+		if recipient == "" {
+			recipient = fieldValue(h.Get("Original-Recipient"))
+		}
+		if recipient == "" {
+			continue
+		}
+		status := strings.TrimSpace(h.Get("Status"))
+		bounces = append(bounces, models.Bounce{
+			Recipient:  recipient,
+			MessageID:  messageID,
+			Hard:       strings.HasPrefix(status, "5"),
+			Status:     status,
+			Diagnostic: fieldValue(h.Get("Diagnostic-Code")),
+		})
+	}
+	return bounces, nil
+}
+
+// parseDeliveryStatus разбирает тело message/delivery-status: блок полей сообщения
+// и блоки полей получателей, разделенные пустыми строками. Возвращает блоки получателей.
+func parseDeliveryStatus(r io.Reader) ([]textproto.MIMEHeader, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+	var blocks []textproto.MIMEHeader
+	for {
+		h, err := tp.ReadMIMEHeader()
+		if len(h) > 0 {
+			blocks = append(blocks, h)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading delivery status: %w", err)
+		}
+	}
+	if len(blocks) < 2 {
+		return nil, fmt.Errorf("%w: no per-recipient fields", ErrNotDSN)
+	}
+	return blocks[1:], nil
+}
+
+// fieldValue возвращает значение поля вида "<type>; <value>", например "rfc822; user@example.com",
+// без типа. Адрес в угловых скобках возвращается без них.
+func fieldValue(v string) string {
+	if _, value, ok := strings.Cut(v, ";"); ok {
+		v = value
+	}
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "<") && strings.HasSuffix(v, ">") {
+		v = v[1 : len(v)-1]
+	}
+	return v
+}
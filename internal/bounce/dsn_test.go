@@ -0,0 +1,85 @@
+package bounce
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDSN отчет о доставке в формате Postfix: один адрес не существует, второй письмо еще ждет.
+var testDSN = strings.ReplaceAll(`From: MAILER-DAEMON@mx.example.com
+To: notify@example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="B1"
+
+--B1
+Content-Type: text/plain
+
+This is the mail system at host mx.example.com.
+
+--B1
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.com
+Arrival-Date: Tue, 11 Nov 2025 10:00:00 +0000
+
+Final-Recipient: rfc822; User@Example.com
+Original-Recipient: rfc822;User@Example.com
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 <User@Example.com>: Recipient address rejected
+
+Final-Recipient: rfc822; slow@example.org
+Action: delayed
+Status: 4.4.1
+
+--B1
+Content-Type: text/rfc822-headers
+
+From: notify@example.com
+To: User@Example.com
+Subject: Hello
+Message-ID: <abc123@example.com>
+
+--B1--
+`, "\n", "\r\n")
+
+func TestParseDSN(t *testing.T) {
+	bounces, err := ParseDSN(strings.NewReader(testDSN))
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []models.Bounce{{
+		Recipient:  "User@Example.com",
+		MessageID:  "<abc123@example.com>",
+		Hard:       true,
+		Status:     "5.1.1",
+		Diagnostic: "550 5.1.1 <User@Example.com>: Recipient address rejected",
+	}}, bounces)
+}
+
+func TestParseDSNSoftBounce(t *testing.T) {
+	dsn := strings.Replace(strings.Replace(testDSN, "Status: 5.1.1", "Status: 4.2.2", 1), "Action: delayed", "Action: failed", 1)
+
+	bounces, err := ParseDSN(strings.NewReader(dsn))
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Len(t, bounces, 2) {
+		return
+	}
+	assert.False(t, bounces[0].Hard)
+	assert.Equal(t, "slow@example.org", bounces[1].Recipient)
+	assert.False(t, bounces[1].Hard)
+}
+
+func TestParseDSNNotReport(t *testing.T) {
+	_, err := ParseDSN(strings.NewReader("From: user@example.com\r\nSubject: Out of office\r\nContent-Type: text/plain\r\n\r\nI am away.\r\n"))
+
+	assert.ErrorIs(t, err, ErrNotDSN)
+}
@@ -0,0 +1,199 @@
+package bounce
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+const (
+	// DefaultMaxMessageSize ограничение размера принимаемого письма, если MaxMessageSize не задан.
+	DefaultMaxMessageSize = 10 << 20
+	// maxRecipients сколько получателей принимается в одном SMTP-сеансе.
+	maxRecipients = 100
+	// commandTimeout сколько сервер ждет следующей команды или данных письма.
+	commandTimeout = 5 * time.Minute
+)
+
+// Handler обрабатывает сообщения о недоставке из принятого письма. Ошибка отклоняется
+// временным кодом 451, и отправитель повторит доставку отчета позже.
+type Handler func(ctx context.Context, bounces []models.Bounce) error
+
+// Server минимальный SMTP-сервер, принимающий письма на адрес возврата (Return-Path)
+// рассылаемых уведомлений. Письма, которые не являются отчетами о доставке
+// (например, автоответы), принимаются и отбрасываются, чтобы не порождать новых отчетов.
+// Сервер не проверяет отправителя, поэтому его не следует открывать в интернет напрямую:
+// отчеты должен пересылать MTA, принимающий почту домена.
+type Server struct {
+	Addr    string
+	Handler Handler
+	// Hostname имя сервера в приветствии; по умолчанию "localhost"
+	Hostname string
+	// MaxMessageSize ограничение размера письма в байтах; по умолчанию DefaultMaxMessageSize
+	MaxMessageSize int64
+}
+
+// ListenAndServe слушает Addr и обслуживает соединения, пока не отменен ctx.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve обслуживает соединения l, пока не отменен ctx. После отмены закрывает l и возвращает nil.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { l.Close() })
+	defer stop()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(ctx, conn)
+	}
+}
+
+// session состояние SMTP-сеанса между командами.
+type session struct {
+	from       bool
+	recipients int
+}
+
+// serveConn ведет SMTP-сеанс одного соединения.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	tc := textproto.NewConn(conn)
+	hostname := s.Hostname
+	if hostname == "" {
+		hostname = "localhost"
+	}
+
+	var st session
+	reply(tc, 220, hostname+" ESMTP bounce receiver")
+	for {
+		conn.SetDeadline(time.Now().Add(commandTimeout))
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply(tc, 250, hostname, "8BITMIME", fmt.Sprintf("SIZE %d", s.maxMessageSize()))
+		case "HELO":
+			reply(tc, 250, hostname)
+		case "MAIL":
+			// отчеты о доставке приходят с пустым отправителем MAIL FROM:<>
+			if !strings.HasPrefix(strings.ToUpper(arg), "FROM:") {
+				reply(tc, 501, "Syntax: MAIL FROM:<address>")
+				continue
+			}
+			st = session{from: true}
+			reply(tc, 250, "OK")
+		case "RCPT":
+			switch {
+			case !st.from:
+				reply(tc, 503, "Need MAIL command")
+			case !strings.HasPrefix(strings.ToUpper(arg), "TO:"):
+				reply(tc, 501, "Syntax: RCPT TO:<address>")
+			case st.recipients >= maxRecipients:
+				reply(tc, 452, "Too many recipients")
+			default:
+				st.recipients++
+				reply(tc, 250, "OK")
+			}
+		case "DATA":
+			if st.recipients == 0 {
+				reply(tc, 503, "Need RCPT command")
+				continue
+			}
+			reply(tc, 354, "End data with <CR><LF>.<CR><LF>")
+			conn.SetDeadline(time.Now().Add(commandTimeout))
+			code, text := s.receive(ctx, tc)
+			if code == 0 {
+				return
+			}
+			st = session{}
+			reply(tc, code, text)
+		case "RSET":
+			st = session{}
+			reply(tc, 250, "OK")
+		case "NOOP":
+			reply(tc, 250, "OK")
+		case "VRFY":
+			reply(tc, 252, "Cannot verify user")
+		case "QUIT":
+			reply(tc, 221, "Bye")
+			return
+		default:
+			reply(tc, 502, "Command not implemented")
+		}
+	}
+}
+
+// receive читает данные письма после DATA и передает отчет о доставке Handler.
+// Возвращает код и текст ответа; код 0 — соединение оборвалось.
+func (s *Server) receive(ctx context.Context, tc *textproto.Conn) (int, string) {
+	limit := s.maxMessageSize()
+	dr := tc.DotReader()
+	data, err := io.ReadAll(io.LimitReader(dr, limit+1))
+	if err != nil {
+		return 0, ""
+	}
+	if int64(len(data)) > limit {
+		if _, err := io.Copy(io.Discard, dr); err != nil {
+			return 0, ""
+		}
+		return 552, "Message exceeds fixed maximum message size"
+	}
+
+	bounces, err := ParseDSN(bytes.NewReader(data))
+	if errors.Is(err, ErrNotDSN) {
+		log.Printf("bounce server: message is not a delivery status notification, dropped")
+		return 250, "OK"
+	}
+	if err != nil {
+		log.Printf("bounce server: %v", err)
+		return 550, "Malformed delivery status notification"
+	}
+	if err := s.Handler(ctx, bounces); err != nil {
+		log.Printf("bounce server: failed to handle bounces: %v", err)
+		return 451, "Temporary failure, try again later"
+	}
+	return 250, "OK"
+}
+
+// maxMessageSize возвращает ограничение размера письма.
+func (s *Server) maxMessageSize() int64 {
+	if s.MaxMessageSize > 0 {
+		return s.MaxMessageSize
+	}
+	return DefaultMaxMessageSize
+}
+
+// reply отправляет ответ с кодом code; несколько строк отправляются многострочным ответом.
+func reply(tc *textproto.Conn, code int, lines ...string) {
+	for i, line := range lines {
+		sep := " "
+		if i < len(lines)-1 {
+			sep = "-"
+		}
+		if err := tc.PrintfLine("%d%s%s", code, sep, line); err != nil {
+			return
+		}
+	}
+}
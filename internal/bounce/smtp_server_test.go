@@ -0,0 +1,94 @@
+package bounce
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestServer запускает сервер на свободном порту и возвращает его адрес.
+func startTestServer(t *testing.T, handler Handler) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := &Server{Handler: handler, Hostname: "bounces.example.com", MaxMessageSize: 4096}
+	go srv.Serve(ctx, l)
+	return l.Addr().String()
+}
+
+// sendDSN отправляет письмо серверу с пустым отправителем, как MTA отправляет отчеты о доставке.
+func sendDSN(addr, msg string) error {
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Mail(""); err != nil {
+		return err
+	}
+	if err := c.Rcpt("bounces@example.com"); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func TestServerReceivesDSN(t *testing.T) {
+	received := make(chan []models.Bounce, 1)
+	addr := startTestServer(t, func(ctx context.Context, bounces []models.Bounce) error {
+		received <- bounces
+		return nil
+	})
+
+	err := sendDSN(addr, testDSN)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	bounces := <-received
+	if assert.Len(t, bounces, 1) {
+		assert.Equal(t, "User@Example.com", bounces[0].Recipient)
+		assert.Equal(t, "<abc123@example.com>", bounces[0].MessageID)
+	}
+
+	// письмо, которое не является отчетом, принимается без вызова обработчика
+	assert.NoError(t, sendDSN(addr, "Subject: Out of office\r\n\r\nI am away.\r\n"))
+	assert.Empty(t, received)
+}
+
+func TestServerRejects(t *testing.T) {
+	addr := startTestServer(t, func(ctx context.Context, bounces []models.Bounce) error {
+		return errors.New("db is down")
+	})
+
+	// ошибка обработчика — временный отказ, MTA повторит доставку
+	err := sendDSN(addr, testDSN)
+	var tpErr *textproto.Error
+	if assert.ErrorAs(t, err, &tpErr) {
+		assert.Equal(t, 451, tpErr.Code)
+	}
+
+	big := "Subject: big\r\n\r\n" + string(make([]byte, 5000))
+	err = sendDSN(addr, big)
+	if assert.ErrorAs(t, err, &tpErr) {
+		assert.Equal(t, 552, tpErr.Code)
+	}
+}
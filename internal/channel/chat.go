@@ -0,0 +1,61 @@
+package channel
+
+import (
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+)
+
+// Slack создает канал входящих вебхуков Slack. s может быть nil, если процесс не отправляет уведомления.
+func Slack(s *sender.ChatSender) *Channel {
+	return chatChannel(models.NotificationTypeSlack, sender.SlackMaxMessageLength, s)
+}
+
+// Discord создает канал входящих вебхуков Discord. s может быть nil, если процесс не отправляет уведомления.
+func Discord(s *sender.ChatSender) *Channel {
+	return chatChannel(models.NotificationTypeDiscord, sender.DiscordMaxMessageLength, s)
+}
+
+// Mattermost создает канал входящих вебхуков Mattermost. s может быть nil, если процесс не отправляет уведомления.
+func Mattermost(s *sender.ChatSender) *Channel {
+	return chatChannel(models.NotificationTypeMattermost, sender.MattermostMaxMessageLength, s)
+}
+
+// chatChannel общее описание каналов входящих вебхуков чатов.
+// Данные уведомления хранятся в notification_payloads.
+func chatChannel(t models.NotificationType, maxLength int, s *sender.ChatSender) *Channel {
+	ch := &Channel{
+		Type:     t,
+		Validate: validation.ChatValidator(maxLength),
+		Build: func(req *models.CreateNotificationRequest, n *models.Notification) {
+			c := &models.ChatNotification{Subject: req.Subject, Message: req.Message}
+			if req.Chat != nil {
+				c.ChatOptions = *req.Chat
+			}
+			n.Payload = c
+		},
+		SetContent: func(n *models.Notification, c models.TemplateContent) {
+			if chat := models.PayloadOf[models.ChatNotification](n); chat != nil {
+				chat.Subject, chat.Message = c.Subject, c.Message
+			}
+		},
+		Describe: func(n *models.Notification, resp *models.NotificationResponse) {
+			c := models.PayloadOf[models.ChatNotification](n)
+			if c == nil {
+				return
+			}
+			resp.Recipient = c.Webhook
+			resp.Subject = c.Subject
+			resp.Message = c.Message
+		},
+		Storage: repository.PayloadStorage{
+			New:          func() any { return &models.ChatNotification{} },
+			RecipientKey: "webhook",
+		},
+	}
+	if s != nil {
+		ch.Sender, ch.Renderer = s, s
+	}
+	return ch
+}
@@ -0,0 +1,348 @@
+package channel
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/pushtoken"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/unsubscribe"
+)
+
+// Config настройки встроенных каналов доставки.
+type Config struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+	// SMTPTLSMode режим шифрования: tls, starttls, opportunistic или none
+	SMTPTLSMode string
+	// SMTPCAFile PEM-файл с корневыми сертификатами для проверки SMTP-сервера
+	SMTPCAFile string
+	// SMTPInsecureSkipVerify отключает проверку сертификата; только для тестовых стендов
+	SMTPInsecureSkipVerify bool
+	// DKIMDomain, DKIMSelector и DKIMKeyFile настройки подписи DKIM; без ключа письма не подписываются
+	DKIMDomain   string
+	DKIMSelector string
+	DKIMKeyFile  string
+	// SMTPPoolSize, SMTPIdleTimeout и SMTPMaxMessages настройки пула SMTP-соединений
+	SMTPPoolSize    int
+	SMTPIdleTimeout time.Duration
+	SMTPMaxMessages int
+	// Attachments хранилище вложений писем и файлов Telegram; задается процессом, который отправляет уведомления
+	Attachments sender.AttachmentStore
+
+	TelegramToken string
+	// TelegramAPIURL адрес Bot API: облачный или локальный telegram-bot-api
+	TelegramAPIURL string
+	// TelegramTimeout ограничивает запрос к Bot API
+	TelegramTimeout time.Duration
+	// TelegramProxy прокси для запросов к Bot API (http, https или socks5 URL)
+	TelegramProxy string
+	// TelegramBotUsername имя бота без @ для ссылок https://t.me/<bot>?start=<token>
+	TelegramBotUsername string
+	// TelegramUpdates способ получения обновлений бота: polling (воркер), webhook (сервер) или пусто — не получать
+	TelegramUpdates string
+	// TelegramWebhookURL публичный адрес /v1/telegram/webhook, который сервер регистрирует в Bot API
+	TelegramWebhookURL string
+	// TelegramWebhookSecret секрет, которым Bot API подписывает запросы вебхука
+	TelegramWebhookSecret string
+
+	// UnsubscribeSecret ключ подписи ссылок отписки; UnsubscribeBaseURL публичный адрес API для них.
+	// Без них письма отправляются без заголовка List-Unsubscribe
+	UnsubscribeSecret  string
+	UnsubscribeBaseURL string
+
+	// WebhookSecret общий ключ подписи вебхуков
+	WebhookSecret string
+
+	// базовые URL входящих вебхуков чатов; можно указать локальную заглушку
+	SlackURL      string
+	DiscordURL    string
+	MattermostURL string
+
+	// SMSProvider провайдер SMS: http (HTTP-шлюз) или smpp
+	SMSProvider     string
+	SMSFrom         string
+	SMSGatewayURL   string
+	SMSGatewayToken string
+	SMPPAddr        string
+	SMPPSystemID    string
+	SMPPPassword    string
+
+	// VAPIDPrivateKey закрытый ключ P-256 сервера приложений Web Push (base64url)
+	VAPIDPrivateKey string
+	// VAPIDSubject контакт для push-сервисов (mailto: или https: URL)
+	VAPIDSubject string
+	// PushTokenSecret ключ подписи токенов подписчика Web Push; без него подписки не принимаются
+	PushTokenSecret string
+	// PushSubscriptions хранилище подписок Web Push; задается процессом, который отправляет уведомления
+	PushSubscriptions sender.PushSubscriptionStore
+
+	// разобранные Validate файлы, ключи и адреса; nil, если не заданы
+	rootCAs       *x509.CertPool
+	dkim          *sender.DKIMSigner
+	vapid         *sender.VAPIDKeys
+	telegramProxy *url.URL
+}
+
+// ConfigFromEnv читает настройки каналов из переменных окружения и проверяет их (см. Validate),
+// чтобы непригодные настройки останавливали процесс при запуске, а не при первой отправке.
+func ConfigFromEnv() (Config, error) {
+	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	cfg := Config{
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     port,
+		SMTPUser:     os.Getenv("SMTP_USER"),
+		SMTPPassword: os.Getenv("SMTP_PASS"),
+		SMTPFrom:     os.Getenv("SMTP_FROM"),
+
+		SMTPTLSMode:            envOr("SMTP_TLS_MODE", string(sender.SMTPTLSOpportunistic)),
+		SMTPCAFile:             os.Getenv("SMTP_CA_FILE"),
+		SMTPInsecureSkipVerify: os.Getenv("SMTP_INSECURE_SKIP_VERIFY") == "true",
+		DKIMDomain:             os.Getenv("DKIM_DOMAIN"),
+		DKIMSelector:           os.Getenv("DKIM_SELECTOR"),
+		DKIMKeyFile:            os.Getenv("DKIM_PRIVATE_KEY_FILE"),
+
+		SMTPPoolSize:    envInt("SMTP_POOL_SIZE", 4),
+		SMTPIdleTimeout: envDuration("SMTP_IDLE_TIMEOUT", 30*time.Second),
+		SMTPMaxMessages: envInt("SMTP_MAX_MESSAGES_PER_CONN", 100),
+
+		TelegramToken:   os.Getenv("TELEGRAM_TOKEN"),
+		TelegramAPIURL:  envOr("TELEGRAM_API_URL", sender.TelegramDefaultBaseURL),
+		TelegramTimeout: envDuration("TELEGRAM_TIMEOUT", sender.TelegramDefaultTimeout),
+		TelegramProxy:   os.Getenv("TELEGRAM_PROXY"),
+
+		TelegramBotUsername:   strings.TrimPrefix(os.Getenv("TELEGRAM_BOT_USERNAME"), "@"),
+		TelegramUpdates:       os.Getenv("TELEGRAM_UPDATES"),
+		TelegramWebhookURL:    os.Getenv("TELEGRAM_WEBHOOK_URL"),
+		TelegramWebhookSecret: os.Getenv("TELEGRAM_WEBHOOK_SECRET"),
+
+		UnsubscribeSecret:  os.Getenv("UNSUBSCRIBE_SECRET"),
+		UnsubscribeBaseURL: os.Getenv("UNSUBSCRIBE_BASE_URL"),
+
+		WebhookSecret: os.Getenv("WEBHOOK_SECRET"),
+		SlackURL:      envOr("SLACK_WEBHOOK_URL", "https://hooks.slack.com"),
+		DiscordURL:    envOr("DISCORD_WEBHOOK_URL", "https://discord.com"),
+		MattermostURL: os.Getenv("MATTERMOST_URL"),
+
+		SMSProvider:     envOr("SMS_PROVIDER", "http"),
+		SMSFrom:         os.Getenv("SMS_FROM"),
+		SMSGatewayURL:   os.Getenv("SMS_GATEWAY_URL"),
+		SMSGatewayToken: os.Getenv("SMS_GATEWAY_TOKEN"),
+		SMPPAddr:        os.Getenv("SMPP_ADDR"),
+		SMPPSystemID:    os.Getenv("SMPP_SYSTEM_ID"),
+		SMPPPassword:    os.Getenv("SMPP_PASSWORD"),
+
+		VAPIDPrivateKey: os.Getenv("VAPID_PRIVATE_KEY"),
+		VAPIDSubject:    envOr("VAPID_SUBJECT", "mailto:admin@example.com"),
+		PushTokenSecret: os.Getenv("PUSH_TOKEN_SECRET"),
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid channel config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate проверяет режим TLS и разбирает заданные ключи, файлы и адрес прокси каналов, сохраняя
+// результат для NewDefaultRegistry. Незаданные не проверяются: без них канал работает в урезанном
+// режиме (например, письма без подписи DKIM). Config, собранный не через ConfigFromEnv, нужно
+// проверить до создания реестра, иначе ключи и файлы из него не используются.
+func (cfg *Config) Validate() error {
+	var errs []error
+	switch sender.SMTPTLSMode(cfg.SMTPTLSMode) {
+	case "", sender.SMTPTLSImplicit, sender.SMTPTLSStartTLS, sender.SMTPTLSOpportunistic, sender.SMTPTLSNone:
+	default:
+		errs = append(errs, fmt.Errorf("SMTP_TLS_MODE: unsupported smtp tls mode %q", cfg.SMTPTLSMode))
+	}
+	var err error
+	if cfg.rootCAs, err = cfg.loadSMTPRootCAs(); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.dkim, err = cfg.loadDKIMSigner(); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.vapid, err = cfg.parseVAPIDKeys(); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.telegramProxy, err = cfg.parseTelegramProxy(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// envOr возвращает значение переменной окружения или def, если она не задана.
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt возвращает целое значение переменной окружения или def, если она не задана или некорректна.
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return v
+	}
+	return def
+}
+
+// envDuration возвращает длительность из переменной окружения (например, 30s) или def.
+func envDuration(name string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(name)); err == nil {
+		return v
+	}
+	return def
+}
+
+// NewDefaultRegistry создает реестр со всеми встроенными каналами.
+// Новый канал добавляется сюда одной строкой регистрации.
+func NewDefaultRegistry(cfg Config) *Registry {
+	return NewRegistry(
+		Email(sender.NewEmailSender(sender.SMTPConfig{
+			Host:               cfg.SMTPHost,
+			Port:               cfg.SMTPPort,
+			Username:           cfg.SMTPUser,
+			Password:           cfg.SMTPPassword,
+			From:               cfg.SMTPFrom,
+			TLSMode:            sender.SMTPTLSMode(cfg.SMTPTLSMode),
+			RootCAs:            cfg.rootCAs,
+			InsecureSkipVerify: cfg.SMTPInsecureSkipVerify,
+			DKIM:               cfg.dkim,
+			PoolSize:           cfg.SMTPPoolSize,
+			IdleTimeout:        cfg.SMTPIdleTimeout,
+			MaxMessagesPerConn: cfg.SMTPMaxMessages,
+			Unsubscribe:        cfg.unsubscribeLinker(),
+		}, cfg.Attachments)),
+		Telegram(sender.NewTelegramSender(cfg.TelegramToken, cfg.TelegramAPIURL, cfg.telegramClient(cfg.TelegramTimeout), cfg.Attachments)),
+		Webhook(sender.NewWebhookSender(cfg.WebhookSecret)),
+		Slack(sender.NewSlackSender(cfg.SlackURL)),
+		Discord(sender.NewDiscordSender(cfg.DiscordURL)),
+		Mattermost(sender.NewMattermostSender(cfg.MattermostURL)),
+		SMS(sender.NewSMSSender(smsProvider(cfg), cfg.SMSFrom)),
+		WebPush(sender.NewWebPushSender(cfg.PushSubscriptions, cfg.vapid, cfg.VAPIDSubject)),
+	)
+}
+
+// VAPIDPublicKey возвращает открытый ключ VAPID для подписки браузера или пустую строку, если ключ не задан.
+func (cfg Config) VAPIDPublicKey() string {
+	if cfg.vapid != nil {
+		return cfg.vapid.PublicKey
+	}
+	return ""
+}
+
+// parseVAPIDKeys разбирает ключ VAPID; без ключа возвращает nil, и запросы к push-сервисам не подписываются.
+func (cfg Config) parseVAPIDKeys() (*sender.VAPIDKeys, error) {
+	if cfg.VAPIDPrivateKey == "" {
+		return nil, nil
+	}
+	keys, err := sender.ParseVAPIDKeys(cfg.VAPIDPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID_PRIVATE_KEY: %w", err)
+	}
+	return keys, nil
+}
+
+// PushTokenSigner создает подписчика токенов Web Push. Без ключа возвращает nil.
+func (cfg Config) PushTokenSigner() *pushtoken.Signer {
+	if cfg.PushTokenSecret == "" {
+		return nil
+	}
+	return pushtoken.NewSigner(cfg.PushTokenSecret)
+}
+
+// UnsubscribeSigner создает подписчика ссылок отписки. Без ключа или адреса API возвращает nil.
+func (cfg Config) UnsubscribeSigner() *unsubscribe.Signer {
+	if cfg.UnsubscribeSecret == "" || cfg.UnsubscribeBaseURL == "" {
+		return nil
+	}
+	return unsubscribe.NewSigner(cfg.UnsubscribeSecret, cfg.UnsubscribeBaseURL)
+}
+
+// unsubscribeLinker возвращает UnsubscribeSigner как sender.UnsubscribeLinker; nil, если отписка не настроена.
+func (cfg Config) unsubscribeLinker() sender.UnsubscribeLinker {
+	if signer := cfg.UnsubscribeSigner(); signer != nil {
+		return signer
+	}
+	return nil
+}
+
+// TelegramBotAPI создает клиент Bot API для обработки обновлений бота. Таймаут запроса
+// увеличен на время ожидания long polling getUpdates. Без токена возвращает nil.
+func (cfg Config) TelegramBotAPI() *sender.TelegramSender {
+	if cfg.TelegramToken == "" {
+		return nil
+	}
+	return sender.NewTelegramSender(cfg.TelegramToken, cfg.TelegramAPIURL, cfg.telegramClient(cfg.TelegramTimeout+sender.TelegramPollTimeout), nil)
+}
+
+// telegramClient создает HTTP-клиент Bot API с таймаутом timeout и прокси из настроек.
+func (cfg Config) telegramClient(timeout time.Duration) *http.Client {
+	return sender.NewTelegramHTTPClient(timeout, cfg.telegramProxy)
+}
+
+// parseTelegramProxy разбирает адрес прокси Bot API; без прокси возвращает nil.
+func (cfg Config) parseTelegramProxy() (*url.URL, error) {
+	if cfg.TelegramProxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.TelegramProxy)
+	if err != nil {
+		return nil, fmt.Errorf("TELEGRAM_PROXY: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("TELEGRAM_PROXY: unsupported proxy scheme %q", u.Scheme)
+	}
+	return u, nil
+}
+
+// loadSMTPRootCAs читает файл корневых сертификатов; без файла возвращает nil, и используются системные.
+func (cfg Config) loadSMTPRootCAs() (*x509.CertPool, error) {
+	if cfg.SMTPCAFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(cfg.SMTPCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("SMTP_CA_FILE: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("SMTP_CA_FILE: no certificates found in %s", cfg.SMTPCAFile)
+	}
+	return pool, nil
+}
+
+// loadDKIMSigner загружает ключ DKIM; без ключа возвращает nil, и письма отправляются без подписи.
+func (cfg Config) loadDKIMSigner() (*sender.DKIMSigner, error) {
+	if cfg.DKIMKeyFile == "" {
+		return nil, nil
+	}
+	key, err := os.ReadFile(cfg.DKIMKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("DKIM_PRIVATE_KEY_FILE: %w", err)
+	}
+	signer, err := sender.NewDKIMSigner(cfg.DKIMDomain, cfg.DKIMSelector, key)
+	if err != nil {
+		return nil, fmt.Errorf("DKIM_PRIVATE_KEY_FILE: %w", err)
+	}
+	return signer, nil
+}
+
+// smsProvider выбирает провайдера SMS по настройкам.
+func smsProvider(cfg Config) sender.SMSProvider {
+	if cfg.SMSProvider == "smpp" {
+		return sender.NewSMPPClient(cfg.SMPPAddr, cfg.SMPPSystemID, cfg.SMPPPassword)
+	}
+	return sender.NewHTTPSMSGateway(cfg.SMSGatewayURL, cfg.SMSGatewayToken)
+}
@@ -0,0 +1,48 @@
+package channel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	_, private, err := sender.GenerateVAPIDKeys()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// незаданные ключи не проверяются
+	assert.NoError(t, validate(Config{}))
+
+	// разобранный ключ сохраняется в настройках
+	cfg := Config{VAPIDPrivateKey: private}
+	assert.NoError(t, cfg.Validate())
+	assert.NotEmpty(t, cfg.VAPIDPublicKey())
+
+	// заданный, но неразборчивый ключ — ошибка конфигурации
+	assert.ErrorContains(t, validate(Config{VAPIDPrivateKey: "not-a-key"}), "VAPID_PRIVATE_KEY")
+
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.pem")
+	if !assert.NoError(t, os.WriteFile(garbage, []byte("not a pem"), 0o600)) {
+		return
+	}
+	missing := filepath.Join(dir, "missing.pem")
+	assert.ErrorContains(t, validate(Config{SMTPTLSMode: "ssl"}), "SMTP_TLS_MODE")
+	assert.ErrorContains(t, validate(Config{SMTPCAFile: missing}), "SMTP_CA_FILE")
+	assert.ErrorContains(t, validate(Config{SMTPCAFile: garbage}), "SMTP_CA_FILE")
+	assert.ErrorContains(t, validate(Config{DKIMDomain: "example.com", DKIMSelector: "s1", DKIMKeyFile: missing}), "DKIM_PRIVATE_KEY_FILE")
+	assert.ErrorContains(t, validate(Config{DKIMDomain: "example.com", DKIMSelector: "s1", DKIMKeyFile: garbage}), "DKIM_PRIVATE_KEY_FILE")
+
+	assert.NoError(t, validate(Config{TelegramProxy: "socks5://127.0.0.1:1080"}))
+	assert.ErrorContains(t, validate(Config{TelegramProxy: "127.0.0.1:1080"}), "TELEGRAM_PROXY")
+	assert.ErrorContains(t, validate(Config{TelegramProxy: "http://[::1"}), "TELEGRAM_PROXY")
+}
+
+func validate(cfg Config) error {
+	return cfg.Validate()
+}
@@ -0,0 +1,84 @@
+package channel
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+)
+
+// Email создает канал email-уведомлений. s может быть nil, если процесс не отправляет письма.
+func Email(s *sender.EmailSender) *Channel {
+	ch := &Channel{
+		Type:           models.NotificationTypeEmail,
+		RecipientParam: "email",
+		Validate:       validation.ValidateEmail,
+		Build: func(req *models.CreateNotificationRequest, n *models.Notification) {
+			message := req.Message
+			if message == "" && req.HTML != "" {
+				// текстовая альтернатива HTML-письма
+				message = sender.HTMLToText(req.HTML)
+			}
+			n.Payload = &models.EmailNotification{
+				Email:       req.Email,
+				Message:     message,
+				Subject:     req.Subject,
+				HTML:        req.HTML,
+				Attachments: attachments(req.Attachments),
+			}
+		},
+		SetContent: func(n *models.Notification, c models.TemplateContent) {
+			email := models.PayloadOf[models.EmailNotification](n)
+			if email == nil {
+				return
+			}
+			message := c.Message
+			if message == "" && c.HTML != "" {
+				message = sender.HTMLToText(c.HTML)
+			}
+			email.Subject, email.Message, email.HTML = c.Subject, message, c.HTML
+		},
+		Describe: func(n *models.Notification, resp *models.NotificationResponse) {
+			email := models.PayloadOf[models.EmailNotification](n)
+			if email == nil {
+				return
+			}
+			resp.Recipient = email.Email
+			resp.Email = email.Email
+			resp.Message = email.Message
+			resp.Subject = email.Subject
+			resp.HTML = email.HTML
+			for _, a := range email.Attachments {
+				a.Content = nil
+				resp.Attachments = append(resp.Attachments, a)
+			}
+		},
+		Storage: repository.EmailStorage{},
+	}
+	if s != nil {
+		ch.Sender, ch.Renderer = s, s
+	}
+	return ch
+}
+
+// attachments копирует вложения запроса, заполняя размер и тип содержимого,
+// если он не указан (по расширению файла или по первым байтам).
+func attachments(in []models.Attachment) []models.Attachment {
+	var out []models.Attachment
+	for _, a := range in {
+		a.ID = ""
+		a.Size = len(a.Content)
+		if a.ContentType == "" {
+			a.ContentType = mime.TypeByExtension(filepath.Ext(a.Filename))
+		}
+		if a.ContentType == "" {
+			a.ContentType = http.DetectContentType(a.Content)
+		}
+		out = append(out, a)
+	}
+	return out
+}
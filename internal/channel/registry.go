@@ -0,0 +1,224 @@
+package channel
+
+import (
+	"fmt"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+	"github.com/google/uuid"
+)
+
+// Channel описывает канал доставки уведомлений. Все, что зависит от типа уведомления,
+// собрано здесь, поэтому новый канал добавляется регистрацией, без изменений в хендлерах,
+// сервисе, репозитории и воркере.
+type Channel struct {
+	// Type тип уведомлений, которые обслуживает канал
+	Type models.NotificationType
+	// RecipientParam имя поля запроса с получателем; используется как query-параметр фильтра
+	RecipientParam string
+	// Validate проверяет поля запроса, относящиеся к каналу
+	Validate validation.ChannelValidator
+	// Build заполняет данные канала в уведомлении из запроса
+	Build func(req *models.CreateNotificationRequest, n *models.Notification)
+	// SetContent подставляет в данные канала текст, подготовленный по шаблону
+	SetContent func(n *models.Notification, c models.TemplateContent)
+	// Describe заполняет получателя и текст в ответе API
+	Describe func(n *models.Notification, resp *models.NotificationResponse)
+	// Storage хранит данные канала в БД
+	Storage repository.ChannelStorage
+	// Renderer готовит сообщение для предпросмотра; nil — предпросмотр не поддерживается
+	Renderer sender.Renderer
+	// Sender доставляет сообщение; nil — процесс не отправляет уведомления этого канала
+	Sender sender.Sender
+}
+
+// Registry реестр каналов доставки.
+type Registry struct {
+	channels map[models.NotificationType]*Channel
+	order    []models.NotificationType
+}
+
+// NewRegistry создает реестр и регистрирует в нем переданные каналы.
+func NewRegistry(channels ...*Channel) *Registry {
+	r := &Registry{channels: make(map[models.NotificationType]*Channel)}
+	for _, ch := range channels {
+		r.Register(ch)
+	}
+	return r
+}
+
+// Register добавляет канал в реестр. Повторная регистрация типа — ошибка конфигурации.
+func (r *Registry) Register(ch *Channel) {
+	if _, ok := r.channels[ch.Type]; ok {
+		panic(fmt.Sprintf("channel %q is already registered", ch.Type))
+	}
+	r.channels[ch.Type] = ch
+	r.order = append(r.order, ch.Type)
+}
+
+// Get возвращает канал по типу уведомления.
+func (r *Registry) Get(t models.NotificationType) (*Channel, bool) {
+	ch, ok := r.channels[t]
+	return ch, ok
+}
+
+// Channels возвращает каналы в порядке регистрации.
+func (r *Registry) Channels() []*Channel {
+	channels := make([]*Channel, 0, len(r.order))
+	for _, t := range r.order {
+		channels = append(channels, r.channels[t])
+	}
+	return channels
+}
+
+// Validate проверяет запрос на создание уведомления правилами его канала
+// (или правилами каждого канала, если заданы targets).
+func (r *Registry) Validate(req *models.CreateNotificationRequest) error {
+	if len(req.Targets) > 0 {
+		return validation.ValidateGroup(req, r.validator)
+	}
+	return validation.ValidateCreateRequest(req, r.validator(req.Type))
+}
+
+// validator возвращает валидатор канала или nil, если тип не зарегистрирован.
+func (r *Registry) validator(t models.NotificationType) validation.ChannelValidator {
+	var validate validation.ChannelValidator
+	if ch, ok := r.channels[t]; ok {
+		validate = ch.Validate
+	}
+	return validate
+}
+
+// ValidateTemplate проверяет шаблон сообщения: тексты задаются только для зарегистрированных каналов.
+func (r *Registry) ValidateTemplate(t *models.Template) error {
+	return validation.ValidateTemplate(t, r.validator)
+}
+
+// Build преобразует запрос в уведомление со статусом scheduled.
+// Используется при создании и для предпросмотра, поэтому уведомления совпадают.
+func (r *Registry) Build(req *models.CreateNotificationRequest) (*models.Notification, error) {
+	if len(req.Targets) > 0 {
+		return r.buildGroup(req)
+	}
+	ch, ok := r.channels[req.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", sender.ErrUnsupportedType, req.Type)
+	}
+	n := &models.Notification{
+		ID:          uuid.NewString(),
+		Type:        req.Type,
+		ScheduledAt: req.ScheduledAt,
+		Status:      models.StatusScheduled,
+		Retries:     0,
+		Category:    req.Category,
+		// текст уведомления по шаблону воркер готовит при отправке
+		TemplateID:      req.TemplateID,
+		TemplateVersion: req.TemplateVersion,
+		Variables:       req.Variables,
+	}
+	ch.Build(req, n)
+	return n, nil
+}
+
+// buildGroup собирает уведомление с несколькими каналами. В режиме fallback запускается
+// только первый канал, остальные ждут в статусе standby.
+func (r *Registry) buildGroup(req *models.CreateNotificationRequest) (*models.Notification, error) {
+	group := &models.NotificationGroup{Mode: req.Mode, FallbackAfter: req.FallbackAfter}
+	for i := range req.Targets {
+		target := req.Target(i)
+		if len(target.Targets) > 0 {
+			return nil, fmt.Errorf("%w: nested targets", sender.ErrUnsupportedType)
+		}
+		child, err := r.Build(&target)
+		if err != nil {
+			return nil, err
+		}
+		if req.Mode == models.DeliveryModeFallback && i > 0 {
+			child.Status = models.StatusStandby
+		}
+		group.Targets = append(group.Targets, child)
+	}
+	return &models.Notification{
+		ID:              uuid.NewString(),
+		Type:            models.NotificationTypeMulti,
+		ScheduledAt:     req.ScheduledAt,
+		Status:          models.StatusScheduled,
+		Category:        req.Category,
+		TemplateID:      req.TemplateID,
+		TemplateVersion: req.TemplateVersion,
+		Variables:       req.Variables,
+		Group:           group,
+	}, nil
+}
+
+// Describe преобразует уведомление в DTO ответа API.
+func (r *Registry) Describe(n *models.Notification) models.NotificationResponse {
+	resp := models.NotificationResponse{
+		ID:              n.ID,
+		Type:            n.Type,
+		ScheduledAt:     n.ScheduledAt,
+		Status:          n.Status,
+		Retries:         n.Retries,
+		Category:        n.Category,
+		TemplateID:      n.TemplateID,
+		TemplateVersion: n.TemplateVersion,
+		Variables:       n.Variables,
+		ParentID:        n.ParentID,
+		FailureClass:    n.FailureClass,
+		FailureReason:   n.FailureReason,
+	}
+	if ch, ok := r.channels[n.Type]; ok {
+		ch.Describe(n, &resp)
+	}
+	if n.Group != nil {
+		resp.Mode = n.Group.Mode
+		for _, target := range n.Group.Targets {
+			resp.Targets = append(resp.Targets, r.Describe(target))
+		}
+	}
+	return resp
+}
+
+// SetContent подставляет в уведомление текст, подготовленный по шаблону.
+func (r *Registry) SetContent(n *models.Notification, c models.TemplateContent) error {
+	ch, ok := r.channels[n.Type]
+	if !ok {
+		return fmt.Errorf("%w: %s", sender.ErrUnsupportedType, n.Type)
+	}
+	ch.SetContent(n, c)
+	return nil
+}
+
+// Render готовит уведомление к отправке рендерером его канала, ничего не отправляя.
+func (r *Registry) Render(n *models.Notification) (*models.RenderedMessage, error) {
+	ch, ok := r.channels[n.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", sender.ErrUnsupportedType, n.Type)
+	}
+	if ch.Renderer == nil {
+		return nil, fmt.Errorf("preview is not supported for notification type: %s", n.Type)
+	}
+	return ch.Renderer.Render(n)
+}
+
+// Storages возвращает хранилища данных зарегистрированных каналов для репозитория.
+func (r *Registry) Storages() map[models.NotificationType]repository.ChannelStorage {
+	storages := make(map[models.NotificationType]repository.ChannelStorage, len(r.channels))
+	for t, ch := range r.channels {
+		storages[t] = ch.Storage
+	}
+	return storages
+}
+
+// Sender возвращает отправителя, который выбирает канал по типу уведомления.
+func (r *Registry) Sender() *sender.MultiSender {
+	senders := make(map[models.NotificationType]sender.Sender, len(r.channels))
+	for t, ch := range r.channels {
+		if ch.Sender != nil {
+			senders[t] = ch.Sender
+		}
+	}
+	return sender.NewMultiSender(senders)
+}
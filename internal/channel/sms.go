@@ -0,0 +1,42 @@
+package channel
+
+import (
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+)
+
+// SMS создает канал SMS-уведомлений. Данные уведомления хранятся в notification_payloads.
+// s может быть nil, если процесс не отправляет сообщения.
+func SMS(s *sender.SMSSender) *Channel {
+	ch := &Channel{
+		Type:           models.NotificationTypeSMS,
+		RecipientParam: "phone",
+		Validate:       validation.ValidateSMS,
+		Build: func(req *models.CreateNotificationRequest, n *models.Notification) {
+			n.Payload = &models.SMSNotification{Phone: req.Phone, Message: req.Message}
+		},
+		SetContent: func(n *models.Notification, c models.TemplateContent) {
+			if sms := models.PayloadOf[models.SMSNotification](n); sms != nil {
+				sms.Message = c.Message
+			}
+		},
+		Describe: func(n *models.Notification, resp *models.NotificationResponse) {
+			sms := models.PayloadOf[models.SMSNotification](n)
+			if sms == nil {
+				return
+			}
+			resp.Recipient = sms.Phone
+			resp.Message = sms.Message
+		},
+		Storage: repository.PayloadStorage{
+			New:          func() any { return &models.SMSNotification{} },
+			RecipientKey: "phone",
+		},
+	}
+	if s != nil {
+		ch.Sender, ch.Renderer = s, s
+	}
+	return ch
+}
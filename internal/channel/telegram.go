@@ -0,0 +1,67 @@
+package channel
+
+import (
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+)
+
+// Telegram создает канал telegram-уведомлений. s может быть nil, если процесс не отправляет сообщения.
+func Telegram(s *sender.TelegramSender) *Channel {
+	ch := &Channel{
+		Type:           models.NotificationTypeTelegram,
+		RecipientParam: "chat_id",
+		Validate:       validation.ValidateTelegram,
+		Build: func(req *models.CreateNotificationRequest, n *models.Notification) {
+			tg := &models.TelegramNotification{
+				ChatID:  req.ChatID,
+				Message: req.Message,
+			}
+			if req.Telegram != nil {
+				tg.TelegramOptions = *req.Telegram
+				tg.Media = media(req.Telegram.Media)
+			}
+			n.Payload = tg
+		},
+		SetContent: func(n *models.Notification, c models.TemplateContent) {
+			if tg := models.PayloadOf[models.TelegramNotification](n); tg != nil {
+				tg.Message = c.Message
+			}
+		},
+		Describe: func(n *models.Notification, resp *models.NotificationResponse) {
+			tg := models.PayloadOf[models.TelegramNotification](n)
+			if tg == nil {
+				return
+			}
+			resp.Recipient = tg.ChatID
+			resp.ChatID = tg.ChatID
+			resp.Message = tg.Message
+			for _, m := range tg.Media {
+				if m.File != nil {
+					file := *m.File
+					file.Content = nil
+					resp.Attachments = append(resp.Attachments, file)
+				}
+			}
+		},
+		Storage: repository.TelegramStorage{},
+	}
+	if s != nil {
+		ch.Sender, ch.Renderer = s, s
+	}
+	return ch
+}
+
+// media копирует вложения сообщения Telegram, заполняя размер и тип загруженных файлов.
+func media(in []models.TelegramMedia) []models.TelegramMedia {
+	var out []models.TelegramMedia
+	for _, m := range in {
+		if m.File != nil {
+			file := attachments([]models.Attachment{*m.File})[0]
+			m.File = &file
+		}
+		out = append(out, m)
+	}
+	return out
+}
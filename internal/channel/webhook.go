@@ -0,0 +1,48 @@
+package channel
+
+import (
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+)
+
+// Webhook создает канал доставки HTTP-вебхуком. Данные уведомления хранятся в notification_payloads.
+// s может быть nil, если процесс не отправляет уведомления.
+func Webhook(s *sender.WebhookSender) *Channel {
+	ch := &Channel{
+		Type:           models.NotificationTypeWebhook,
+		RecipientParam: "url",
+		Validate:       validation.ValidateWebhook,
+		Build: func(req *models.CreateNotificationRequest, n *models.Notification) {
+			w := &models.WebhookNotification{Subject: req.Subject, Message: req.Message}
+			if req.Webhook != nil {
+				w.WebhookOptions = *req.Webhook
+			}
+			n.Payload = w
+		},
+		SetContent: func(n *models.Notification, c models.TemplateContent) {
+			if w := models.PayloadOf[models.WebhookNotification](n); w != nil {
+				w.Subject, w.Message = c.Subject, c.Message
+			}
+		},
+		Describe: func(n *models.Notification, resp *models.NotificationResponse) {
+			w := models.PayloadOf[models.WebhookNotification](n)
+			if w == nil {
+				return
+			}
+			// заголовки и ключ подписи могут содержать секреты, поэтому в ответ не попадают
+			resp.Recipient = w.URL
+			resp.Subject = w.Subject
+			resp.Message = w.Message
+		},
+		Storage: repository.PayloadStorage{
+			New:          func() any { return &models.WebhookNotification{} },
+			RecipientKey: "url",
+		},
+	}
+	if s != nil {
+		ch.Sender, ch.Renderer = s, s
+	}
+	return ch
+}
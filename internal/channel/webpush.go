@@ -0,0 +1,48 @@
+package channel
+
+import (
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+)
+
+// WebPush создает канал браузерных push-уведомлений. Получатель — подписчик, подписки которого
+// хранятся в push_subscriptions; данные уведомления хранятся в notification_payloads.
+// s может быть nil, если процесс не отправляет уведомления.
+func WebPush(s *sender.WebPushSender) *Channel {
+	ch := &Channel{
+		Type:           models.NotificationTypeWebPush,
+		RecipientParam: "subscriber",
+		Validate:       validation.ValidateWebPush,
+		Build: func(req *models.CreateNotificationRequest, n *models.Notification) {
+			p := &models.WebPushNotification{Subject: req.Subject, Message: req.Message}
+			if req.Push != nil {
+				p.WebPushOptions = *req.Push
+			}
+			n.Payload = p
+		},
+		SetContent: func(n *models.Notification, c models.TemplateContent) {
+			if p := models.PayloadOf[models.WebPushNotification](n); p != nil {
+				p.Subject, p.Message = c.Subject, c.Message
+			}
+		},
+		Describe: func(n *models.Notification, resp *models.NotificationResponse) {
+			p := models.PayloadOf[models.WebPushNotification](n)
+			if p == nil {
+				return
+			}
+			resp.Recipient = p.Subscriber
+			resp.Subject = p.Subject
+			resp.Message = p.Message
+		},
+		Storage: repository.PayloadStorage{
+			New:          func() any { return &models.WebPushNotification{} },
+			RecipientKey: "subscriber",
+		},
+	}
+	if s != nil {
+		ch.Sender, ch.Renderer = s, s
+	}
+	return ch
+}
@@ -0,0 +1,195 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// Format формат выгрузки.
+type Format string
+
+const (
+	// FormatCSV CSV с заголовком, история статусов в одной колонке
+	FormatCSV Format = "csv"
+	// FormatNDJSON по одному JSON-объекту на строку
+	FormatNDJSON Format = "ndjson"
+)
+
+// ErrUnknownFormat возвращается для неподдерживаемого формата выгрузки.
+var ErrUnknownFormat = errors.New("unknown export format, expected csv or ndjson")
+
+// csvHeader колонки CSV-выгрузки. Каналы уведомления с несколькими каналами выгружаются
+// отдельными строками сразу после него, с его id в parent_id.
+var csvHeader = []string{
+	"id", "parent_id", "type", "status", "recipient", "email", "chat_id", "subject", "message",
+	"scheduled_at", "created_at", "updated_at", "retries", "segments", "failure_class", "failure_reason", "history",
+}
+
+// ParseFormat определяет формат по явному значению или заголовку Accept. По умолчанию NDJSON.
+func ParseFormat(format, accept string) (Format, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	case "":
+	default:
+		return "", ErrUnknownFormat
+	}
+	if strings.Contains(accept, "text/csv") {
+		return FormatCSV, nil
+	}
+	return FormatNDJSON, nil
+}
+
+// ContentType возвращает MIME-тип формата.
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Writer записывает уведомления в выгрузку по одному.
+type Writer interface {
+	Write(n *models.Notification) error
+	Flush() error
+}
+
+// Describer заполняет поля получателя и текста уведомления в терминах его канала.
+type Describer func(n *models.Notification) models.NotificationResponse
+
+// NewWriter создает Writer для указанного формата.
+func NewWriter(w io.Writer, format Format, describe Describer) Writer {
+	if format == FormatCSV {
+		return &csvWriter{w: csv.NewWriter(w), describe: describe}
+	}
+	return &ndjsonWriter{enc: json.NewEncoder(w), describe: describe}
+}
+
+// record строка выгрузки.
+type record struct {
+	ID          string                  `json:"id"`
+	ParentID    string                  `json:"parent_id,omitempty"`
+	Type        models.NotificationType `json:"type"`
+	Status      models.Status           `json:"status"`
+	Recipient   string                  `json:"recipient,omitempty"`
+	Email       string                  `json:"email,omitempty"`
+	ChatID      string                  `json:"chat_id,omitempty"`
+	Subject     string                  `json:"subject,omitempty"`
+	Message     string                  `json:"message"`
+	ScheduledAt time.Time               `json:"scheduled_at"`
+	CreatedAt   time.Time               `json:"created_at"`
+	UpdatedAt   time.Time               `json:"updated_at"`
+	Retries     int                     `json:"retries"`
+	Segments    int                     `json:"segments,omitempty"`
+	// FailureClass и FailureReason заполняются, если доставка завершилась неудачей
+	FailureClass  string                `json:"failure_class,omitempty"`
+	FailureReason string                `json:"failure_reason,omitempty"`
+	History       []models.StatusChange `json:"history"`
+	// Targets попытки по каналам уведомления с несколькими каналами, каждая со своей историей
+	Targets []record `json:"targets,omitempty"`
+}
+
+func newRecord(n *models.Notification, describe Describer) record {
+	resp := describe(n)
+	rec := record{
+		ID:            n.ID,
+		ParentID:      n.ParentID,
+		Type:          n.Type,
+		Status:        n.Status,
+		Recipient:     resp.Recipient,
+		Email:         resp.Email,
+		ChatID:        resp.ChatID,
+		Subject:       resp.Subject,
+		Message:       resp.Message,
+		ScheduledAt:   n.ScheduledAt,
+		CreatedAt:     n.CreatedAt,
+		UpdatedAt:     n.UpdatedAt,
+		Retries:       n.Retries,
+		Segments:      n.Segments,
+		FailureClass:  n.FailureClass,
+		FailureReason: n.FailureReason,
+		History:       n.History,
+	}
+	if rec.History == nil {
+		rec.History = []models.StatusChange{}
+	}
+	if n.Group != nil {
+		for _, target := range n.Group.Targets {
+			rec.Targets = append(rec.Targets, newRecord(target, describe))
+		}
+	}
+	return rec
+}
+
+type ndjsonWriter struct {
+	enc      *json.Encoder
+	describe Describer
+}
+
+func (w *ndjsonWriter) Write(n *models.Notification) error {
+	return w.enc.Encode(newRecord(n, w.describe))
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return nil
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	describe      Describer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(n *models.Notification) error {
+	if !w.headerWritten {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	return w.writeRecord(newRecord(n, w.describe))
+}
+
+// writeRecord записывает строку уведомления, а за ней — строки его каналов.
+func (w *csvWriter) writeRecord(rec record) error {
+	// история в виде "status@время;status@время"
+	history := make([]string, 0, len(rec.History))
+	for _, h := range rec.History {
+		history = append(history, string(h.Status)+"@"+h.ChangedAt.UTC().Format(time.RFC3339))
+	}
+	err := w.w.Write([]string{
+		rec.ID, rec.ParentID, string(rec.Type), string(rec.Status), rec.Recipient, rec.Email, rec.ChatID, rec.Subject, rec.Message,
+		rec.ScheduledAt.UTC().Format(time.RFC3339), rec.CreatedAt.UTC().Format(time.RFC3339),
+		rec.UpdatedAt.UTC().Format(time.RFC3339), strconv.Itoa(rec.Retries),
+		strconv.Itoa(rec.Segments), rec.FailureClass, rec.FailureReason, strings.Join(history, ";"),
+	})
+	if err != nil {
+		return err
+	}
+	for _, target := range rec.Targets {
+		if err := w.writeRecord(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvWriter) Flush() error {
+	if !w.headerWritten {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fallbackGroup уведомление с доставкой по очереди: Telegram не сработал, письмо ушло.
+func fallbackGroup() *models.Notification {
+	at := time.Date(2025, 11, 1, 10, 0, 0, 0, time.UTC)
+	return &models.Notification{
+		ID:     "1",
+		Type:   models.NotificationTypeMulti,
+		Status: models.StatusSent,
+		Group: &models.NotificationGroup{
+			Mode: models.DeliveryModeFallback,
+			Targets: []*models.Notification{
+				{
+					ID:           "2",
+					ParentID:     "1",
+					Type:         models.NotificationTypeTelegram,
+					Status:       models.StatusFailed,
+					FailureClass: "permanent",
+					Payload:      &models.TelegramNotification{ChatID: "42", Message: "Привет"},
+					History: []models.StatusChange{
+						{Status: models.StatusScheduled, ChangedAt: at},
+						{Status: models.StatusFailed, ChangedAt: at.Add(time.Second)},
+					},
+				},
+				{
+					ID:       "3",
+					ParentID: "1",
+					Type:     models.NotificationTypeEmail,
+					Status:   models.StatusSent,
+					Payload:  &models.EmailNotification{Email: "test@example.com", Message: "Привет"},
+					History: []models.StatusChange{
+						{Status: models.StatusScheduled, ChangedAt: at},
+						{Status: models.StatusSent, ChangedAt: at.Add(2 * time.Second)},
+					},
+				},
+			},
+		},
+	}
+}
+
+var testChannels = channel.NewRegistry(channel.Email(nil), channel.Telegram(nil))
+
+func TestNDJSONWriterFallbackGroup(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, FormatNDJSON, testChannels.Describe)
+	assert.NoError(t, w.Write(fallbackGroup()))
+	assert.NoError(t, w.Flush())
+
+	var rec record
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal(t, "1", rec.ID)
+	if assert.Len(t, rec.Targets, 2) {
+		assert.Equal(t, "1", rec.Targets[0].ParentID)
+		assert.Equal(t, "42", rec.Targets[0].Recipient)
+		assert.Equal(t, models.StatusFailed, rec.Targets[0].Status)
+		assert.Equal(t, "permanent", rec.Targets[0].FailureClass)
+		assert.Len(t, rec.Targets[0].History, 2)
+		assert.Equal(t, "test@example.com", rec.Targets[1].Recipient)
+		assert.Equal(t, "Привет", rec.Targets[1].Message)
+		assert.Equal(t, models.StatusSent, rec.Targets[1].History[1].Status)
+	}
+}
+
+func TestCSVWriterFallbackGroup(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, FormatCSV, testChannels.Describe)
+	assert.NoError(t, w.Write(fallbackGroup()))
+	assert.NoError(t, w.Flush())
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 4) {
+		assert.Equal(t, csvHeader, rows[0])
+		assert.Equal(t, []string{"1", ""}, rows[1][:2])
+		assert.Equal(t, []string{"2", "1", "telegram", "failed", "42"}, rows[2][:5])
+		assert.Equal(t, "scheduled@2025-11-01T10:00:00Z;failed@2025-11-01T10:00:01Z", rows[2][16])
+		assert.Equal(t, []string{"3", "1", "email", "sent", "test@example.com"}, rows[3][:5])
+	}
+}
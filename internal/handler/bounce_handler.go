@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// BounceHandler принимает события недоставки писем от почтового провайдера (ESP).
+type BounceHandler struct {
+	processor *service.BounceProcessor
+	secret    string
+}
+
+// bounceEvent событие вебхука в формате SendGrid Event Webhook; такой же формат
+// поддерживают и другие провайдеры. Учитываются только события bounce.
+type bounceEvent struct {
+	Event string `json:"event"`
+	Email string `json:"email"`
+	// Type bounce — постоянная ошибка, blocked — временная (провайдер отказался от повторов)
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+	// SMTPID заголовок Message-ID отправленного письма
+	SMTPID string `json:"smtp-id"`
+}
+
+// NewBounceHandler создает обработчик и регистрирует маршрут POST /v1/bounces?token=<secret>.
+// Без секрета вебхук не регистрируется: иначе кто угодно мог бы добавлять адреса в список подавления.
+func NewBounceHandler(r *ginext.Engine, processor *service.BounceProcessor, secret string) {
+	if secret == "" {
+		return
+	}
+	h := &BounceHandler{processor: processor, secret: secret}
+	r.POST("/v1/bounces", h.webhook)
+}
+
+// webhook хендлер, принимающий массив событий провайдера. Ответ не 2xx заставляет провайдера
+// повторить доставку; повторная обработка тех же событий безопасна.
+func (h *BounceHandler) webhook(c *ginext.Context) {
+	if subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(h.secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, map[string]any{"error": "invalid token"})
+		return
+	}
+	var events []bounceEvent
+	if err := c.BindJSON(&events); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": "invalid request"})
+		return
+	}
+
+	var bounces []models.Bounce
+	for _, e := range events {
+		if e.Event != "bounce" || e.Email == "" {
+			continue
+		}
+		bounces = append(bounces, models.Bounce{
+			Recipient:  e.Email,
+			MessageID:  e.SMTPID,
+			Hard:       e.Type != "blocked",
+			Status:     e.Status,
+			Diagnostic: e.Reason,
+		})
+	}
+	if err := h.processor.ProcessAll(c.Request.Context(), bounces); err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to process bounces"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
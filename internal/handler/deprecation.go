@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+var (
+	// legacyDeprecatedAt дата, с которой маршруты без версии считаются устаревшими.
+	legacyDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	// legacySunset дата, после которой маршруты без версии будут удалены.
+	legacySunset = time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// deprecated возвращает middleware, помечающее ответы устаревших маршрутов
+// заголовками Deprecation и Sunset (RFC 9745, RFC 8594) и ссылкой на актуальную версию.
+func deprecated(deprecatedAt, sunset time.Time, successorPrefix string) ginext.HandlerFunc {
+	deprecationHeader := fmt.Sprintf("@%d", deprecatedAt.Unix())
+	sunsetHeader := sunset.UTC().Format(http.TimeFormat)
+	return func(c *ginext.Context) {
+		c.Writer.Header().Set("Deprecation", deprecationHeader)
+		c.Writer.Header().Set("Sunset", sunsetHeader)
+		c.Writer.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", successorPrefix, c.Request.URL.Path))
+		c.Next()
+	}
+}
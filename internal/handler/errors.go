@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+)
+
+// validationErrorResponse формирует тело ответа 400 для ошибки валидации:
+// "error" содержит все сообщения, "fields" — ошибки по отдельным полям.
+func validationErrorResponse(err error) map[string]any {
+	resp := map[string]any{"error": err.Error()}
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		resp["fields"] = fieldErrs
+	}
+	return resp
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/exporter"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// exportFlushEvery через сколько записей ответ сбрасывается клиенту.
+const exportFlushEvery = 100
+
+// export хендлер для потоковой выгрузки уведомлений с историей статусов в CSV или NDJSON.
+// Поддерживает те же фильтры, что и список уведомлений.
+func (h *NotificationHandler) export(c *ginext.Context) {
+	filter, err := h.parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	format, err := exporter.ParseFormat(c.Query("format"), c.GetHeader("Accept"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("notifications-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w := exporter.NewWriter(c.Writer, format, h.channels.Describe)
+	written := 0
+	err = h.svc.Export(c.Request.Context(), filter, func(n *models.Notification) error {
+		if err := w.Write(n); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// заголовки уже отправлены, поэтому сообщить об ошибке можно только обрывом ответа
+		log.Printf("export failed after %d records: %v", written, err)
+		c.Abort()
+		return
+	}
+	c.Writer.Flush()
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// errManyRecipients возвращается, если в запросе указано несколько фильтров по получателю.
+var errManyRecipients = errors.New("only one recipient filter can be used")
+
+// parseFilter читает фильтры списка уведомлений из query-параметров:
+// type, status, recipient, from, to (RFC3339, по scheduled_at).
+// Получателя также можно указать параметром канала (email, chat_id и т.п.) — тогда тип задается им.
+func (h *NotificationHandler) parseFilter(c *ginext.Context) (models.NotificationFilter, error) {
+	filter := models.NotificationFilter{
+		Type:      models.NotificationType(c.Query("type")),
+		Status:    models.Status(c.Query("status")),
+		Recipient: c.Query("recipient"),
+	}
+	for _, ch := range h.channels.Channels() {
+		value := c.Query(ch.RecipientParam)
+		if ch.RecipientParam == "" || value == "" {
+			continue
+		}
+		if filter.Recipient != "" {
+			return filter, errManyRecipients
+		}
+		filter.Recipient = value
+		if filter.Type == "" {
+			filter.Type = ch.Type
+		}
+	}
+	for name, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s: expected RFC3339 datetime", name)
+		}
+		*dst = t
+	}
+	return filter, nil
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/importer"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// maxImportSize максимальный размер загружаемого файла импорта.
+const maxImportSize = 32 << 20
+
+// importResponse ответ на импорт: прогресс задачи и, для синхронного режима, отклоненные строки.
+type importResponse struct {
+	*importer.Job
+	Errors []importer.RowError `json:"errors,omitempty"`
+}
+
+// importNotifications хендлер для импорта уведомлений из CSV или NDJSON.
+// Файл передается телом запроса или полем "file" multipart-формы.
+// С параметром async=true импорт выполняется в фоне, прогресс доступен по GET /imports/:id.
+func (h *NotificationHandler) importNotifications(c *ginext.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var (
+		body     io.Reader = c.Request.Body
+		filename string
+	)
+	contentType := c.GetHeader("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, map[string]any{"error": "file is required"})
+			return
+		}
+		defer file.Close()
+		body = file
+		filename = header.Filename
+		contentType = header.Header.Get("Content-Type")
+	}
+
+	format, err := importer.ParseFormat(c.Query("format"), contentType, filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	if c.Query("async") == "true" {
+		job, err := h.importer.Start(body, format)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		c.Header("Location", fmt.Sprintf("%s/%s", importsPath(c), job.ID))
+		c.JSON(http.StatusAccepted, importResponse{Job: job})
+		return
+	}
+
+	job := h.importer.Import(c.Request.Context(), body, format)
+	c.JSON(http.StatusOK, importResponse{Job: job, Errors: job.RowErrors})
+}
+
+// getImport хендлер для получения прогресса задачи импорта.
+func (h *NotificationHandler) getImport(c *ginext.Context) {
+	job, err := h.importer.Job(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, map[string]any{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, importResponse{Job: job})
+}
+
+// getImportErrors хендлер для скачивания CSV с отклоненными строками импорта.
+func (h *NotificationHandler) getImportErrors(c *ginext.Context) {
+	job, err := h.importer.Job(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, map[string]any{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "import-"+job.ID+"-errors.csv"))
+	c.Status(http.StatusOK)
+	if err := importer.WriteErrors(c.Writer, job); err != nil {
+		c.Error(err)
+	}
+}
+
+// importsPath возвращает путь к задачам импорта с учетом версии API в текущем запросе.
+func importsPath(c *ginext.Context) string {
+	prefix, _, _ := strings.Cut(c.FullPath(), "/notify/import")
+	return prefix + "/imports"
+}
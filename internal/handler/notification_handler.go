@@ -3,8 +3,9 @@ package handler
 import (
 	"log"
 	"net/http"
-	"time"
 
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/importer"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
@@ -16,18 +17,21 @@ import (
 type NotificationHandler struct {
 	svc         service.NotificationService
 	statusCache *statuscache.Cache
+	importer    *importer.Importer
+	channels    *channel.Registry
 }
 
 // NewNotificationHandler создает новый обработчик уведомлений и регистрирует маршруты
-func NewNotificationHandler(r *ginext.Engine, svc service.NotificationService, frontendURL string, cache *statuscache.Cache) {
+func NewNotificationHandler(r *ginext.Engine, svc service.NotificationService, frontendURL string, cache *statuscache.Cache, imp *importer.Importer, channels *channel.Registry) {
 	log.Printf("Frontend URL: %s\n", frontendURL)
-	h := &NotificationHandler{svc: svc, statusCache: cache}
+	h := &NotificationHandler{svc: svc, statusCache: cache, importer: imp, channels: channels}
 	// CORS middleware
 	r.Use(func(c *ginext.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", frontendURL)
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Deprecation, Sunset, Link")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -36,10 +40,38 @@ func NewNotificationHandler(r *ginext.Engine, svc service.NotificationService, f
 
 		c.Next()
 	})
-	r.POST("/notify", h.create)
-	r.GET("/notify", h.getAll)
-	r.GET("/notify/:id", h.get)
-	r.DELETE("/notify/:id", h.cancel)
+
+	// актуальная версия API
+	h.registerV1(r.Group("/v1"))
+
+	// маршруты без версии оставлены для обратной совместимости
+	h.registerV1(r.Group("", deprecated(legacyDeprecatedAt, legacySunset, "/v1")))
+}
+
+// registerV1 регистрирует маршруты первой версии API в переданной группе.
+// Следующая версия регистрируется отдельным методом в своей группе (например, /v2).
+func (h *NotificationHandler) registerV1(g *ginext.RouterGroup) {
+	g.POST("/notify", h.create)
+	g.GET("/notify", h.getAll)
+	g.GET("/notify/export", h.export)
+	g.GET("/notify/:id", h.get)
+	g.GET("/notify/:id/targets", h.targets)
+	g.DELETE("/notify/:id", h.cancel)
+	g.GET("/recipients/:type/:recipient/notifications", h.recipientNotifications)
+	g.POST("/notify/import", h.importNotifications)
+	g.POST("/notify/preview", h.preview)
+	g.GET("/imports/:id", h.getImport)
+	g.GET("/imports/:id/errors", h.getImportErrors)
+	g.GET("/suppressions/:type/:recipient", h.getSuppression)
+	g.DELETE("/suppressions/:type/:recipient", h.deleteSuppression)
+	g.GET("/preferences/:type/:recipient", h.getPreferences)
+	g.PUT("/preferences/:type/:recipient", h.setPreference)
+	g.POST("/templates", h.createTemplate)
+	g.GET("/templates", h.listTemplates)
+	g.GET("/templates/:id", h.getTemplate)
+	g.GET("/templates/:id/versions", h.templateVersions)
+	g.PUT("/templates/:id", h.updateTemplate)
+	g.DELETE("/templates/:id", h.deleteTemplate)
 }
 
 // create хендлер для создания нового уведомления.
@@ -49,33 +81,18 @@ func (h *NotificationHandler) create(c *ginext.Context) {
 		c.JSON(http.StatusBadRequest, map[string]any{"error": "invalid request"})
 		return
 	}
-	if req.Type != models.NotificationTypeEmail && req.Type != models.NotificationTypeTelegram {
-		c.JSON(http.StatusBadRequest, map[string]any{"error": "unsupported notification type"})
-		return
-	}
-
-	if req.Type == models.NotificationTypeEmail && req.Email == "" {
-		c.JSON(http.StatusBadRequest, map[string]any{"error": "email is required for email notifications"})
-		return
-	}
-
-	if req.Type == models.NotificationTypeTelegram && req.ChatID == "" {
-		c.JSON(http.StatusBadRequest, map[string]any{"error": "chat_id is required for telegram notifications"})
-		return
-	}
-
-	if req.Message == "" {
-		c.JSON(http.StatusBadRequest, map[string]any{"error": "message cannot be empty"})
+	if err := h.svc.ResolveRecipients(c.Request.Context(), &req); err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to resolve recipients"})
 		return
 	}
-
-	if req.ScheduledAt.IsZero() {
-		c.JSON(http.StatusBadRequest, map[string]any{"error": "scheduled_at is required"})
+	if err := h.svc.ResolveTemplate(c.Request.Context(), &req); err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to load template"})
 		return
 	}
-
-	if req.ScheduledAt.Before(time.Now()) {
-		c.JSON(http.StatusBadRequest, map[string]any{"error": "scheduled_at cannot be in the past"})
+	if err := h.channels.Validate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse(err))
 		return
 	}
 
@@ -111,9 +128,11 @@ func (h *NotificationHandler) get(c *ginext.Context) {
 		}
 	}
 
-	// если нет в Redis — берём из БД
-	if status == "" {
-		n, err := h.svc.Get(ctx, id)
+	// если нет в Redis — берём из БД; причина неудачи хранится только в БД
+	var n *models.Notification
+	if status == "" || status == models.StatusFailed || status == models.StatusBounced || status == models.StatusSuppressed {
+		var err error
+		n, err = h.svc.Get(ctx, id)
 		if err != nil {
 			c.JSON(http.StatusNotFound, map[string]any{"error": "notification not found"})
 			return
@@ -126,14 +145,24 @@ func (h *NotificationHandler) get(c *ginext.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, map[string]string{
+	resp := map[string]string{
 		"status": string(status),
-	})
+	}
+	if n != nil && n.FailureReason != "" {
+		resp["failure_class"] = n.FailureClass
+		resp["failure_reason"] = n.FailureReason
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
-// getAll хендлер для получения всех уведомлений. (метод для фронтенда)
+// getAll хендлер для получения всех уведомлений с фильтрами из query-параметров. (метод для фронтенда)
 func (h *NotificationHandler) getAll(c *ginext.Context) {
-	n, err := h.svc.GetAll(c.Request.Context())
+	filter, err := h.parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	n, err := h.svc.GetAll(c.Request.Context(), filter)
 	if err != nil {
 		// если уведомлений нет, возвращаем пустой массив
 		if err == repository.ErrNotFound {
@@ -149,31 +178,7 @@ func (h *NotificationHandler) getAll(c *ginext.Context) {
 	}
 	var resp []models.NotificationResponse
 	for _, notif := range n {
-		var response models.NotificationResponse
-		switch notif.Type {
-		case models.NotificationTypeEmail:
-			response = models.NotificationResponse{
-				ID:          notif.ID,
-				Email:       notif.EmailNotification.Email,
-				Type:        notif.Type,
-				Message:     notif.EmailNotification.Message,
-				Subject:     notif.EmailNotification.Subject,
-				ScheduledAt: notif.ScheduledAt,
-				Status:      notif.Status,
-				Retries:     notif.Retries,
-			}
-		case models.NotificationTypeTelegram:
-			response = models.NotificationResponse{
-				ID:          notif.ID,
-				ChatID:      notif.TelegramNotification.ChatID,
-				Type:        notif.Type,
-				Message:     notif.TelegramNotification.Message,
-				ScheduledAt: notif.ScheduledAt,
-				Status:      notif.Status,
-				Retries:     notif.Retries,
-			}
-		}
-		resp = append(resp, response)
+		resp = append(resp, h.channels.Describe(notif))
 	}
 
 	c.JSON(http.StatusOK, resp)
@@ -205,3 +210,17 @@ func (h *NotificationHandler) cancel(c *ginext.Context) {
 	}
 	c.JSON(http.StatusOK, map[string]any{"status": "canceled"})
 }
+
+// targets хендлер для получения статусов доставки уведомления по каждому каналу.
+func (h *NotificationHandler) targets(c *ginext.Context) {
+	n, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "notification not found"})
+		return
+	}
+	if n.Group == nil {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "notification has no targets"})
+		return
+	}
+	c.JSON(http.StatusOK, h.channels.Describe(n))
+}
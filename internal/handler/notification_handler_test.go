@@ -3,20 +3,33 @@ package handler
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/pushtoken"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/unsubscribe"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/wb-go/wbf/ginext"
 )
 
+// testChannels реестр встроенных каналов без отправителей.
+var testChannels = channel.NewRegistry(channel.Email(nil), channel.Telegram(nil))
+
 // MockNotificationService - мок для сервиса уведомлений
 type MockNotificationService struct {
 	mock.Mock
@@ -35,11 +48,31 @@ func (m *MockNotificationService) Get(ctx context.Context, id string) (*models.N
 	return args.Get(0).(*models.Notification), args.Error(1)
 }
 
-func (m *MockNotificationService) GetAll(ctx context.Context) ([]*models.Notification, error) {
-	args := m.Called(ctx)
+func (m *MockNotificationService) GetAll(ctx context.Context, filter models.NotificationFilter) ([]*models.Notification, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]*models.Notification), args.Error(1)
 }
 
+func (m *MockNotificationService) Export(ctx context.Context, filter models.NotificationFilter, fn func(n *models.Notification) error) error {
+	args := m.Called(ctx, filter)
+	if notifications, ok := args.Get(0).([]*models.Notification); ok {
+		for _, n := range notifications {
+			if err := fn(n); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockNotificationService) CountByStatus(ctx context.Context, filter models.NotificationFilter) (map[models.Status]int, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[models.Status]int), args.Error(1)
+}
+
 func (m *MockNotificationService) Cancel(ctx context.Context, id string) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -60,11 +93,187 @@ func (m *MockNotificationService) IncrementRetries(ctx context.Context, id strin
 	return args.Error(0)
 }
 
+func (m *MockNotificationService) RecordSegments(ctx context.Context, id string, segments int) error {
+	args := m.Called(ctx, id, segments)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) RecordFailure(ctx context.Context, id, class, reason string) error {
+	args := m.Called(ctx, id, class, reason)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) RecordProviderMessageID(ctx context.Context, id, messageID string) error {
+	args := m.Called(ctx, id, messageID)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) RecordBounce(ctx context.Context, messageID, class, reason string) (*models.Notification, error) {
+	args := m.Called(ctx, messageID, class, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationService) SyncGroup(ctx context.Context, parentID string) (models.Status, error) {
+	args := m.Called(ctx, parentID)
+	return args.Get(0).(models.Status), args.Error(1)
+}
+
+func (m *MockNotificationService) PendingGroups(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockNotificationService) Reschedule(ctx context.Context, id string, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) Suppress(ctx context.Context, s *models.Suppression) error {
+	args := m.Called(ctx, s)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) Suppressed(ctx context.Context, n *models.Notification) (*models.Suppression, error) {
+	args := m.Called(ctx, n)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Suppression), args.Error(1)
+}
+
+func (m *MockNotificationService) GetSuppression(ctx context.Context, t models.NotificationType, recipient string) (*models.Suppression, error) {
+	args := m.Called(ctx, t, recipient)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Suppression), args.Error(1)
+}
+
+func (m *MockNotificationService) SetPreference(ctx context.Context, p *models.Preference) error {
+	args := m.Called(ctx, p)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) GetPreferences(ctx context.Context, t models.NotificationType, recipient string) ([]models.Preference, error) {
+	args := m.Called(ctx, t, recipient)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Preference), args.Error(1)
+}
+
+func (m *MockNotificationService) OptedOut(ctx context.Context, n *models.Notification) (*models.Preference, error) {
+	args := m.Called(ctx, n)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Preference), args.Error(1)
+}
+
+func (m *MockNotificationService) Unsuppress(ctx context.Context, t models.NotificationType, recipient string) error {
+	args := m.Called(ctx, t, recipient)
+	return args.Error(0)
+}
+
+// ResolveRecipients ничего не делает: получатели в тестах хендлера заданы явно.
+func (m *MockNotificationService) ResolveRecipients(ctx context.Context, req *models.CreateNotificationRequest) error {
+	return nil
+}
+
+func (m *MockNotificationService) CreateTelegramLinkToken(ctx context.Context, userID string) (*models.TelegramLinkToken, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TelegramLinkToken), args.Error(1)
+}
+
+func (m *MockNotificationService) LinkTelegramChat(ctx context.Context, token string, link *models.TelegramLink) error {
+	args := m.Called(ctx, token, link)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) GetTelegramLink(ctx context.Context, userID string) (*models.TelegramLink, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TelegramLink), args.Error(1)
+}
+
+func (m *MockNotificationService) DeleteTelegramLink(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) CreateTemplate(ctx context.Context, t *models.Template) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) UpdateTemplate(ctx context.Context, t *models.Template) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) GetTemplate(ctx context.Context, id string, version int) (*models.Template, error) {
+	args := m.Called(ctx, id, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Template), args.Error(1)
+}
+
+func (m *MockNotificationService) ListTemplates(ctx context.Context) ([]models.Template, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Template), args.Error(1)
+}
+
+func (m *MockNotificationService) ListTemplateVersions(ctx context.Context, id string) ([]models.Template, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Template), args.Error(1)
+}
+
+func (m *MockNotificationService) DeleteTemplate(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// ResolveTemplate обращается к моку только для запросов с шаблоном.
+func (m *MockNotificationService) ResolveTemplate(ctx context.Context, req *models.CreateNotificationRequest) error {
+	if req.TemplateID == "" {
+		return nil
+	}
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+// RenderTemplate обращается к моку только для уведомлений с шаблоном.
+func (m *MockNotificationService) RenderTemplate(ctx context.Context, n *models.Notification) error {
+	if n.TemplateID == "" {
+		return nil
+	}
+	args := m.Called(ctx, n)
+	return args.Error(0)
+}
+
 // TestCreateNotificationHandlerSuccess - Тест успешного создания уведомления
 func TestCreateNotificationHandlerSuccess(t *testing.T) {
 	// Setup
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.POST("/notify", handler.create)
 
@@ -102,7 +311,7 @@ func TestCreateNotificationHandlerSuccess(t *testing.T) {
 func TestCreateNotificationHandlerInvalidRequest(t *testing.T) {
 	// Setup
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.POST("/notify", handler.create)
 
@@ -126,7 +335,7 @@ func TestCreateNotificationHandlerInvalidRequest(t *testing.T) {
 func TestCreateNotificationHandlerUnsupportedType(t *testing.T) {
 	// Setup
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.POST("/notify", handler.create)
 
@@ -160,7 +369,7 @@ func TestCreateNotificationHandlerUnsupportedType(t *testing.T) {
 func TestCreateNotificationHandlerMissingEmail(t *testing.T) {
 	// Setup
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.POST("/notify", handler.create)
 
@@ -193,7 +402,7 @@ func TestCreateNotificationHandlerMissingEmail(t *testing.T) {
 func TestCreateNotificationHandlerMissingChatID(t *testing.T) {
 	// Setup
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.POST("/notify", handler.create)
 
@@ -226,7 +435,7 @@ func TestCreateNotificationHandlerMissingChatID(t *testing.T) {
 func TestCreateNotificationHandlerMissingMessage(t *testing.T) {
 	// Setup
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.POST("/notify", handler.create)
 
@@ -259,7 +468,7 @@ func TestCreateNotificationHandlerMissingMessage(t *testing.T) {
 func TestCreateNotificationHandlerMissingScheduledAt(t *testing.T) {
 	// Setup
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.POST("/notify", handler.create)
 
@@ -291,7 +500,7 @@ func TestCreateNotificationHandlerMissingScheduledAt(t *testing.T) {
 func TestCreateNotificationHandlerScheduledAtInPast(t *testing.T) {
 	// Setup
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.POST("/notify", handler.create)
 
@@ -324,7 +533,7 @@ func TestCreateNotificationHandlerScheduledAtInPast(t *testing.T) {
 // TestGetNotificationHandlerSuccess tests the get handler when the notification is found.
 func TestGetNotificationHandlerSuccess(t *testing.T) {
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.GET("/notify/:id", handler.get)
 
@@ -336,7 +545,7 @@ func TestGetNotificationHandlerSuccess(t *testing.T) {
 		Status:      models.StatusScheduled,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
-		EmailNotification: &models.EmailNotification{
+		Payload: &models.EmailNotification{
 			Email:   "test@example.com",
 			Message: "Test message",
 			Subject: "Test subject",
@@ -357,15 +566,15 @@ func TestGetNotificationHandlerSuccess(t *testing.T) {
 	switch response.Type {
 	case models.NotificationTypeEmail:
 		assert.Equal(t, expectedNotification.ID, response.ID)
-		assert.Equal(t, expectedNotification.EmailNotification.Email, response.Email)
-		assert.Equal(t, expectedNotification.EmailNotification.Message, response.Message)
-		assert.Equal(t, expectedNotification.EmailNotification.Subject, response.Subject)
+		assert.Equal(t, models.PayloadOf[models.EmailNotification](expectedNotification).Email, response.Email)
+		assert.Equal(t, models.PayloadOf[models.EmailNotification](expectedNotification).Message, response.Message)
+		assert.Equal(t, models.PayloadOf[models.EmailNotification](expectedNotification).Subject, response.Subject)
 		assert.Equal(t, expectedNotification.Type, response.Type)
 		assert.True(t, expectedNotification.ScheduledAt.Truncate(time.Second).Equal(response.ScheduledAt.Truncate(time.Second)), "CreatedAt times are not equal")
 		assert.Equal(t, expectedNotification.Status, response.Status)
 	case models.NotificationTypeTelegram:
 		assert.Equal(t, expectedNotification.ID, response.ID)
-		assert.Equal(t, expectedNotification.TelegramNotification.ChatID, response.ChatID)
+		assert.Equal(t, models.PayloadOf[models.TelegramNotification](expectedNotification).ChatID, response.ChatID)
 		assert.Equal(t, expectedNotification.Type, response.Type)
 		assert.True(t, expectedNotification.ScheduledAt.Truncate(time.Second).Equal(response.ScheduledAt.Truncate(time.Second)), "CreatedAt times are not equal")
 		assert.Equal(t, expectedNotification.Status, response.Status)
@@ -373,10 +582,37 @@ func TestGetNotificationHandlerSuccess(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestGetNotificationHandlerFailed(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.GET("/notify/:id", handler.get)
+
+	mockService.On("Get", mock.Anything, "123").Return(&models.Notification{
+		ID:            "123",
+		Type:          models.NotificationTypeEmail,
+		Status:        models.StatusFailed,
+		FailureClass:  "permanent",
+		FailureReason: "failed to send email: 550 5.1.1 user unknown",
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/notify/123", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"status": "failed",
+		"failure_class": "permanent",
+		"failure_reason": "failed to send email: 550 5.1.1 user unknown"
+	}`, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
 // TestGetNotificationHandlerNotFound tests the get handler when the notification is not found.
 func TestGetNotificationHandlerNotFound(t *testing.T) {
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.GET("/notify/:id", handler.get)
 
@@ -400,7 +636,7 @@ func TestGetNotificationHandlerNotFound(t *testing.T) {
 
 func TestGetAllNotificationHandlerSuccess(t *testing.T) {
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.GET("/notify", handler.getAll)
 
@@ -412,7 +648,7 @@ func TestGetAllNotificationHandlerSuccess(t *testing.T) {
 			Status:      models.StatusScheduled,
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
-			EmailNotification: &models.EmailNotification{
+			Payload: &models.EmailNotification{
 				Email:   "test1@example.com",
 				Message: "Test message 1",
 				Subject: "Test subject 1",
@@ -425,14 +661,14 @@ func TestGetAllNotificationHandlerSuccess(t *testing.T) {
 			Status:      models.StatusScheduled,
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
-			TelegramNotification: &models.TelegramNotification{
+			Payload: &models.TelegramNotification{
 				ChatID:  "1351515",
 				Message: "Test message 2",
 			},
 		},
 	}
 
-	mockService.On("GetAll", mock.Anything).Return(expectedNotifications, nil)
+	mockService.On("GetAll", mock.Anything, models.NotificationFilter{}).Return(expectedNotifications, nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/notify", nil)
@@ -451,16 +687,16 @@ func TestGetAllNotificationHandlerSuccess(t *testing.T) {
 		switch expected.Type {
 		case models.NotificationTypeEmail:
 			assert.Equal(t, expected.ID, response[i].ID)
-			assert.Equal(t, expected.EmailNotification.Email, response[i].Email)
+			assert.Equal(t, models.PayloadOf[models.EmailNotification](expected).Email, response[i].Email)
 			assert.Equal(t, expected.Type, response[i].Type)
-			assert.Equal(t, expected.EmailNotification.Subject, response[i].Subject)
-			assert.Equal(t, expected.EmailNotification.Message, response[i].Message)
+			assert.Equal(t, models.PayloadOf[models.EmailNotification](expected).Subject, response[i].Subject)
+			assert.Equal(t, models.PayloadOf[models.EmailNotification](expected).Message, response[i].Message)
 			assert.Equal(t, expected.Status, response[i].Status)
 		case models.NotificationTypeTelegram:
 			assert.Equal(t, expected.ID, response[i].ID)
-			assert.Equal(t, expected.TelegramNotification.ChatID, response[i].ChatID)
+			assert.Equal(t, models.PayloadOf[models.TelegramNotification](expected).ChatID, response[i].ChatID)
 			assert.Equal(t, expected.Type, response[i].Type)
-			assert.Equal(t, expected.TelegramNotification.Message, response[i].Message)
+			assert.Equal(t, models.PayloadOf[models.TelegramNotification](expected).Message, response[i].Message)
 		}
 	}
 
@@ -470,11 +706,11 @@ func TestGetAllNotificationHandlerSuccess(t *testing.T) {
 func TestGetAllNotificationHandlerNotFound(t *testing.T) {
 	// Setup
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.GET("/notify", handler.getAll)
 
-	mockService.On("GetAll", mock.Anything).Return([]*models.Notification{}, repository.ErrNotFound)
+	mockService.On("GetAll", mock.Anything, models.NotificationFilter{}).Return([]*models.Notification{}, repository.ErrNotFound)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/notify", nil)
@@ -494,7 +730,7 @@ func TestGetAllNotificationHandlerNotFound(t *testing.T) {
 
 func TestCancelNotificationHandlerSuccess(t *testing.T) {
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.POST("/notify/:id/cancel", handler.cancel)
 
@@ -507,7 +743,7 @@ func TestCancelNotificationHandlerSuccess(t *testing.T) {
 		Status:      models.StatusScheduled,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
-		EmailNotification: &models.EmailNotification{
+		Payload: &models.EmailNotification{
 			Email:   "test1@example.com",
 			Message: "Test message 1",
 			Subject: "Test subject 1",
@@ -533,7 +769,7 @@ func TestCancelNotificationHandlerSuccess(t *testing.T) {
 
 func TestCancelNotificationHandlerNotFound(t *testing.T) {
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.DELETE("/notify/:id", handler.cancel)
 
@@ -559,7 +795,7 @@ func TestCancelNotificationHandlerNotScheduled(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.DELETE("/notify/:id", handler.cancel)
 
@@ -571,7 +807,7 @@ func TestCancelNotificationHandlerNotScheduled(t *testing.T) {
 		Status:      models.StatusProcessing,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
-		EmailNotification: &models.EmailNotification{
+		Payload: &models.EmailNotification{
 			Email:   "test1@example.com",
 			Message: "Test message 1",
 			Subject: "Test subject 1",
@@ -596,7 +832,7 @@ func TestCancelNotificationHandlerNotScheduled(t *testing.T) {
 
 func TestCancelNotificationHandlerCancelError(t *testing.T) {
 	mockService := new(MockNotificationService)
-	handler := &NotificationHandler{svc: mockService}
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
 	router := ginext.New()
 	router.DELETE("/notify/:id", handler.cancel)
 
@@ -609,7 +845,7 @@ func TestCancelNotificationHandlerCancelError(t *testing.T) {
 		Status:      models.StatusScheduled,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
-		EmailNotification: &models.EmailNotification{
+		Payload: &models.EmailNotification{
 			Email:   "test1@example.com",
 			Message: "Test message 1",
 			Subject: "Test subject 1",
@@ -632,3 +868,684 @@ func TestCancelNotificationHandlerCancelError(t *testing.T) {
 
 	mockService.AssertExpectations(t)
 }
+
+// TestVersionedRoutes проверяет, что API доступно под /v1, а маршруты без версии помечены как устаревшие.
+func TestVersionedRoutes(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := ginext.New()
+	NewNotificationHandler(router, mockService, "http://localhost:8080", nil, nil, testChannels)
+
+	notification := &models.Notification{
+		ID:          "123",
+		Type:        models.NotificationTypeEmail,
+		ScheduledAt: time.Now().Add(time.Hour),
+		Status:      models.StatusScheduled,
+	}
+	mockService.On("Get", mock.Anything, "123").Return(notification, nil)
+
+	t.Run("V1", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/notify/123", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Deprecation"))
+		assert.Empty(t, w.Header().Get("Sunset"))
+	})
+
+	t.Run("Legacy", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/notify/123", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, fmt.Sprintf("@%d", legacyDeprecatedAt.Unix()), w.Header().Get("Deprecation"))
+		assert.Equal(t, legacySunset.Format(http.TimeFormat), w.Header().Get("Sunset"))
+		assert.Equal(t, `</v1/notify/123>; rel="successor-version"`, w.Header().Get("Link"))
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestExportNotificationHandlerNDJSON(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.GET("/notify/export", handler.export)
+
+	from := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+	filter := models.NotificationFilter{Type: models.NotificationTypeEmail, Recipient: "test@example.com", From: from}
+	notifications := []*models.Notification{
+		{
+			ID:     "1",
+			Type:   models.NotificationTypeEmail,
+			Status: models.StatusSent,
+			Payload: &models.EmailNotification{
+				Email:   "test@example.com",
+				Message: "Test message 1",
+			},
+			History: []models.StatusChange{{Status: models.StatusScheduled}, {Status: models.StatusSent}},
+		},
+		{
+			ID:     "2",
+			Type:   models.NotificationTypeEmail,
+			Status: models.StatusFailed,
+			Payload: &models.EmailNotification{
+				Email:   "test@example.com",
+				Message: "Test message 2",
+			},
+		},
+	}
+	mockService.On("Export", mock.Anything, filter).Return(notifications, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/notify/export?format=ndjson&email=test@example.com&from="+from.Format(time.RFC3339), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := bytes.Split(bytes.TrimSpace(w.Body.Bytes()), []byte("\n"))
+	if assert.Len(t, lines, 2) {
+		var first map[string]any
+		assert.NoError(t, json.Unmarshal(lines[0], &first))
+		assert.Equal(t, "1", first["id"])
+		assert.Len(t, first["history"], 2)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestGetAllNotificationHandlerInvalidFilter(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.GET("/notify", handler.getAll)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/notify?from=yesterday", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything)
+}
+
+func TestRecipientNotificationsHandler(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.GET("/recipients/:type/:recipient/notifications", handler.recipientNotifications)
+
+	filter := models.NotificationFilter{
+		Type:      models.NotificationTypeEmail,
+		Recipient: "test@example.com",
+		Limit:     1,
+		Offset:    1,
+	}
+	mockService.On("CountByStatus", mock.Anything, filter).
+		Return(map[models.Status]int{models.StatusSent: 2, models.StatusFailed: 1}, nil)
+	mockService.On("GetAll", mock.Anything, filter).Return([]*models.Notification{
+		{
+			ID:     "2",
+			Type:   models.NotificationTypeEmail,
+			Status: models.StatusSent,
+			Payload: &models.EmailNotification{
+				Email:   "test@example.com",
+				Message: "Test message",
+			},
+		},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/recipients/email/test@example.com/notifications?limit=1&offset=1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.RecipientNotificationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "test@example.com", response.Recipient)
+	assert.Equal(t, 3, response.Total)
+	assert.Equal(t, 2, response.Summary[models.StatusSent])
+	if assert.Len(t, response.Items, 1) {
+		assert.Equal(t, "2", response.Items[0].ID)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestRecipientNotificationsHandlerInvalidLimit(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.GET("/recipients/:type/:recipient/notifications", handler.recipientNotifications)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/recipients/telegram/12345/notifications?limit=0", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "CountByStatus", mock.Anything, mock.Anything)
+}
+
+func TestRecipientNotificationsHandlerUnknownChannel(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.GET("/recipients/:type/:recipient/notifications", handler.recipientNotifications)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/recipients/pigeon/12345/notifications", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertNotCalled(t, "CountByStatus", mock.Anything, mock.Anything)
+}
+
+func TestSuppressionHandlers(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.GET("/suppressions/:type/:recipient", handler.getSuppression)
+	router.DELETE("/suppressions/:type/:recipient", handler.deleteSuppression)
+
+	sup := &models.Suppression{Type: models.NotificationTypeTelegram, Recipient: "12345", Reason: "Forbidden: bot was blocked by the user"}
+	mockService.On("GetSuppression", mock.Anything, models.NotificationTypeTelegram, "12345").Return(sup, nil)
+	mockService.On("Unsuppress", mock.Anything, models.NotificationTypeTelegram, "12345").Return(nil)
+	mockService.On("Unsuppress", mock.Anything, models.NotificationTypeTelegram, "777").Return(repository.ErrNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/suppressions/telegram/12345", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response models.Suppression
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, sup.Reason, response.Reason)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/suppressions/telegram/12345", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/suppressions/telegram/777", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/suppressions/pigeon/12345", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestPreviewNotificationHandler(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{
+		svc: mockService,
+		channels: channel.NewRegistry(
+			channel.Email(sender.NewEmailSender(sender.SMTPConfig{Host: "localhost", Port: 1025, From: "no-reply@example.com"}, nil)),
+			channel.Telegram(sender.NewTelegramSender("token", "", nil, nil)),
+		),
+	}
+	router := ginext.New()
+	router.POST("/notify/preview", handler.preview)
+
+	t.Run("Email", func(t *testing.T) {
+		requestBody := models.CreateNotificationRequest{
+			Type:        models.NotificationTypeEmail,
+			Email:       "test@example.com",
+			Subject:     "Test subject",
+			Message:     "Test message",
+			ScheduledAt: time.Now().Add(time.Hour),
+		}
+		jsonValue, _ := json.Marshal(requestBody)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/notify/preview", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response models.RenderedMessage
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "test@example.com", response.Recipient)
+		assert.Equal(t, "no-reply@example.com", response.Headers["From"])
+		assert.Contains(t, response.Raw, "Subject: Test subject")
+	})
+
+	t.Run("Telegram", func(t *testing.T) {
+		requestBody := models.CreateNotificationRequest{
+			Type:        models.NotificationTypeTelegram,
+			ChatID:      "12345",
+			Message:     "Test message",
+			ScheduledAt: time.Now().Add(time.Hour),
+		}
+		jsonValue, _ := json.Marshal(requestBody)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/notify/preview", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response models.RenderedMessage
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.False(t, response.Truncated)
+		assert.Equal(t, "12345", response.Payload["chat_id"])
+		assert.Equal(t, "Test message", response.Payload["text"])
+	})
+
+	t.Run("TelegramTooLong", func(t *testing.T) {
+		requestBody := models.CreateNotificationRequest{
+			Type:        models.NotificationTypeTelegram,
+			ChatID:      "12345",
+			Message:     strings.Repeat("я", sender.TelegramMaxMessageLength+1),
+			ScheduledAt: time.Now().Add(time.Hour),
+		}
+		jsonValue, _ := json.Marshal(requestBody)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/notify/preview", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var response map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, []any{map[string]any{"field": "message", "message": "message exceeds 4096 characters"}}, response["fields"])
+	})
+
+	mockService.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+// telegramBotAPIStub Bot API, который ничего не отправляет.
+type telegramBotAPIStub struct{}
+
+func (telegramBotAPIStub) GetUpdates(ctx context.Context, offset int64) ([]models.TelegramUpdate, error) {
+	return nil, nil
+}
+
+func (telegramBotAPIStub) DeleteWebhook(ctx context.Context) error { return nil }
+
+func (telegramBotAPIStub) SendText(ctx context.Context, chatID, text string) error { return nil }
+
+func TestTelegramHandlers(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := ginext.New()
+	NewTelegramHandler(router, mockService, service.NewTelegramBot(mockService, telegramBotAPIStub{}), "notify_bot", "s3cret")
+
+	expiresAt := time.Date(2025, 11, 11, 10, 0, 0, 0, time.UTC)
+	mockService.On("CreateTelegramLinkToken", mock.Anything, "user-1").
+		Return(&models.TelegramLinkToken{Token: "abc", UserID: "user-1", ExpiresAt: expiresAt}, nil)
+	mockService.On("GetTelegramLink", mock.Anything, "user-2").Return(nil, repository.ErrNotFound)
+	mockService.On("Suppress", mock.Anything, &models.Suppression{Type: models.NotificationTypeTelegram, Recipient: "42", Reason: "user sent /stop"}).Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/telegram/links", strings.NewReader(`{"user_id": "user-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"token": "abc", "user_id": "user-1", "url": "https://t.me/notify_bot?start=abc", "expires_at": "2025-11-11T10:00:00Z"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/telegram/links", strings.NewReader(`{"user_id": " "}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/telegram/links/user-2", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	update := `{"update_id": 1, "message": {"message_id": 5, "chat": {"id": 42, "type": "private"}, "text": "/stop"}}`
+
+	// запрос без секрета отклоняется
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/telegram/webhook", strings.NewReader(update))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/telegram/webhook", strings.NewReader(update))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "s3cret")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestTelegramHandlerWithoutSecret(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := ginext.New()
+	NewTelegramHandler(router, mockService, service.NewTelegramBot(mockService, telegramBotAPIStub{}), "notify_bot", "")
+
+	// без секрета вебхук не регистрируется
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/telegram/webhook", strings.NewReader(`{"update_id": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBounceHandler(t *testing.T) {
+	mockService := new(MockNotificationService)
+	router := ginext.New()
+	NewBounceHandler(router, service.NewBounceProcessor(mockService, nil), "s3cret")
+
+	mockService.On("Suppress", mock.Anything, &models.Suppression{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Reason: "hard bounce 5.1.1: 550 5.1.1 User unknown"}).Return(nil)
+	mockService.On("RecordBounce", mock.Anything, "<abc@example.com>", "permanent", "hard bounce 5.1.1: 550 5.1.1 User unknown").
+		Return(&models.Notification{ID: "notification-1", Type: models.NotificationTypeEmail, Status: models.StatusBounced}, nil)
+
+	events := `[
+		{"event": "delivered", "email": "ok@example.com", "smtp-id": "<ok@example.com>"},
+		{"event": "bounce", "type": "bounce", "email": "user@example.com", "status": "5.1.1", "reason": "550 5.1.1 User unknown", "smtp-id": "<abc@example.com>"}
+	]`
+
+	// запрос без токена отклоняется
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/bounces", strings.NewReader(events))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/bounces?token=s3cret", strings.NewReader(events))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestPreferenceHandlers(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.GET("/preferences/:type/:recipient", handler.getPreferences)
+	router.PUT("/preferences/:type/:recipient", handler.setPreference)
+
+	prefs := []models.Preference{{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Category: "newsletter", Enabled: false}}
+	mockService.On("GetPreferences", mock.Anything, models.NotificationTypeEmail, "user@example.com").Return(prefs, nil)
+	mockService.On("SetPreference", mock.Anything, &models.Preference{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Category: "newsletter", Enabled: true}).Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/preferences/email/user@example.com", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Preferences []models.Preference `json:"preferences"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, prefs, response.Preferences)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/preferences/email/user@example.com", strings.NewReader(`{"category": "newsletter", "enabled": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// без enabled запрос неполный
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/preferences/email/user@example.com", strings.NewReader(`{"category": "newsletter"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/preferences/email/user@example.com", strings.NewReader(`{"category": "News Letter", "enabled": false}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/preferences/pigeon/user@example.com", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestUnsubscribeHandler(t *testing.T) {
+	mockService := new(MockNotificationService)
+	signer := unsubscribe.NewSigner("s3cret", "https://notify.example.com")
+	router := ginext.New()
+	NewUnsubscribeHandler(router, mockService, signer)
+
+	mockService.On("SetPreference", mock.Anything, &models.Preference{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Category: "newsletter", Enabled: false}).Return(nil)
+	target := unsubscribe.Path + "?token=" + signer.Token(models.NotificationTypeEmail, "user@example.com", "newsletter")
+
+	// переход по ссылке только показывает форму подтверждения
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", target, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<form method="post"`)
+	mockService.AssertNotCalled(t, "SetPreference", mock.Anything, mock.Anything)
+
+	// One-Click POST почтового клиента (RFC 8058)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", target, strings.NewReader("List-Unsubscribe=One-Click"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Вы отписались")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", unsubscribe.Path+"?token=forged.token", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+	mockService.AssertNumberOfCalls(t, "SetPreference", 1)
+}
+
+// MockPushSubscriptionRepository - мок для репозитория подписок Web Push
+type MockPushSubscriptionRepository struct {
+	mock.Mock
+}
+
+func (m *MockPushSubscriptionRepository) Save(ctx context.Context, s *models.PushSubscription) error {
+	args := m.Called(ctx, s)
+	return args.Error(0)
+}
+
+func (m *MockPushSubscriptionRepository) ListBySubscriber(ctx context.Context, subscriber string) ([]models.PushSubscription, error) {
+	args := m.Called(ctx, subscriber)
+	return args.Get(0).([]models.PushSubscription), args.Error(1)
+}
+
+func (m *MockPushSubscriptionRepository) DeleteByEndpoint(ctx context.Context, endpoint string) error {
+	args := m.Called(ctx, endpoint)
+	return args.Error(0)
+}
+
+func (m *MockPushSubscriptionRepository) Delete(ctx context.Context, subscriber, endpoint string) error {
+	args := m.Called(ctx, subscriber, endpoint)
+	return args.Error(0)
+}
+
+func TestPushHandler(t *testing.T) {
+	subs := new(MockPushSubscriptionRepository)
+	signer := pushtoken.NewSigner("s3cret")
+	router := ginext.New()
+	NewPushHandler(router, subs, "BPublicKey", signer)
+
+	token := signer.Token("user-1", time.Now().Add(time.Hour))
+	keys := models.PushSubscriptionKeys{
+		P256DH: base64.RawURLEncoding.EncodeToString(append([]byte{4}, make([]byte, 64)...)),
+		Auth:   base64.RawURLEncoding.EncodeToString(make([]byte, 16)),
+	}
+	endpoint := "https://push.example.com/abc"
+	subs.On("Save", mock.Anything, &models.PushSubscription{Subscriber: "user-1", Endpoint: endpoint, Keys: keys}).Return(nil)
+	subs.On("Delete", mock.Anything, "user-1", endpoint).Return(nil)
+	subs.On("Delete", mock.Anything, "user-1", "https://push.example.com/other").Return(repository.ErrNotFound)
+
+	body, _ := json.Marshal(map[string]any{"token": token, "endpoint": endpoint, "keys": keys, "subscriber": "victim"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/push/subscriptions", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	// подписчик берется из токена, а не из тела
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"subscriber":"user-1"`)
+
+	// без токена или с поддельным токеном подписка не принимается
+	body, _ = json.Marshal(map[string]any{"token": "forged.token", "endpoint": endpoint, "keys": keys})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/push/subscriptions", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/v1/push/subscriptions?endpoint="+url.QueryEscape(endpoint), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/v1/push/subscriptions?endpoint="+url.QueryEscape(endpoint)+"&token="+token, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// чужую подписку удалить нельзя
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/push/subscriptions?endpoint="+url.QueryEscape("https://push.example.com/other")+"&token="+token, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Deprecation"))
+
+	subs.AssertExpectations(t)
+}
+
+func TestPushHandlerWithoutSecret(t *testing.T) {
+	router := ginext.New()
+	NewPushHandler(router, new(MockPushSubscriptionRepository), "BPublicKey", nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/push/vapid-public-key", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/push/subscriptions", strings.NewReader(`{}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTemplateHandlers(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.POST("/templates", handler.createTemplate)
+	router.GET("/templates", handler.listTemplates)
+	router.GET("/templates/:id", handler.getTemplate)
+	router.GET("/templates/:id/versions", handler.templateVersions)
+	router.PUT("/templates/:id", handler.updateTemplate)
+	router.DELETE("/templates/:id", handler.deleteTemplate)
+
+	channels := map[models.NotificationType]models.TemplateContent{
+		models.NotificationTypeEmail: {Subject: "Заказ {{.order}}", Message: "Здравствуйте, {{.name}}!"},
+	}
+	tmpl := &models.Template{ID: "tmpl-1", Name: "order", Version: 2, Channels: channels}
+	mockService.On("CreateTemplate", mock.Anything, &models.Template{Name: "order", Channels: channels}).Return(nil)
+	mockService.On("UpdateTemplate", mock.Anything, &models.Template{ID: "missing", Name: "order", Channels: channels}).Return(repository.ErrNotFound)
+	mockService.On("GetTemplate", mock.Anything, "tmpl-1", 2).Return(tmpl, nil)
+	mockService.On("ListTemplateVersions", mock.Anything, "tmpl-1").Return([]models.Template{*tmpl}, nil)
+	mockService.On("DeleteTemplate", mock.Anything, "tmpl-1").Return(nil)
+	mockService.On("DeleteTemplate", mock.Anything, "missing").Return(repository.ErrNotFound)
+
+	body := `{"name": "order", "channels": {"email": {"subject": "Заказ {{.order}}", "message": "Здравствуйте, {{.name}}!"}}}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/templates", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// ошибка синтаксиса шаблона
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/templates", strings.NewReader(`{"name": "order", "channels": {"email": {"message": "{{.name"}}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "channels.email.message")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/templates/missing", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/templates/tmpl-1?version=2", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got models.Template
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, *tmpl, got)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/templates/tmpl-1?version=latest", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/templates/tmpl-1/versions", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"versions"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/templates/tmpl-1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/templates/missing", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateNotificationHandlerTemplate(t *testing.T) {
+	mockService := new(MockNotificationService)
+	handler := &NotificationHandler{svc: mockService, channels: testChannels}
+	router := ginext.New()
+	router.POST("/notify", handler.create)
+
+	tmpl := &models.Template{ID: "tmpl-1", Name: "order", Version: 3, Channels: map[models.NotificationType]models.TemplateContent{
+		models.NotificationTypeEmail: {Subject: "Заказ {{.order}}", Message: "Здравствуйте, {{.name}}!"},
+	}}
+	mockService.On("ResolveTemplate", mock.Anything, mock.AnythingOfType("*models.CreateNotificationRequest")).
+		Run(func(args mock.Arguments) {
+			req := args.Get(1).(*models.CreateNotificationRequest)
+			req.Template, req.TemplateVersion = tmpl, tmpl.Version
+		}).Return(nil)
+	mockService.On("Create", mock.Anything, mock.MatchedBy(func(req *models.CreateNotificationRequest) bool {
+		return req.TemplateVersion == 3 && req.Message == ""
+	})).Return("test-notification-id", nil).Once()
+
+	scheduledAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/notify", strings.NewReader(`{"type": "email", "email": "test@example.com", "scheduled_at": "`+scheduledAt+`", "template_id": "tmpl-1", "variables": {"order": 42, "name": "Анна"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// переменной name нет — уведомление не создается
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/notify", strings.NewReader(`{"type": "email", "email": "test@example.com", "scheduled_at": "`+scheduledAt+`", "template_id": "tmpl-1", "variables": {"order": 42}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response struct {
+		Fields validation.Errors `json:"fields"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, validation.Errors{{Field: "variables", Message: `message: variable "name" is missing`}}, response.Fields)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// getPreferences хендлер, возвращающий настройки получателя канала.
+func (h *NotificationHandler) getPreferences(c *ginext.Context) {
+	channel := models.NotificationType(c.Param("type"))
+	if _, ok := h.channels.Get(channel); !ok {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "unknown channel"})
+		return
+	}
+
+	prefs, err := h.svc.GetPreferences(c.Request.Context(), channel, c.Param("recipient"))
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to get preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]any{"preferences": prefs})
+}
+
+// setPreference хендлер, подписывающий получателя канала на категорию уведомлений или отписывающий от нее.
+// Без категории настройка относится ко всем уведомлениям канала.
+func (h *NotificationHandler) setPreference(c *ginext.Context) {
+	channel := models.NotificationType(c.Param("type"))
+	if _, ok := h.channels.Get(channel); !ok {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "unknown channel"})
+		return
+	}
+	var req struct {
+		Category string `json:"category"`
+		Enabled  *bool  `json:"enabled"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.Enabled == nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": "invalid request"})
+		return
+	}
+
+	p := &models.Preference{Type: channel, Recipient: c.Param("recipient"), Category: req.Category, Enabled: *req.Enabled}
+	if err := validation.ValidatePreference(p); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse(err))
+		return
+	}
+	if err := h.svc.SetPreference(c.Request.Context(), p); err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to save preference"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// preview хендлер для предпросмотра уведомления.
+// Запрос проходит ту же валидацию, что и create, и рендерится отправителем канала,
+// но уведомление не сохраняется и не отправляется.
+func (h *NotificationHandler) preview(c *ginext.Context) {
+	var req models.CreateNotificationRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": "invalid request"})
+		return
+	}
+	if err := h.svc.ResolveRecipients(c.Request.Context(), &req); err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to resolve recipients"})
+		return
+	}
+	if err := h.svc.ResolveTemplate(c.Request.Context(), &req); err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to load template"})
+		return
+	}
+	if err := h.channels.Validate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse(err))
+		return
+	}
+
+	n, err := h.channels.Build(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	if err := h.svc.RenderTemplate(c.Request.Context(), n); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+		return
+	}
+	rendered, err := h.channels.Render(n)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
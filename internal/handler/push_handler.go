@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/pushtoken"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// PushHandler для управления подписками браузеров на Web Push
+type PushHandler struct {
+	subs      repository.PushSubscriptionRepository
+	publicKey string
+	signer    *pushtoken.Signer
+}
+
+// pushSubscriptionRequest тело запроса подписки: PushSubscription.toJSON() и токен подписчика.
+type pushSubscriptionRequest struct {
+	Token    string                      `json:"token"`
+	Endpoint string                      `json:"endpoint"`
+	Keys     models.PushSubscriptionKeys `json:"keys"`
+}
+
+// NewPushHandler создает обработчик подписок Web Push и регистрирует маршруты в /v1 и без версии.
+// publicKey — открытый ключ VAPID, который браузер передает в pushManager.subscribe.
+// signer проверяет токены подписчика; nil — маршруты подписок не регистрируются.
+func NewPushHandler(r *ginext.Engine, subs repository.PushSubscriptionRepository, publicKey string, signer *pushtoken.Signer) {
+	h := &PushHandler{subs: subs, publicKey: publicKey, signer: signer}
+
+	// актуальная версия API
+	h.registerV1(r.Group("/v1"))
+
+	// маршруты без версии оставлены для обратной совместимости
+	h.registerV1(r.Group("", deprecated(legacyDeprecatedAt, legacySunset, "/v1")))
+}
+
+// registerV1 регистрирует маршруты Web Push первой версии API в переданной группе. Без ключа
+// подписи токенов подписки не принимаются: иначе кто угодно мог бы подписать свой браузер
+// на уведомления чужого подписчика или удалить его подписки.
+func (h *PushHandler) registerV1(g *ginext.RouterGroup) {
+	g.GET("/push/vapid-public-key", h.vapidPublicKey)
+	if h.signer == nil {
+		return
+	}
+	g.POST("/push/subscriptions", h.subscribe)
+	g.DELETE("/push/subscriptions", h.unsubscribe)
+}
+
+// vapidPublicKey хендлер, возвращающий открытый ключ VAPID для подписки.
+func (h *PushHandler) vapidPublicKey(c *ginext.Context) {
+	if h.publicKey == "" {
+		c.JSON(http.StatusServiceUnavailable, map[string]any{"error": "web push is not configured"})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]any{"public_key": h.publicKey})
+}
+
+// subscribe хендлер для сохранения подписки браузера. Подписчик берется из токена,
+// выданного бэкендом приложения, а не из тела запроса.
+func (h *PushHandler) subscribe(c *ginext.Context) {
+	var req pushSubscriptionRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": "invalid request"})
+		return
+	}
+	subscriber, err := h.signer.Parse(req.Token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, map[string]any{"error": "invalid token"})
+		return
+	}
+	sub := models.PushSubscription{Subscriber: subscriber, Endpoint: req.Endpoint, Keys: req.Keys}
+	if err := validation.ValidatePushSubscription(&sub); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse(err))
+		return
+	}
+	if err := h.subs.Save(c.Request.Context(), &sub); err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to save subscription"})
+		return
+	}
+	c.JSON(http.StatusCreated, sub)
+}
+
+// unsubscribe хендлер для удаления подписки по endpoint. Удалить можно только подписку
+// подписчика из токена.
+func (h *PushHandler) unsubscribe(c *ginext.Context) {
+	subscriber, err := h.signer.Parse(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, map[string]any{"error": "invalid token"})
+		return
+	}
+	endpoint := c.Query("endpoint")
+	if endpoint == "" {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": "endpoint is required"})
+		return
+	}
+	err = h.subs.Delete(c.Request.Context(), subscriber, endpoint)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "subscription not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to delete subscription"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
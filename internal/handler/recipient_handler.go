@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/wb-go/wbf/ginext"
+)
+
+const (
+	// defaultPageLimit размер страницы по умолчанию
+	defaultPageLimit = 50
+	// maxPageLimit максимальный размер страницы
+	maxPageLimit = 500
+)
+
+// recipientNotifications хендлер для получения страницы уведомлений получателя канала и сводки по статусам.
+// Сводка учитывает фильтры периода (from, to), но не фильтр status.
+func (h *NotificationHandler) recipientNotifications(c *ginext.Context) {
+	channel := models.NotificationType(c.Param("type"))
+	if _, ok := h.channels.Get(channel); !ok {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "unknown channel"})
+		return
+	}
+	recipient := c.Param("recipient")
+
+	filter, err := h.parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	filter.Limit, filter.Offset, err = parsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	filter.Type = channel
+	filter.Recipient = recipient
+
+	ctx := c.Request.Context()
+	summaryFilter := filter
+	summaryFilter.Status = ""
+	summary, err := h.svc.CountByStatus(ctx, summaryFilter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	resp := models.RecipientNotificationsResponse{
+		Channel:   channel,
+		Recipient: recipient,
+		Items:     []models.NotificationResponse{},
+		Limit:     filter.Limit,
+		Offset:    filter.Offset,
+		Summary:   summary,
+	}
+	for status, count := range summary {
+		if filter.Status == "" || filter.Status == status {
+			resp.Total += count
+		}
+	}
+	if resp.Total == 0 {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	notifications, err := h.svc.GetAll(ctx, filter)
+	if err != nil && err != repository.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	for _, n := range notifications {
+		resp.Items = append(resp.Items, h.channels.Describe(n))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// parsePage читает параметры пагинации limit и offset.
+func parsePage(c *ginext.Context) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, fmt.Errorf("invalid limit: expected 1..%d", maxPageLimit)
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("invalid offset: expected non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
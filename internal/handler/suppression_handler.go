@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// getSuppression хендлер, возвращающий запись списка подавления получателя канала.
+func (h *NotificationHandler) getSuppression(c *ginext.Context) {
+	channel := models.NotificationType(c.Param("type"))
+	if _, ok := h.channels.Get(channel); !ok {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "unknown channel"})
+		return
+	}
+
+	sup, err := h.svc.GetSuppression(c.Request.Context(), channel, c.Param("recipient"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "recipient is not suppressed"})
+		return
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to get suppression"})
+		return
+	}
+	c.JSON(http.StatusOK, sup)
+}
+
+// deleteSuppression хендлер, удаляющий получателя из списка подавления,
+// например после того как пользователь снова разрешил боту писать.
+func (h *NotificationHandler) deleteSuppression(c *ginext.Context) {
+	channel := models.NotificationType(c.Param("type"))
+	if _, ok := h.channels.Get(channel); !ok {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "unknown channel"})
+		return
+	}
+
+	err := h.svc.Unsuppress(c.Request.Context(), channel, c.Param("recipient"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "recipient is not suppressed"})
+		return
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to delete suppression"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
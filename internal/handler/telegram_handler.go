@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/validation"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// telegramSecretHeader заголовок, в котором Bot API передает secret_token вебхука.
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// TelegramHandler связывает чаты Telegram с пользователями клиента и принимает обновления бота.
+type TelegramHandler struct {
+	svc           service.TelegramLinkService
+	bot           *service.TelegramBot
+	botUsername   string
+	webhookSecret string
+}
+
+// NewTelegramHandler создает обработчик и регистрирует маршруты в /v1/telegram.
+// bot обрабатывает обновления, пришедшие на вебхук; nil — вебхук не регистрируется
+// (например, обновления получает воркер через getUpdates). Без секрета вебхук тоже не регистрируется:
+// иначе кто угодно мог бы подделать /stop или /start и менять список подавления.
+// botUsername нужен для ссылок t.me.
+func NewTelegramHandler(r *ginext.Engine, svc service.TelegramLinkService, bot *service.TelegramBot, botUsername, webhookSecret string) {
+	h := &TelegramHandler{svc: svc, bot: bot, botUsername: botUsername, webhookSecret: webhookSecret}
+	g := r.Group("/v1/telegram")
+	g.POST("/links", h.createLink)
+	g.GET("/links/:user_id", h.getLink)
+	g.DELETE("/links/:user_id", h.deleteLink)
+	if bot != nil && webhookSecret != "" {
+		g.POST("/webhook", h.webhook)
+	}
+}
+
+// createLink хендлер, выдающий пользователю ссылку https://t.me/<bot>?start=<token>.
+// Пользователь открывает ссылку, нажимает «Start», и бот связывает его чат с user_id.
+func (h *TelegramHandler) createLink(c *ginext.Context) {
+	var req models.TelegramLinkToken
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": "invalid request"})
+		return
+	}
+	if err := validation.ValidateTelegramLinkToken(&req); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse(err))
+		return
+	}
+
+	t, err := h.svc.CreateTelegramLinkToken(c.Request.Context(), req.UserID)
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to create link"})
+		return
+	}
+	if h.botUsername != "" {
+		t.URL = "https://t.me/" + h.botUsername + "?start=" + t.Token
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// getLink хендлер, возвращающий чат Telegram пользователя.
+func (h *TelegramHandler) getLink(c *ginext.Context) {
+	link, err := h.svc.GetTelegramLink(c.Request.Context(), c.Param("user_id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "telegram chat is not linked"})
+		return
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to get link"})
+		return
+	}
+	c.JSON(http.StatusOK, link)
+}
+
+// deleteLink хендлер, отвязывающий чат Telegram от пользователя.
+func (h *TelegramHandler) deleteLink(c *ginext.Context) {
+	err := h.svc.DeleteTelegramLink(c.Request.Context(), c.Param("user_id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "telegram chat is not linked"})
+		return
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to delete link"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// webhook хендлер для обновлений, которые Bot API отправляет на адрес, заданный setWebhook.
+// Запросы без верного X-Telegram-Bot-Api-Secret-Token отклоняются.
+// Ответ не 2xx заставляет Bot API повторить доставку обновления.
+func (h *TelegramHandler) webhook(c *ginext.Context) {
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader(telegramSecretHeader)), []byte(h.webhookSecret)) != 1 {
+		c.JSON(http.StatusUnauthorized, map[string]any{"error": "invalid secret token"})
+		return
+	}
+	var u models.TelegramUpdate
+	if err := c.BindJSON(&u); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": "invalid request"})
+		return
+	}
+	if err := h.bot.HandleUpdate(c.Request.Context(), &u); err != nil {
+		log.Printf("telegram bot: failed to handle update %d: %v", u.UpdateID, err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to handle update"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// templateRequest тело запроса создания и изменения шаблона.
+type templateRequest struct {
+	Name     string                                             `json:"name"`
+	Channels map[models.NotificationType]models.TemplateContent `json:"channels"`
+}
+
+// bindTemplate читает и проверяет шаблон из тела запроса. При ошибке ответ уже отправлен.
+func (h *NotificationHandler) bindTemplate(c *ginext.Context) (*models.Template, bool) {
+	var req templateRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]any{"error": "invalid request"})
+		return nil, false
+	}
+	t := &models.Template{Name: req.Name, Channels: req.Channels}
+	if err := h.channels.ValidateTemplate(t); err != nil {
+		c.JSON(http.StatusBadRequest, validationErrorResponse(err))
+		return nil, false
+	}
+	return t, true
+}
+
+// createTemplate хендлер, сохраняющий новый шаблон сообщения (версия 1).
+func (h *NotificationHandler) createTemplate(c *ginext.Context) {
+	t, ok := h.bindTemplate(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.CreateTemplate(c.Request.Context(), t); err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to create template"})
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// updateTemplate хендлер, сохраняющий новую версию шаблона. Уведомления, созданные
+// по прежним версиям, отправляются с прежним текстом.
+func (h *NotificationHandler) updateTemplate(c *ginext.Context) {
+	t, ok := h.bindTemplate(c)
+	if !ok {
+		return
+	}
+	t.ID = c.Param("id")
+	err := h.svc.UpdateTemplate(c.Request.Context(), t)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to update template"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// getTemplate хендлер, возвращающий последнюю версию шаблона или версию из параметра version.
+func (h *NotificationHandler) getTemplate(c *ginext.Context) {
+	version := 0
+	if v := c.Query("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, map[string]any{"error": "invalid version"})
+			return
+		}
+		version = n
+	}
+
+	t, err := h.svc.GetTemplate(c.Request.Context(), c.Param("id"), version)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to get template"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// listTemplates хендлер, возвращающий последние версии всех шаблонов.
+func (h *NotificationHandler) listTemplates(c *ginext.Context) {
+	templates, err := h.svc.ListTemplates(c.Request.Context())
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to get templates"})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]any{"templates": templates})
+}
+
+// templateVersions хендлер, возвращающий все версии шаблона, начиная с последней.
+func (h *NotificationHandler) templateVersions(c *ginext.Context) {
+	versions, err := h.svc.ListTemplateVersions(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to get template versions"})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]any{"versions": versions})
+}
+
+// deleteTemplate хендлер, удаляющий шаблон. Новые уведомления на него ссылаться не могут,
+// а уже созданные отправляются по своей версии шаблона.
+func (h *NotificationHandler) deleteTemplate(c *ginext.Context) {
+	err := h.svc.DeleteTemplate(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, map[string]any{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to delete template"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/unsubscribe"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// unsubscribePage страница отписки. Переход по ссылке только показывает форму: ссылки в письмах
+// открывают антивирусы и превью, поэтому отписка выполняется POST-запросом (RFC 8058).
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><title>Отписка от уведомлений</title></head>
+<body>
+{{- if .Done}}
+<p>Вы отписались{{if .Category}} от рассылки «{{.Category}}»{{end}}. Уведомления на {{.Recipient}} больше не придут.</p>
+{{- else if .Token}}
+<p>Отписать {{.Recipient}} от {{if .Category}}рассылки «{{.Category}}»{{else}}всех уведомлений{{end}}?</p>
+<form method="post" action="?token={{.Token}}">
+<input type="hidden" name="List-Unsubscribe" value="One-Click">
+<button type="submit">Отписаться</button>
+</form>
+{{- else}}
+<p>Ссылка для отписки недействительна.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// unsubscribeView данные страницы отписки.
+type unsubscribeView struct {
+	*models.Preference
+	Token string
+	Done  bool
+}
+
+// UnsubscribeHandler отписывает получателя по подписанной ссылке из письма.
+type UnsubscribeHandler struct {
+	svc    service.PreferenceService
+	signer *unsubscribe.Signer
+}
+
+// NewUnsubscribeHandler создает обработчик и регистрирует маршруты GET и POST /v1/unsubscribe?token=<token>.
+// Без signer (не задан ключ подписи) маршруты не регистрируются.
+func NewUnsubscribeHandler(r *ginext.Engine, svc service.PreferenceService, signer *unsubscribe.Signer) {
+	if signer == nil {
+		return
+	}
+	h := &UnsubscribeHandler{svc: svc, signer: signer}
+	r.GET(unsubscribe.Path, h.confirm)
+	r.POST(unsubscribe.Path, h.unsubscribe)
+}
+
+// confirm хендлер, показывающий страницу с подтверждением отписки.
+func (h *UnsubscribeHandler) confirm(c *ginext.Context) {
+	token := c.Query("token")
+	p, err := h.signer.Parse(token)
+	if err != nil {
+		h.render(c, http.StatusBadRequest, unsubscribeView{})
+		return
+	}
+	h.render(c, http.StatusOK, unsubscribeView{Preference: p, Token: token})
+}
+
+// unsubscribe хендлер отписки: запрос формы со страницы или One-Click POST почтового клиента
+// с телом List-Unsubscribe=One-Click. Повторная отписка безопасна.
+func (h *UnsubscribeHandler) unsubscribe(c *ginext.Context) {
+	p, err := h.signer.Parse(c.Query("token"))
+	if err != nil {
+		h.render(c, http.StatusBadRequest, unsubscribeView{})
+		return
+	}
+	p.Enabled = false
+	if err := h.svc.SetPreference(c.Request.Context(), p); err != nil {
+		log.Printf("err: %v\n", err)
+		c.JSON(http.StatusInternalServerError, map[string]any{"error": "failed to unsubscribe"})
+		return
+	}
+	h.render(c, http.StatusOK, unsubscribeView{Preference: p, Done: true})
+}
+
+// render отдает страницу отписки.
+func (h *UnsubscribeHandler) render(c *ginext.Context, status int, v unsubscribeView) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := unsubscribePage.Execute(c.Writer, v); err != nil {
+		log.Printf("err: %v\n", err)
+	}
+}
@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// Format формат файла импорта.
+type Format string
+
+const (
+	// FormatCSV CSV с заголовком, колонки совпадают с полями CreateNotificationRequest
+	FormatCSV Format = "csv"
+	// FormatNDJSON по одному JSON-объекту CreateNotificationRequest на строку
+	FormatNDJSON Format = "ndjson"
+)
+
+// ErrUnknownFormat возвращается, если формат файла не удалось определить.
+var ErrUnknownFormat = errors.New("unknown import format, expected csv or ndjson")
+
+// maxLineSize максимальный размер одной строки NDJSON.
+const maxLineSize = 1 << 20
+
+// csvColumns поддерживаемые колонки CSV (совпадают с JSON-именами полей CreateNotificationRequest).
+var csvColumns = map[string]bool{
+	"type":         true,
+	"email":        true,
+	"chat_id":      true,
+	"phone":        true,
+	"message":      true,
+	"subject":      true,
+	"scheduled_at": true,
+	"template_id":  true,
+	"variables":    true,
+}
+
+// ParseFormat определяет формат по явному значению, Content-Type или имени файла.
+func ParseFormat(format, contentType, filename string) (Format, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	case "":
+	default:
+		return "", ErrUnknownFormat
+	}
+
+	switch strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0])) {
+	case "text/csv":
+		return FormatCSV, nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	}
+
+	return "", ErrUnknownFormat
+}
+
+// Row строка файла импорта.
+type Row struct {
+	Line    int
+	Raw     string
+	Request *models.CreateNotificationRequest
+	// Err ошибка разбора строки; Request в этом случае nil
+	Err error
+}
+
+// Decode последовательно читает строки файла и передает их в fn.
+// Ошибки разбора отдельных строк передаются в Row.Err, ошибка fn прерывает чтение.
+func Decode(r io.Reader, format Format, fn func(row Row) error) error {
+	switch format {
+	case FormatCSV:
+		return decodeCSV(r, fn)
+	case FormatNDJSON:
+		return decodeNDJSON(r, fn)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+func decodeCSV(r io.Reader, fn func(row Row) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read csv header: %w", err)
+	}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !csvColumns[name] {
+			return fmt.Errorf("unknown csv column %q", name)
+		}
+		header[i] = name
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		row := Row{Raw: csvLine(record)}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return fmt.Errorf("failed to read csv: %w", err)
+			}
+			row.Line = parseErr.StartLine
+			row.Err = err
+		} else {
+			row.Line, _ = cr.FieldPos(0)
+			row.Request, row.Err = csvRequest(header, record)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// csvLine кодирует запись обратно в строку CSV: поля с запятыми, кавычками и переводами строк
+// снова берутся в кавычки, поэтому строку из файла ошибок можно исправить и импортировать повторно.
+func csvLine(record []string) string {
+	var b strings.Builder
+	cw := csv.NewWriter(&b)
+	cw.Write(record)
+	cw.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func csvRequest(header, record []string) (*models.CreateNotificationRequest, error) {
+	if len(record) != len(header) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(header), len(record))
+	}
+	req := &models.CreateNotificationRequest{}
+	for i, name := range header {
+		value := record[i]
+		switch name {
+		case "type":
+			req.Type = models.NotificationType(strings.TrimSpace(value))
+		case "email":
+			req.Email = strings.TrimSpace(value)
+		case "chat_id":
+			req.ChatID = strings.TrimSpace(value)
+		case "phone":
+			req.Phone = strings.TrimSpace(value)
+		case "message":
+			req.Message = value
+		case "subject":
+			req.Subject = value
+		case "scheduled_at":
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid scheduled_at %q: expected RFC3339", value)
+			}
+			req.ScheduledAt = t
+		case "template_id":
+			req.TemplateID = strings.TrimSpace(value)
+		case "variables":
+			// переменные шаблона — JSON-объект
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			if err := json.Unmarshal([]byte(value), &req.Variables); err != nil {
+				return nil, errors.New("invalid variables: expected JSON object")
+			}
+		}
+	}
+	return req, nil
+}
+
+func decodeNDJSON(r io.Reader, fn func(row Row) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	line := 0
+	for sc.Scan() {
+		line++
+		raw := bytes.TrimSpace(sc.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		row := Row{Line: line, Raw: string(raw)}
+		var req models.CreateNotificationRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			row.Err = fmt.Errorf("invalid json: %w", err)
+		} else {
+			row.Request = &req
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read ndjson: %w", err)
+	}
+	return nil
+}
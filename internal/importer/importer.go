@@ -0,0 +1,290 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/statuscache"
+	"github.com/google/uuid"
+)
+
+// JobStatus статус задачи импорта
+type JobStatus string
+
+const (
+	// JobStatusPending задача создана, но еще не запущена
+	JobStatusPending JobStatus = "pending"
+	// JobStatusRunning задача выполняется
+	JobStatusRunning JobStatus = "running"
+	// JobStatusDone все строки обработаны
+	JobStatusDone JobStatus = "done"
+	// JobStatusFailed импорт прерван из-за ошибки чтения файла или БД
+	JobStatusFailed JobStatus = "failed"
+)
+
+const (
+	// jobTTL время хранения завершенных задач в памяти.
+	jobTTL = 24 * time.Hour
+	// maxJobs сколько задач хранится в памяти; при переполнении удаляются самые старые завершенные.
+	maxJobs = 1000
+)
+
+// ErrJobNotFound возвращается, если задача импорта не найдена.
+var ErrJobNotFound = errors.New("import job not found")
+
+// RowError отклоненная строка файла импорта.
+type RowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+	Raw   string `json:"raw"`
+}
+
+// Job задача импорта и ее прогресс.
+type Job struct {
+	ID         string     `json:"id"`
+	Format     Format     `json:"format"`
+	Status     JobStatus  `json:"status"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Created    int        `json:"created"`
+	Rejected   int        `json:"rejected"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	RowErrors  []RowError `json:"-"`
+}
+
+// Importer создает уведомления из CSV/NDJSON файлов.
+// Каждая строка проходит ту же валидацию, что и POST /notify. Задачи хранятся только в памяти
+// процесса (не дольше jobTTL после завершения и не больше maxJobs) и теряются при перезапуске.
+type Importer struct {
+	svc         service.NotificationService
+	channels    *channel.Registry
+	statusCache *statuscache.Cache
+
+	mu   sync.RWMutex
+	jobs map[string]*Job
+}
+
+// NewImporter создает новый экземпляр Importer. statusCache может быть nil.
+func NewImporter(svc service.NotificationService, channels *channel.Registry, cache *statuscache.Cache) *Importer {
+	return &Importer{svc: svc, channels: channels, statusCache: cache, jobs: make(map[string]*Job)}
+}
+
+// Import синхронно импортирует файл и возвращает итоговый отчет.
+func (im *Importer) Import(ctx context.Context, r io.Reader, format Format) *Job {
+	job := im.newJob(format)
+	im.run(ctx, job, r)
+	return im.snapshot(job)
+}
+
+// Start запускает импорт в фоне и сразу возвращает задачу.
+// Файл копируется во временный файл, поэтому r можно закрыть после возврата.
+func (im *Importer) Start(r io.Reader, format Format) (*Job, error) {
+	tmp, err := os.CreateTemp("", "notify-import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	job := im.newJob(format)
+	go func() {
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			im.finish(job, fmt.Errorf("failed to rewind upload: %w", err))
+			return
+		}
+		total, err := countRows(tmp, format)
+		if err == nil {
+			im.update(job, func(j *Job) { j.Total = total })
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			im.finish(job, fmt.Errorf("failed to rewind upload: %w", err))
+			return
+		}
+		im.run(context.Background(), job, tmp)
+	}()
+	return im.snapshot(job), nil
+}
+
+// Job возвращает копию задачи импорта по ID.
+func (im *Importer) Job(id string) (*Job, error) {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+	job, ok := im.jobs[id]
+	if !ok || expired(job, time.Now()) {
+		return nil, ErrJobNotFound
+	}
+	return copyJob(job), nil
+}
+
+// WriteErrors записывает отклоненные строки задачи в CSV (line, error, raw).
+func WriteErrors(w io.Writer, job *Job) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"line", "error", "raw"}); err != nil {
+		return err
+	}
+	for _, e := range job.RowErrors {
+		if err := cw.Write([]string{strconv.Itoa(e.Line), e.Error, e.Raw}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (im *Importer) run(ctx context.Context, job *Job, r io.Reader) {
+	im.update(job, func(j *Job) { j.Status = JobStatusRunning })
+
+	err := Decode(r, job.Format, func(row Row) error {
+		if row.Err == nil {
+			row.Err = im.resolve(ctx, row.Request)
+			// недоступность БД не связана с содержимым строки — прерываем импорт
+			if errors.Is(row.Err, repository.ErrUnavailable) {
+				return fmt.Errorf("line %d: %w", row.Line, row.Err)
+			}
+		}
+		if row.Err != nil {
+			im.update(job, func(j *Job) {
+				j.Processed++
+				j.Rejected++
+				j.RowErrors = append(j.RowErrors, RowError{Line: row.Line, Error: row.Err.Error(), Raw: row.Raw})
+			})
+			return nil
+		}
+
+		id, err := im.svc.Create(ctx, row.Request)
+		if err != nil {
+			// ошибка БД не связана с содержимым строки — прерываем импорт
+			return fmt.Errorf("line %d: failed to create notification: %w", row.Line, err)
+		}
+		if im.statusCache != nil {
+			if err := im.statusCache.SetStatus(ctx, id, models.StatusScheduled); err != nil {
+				log.Printf("failed to set status in redis for id=%v: %v", id, err)
+			}
+		}
+		im.update(job, func(j *Job) {
+			j.Processed++
+			j.Created++
+		})
+		return nil
+	})
+	im.finish(job, err)
+}
+
+// resolve подставляет в строку чат пользователя и шаблон и проверяет ее. Ошибка, относящаяся
+// к самой строке (например, испорченный шаблон), отклоняет только ее.
+func (im *Importer) resolve(ctx context.Context, req *models.CreateNotificationRequest) error {
+	if err := im.svc.ResolveRecipients(ctx, req); err != nil {
+		return fmt.Errorf("failed to resolve recipients: %w", err)
+	}
+	if err := im.svc.ResolveTemplate(ctx, req); err != nil {
+		return fmt.Errorf("failed to load template: %w", err)
+	}
+	return im.channels.Validate(req)
+}
+
+func (im *Importer) newJob(format Format) *Job {
+	job := &Job{
+		ID:        uuid.NewString(),
+		Format:    format,
+		Status:    JobStatusPending,
+		StartedAt: time.Now(),
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.evict(job.StartedAt)
+	im.jobs[job.ID] = job
+	return job
+}
+
+// evict удаляет давно завершенные задачи, а если задач все равно maxJobs или больше — самые старые
+// из завершенных, чтобы не копить их в памяти. Выполняющиеся задачи не удаляются. Вызывается под mu.
+func (im *Importer) evict(now time.Time) {
+	var finished []*Job
+	for id, j := range im.jobs {
+		switch {
+		case expired(j, now):
+			delete(im.jobs, id)
+		case j.FinishedAt != nil:
+			finished = append(finished, j)
+		}
+	}
+	if excess := len(im.jobs) - maxJobs + 1; excess > 0 {
+		sort.Slice(finished, func(a, b int) bool { return finished[a].FinishedAt.Before(*finished[b].FinishedAt) })
+		for _, j := range finished[:min(excess, len(finished))] {
+			delete(im.jobs, j.ID)
+		}
+	}
+}
+
+// expired сообщает, что задача завершилась больше jobTTL назад.
+func expired(j *Job, now time.Time) bool {
+	return j.FinishedAt != nil && now.Sub(*j.FinishedAt) > jobTTL
+}
+
+func (im *Importer) finish(job *Job, err error) {
+	im.update(job, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		j.Status = JobStatusDone
+		if err != nil {
+			j.Status = JobStatusFailed
+			j.Error = err.Error()
+		}
+		if j.Total < j.Processed {
+			j.Total = j.Processed
+		}
+	})
+	if err != nil {
+		log.Printf("import %v failed: %v", job.ID, err)
+	}
+}
+
+func (im *Importer) update(job *Job, fn func(j *Job)) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	fn(job)
+}
+
+func (im *Importer) snapshot(job *Job) *Job {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+	return copyJob(job)
+}
+
+func copyJob(job *Job) *Job {
+	cp := *job
+	cp.RowErrors = append([]RowError(nil), job.RowErrors...)
+	return &cp
+}
+
+// countRows считает строки файла, чтобы показывать прогресс импорта.
+func countRows(r io.Reader, format Format) (int, error) {
+	total := 0
+	err := Decode(r, format, func(Row) error {
+		total++
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,209 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testChannels реестр встроенных каналов без отправителей.
+var testChannels = channel.NewRegistry(channel.Email(nil), channel.Telegram(nil))
+
+// mockNotificationService мок сервиса уведомлений; реализованы только Create, ResolveRecipients и ResolveTemplate.
+type mockNotificationService struct {
+	service.NotificationService
+	mock.Mock
+	// templateErrs ошибки загрузки шаблонов по template_id
+	templateErrs map[string]error
+}
+
+func (m *mockNotificationService) Create(ctx context.Context, req *models.CreateNotificationRequest) (string, error) {
+	args := m.Called(ctx, req)
+	return args.String(0), args.Error(1)
+}
+
+// ResolveRecipients ничего не делает: получатели в тестах импорта заданы явно.
+func (m *mockNotificationService) ResolveRecipients(ctx context.Context, req *models.CreateNotificationRequest) error {
+	return nil
+}
+
+// ResolveTemplate возвращает ошибку из templateErrs; шаблоны в тестах импорта не подставляются.
+func (m *mockNotificationService) ResolveTemplate(ctx context.Context, req *models.CreateNotificationRequest) error {
+	return m.templateErrs[req.TemplateID]
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("", "text/csv; charset=utf-8", "")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatCSV, f)
+
+	f, err = ParseFormat("", "application/octet-stream", "reminders.ndjson")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatNDJSON, f)
+
+	_, err = ParseFormat("xml", "", "")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestImporterImportCSV(t *testing.T) {
+	svc := new(mockNotificationService)
+	svc.On("Create", mock.Anything, mock.AnythingOfType("*models.CreateNotificationRequest")).Return("id-1", nil).Once()
+
+	scheduledAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	file := "type,email,chat_id,subject,message,scheduled_at\n" +
+		"email,test@example.com,,Hello,Test message," + scheduledAt + "\n" +
+		"telegram,,,,Test message," + scheduledAt + "\n" +
+		"email,test@example.com,,Hello,Test message,tomorrow\n"
+
+	job := NewImporter(svc, testChannels, nil).Import(context.Background(), strings.NewReader(file), FormatCSV)
+
+	assert.Equal(t, JobStatusDone, job.Status)
+	assert.Equal(t, 3, job.Processed)
+	assert.Equal(t, 1, job.Created)
+	assert.Equal(t, 2, job.Rejected)
+	if assert.Len(t, job.RowErrors, 2) {
+		assert.Equal(t, 3, job.RowErrors[0].Line)
+		assert.Equal(t, "chat_id is required for telegram notifications", job.RowErrors[0].Error)
+		assert.Equal(t, 4, job.RowErrors[1].Line)
+		assert.Contains(t, job.RowErrors[1].Error, "invalid scheduled_at")
+	}
+	svc.AssertExpectations(t)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteErrors(&buf, job))
+	assert.True(t, strings.HasPrefix(buf.String(), "line,error,raw\n3,"))
+}
+
+func TestImporterCSVRowErrorKeepsQuoting(t *testing.T) {
+	file := "type,email,message,scheduled_at\n" +
+		`email,test@example.com,"Hello, ""Anna""",tomorrow` + "\n"
+
+	job := NewImporter(new(mockNotificationService), testChannels, nil).Import(context.Background(), strings.NewReader(file), FormatCSV)
+
+	// отклоненная строка в файле ошибок совпадает с исходной, а не склеена через запятую
+	if assert.Len(t, job.RowErrors, 1) {
+		assert.Equal(t, `email,test@example.com,"Hello, ""Anna""",tomorrow`, job.RowErrors[0].Raw)
+	}
+}
+
+func TestImporterTemplateErrors(t *testing.T) {
+	svc := &mockNotificationService{templateErrs: map[string]error{
+		"broken": errors.New("error decoding template channels: unexpected end of JSON input"),
+		"down":   fmt.Errorf("error getting template: %w: connection refused", repository.ErrUnavailable),
+	}}
+	svc.On("Create", mock.Anything, mock.AnythingOfType("*models.CreateNotificationRequest")).Return("id-1", nil).Once()
+
+	scheduledAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	file := `{"type":"telegram","chat_id":"42","template_id":"broken","scheduled_at":"` + scheduledAt + `"}` + "\n" +
+		`{"type":"telegram","chat_id":"42","message":"Test message","scheduled_at":"` + scheduledAt + `"}` + "\n" +
+		`{"type":"telegram","chat_id":"42","template_id":"down","scheduled_at":"` + scheduledAt + `"}` + "\n" +
+		`{"type":"telegram","chat_id":"42","message":"Test message","scheduled_at":"` + scheduledAt + `"}` + "\n"
+
+	job := NewImporter(svc, testChannels, nil).Import(context.Background(), strings.NewReader(file), FormatNDJSON)
+
+	// испорченный шаблон отклоняет строку, недоступная БД прерывает импорт
+	assert.Equal(t, JobStatusFailed, job.Status)
+	assert.Contains(t, job.Error, "line 3")
+	assert.Equal(t, 1, job.Created)
+	if assert.Len(t, job.RowErrors, 1) {
+		assert.Equal(t, 1, job.RowErrors[0].Line)
+		assert.Contains(t, job.RowErrors[0].Error, "failed to load template")
+	}
+	svc.AssertExpectations(t)
+}
+
+func TestImporterEvictsJobs(t *testing.T) {
+	imp := NewImporter(new(mockNotificationService), testChannels, nil)
+	now := time.Now()
+
+	expiredAt := now.Add(-jobTTL - time.Minute)
+	imp.jobs["expired"] = &Job{ID: "expired", FinishedAt: &expiredAt}
+	_, err := imp.Job("expired")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	// при переполнении удаляются самые старые завершенные задачи, выполняющиеся остаются
+	imp.jobs["running"] = &Job{ID: "running", Status: JobStatusRunning}
+	for i := 1; i < maxJobs; i++ {
+		finishedAt := now.Add(time.Duration(i) * time.Second)
+		id := fmt.Sprintf("job-%d", i)
+		imp.jobs[id] = &Job{ID: id, FinishedAt: &finishedAt}
+	}
+	job := imp.newJob(FormatCSV)
+
+	assert.Len(t, imp.jobs, maxJobs)
+	assert.NotContains(t, imp.jobs, "expired")
+	assert.NotContains(t, imp.jobs, "job-1")
+	assert.Contains(t, imp.jobs, "job-2")
+	assert.Contains(t, imp.jobs, "running")
+	assert.Contains(t, imp.jobs, job.ID)
+}
+
+func TestCSVRequestTemplate(t *testing.T) {
+	header := []string{"type", "email", "template_id", "variables"}
+
+	req, err := csvRequest(header, []string{"email", "test@example.com", " tmpl-1 ", `{"name":"Anna","count":3}`})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "tmpl-1", req.TemplateID)
+	assert.Equal(t, map[string]any{"name": "Anna", "count": float64(3)}, req.Variables)
+
+	_, err = csvRequest(header, []string{"email", "test@example.com", "tmpl-1", "name=Anna"})
+	assert.EqualError(t, err, "invalid variables: expected JSON object")
+}
+
+func TestImporterImportNDJSON(t *testing.T) {
+	svc := new(mockNotificationService)
+	svc.On("Create", mock.Anything, mock.AnythingOfType("*models.CreateNotificationRequest")).Return("id-1", nil).Once()
+
+	scheduledAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	file := `{"type":"telegram","chat_id":"42","message":"Test message","scheduled_at":"` + scheduledAt + `"}` + "\n" +
+		"\n" +
+		`{"type":"telegram",` + "\n"
+
+	job := NewImporter(svc, testChannels, nil).Import(context.Background(), strings.NewReader(file), FormatNDJSON)
+
+	assert.Equal(t, JobStatusDone, job.Status)
+	assert.Equal(t, 1, job.Created)
+	assert.Equal(t, 1, job.Rejected)
+	if assert.Len(t, job.RowErrors, 1) {
+		assert.Equal(t, 3, job.RowErrors[0].Line)
+	}
+	svc.AssertExpectations(t)
+}
+
+func TestImporterStartAsync(t *testing.T) {
+	svc := new(mockNotificationService)
+	svc.On("Create", mock.Anything, mock.Anything).Return("", errors.New("db is down"))
+
+	scheduledAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	file := `{"type":"telegram","chat_id":"42","message":"Test message","scheduled_at":"` + scheduledAt + `"}` + "\n"
+
+	imp := NewImporter(svc, testChannels, nil)
+	job, err := imp.Start(strings.NewReader(file), FormatNDJSON)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		j, err := imp.Job(job.ID)
+		return err == nil && j.FinishedAt != nil
+	}, time.Second, 10*time.Millisecond)
+
+	j, _ := imp.Job(job.ID)
+	assert.Equal(t, JobStatusFailed, j.Status)
+	assert.Equal(t, 1, j.Total)
+	assert.Contains(t, j.Error, "db is down")
+
+	_, err = imp.Job("unknown")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_notifications_scheduled_at;
+DROP TRIGGER IF EXISTS record_notification_status ON notifications;
+DROP FUNCTION IF EXISTS record_notification_status();
+DROP TABLE IF EXISTS notification_status_history;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS notification_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    notification_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE
+);
+
+-- Функция для записи смены статуса в историю
+CREATE OR REPLACE FUNCTION record_notification_status()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR NEW.status IS DISTINCT FROM OLD.status THEN
+        INSERT INTO notification_status_history (notification_id, status)
+        VALUES (NEW.id, NEW.status);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Триггер для автоматического ведения истории статусов
+CREATE OR REPLACE TRIGGER record_notification_status
+AFTER INSERT OR UPDATE OF status ON notifications
+FOR EACH ROW
+EXECUTE FUNCTION record_notification_status();
+
+-- Текущий статус существующих уведомлений становится первой записью истории
+INSERT INTO notification_status_history (notification_id, status, changed_at)
+SELECT id, status, updated_at FROM notifications;
+
+CREATE INDEX IF NOT EXISTS idx_notification_status_history_notification_id ON notification_status_history (notification_id, changed_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_scheduled_at ON notifications (scheduled_at);
@@ -0,0 +1,6 @@
+-- Данные каналов, которым не нужна собственная таблица (repository.PayloadStorage)
+CREATE TABLE IF NOT EXISTS notification_payloads (
+    notification_id UUID PRIMARY KEY,
+    payload JSONB NOT NULL,
+    FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE
+);
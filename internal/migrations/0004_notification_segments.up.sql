@@ -0,0 +1,2 @@
+-- Количество тарифицируемых сегментов (SMS), записывается воркером после отправки
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS segments INTEGER;
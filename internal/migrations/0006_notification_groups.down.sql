@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notification_groups;
+DROP INDEX IF EXISTS idx_notifications_parent_id;
+DELETE FROM notifications WHERE parent_id IS NOT NULL OR type = 'multi';
+ALTER TABLE notifications DROP COLUMN IF EXISTS position, DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,14 @@
+-- Уведомление с несколькими каналами: родитель (type = 'multi') и дочерние уведомления по каналам
+ALTER TABLE notifications
+    ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES notifications(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS position INT;
+
+-- Режим доставки группы: all — во все каналы, fallback — по очереди до первой успешной отправки
+CREATE TABLE IF NOT EXISTS notification_groups (
+    notification_id UUID PRIMARY KEY,
+    mode VARCHAR(20) NOT NULL,
+    fallback_after INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_parent_id ON notifications (parent_id, position);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS email_attachments;
+ALTER TABLE email_notifications DROP COLUMN IF EXISTS html;
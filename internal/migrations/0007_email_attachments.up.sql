@@ -0,0 +1,17 @@
+-- HTML-версия письма; текстовая альтернатива хранится в message
+ALTER TABLE email_notifications ADD COLUMN IF NOT EXISTS html TEXT NOT NULL DEFAULT '';
+
+-- Вложения писем; содержимое читается отправителем по id, в очередь передаются только метаданные
+CREATE TABLE IF NOT EXISTS email_attachments (
+    id UUID PRIMARY KEY,
+    notification_id UUID NOT NULL,
+    position INT NOT NULL,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    content_id TEXT NOT NULL DEFAULT '',
+    size INT NOT NULL,
+    content BYTEA NOT NULL,
+    FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_attachments_notification_id ON email_attachments (notification_id, position);
@@ -0,0 +1 @@
+ALTER TABLE telegram_notifications DROP COLUMN IF EXISTS options;
@@ -0,0 +1,2 @@
+-- Параметры оформления и доставки сообщения Telegram: parse_mode, клавиатура, тихая отправка и т.д.
+ALTER TABLE telegram_notifications ADD COLUMN IF NOT EXISTS options JSONB NOT NULL DEFAULT '{}';
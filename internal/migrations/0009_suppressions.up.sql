@@ -0,0 +1,8 @@
+-- Получатели, которым уведомления больше не отправляются (заблокировали бота, удалили аккаунт и т.п.)
+CREATE TABLE IF NOT EXISTS suppressions (
+    type VARCHAR(20) NOT NULL,
+    recipient TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (type, recipient)
+);
@@ -0,0 +1,2 @@
+ALTER INDEX IF EXISTS idx_attachments_notification_id RENAME TO idx_email_attachments_notification_id;
+ALTER TABLE IF EXISTS attachments RENAME TO email_attachments;
@@ -0,0 +1,3 @@
+-- Вложения хранятся в общей таблице: ее используют и письма, и медиа-сообщения Telegram
+ALTER TABLE IF EXISTS email_attachments RENAME TO attachments;
+ALTER INDEX IF EXISTS idx_email_attachments_notification_id RENAME TO idx_attachments_notification_id;
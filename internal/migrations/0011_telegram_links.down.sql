@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS telegram_links;
+DROP TABLE IF EXISTS telegram_link_tokens;
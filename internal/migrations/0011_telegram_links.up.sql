@@ -0,0 +1,17 @@
+-- Одноразовые токены deep link /start <token>, выданные пользователям клиента
+CREATE TABLE IF NOT EXISTS telegram_link_tokens (
+    token TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Чаты Telegram пользователей клиента; уведомления с user_id отправляются в связанный чат
+CREATE TABLE IF NOT EXISTS telegram_links (
+    user_id TEXT PRIMARY KEY,
+    chat_id TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    linked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_telegram_links_chat_id ON telegram_links (chat_id);
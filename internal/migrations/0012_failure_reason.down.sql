@@ -0,0 +1,2 @@
+ALTER TABLE notifications DROP COLUMN IF EXISTS failure_reason;
+ALTER TABLE notifications DROP COLUMN IF EXISTS failure_class;
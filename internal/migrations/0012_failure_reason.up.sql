@@ -0,0 +1,3 @@
+-- Причина окончательной неудачи доставки и ее класс (transient, rate_limited, permanent, unknown)
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS failure_class VARCHAR(20);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS failure_reason TEXT;
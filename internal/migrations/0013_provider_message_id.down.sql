@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_notifications_provider_message_id;
+ALTER TABLE notifications DROP COLUMN IF EXISTS provider_message_id;
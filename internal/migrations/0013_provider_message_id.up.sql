@@ -0,0 +1,5 @@
+-- Идентификатор сообщения у провайдера (Message-ID письма, message_id в Telegram, ID SMS).
+-- По Message-ID сообщение о недоставке письма связывается с уведомлением
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS provider_message_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_provider_message_id ON notifications (provider_message_id) WHERE provider_message_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS preferences;
+ALTER TABLE notifications DROP COLUMN IF EXISTS category;
@@ -0,0 +1,13 @@
+-- Категория рассылки уведомления; получатель может отписаться от нее отдельно от остальных уведомлений
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS category VARCHAR(50) NOT NULL DEFAULT '';
+
+-- Настройки получателей: подписка на канал целиком (пустая категория) или на отдельную категорию.
+-- Без записи получателю можно отправлять
+CREATE TABLE IF NOT EXISTS preferences (
+    type VARCHAR(20) NOT NULL,
+    recipient TEXT NOT NULL,
+    category VARCHAR(50) NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (type, recipient, category)
+);
@@ -0,0 +1,5 @@
+ALTER TABLE notifications DROP COLUMN IF EXISTS variables;
+ALTER TABLE notifications DROP COLUMN IF EXISTS template_version;
+ALTER TABLE notifications DROP COLUMN IF EXISTS template_id;
+DROP TABLE IF EXISTS template_versions;
+DROP TABLE IF EXISTS templates;
@@ -0,0 +1,25 @@
+-- Шаблоны сообщений. Изменение шаблона создает новую версию; version — номер последней.
+-- Удаленный шаблон остается в таблице, чтобы уже запланированные по нему уведомления были отправлены
+CREATE TABLE IF NOT EXISTS templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMPTZ
+);
+
+-- Версии шаблона: тексты по каналам ({"email": {"subject": ..., "message": ..., "html": ...}, ...}).
+-- Версия после создания не меняется
+CREATE TABLE IF NOT EXISTS template_versions (
+    template_id UUID NOT NULL REFERENCES templates(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    channels JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (template_id, version)
+);
+
+-- Уведомление по шаблону: версия фиксируется при создании, текст готовит воркер при отправке
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS template_id UUID REFERENCES templates(id);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS template_version INT;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS variables JSONB;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_email_notifications_email_lower;
+CREATE INDEX IF NOT EXISTS idx_email_notifications_email ON email_notifications (email);
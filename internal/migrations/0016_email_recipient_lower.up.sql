@@ -0,0 +1,3 @@
+-- Уведомления ищутся по адресу email без учета регистра: lower(email) = lower($1)
+DROP INDEX IF EXISTS idx_email_notifications_email;
+CREATE INDEX IF NOT EXISTS idx_email_notifications_email_lower ON email_notifications (lower(email));
@@ -0,0 +1,3 @@
+-- Сколько раз отправка переносилась из-за ограничения частоты запросов провайдером.
+-- Считается отдельно от retries, чтобы ограничение политики rate_limited не съедали другие ошибки
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS rate_limits INT NOT NULL DEFAULT 0;
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Status Статус уведомления
 type Status string
@@ -16,6 +19,14 @@ const (
 	StatusFailed Status = "failed"
 	// StatusProcessing статус при начале обработки планировщиком
 	StatusProcessing Status = "processing"
+	// StatusStandby канал в режиме fallback ждет, пока не сработают предыдущие каналы
+	StatusStandby Status = "standby"
+	// StatusPartiallySent в режиме all доставлено только по части каналов
+	StatusPartiallySent Status = "partially_sent"
+	// StatusBounced письмо передано SMTP-серверу, но позже вернулось с сообщением о недоставке
+	StatusBounced Status = "bounced"
+	// StatusSuppressed уведомление не отправлялось: получатель отписался или числится в списке подавления
+	StatusSuppressed Status = "suppressed"
 )
 
 // NotificationType Тип доставки уведомления
@@ -26,19 +37,162 @@ const (
 	NotificationTypeEmail NotificationType = "email"
 	// NotificationTypeTelegram константа для telegram уведомлений
 	NotificationTypeTelegram NotificationType = "telegram"
+	// NotificationTypeWebhook константа для уведомлений, доставляемых HTTP-вебхуком
+	NotificationTypeWebhook NotificationType = "webhook"
+	// NotificationTypeSlack константа для уведомлений во входящий вебхук Slack
+	NotificationTypeSlack NotificationType = "slack"
+	// NotificationTypeDiscord константа для уведомлений во входящий вебхук Discord
+	NotificationTypeDiscord NotificationType = "discord"
+	// NotificationTypeMattermost константа для уведомлений во входящий вебхук Mattermost
+	NotificationTypeMattermost NotificationType = "mattermost"
+	// NotificationTypeSMS константа для SMS-уведомлений
+	NotificationTypeSMS NotificationType = "sms"
+	// NotificationTypeWebPush константа для браузерных push-уведомлений (Web Push)
+	NotificationTypeWebPush NotificationType = "webpush"
+	// NotificationTypeMulti уведомление с несколькими каналами доставки (targets)
+	NotificationTypeMulti NotificationType = "multi"
+)
+
+// DeliveryMode режим доставки уведомления с несколькими каналами
+type DeliveryMode string
+
+const (
+	// DeliveryModeAll отправить по всем каналам
+	DeliveryModeAll DeliveryMode = "all"
+	// DeliveryModeFallback отправлять по каналам по очереди, пока один из них не сработает
+	DeliveryModeFallback DeliveryMode = "fallback"
 )
 
 // Notification Модель для БД (внутренняя)
 type Notification struct {
-	ID                   string                `db:"id"`
-	Type                 NotificationType      `db:"type"`
-	Status               Status                `db:"status"`
-	ScheduledAt          time.Time             `db:"scheduled_at"`
-	Retries              int                   `db:"retries"`
-	CreatedAt            time.Time             `db:"created_at"`
-	UpdatedAt            time.Time             `db:"updated_at"`
-	EmailNotification    *EmailNotification    `db:"email_notifications" json:"email_notification,omitempty"`
-	TelegramNotification *TelegramNotification `db:"telegram_notifications" json:"telegram_notification,omitempty"`
+	ID          string           `db:"id"`
+	Type        NotificationType `db:"type"`
+	Status      Status           `db:"status"`
+	ScheduledAt time.Time        `db:"scheduled_at"`
+	Retries     int              `db:"retries"`
+	// RateLimits сколько раз отправка переносилась из-за ограничения частоты запросов
+	RateLimits int `db:"rate_limits" json:"rate_limits,omitempty"`
+	// Category категория рассылки, от которой получатель может отписаться (пустая — без категории)
+	Category string `db:"category" json:"category,omitempty"`
+	// TemplateID, TemplateVersion и Variables шаблон, по которому воркер готовит текст при отправке
+	TemplateID      string         `db:"template_id" json:"template_id,omitempty"`
+	TemplateVersion int            `db:"template_version" json:"template_version,omitempty"`
+	Variables       map[string]any `db:"variables" json:"variables,omitempty"`
+	// Segments количество тарифицируемых сегментов (SMS), записывается после отправки
+	Segments int `db:"segments"`
+	// FailureClass и FailureReason класс и текст ошибки, после которой доставка завершилась неудачей
+	FailureClass  string `db:"failure_class"`
+	FailureReason string `db:"failure_reason"`
+	// ParentID уведомление с несколькими каналами, к которому относится эта попытка доставки
+	ParentID  string             `db:"parent_id" json:"parent_id,omitempty"`
+	Group     *NotificationGroup `db:"notification_groups" json:"group,omitempty"`
+	CreatedAt time.Time          `db:"created_at"`
+	UpdatedAt time.Time          `db:"updated_at"`
+	// Payload данные канала доставки (например, *EmailNotification). Тип задает канал в реестре,
+	// а хранит его хранилище канала; читается через PayloadOf
+	Payload any            `db:"-" json:"payload,omitempty"`
+	History []StatusChange `db:"-" json:"history,omitempty"`
+}
+
+// UnmarshalJSON разбирает уведомление из очереди. Тип данных канала знает только канал,
+// поэтому Payload сохраняется как json.RawMessage и разбирается PayloadOf при первом обращении.
+func (n *Notification) UnmarshalJSON(data []byte) error {
+	type notification Notification
+	aux := struct {
+		*notification
+		Payload json.RawMessage `json:"payload,omitempty"`
+	}{notification: (*notification)(n)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.Payload) > 0 && string(aux.Payload) != "null" {
+		n.Payload = aux.Payload
+	}
+	return nil
+}
+
+// PayloadOf возвращает данные канала уведомления как *T; nil, если данных нет или они другого типа.
+func PayloadOf[T any](n *Notification) *T {
+	switch p := n.Payload.(type) {
+	case *T:
+		return p
+	case json.RawMessage:
+		v := new(T)
+		if err := json.Unmarshal(p, v); err != nil {
+			return nil
+		}
+		n.Payload = v
+		return v
+	}
+	return nil
+}
+
+// NotificationGroup каналы доставки уведомления с несколькими каналами.
+// Каждый канал хранится отдельным дочерним уведомлением со своими статусом, попытками и историей.
+type NotificationGroup struct {
+	Mode DeliveryMode `json:"mode"`
+	// FallbackAfter через сколько секунд после запуска канала переходить к следующему,
+	// если он так и не доставил сообщение (0 — только после окончательной ошибки)
+	FallbackAfter int             `json:"fallback_after,omitempty"`
+	Targets       []*Notification `json:"targets"`
+}
+
+// GroupStatus вычисляет статус уведомления с несколькими каналами по статусам каналов.
+//
+// В режиме fallback уведомление отправлено, если сработал хотя бы один канал, и не доставлено,
+// если все запущенные каналы завершились ошибкой и ждать больше нечего.
+// В режиме all уведомление отправлено, если сработали все каналы, и частично отправлено,
+// если сработала только часть.
+func GroupStatus(mode DeliveryMode, statuses []Status) Status {
+	counts := make(map[Status]int, len(statuses))
+	for _, s := range statuses {
+		counts[s]++
+	}
+	pending := counts[StatusScheduled] + counts[StatusProcessing] + counts[StatusStandby]
+	finished := counts[StatusSent] + counts[StatusFailed] + counts[StatusCanceled] + counts[StatusPartiallySent] + counts[StatusBounced] + counts[StatusSuppressed]
+
+	if mode == DeliveryModeFallback && counts[StatusSent] > 0 {
+		return StatusSent
+	}
+	if pending > 0 {
+		// уведомление ждет, только пока ни один канал не начал работу
+		if counts[StatusScheduled]+counts[StatusStandby] == len(statuses) && finished == 0 {
+			return StatusScheduled
+		}
+		return StatusProcessing
+	}
+	switch {
+	case counts[StatusCanceled] == len(statuses):
+		return StatusCanceled
+	case counts[StatusSuppressed] == len(statuses):
+		return StatusSuppressed
+	case counts[StatusSent] == len(statuses):
+		return StatusSent
+	case counts[StatusSent] > 0:
+		return StatusPartiallySent
+	default:
+		return StatusFailed
+	}
+}
+
+// StatusChange запись истории статусов уведомления
+type StatusChange struct {
+	Status    Status    `db:"status" json:"status"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
+}
+
+// NotificationFilter фильтры для списка и выгрузки уведомлений.
+// Пустые поля не участвуют в отборе; From/To ограничивают scheduled_at полуинтервалом [From, To).
+// Recipient — адрес получателя в терминах канала (email, chat_id и т.п.); без Type ищется во всех каналах.
+// Limit/Offset задают страницу списка (Limit = 0 — без ограничения).
+type NotificationFilter struct {
+	Type      NotificationType
+	Status    Status
+	Recipient string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
 }
 
 type EmailNotification struct {
@@ -46,7 +200,29 @@ type EmailNotification struct {
 	NotificationID string `db:"notification_id"`
 	Email          string `db:"email"`
 	Subject        string `db:"subject"`
-	Message        string `db:"message"`
+	// Message текст письма; при заданном HTML используется как текстовая альтернатива
+	Message string `db:"message"`
+	// HTML HTML-версия письма (необязательно)
+	HTML        string       `db:"html"`
+	Attachments []Attachment `db:"-"`
+}
+
+// Attachment вложение письма или файл сообщения Telegram. Вложение с ContentID встраивается в письмо (inline),
+// и HTML ссылается на него как cid:<content_id>. Содержимое хранится в БД отдельно
+// и загружается отправителем по ID, поэтому в очередь попадают только метаданные.
+type Attachment struct {
+	ID          string `json:"id,omitempty"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+	Size        int    `json:"size"`
+	// Content содержимое вложения; в запросе передается в base64
+	Content []byte `json:"content,omitempty"`
+}
+
+// Inline сообщает, что вложение встраивается в HTML письма.
+func (a Attachment) Inline() bool {
+	return a.ContentID != ""
 }
 
 type TelegramNotification struct {
@@ -54,21 +230,332 @@ type TelegramNotification struct {
 	NotificationID string `db:"notification_id"`
 	ChatID         string `db:"chat_id"`
 	Message        string `db:"message"`
+	TelegramOptions
+}
+
+// TelegramParseMode режим разбора разметки текста сообщения Telegram
+type TelegramParseMode string
+
+const (
+	// TelegramParseModeMarkdownV2 текст в MarkdownV2; служебные символы экранируются обратной косой чертой
+	TelegramParseModeMarkdownV2 TelegramParseMode = "MarkdownV2"
+	// TelegramParseModeHTML текст с HTML-тегами, поддерживаемыми Telegram
+	TelegramParseModeHTML TelegramParseMode = "HTML"
+)
+
+// TelegramButton кнопка встроенной клавиатуры: ссылка (URL) или кнопка с callback_data
+type TelegramButton struct {
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+// TelegramOptions параметры оформления и доставки сообщения Telegram
+type TelegramOptions struct {
+	// ParseMode разметка текста: MarkdownV2 или HTML (по умолчанию — обычный текст)
+	ParseMode TelegramParseMode `json:"parse_mode,omitempty"`
+	// DisableNotification доставить сообщение без звука
+	DisableNotification bool `json:"disable_notification,omitempty"`
+	// DisableWebPagePreview не показывать предпросмотр ссылок
+	DisableWebPagePreview bool `json:"disable_web_page_preview,omitempty"`
+	// MessageThreadID тема (topic) форума в супергруппе
+	MessageThreadID int64 `json:"message_thread_id,omitempty"`
+	// Buttons строки встроенной клавиатуры под сообщением
+	Buttons [][]TelegramButton `json:"buttons,omitempty"`
+	// ReplyMarkup произвольная разметка reply_markup Bot API (например, reply-клавиатура или force_reply);
+	// не используется вместе с Buttons
+	ReplyMarkup json.RawMessage `json:"reply_markup,omitempty"`
+	// Media фотографии или документы; текст сообщения становится подписью к ним.
+	// Одно вложение отправляется sendPhoto/sendDocument, несколько — альбомом sendMediaGroup
+	Media []TelegramMedia `json:"media,omitempty"`
+}
+
+// TelegramMediaType тип вложения сообщения Telegram
+type TelegramMediaType string
+
+const (
+	// TelegramMediaPhoto фотография (sendPhoto)
+	TelegramMediaPhoto TelegramMediaType = "photo"
+	// TelegramMediaDocument файл любого типа (sendDocument)
+	TelegramMediaDocument TelegramMediaType = "document"
+)
+
+// TelegramMedia вложение сообщения Telegram: ссылка, которую Telegram скачает сам,
+// или загруженный файл, который хранится вместе с уведомлением и отправляется multipart-запросом
+type TelegramMedia struct {
+	Type TelegramMediaType `json:"type"`
+	URL  string            `json:"url,omitempty"`
+	File *Attachment       `json:"file,omitempty"`
+}
+
+// WebhookFormat формат тела запроса вебхука
+type WebhookFormat string
+
+const (
+	// WebhookFormatJSON тело application/json (по умолчанию)
+	WebhookFormatJSON WebhookFormat = "json"
+	// WebhookFormatForm тело application/x-www-form-urlencoded
+	WebhookFormatForm WebhookFormat = "form"
+)
+
+// WebhookOptions параметры доставки HTTP-вебхуком
+type WebhookOptions struct {
+	URL    string        `json:"url"`
+	Format WebhookFormat `json:"format,omitempty"`
+	// Headers дополнительные заголовки запроса
+	Headers map[string]string `json:"headers,omitempty"`
+	// Data дополнительные поля тела запроса
+	Data map[string]string `json:"data,omitempty"`
+	// Secret ключ HMAC-подписи; если пустой, используется общий ключ сервиса
+	Secret string `json:"secret,omitempty"`
+	// Timeout таймаут запроса в секундах
+	Timeout int `json:"timeout,omitempty"`
+	// SuccessCodes коды ответа, которые считаются успешной доставкой (по умолчанию 2xx)
+	SuccessCodes []int `json:"success_codes,omitempty"`
+}
+
+// WebhookNotification данные уведомления, доставляемого HTTP-вебхуком
+type WebhookNotification struct {
+	WebhookOptions
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message"`
+}
+
+// ChatOptions параметры доставки во входящий вебхук Slack, Discord или Mattermost
+type ChatOptions struct {
+	// Webhook путь входящего вебхука относительно базового URL платформы
+	// (например, /services/T000/B000/XXX) или полный URL, выданный платформой
+	Webhook string `json:"webhook"`
+	// Blocks JSON-массив с оформлением сообщения: blocks для Slack, embeds для Discord, attachments для Mattermost
+	Blocks json.RawMessage `json:"blocks,omitempty"`
+}
+
+// ChatNotification данные уведомления для Slack, Discord или Mattermost
+type ChatNotification struct {
+	ChatOptions
+	Subject string `json:"subject,omitempty"`
+	// Message текст в Markdown
+	Message string `json:"message"`
+}
+
+// SMSNotification данные SMS-уведомления
+type SMSNotification struct {
+	// Phone номер получателя в формате E.164 (+79991234567)
+	Phone   string `json:"phone"`
+	Message string `json:"message"`
+}
+
+// WebPushOptions параметры доставки браузерного push-уведомления
+type WebPushOptions struct {
+	// Subscriber идентификатор подписчика; уведомление получают все его подписки
+	Subscriber string `json:"subscriber"`
+	// URL страница, которая откроется по клику на уведомление
+	URL string `json:"url,omitempty"`
+	// TTL сколько секунд push-сервис хранит недоставленное сообщение (0 — по умолчанию)
+	TTL int `json:"ttl,omitempty"`
+	// Urgency срочность: very-low, low, normal или high
+	Urgency string `json:"urgency,omitempty"`
+}
+
+// WebPushNotification данные браузерного push-уведомления
+type WebPushNotification struct {
+	WebPushOptions
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message"`
+}
+
+// PushSubscriptionKeys ключи подписки из PushSubscription.toJSON() браузера (base64url)
+type PushSubscriptionKeys struct {
+	P256DH string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// Suppression получатель канала, которому уведомления больше не отправляются
+// (например, пользователь заблокировал бота)
+type Suppression struct {
+	Type      NotificationType `json:"type"`
+	Recipient string           `json:"recipient"`
+	Reason    string           `json:"reason"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// Preference настройка получателя канала: подписан ли он на категорию рассылки.
+// Пустая категория относится ко всем уведомлениям канала; настройка категории важнее общей
+type Preference struct {
+	Type      NotificationType `json:"type"`
+	Recipient string           `json:"recipient"`
+	Category  string           `json:"category,omitempty"`
+	Enabled   bool             `json:"enabled"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// Template шаблон сообщения. Тексты задаются отдельно для каждого канала на языке Go text/template,
+// HTML письма — html/template. Изменение шаблона создает новую версию, старые версии не меняются
+type Template struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	// Channels тексты по каналам доставки; уведомление по шаблону можно отправить только в эти каналы
+	Channels  map[NotificationType]TemplateContent `json:"channels"`
+	CreatedAt time.Time                            `json:"created_at"`
+	UpdatedAt time.Time                            `json:"updated_at"`
+}
+
+// TemplateContent тексты сообщения канала: шаблоны в Template или готовый текст после подстановки переменных
+type TemplateContent struct {
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message,omitempty"`
+	// HTML HTML-версия письма (только для email)
+	HTML string `json:"html,omitempty"`
+}
+
+// Bounce сообщение о недоставке письма: DSN, вернувшийся на адрес возврата, или событие ESP
+type Bounce struct {
+	// Recipient адрес, на который не удалось доставить письмо
+	Recipient string `json:"recipient"`
+	// MessageID заголовок Message-ID исходного письма; по нему находится уведомление
+	MessageID string `json:"message_id,omitempty"`
+	// Hard постоянная ошибка (адрес не существует, домен не найден): получатель добавляется в список подавления
+	Hard bool `json:"hard"`
+	// Status расширенный код состояния SMTP, например 5.1.1
+	Status string `json:"status,omitempty"`
+	// Diagnostic ответ принимающего сервера
+	Diagnostic string `json:"diagnostic,omitempty"`
+}
+
+// TelegramLinkToken одноразовый токен deep link https://t.me/<bot>?start=<token>,
+// по которому бот связывает чат с пользователем клиента
+type TelegramLinkToken struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"user_id"`
+	URL       string    `json:"url,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TelegramLink чат Telegram, связанный с пользователем клиента
+type TelegramLink struct {
+	UserID   string    `json:"user_id"`
+	ChatID   string    `json:"chat_id"`
+	Username string    `json:"username,omitempty"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// TelegramUpdate входящее обновление Bot API (getUpdates или вебхук); обрабатываются
+// только сообщения и изменения статуса бота в чате
+type TelegramUpdate struct {
+	UpdateID     int64                      `json:"update_id"`
+	Message      *TelegramMessage           `json:"message,omitempty"`
+	MyChatMember *TelegramChatMemberUpdated `json:"my_chat_member,omitempty"`
+}
+
+// TelegramMessage входящее сообщение Bot API
+type TelegramMessage struct {
+	MessageID int64         `json:"message_id"`
+	From      *TelegramUser `json:"from,omitempty"`
+	Chat      TelegramChat  `json:"chat"`
+	Text      string        `json:"text,omitempty"`
+}
+
+// TelegramUser пользователь Telegram
+type TelegramUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+// TelegramChat чат Telegram; type — private, group, supergroup или channel
+type TelegramChat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+}
+
+// TelegramChatMemberUpdated изменение статуса бота в чате; kicked в личном чате означает, что бот заблокирован
+type TelegramChatMemberUpdated struct {
+	Chat          TelegramChat `json:"chat"`
+	From          TelegramUser `json:"from"`
+	NewChatMember struct {
+		Status string `json:"status"`
+	} `json:"new_chat_member"`
+}
+
+// PushSubscription подписка браузера на Web Push
+type PushSubscription struct {
+	ID         string               `json:"id"`
+	Subscriber string               `json:"subscriber"`
+	Endpoint   string               `json:"endpoint"`
+	Keys       PushSubscriptionKeys `json:"keys"`
+	CreatedAt  time.Time            `json:"created_at"`
 }
 
 // CreateNotificationRequest DTO для входящих запросов (например, POST /notifications)
 type CreateNotificationRequest struct {
-	ChatID      string           `json:"chat_id,omitempty"`
+	ChatID string `json:"chat_id,omitempty"`
+	// UserID пользователь клиента, связавший чат Telegram через /start; используется вместо chat_id
+	UserID      string           `json:"user_id,omitempty"`
 	Email       string           `json:"email,omitempty"`
-	Type        NotificationType `json:"type"` // email | telegram
+	Phone       string           `json:"phone,omitempty"`
+	Type        NotificationType `json:"type"` // email | telegram | webhook | slack | discord | mattermost | sms | webpush
 	Message     string           `json:"message"`
 	Subject     string           `json:"subject,omitempty"`
 	ScheduledAt time.Time        `json:"scheduled_at"`
+	Webhook     *WebhookOptions  `json:"webhook,omitempty"`
+	Chat        *ChatOptions     `json:"chat,omitempty"`
+	Push        *WebPushOptions  `json:"push,omitempty"`
+	Telegram    *TelegramOptions `json:"telegram,omitempty"`
+	// HTML и Attachments используются только email-уведомлениями
+	HTML        string       `json:"html,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// Targets каналы доставки по порядку; общие message и subject используются, если не заданы в канале
+	Targets []CreateNotificationRequest `json:"targets,omitempty"`
+	// Mode режим доставки по Targets: all или fallback
+	Mode DeliveryMode `json:"mode,omitempty"`
+	// FallbackAfter в режиме fallback — через сколько секунд переходить к следующему каналу
+	FallbackAfter int `json:"fallback_after,omitempty"`
+	// Category категория рассылки (например, marketing): получатель может отписаться от нее,
+	// не отказываясь от остальных уведомлений канала. В targets наследуется из общего запроса
+	Category string `json:"category,omitempty"`
+
+	// TemplateID шаблон сообщения вместо message, subject и html; текст готовится при отправке
+	// подстановкой Variables. TemplateVersion — версия шаблона (0 — последняя на момент создания).
+	// В targets шаблон и переменные наследуются из общего запроса
+	TemplateID      string         `json:"template_id,omitempty"`
+	TemplateVersion int            `json:"template_version,omitempty"`
+	Variables       map[string]any `json:"variables,omitempty"`
+	// Template версия шаблона для проверки запроса; заполняет сервис по TemplateID
+	Template *Template `json:"-"`
+}
+
+// Target возвращает запрос i-го канала доставки. Текст, тема, категория и шаблон, не заданные в канале,
+// берутся из общего запроса; канал со своим текстом не наследует шаблон, а канал со своим шаблоном — текст.
+// Время отправки всегда общее.
+func (r *CreateNotificationRequest) Target(i int) CreateNotificationRequest {
+	t := r.Targets[i]
+	if t.TemplateID == "" && t.Message == "" && t.Subject == "" {
+		t.TemplateID, t.TemplateVersion, t.Template = r.TemplateID, r.TemplateVersion, r.Template
+	}
+	if t.TemplateID == "" {
+		if t.Message == "" {
+			t.Message = r.Message
+		}
+		if t.Subject == "" {
+			t.Subject = r.Subject
+		}
+	}
+	if t.Category == "" {
+		t.Category = r.Category
+	}
+	if t.Variables == nil {
+		t.Variables = r.Variables
+	}
+	t.ScheduledAt = r.ScheduledAt
+	return t
 }
 
 // NotificationResponse DTO для ответа API
 type NotificationResponse struct {
 	ID          string           `json:"id"`
+	Recipient   string           `json:"recipient,omitempty"`
 	ChatID      string           `json:"chat_id,omitempty"`
 	Email       string           `json:"email,omitempty"`
 	Type        NotificationType `json:"type"`
@@ -77,9 +564,55 @@ type NotificationResponse struct {
 	ScheduledAt time.Time        `json:"scheduled_at"`
 	Status      Status           `json:"status"`
 	Retries     int              `json:"retries"`
+	Category    string           `json:"category,omitempty"`
+	// TemplateID, TemplateVersion и Variables заполняются для уведомлений по шаблону
+	TemplateID      string         `json:"template_id,omitempty"`
+	TemplateVersion int            `json:"template_version,omitempty"`
+	Variables       map[string]any `json:"variables,omitempty"`
+	// FailureClass и FailureReason заполняются, если доставка завершилась неудачей
+	FailureClass  string `json:"failure_class,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
+	HTML          string `json:"html,omitempty"`
+	// Attachments метаданные вложений письма или загруженных файлов Telegram (без содержимого)
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// ParentID уведомление с несколькими каналами, к которому относится эта попытка доставки
+	ParentID string `json:"parent_id,omitempty"`
+	// Mode и Targets заполняются для уведомлений с несколькими каналами
+	Mode    DeliveryMode           `json:"mode,omitempty"`
+	Targets []NotificationResponse `json:"targets,omitempty"`
 }
 
 // CreateNotificationResponse DTO для ответа на создание
 type CreateNotificationResponse struct {
 	ID string `json:"id"`
 }
+
+// RecipientNotificationsResponse DTO для ответа со списком уведомлений получателя
+type RecipientNotificationsResponse struct {
+	Channel   NotificationType       `json:"channel"`
+	Recipient string                 `json:"recipient"`
+	Items     []NotificationResponse `json:"items"`
+	Total     int                    `json:"total"`
+	Limit     int                    `json:"limit"`
+	Offset    int                    `json:"offset"`
+	Summary   map[Status]int         `json:"summary"`
+}
+
+// RenderedMessage DTO с сообщением в том виде, в котором оно будет доставлено получателю
+type RenderedMessage struct {
+	Type      NotificationType `json:"type"`
+	Recipient string           `json:"recipient"`
+	Subject   string           `json:"subject,omitempty"`
+	Body      string           `json:"body"`
+	// HTML HTML-версия письма
+	HTML    string            `json:"html,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Method метод API канала, которому передается Payload (например, sendPhoto)
+	Method string `json:"method,omitempty"`
+	// Payload тело запроса к API канала (например, Telegram Bot API)
+	Payload map[string]any `json:"payload,omitempty"`
+	// Raw итоговое MIME-сообщение для email
+	Raw string `json:"raw,omitempty"`
+	// Truncated сообщение было обрезано до лимита канала
+	Truncated bool `json:"truncated,omitempty"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationPayloadJSON(t *testing.T) {
+	n := &Notification{
+		ID:      "1",
+		Type:    NotificationTypeTelegram,
+		Payload: &TelegramNotification{ChatID: "42", Message: "Привет"},
+		Group: &NotificationGroup{Mode: DeliveryModeAll, Targets: []*Notification{
+			{ID: "2", Type: NotificationTypeEmail, Payload: &EmailNotification{Email: "test@example.com"}},
+		}},
+	}
+	data, err := json.Marshal(n)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// из очереди данные канала приходят без типа и разбираются при первом обращении
+	var got Notification
+	if !assert.NoError(t, json.Unmarshal(data, &got)) {
+		return
+	}
+	assert.Equal(t, "1", got.ID)
+	if tg := PayloadOf[TelegramNotification](&got); assert.NotNil(t, tg) {
+		assert.Equal(t, "42", tg.ChatID)
+		assert.Same(t, tg, PayloadOf[TelegramNotification](&got))
+	}
+	if email := PayloadOf[EmailNotification](got.Group.Targets[0]); assert.NotNil(t, email) {
+		assert.Equal(t, "test@example.com", email.Email)
+	}
+	assert.Nil(t, PayloadOf[EmailNotification](&Notification{Payload: &TelegramNotification{}}))
+	assert.Nil(t, PayloadOf[EmailNotification](&Notification{}))
+}
@@ -0,0 +1,68 @@
+// Package pushtoken выпускает и проверяет подписанные токены подписчика Web Push.
+package pushtoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signatureLength сколько байт HMAC-SHA256 хранится в токене.
+const signatureLength = 16
+
+// ErrInvalidToken токен поврежден, подписан другим ключом или истек.
+var ErrInvalidToken = errors.New("invalid push subscriber token")
+
+// Signer подписывает токены подписчика ключом HMAC. Токен выдает бэкенд приложения пользователю,
+// которого он аутентифицировал, поэтому браузер может подписаться и отписаться только от своего имени.
+type Signer struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewSigner создает Signer.
+func NewSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret), now: time.Now}
+}
+
+// Token возвращает токен подписчика, действующий до expiresAt.
+func (s *Signer) Token(subscriber string, expiresAt time.Time) string {
+	payload := []byte(subscriber + "\x00" + strconv.FormatInt(expiresAt.Unix(), 10))
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// Parse проверяет подпись и срок действия токена и возвращает подписчика.
+func (s *Signer) Parse(token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.sign(payload)) {
+		return "", ErrInvalidToken
+	}
+	subscriber, expires, ok := strings.Cut(string(payload), "\x00")
+	if !ok || subscriber == "" {
+		return "", ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !s.now().Before(time.Unix(unix, 0)) {
+		return "", ErrInvalidToken
+	}
+	return subscriber, nil
+}
+
+// sign возвращает подпись payload.
+func (s *Signer) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(payload)
+	return h.Sum(nil)[:signatureLength]
+}
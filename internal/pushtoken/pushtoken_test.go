@@ -0,0 +1,30 @@
+package pushtoken
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2025, 11, 10, 10, 0, 0, 0, time.UTC)
+	s := NewSigner("s3cret")
+	s.now = func() time.Time { return now }
+
+	token := s.Token("user-1", now.Add(time.Hour))
+	subscriber, err := s.Parse(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", subscriber)
+
+	// токен, подписанный другим ключом, измененный и истекший токены не принимаются
+	_, err = NewSigner("other").Parse(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	forged := s.Token("user-2", now.Add(time.Hour))
+	_, err = s.Parse(forged[:len(forged)-2] + token[len(token)-2:])
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = s.Parse(s.Token("user-1", now))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = s.Parse("garbage")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
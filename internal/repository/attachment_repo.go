@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// AttachmentRepository определяет методы для чтения содержимого вложений.
+// Вложения сохраняются вместе с уведомлением (EmailStorage, TelegramStorage).
+type AttachmentRepository interface {
+	Content(ctx context.Context, id string) ([]byte, error)
+}
+
+type attachmentRepo struct {
+	db *sql.DB
+}
+
+// NewAttachmentRepo создает новый экземпляр AttachmentRepository.
+func NewAttachmentRepo(db *sql.DB) AttachmentRepository {
+	return &attachmentRepo{db: db}
+}
+
+// Content возвращает содержимое вложения по его ID.
+func (r *attachmentRepo) Content(ctx context.Context, id string) ([]byte, error) {
+	var content []byte
+	err := r.db.QueryRowContext(ctx, `SELECT content FROM attachments WHERE id = $1`, id).Scan(&content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("attachment %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting attachment content: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// RecordProviderMessageID сохраняет идентификатор, под которым провайдер принял сообщение.
+func (r *notificationRepo) RecordProviderMessageID(ctx context.Context, id, messageID string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE notifications SET provider_message_id=$1, updated_at=now() WHERE id=$2`, messageID, id)
+	return err
+}
+
+// RecordBounce помечает отправленное письмо с Message-ID messageID как bounced и сохраняет
+// класс и текст ошибки. Повторное сообщение о недоставке того же письма обновляет причину.
+// Возвращает уведомление (ID, тип, статус и родителя) или ErrNotFound, если письма нет
+// или оно не было отправлено.
+func (r *notificationRepo) RecordBounce(ctx context.Context, messageID, class, reason string) (*models.Notification, error) {
+	query := `
+  UPDATE notifications
+  SET status = $1, failure_class = $2, failure_reason = $3, updated_at = now()
+  WHERE provider_message_id = $4 AND type = $5 AND status IN ($6, $1)
+  RETURNING id, type, status, parent_id
+ `
+	n := &models.Notification{}
+	var parentID sql.NullString
+	err := r.db.QueryRowContext(ctx, query, models.StatusBounced, class, reason, messageID, models.NotificationTypeEmail, models.StatusSent).
+		Scan(&n.ID, &n.Type, &n.Status, &parentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error recording bounce: %w", err)
+	}
+	n.ParentID = parentID.String
+	return n, nil
+}
@@ -3,3 +3,7 @@ package repository
 import "errors"
 
 var ErrNotFound = errors.New("notifications not found")
+
+// ErrUnavailable оборачивает ошибки обращения к БД, не связанные с содержимым запроса:
+// недоступность базы, обрыв соединения, таймаут.
+var ErrUnavailable = errors.New("storage unavailable")
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// exportBatchSize количество строк, которое за раз читается из курсора выгрузки.
+const exportBatchSize = 500
+
+// filterCondition строит условие WHERE для таблицы notifications по фильтру.
+// Каналы уведомлений с несколькими каналами хранятся дочерними строками notifications, поэтому
+// отбираются только уведомления верхнего уровня, а подходящий под фильтр канал группы находит
+// ее родительское уведомление. Отбор по получателю делегируется хранилищу канала; если тип
+// не задан, получатель ищется во всех каналах.
+func (r *notificationRepo) filterCondition(f models.NotificationFilter) (string, []any) {
+	var (
+		conds = []string{"notifications.parent_id IS NULL"}
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Type != "" {
+		args = append(args, f.Type)
+		conds = append(conds, r.typeCondition(f.Type, fmt.Sprintf("$%d", len(args))))
+	}
+	if f.Status != "" {
+		add("notifications.status = $%d", f.Status)
+	}
+	if f.Recipient != "" {
+		args = append(args, f.Recipient)
+		conds = append(conds, r.recipientCondition(f.Type, fmt.Sprintf("$%d", len(args))))
+	}
+	if !f.From.IsZero() {
+		add("notifications.scheduled_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("notifications.scheduled_at < $%d", f.To)
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// typeCondition возвращает условие отбора по типу t: уведомления этого типа и уведомления
+// с несколькими каналами, у которых есть канал этого типа.
+func (r *notificationRepo) typeCondition(t models.NotificationType, arg string) string {
+	if t == models.NotificationTypeMulti {
+		return "notifications.type = " + arg
+	}
+	return "(notifications.type = " + arg + " OR notifications.id IN (SELECT notifications.parent_id FROM notifications" +
+		" WHERE notifications.parent_id IS NOT NULL AND notifications.type = " + arg + "))"
+}
+
+// recipientCondition возвращает условие отбора по получателю для канала t
+// или для всех каналов, если t пустой. Получатель канала уведомления с несколькими каналами
+// находит родительское уведомление.
+func (r *notificationRepo) recipientCondition(t models.NotificationType, arg string) string {
+	if t != "" {
+		s, ok := r.storages[t]
+		if !ok {
+			return "FALSE"
+		}
+		group, ok := r.storages[models.NotificationTypeMulti].(groupStorage)
+		if !ok || t == models.NotificationTypeMulti {
+			return s.RecipientCondition(arg)
+		}
+		return "(" + s.RecipientCondition(arg) + " OR " + group.targetCondition([]models.NotificationType{t}, arg) + ")"
+	}
+
+	types := make([]string, 0, len(r.storages))
+	for t := range r.storages {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+	conds := make([]string, 0, len(types))
+	for _, t := range types {
+		conds = append(conds, r.storages[models.NotificationType(t)].RecipientCondition(arg))
+	}
+	if len(conds) == 0 {
+		return "FALSE"
+	}
+	return "(" + strings.Join(conds, " OR ") + ")"
+}
+
+// pageClause добавляет сортировку и, если задан Limit, ограничение страницы.
+// Параметры LIMIT/OFFSET дописываются в args.
+func pageClause(f models.NotificationFilter, args *[]any) string {
+	clause := "\n        ORDER BY scheduled_at DESC, id"
+	if f.Limit > 0 {
+		*args = append(*args, f.Limit, f.Offset)
+		clause += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(*args)-1, len(*args))
+	}
+	return clause
+}
+
+// CountByStatus возвращает количество уведомлений по статусам, подходящих под фильтр.
+// Limit и Offset фильтра не учитываются.
+func (r *notificationRepo) CountByStatus(ctx context.Context, filter models.NotificationFilter) (map[models.Status]int, error) {
+	where, args := r.filterCondition(filter)
+	query := `
+  SELECT status, count(*)
+  FROM notifications
+  ` + where + `
+  GROUP BY status
+ `
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error counting notifications: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[models.Status]int{}
+	for rows.Next() {
+		var (
+			status models.Status
+			count  int
+		)
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("error scanning notification count: %w", err)
+		}
+		counts[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+	return counts, nil
+}
+
+// Export потоково выгружает уведомления с историей статусов, подходящие под фильтр.
+// Строки читаются серверным курсором пачками по exportBatchSize, поэтому в памяти
+// одновременно находится только одна пачка. Ошибка fn прерывает выгрузку.
+func (r *notificationRepo) Export(ctx context.Context, filter models.NotificationFilter, fn func(n *models.Notification) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	// транзакция только читает данные, поэтому откат безопасен и после успешной выгрузки
+	defer tx.Rollback()
+
+	where, args := r.filterCondition(filter)
+	cursorQuery := `
+  DECLARE notifications_export NO SCROLL CURSOR FOR
+  SELECT notifications.id, notifications.type, notifications.status, notifications.scheduled_at,
+   notifications.retries, notifications.created_at, notifications.updated_at, COALESCE(notifications.segments, 0),
+   COALESCE(notifications.failure_class, ''), COALESCE(notifications.failure_reason, ''),
+   COALESCE((
+    SELECT json_agg(json_build_object('status', h.status, 'changed_at', h.changed_at) ORDER BY h.changed_at, h.id)
+    FROM notification_status_history h
+    WHERE h.notification_id = notifications.id
+   ), '[]')
+  FROM notifications
+  ` + where + `
+  ORDER BY notifications.scheduled_at, notifications.id
+ `
+	if _, err := tx.ExecContext(ctx, cursorQuery, args...); err != nil {
+		return fmt.Errorf("error declaring export cursor: %w", err)
+	}
+
+	fetchQuery := fmt.Sprintf(`FETCH %d FROM notifications_export`, exportBatchSize)
+	for {
+		batch, err := fetchExportBatch(ctx, tx, fetchQuery)
+		if err != nil {
+			return err
+		}
+		for _, n := range batch {
+			// данные канала читаются после закрытия FETCH, в той же транзакции
+			if storage, ok := r.storages[n.Type]; ok {
+				if err := storage.Load(ctx, tx, n); err != nil {
+					return err
+				}
+			}
+			if n.Group != nil {
+				if err := loadTargetHistory(ctx, tx, n.ID, n.Group.Targets); err != nil {
+					return err
+				}
+			}
+			if err := fn(n); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// loadTargetHistory дополняет дочерние уведомления группы полями выгрузки и историей статусов:
+// группа выгружается одной строкой, и попытки по каналам видны только через ее targets.
+func loadTargetHistory(ctx context.Context, q Querier, parentID string, targets []*models.Notification) error {
+	rows, err := q.QueryContext(ctx, `
+  SELECT notifications.id, notifications.created_at, notifications.updated_at, COALESCE(notifications.segments, 0),
+   COALESCE(notifications.failure_class, ''), COALESCE(notifications.failure_reason, ''),
+   COALESCE((
+    SELECT json_agg(json_build_object('status', h.status, 'changed_at', h.changed_at) ORDER BY h.changed_at, h.id)
+    FROM notification_status_history h
+    WHERE h.notification_id = notifications.id
+   ), '[]')
+  FROM notifications
+  WHERE notifications.parent_id = $1
+ `, parentID)
+	if err != nil {
+		return fmt.Errorf("error querying notification targets: %w", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[string]*models.Notification, len(targets))
+	for _, t := range targets {
+		byID[t.ID] = t
+	}
+	for rows.Next() {
+		var (
+			id      string
+			n       models.Notification
+			history []byte
+		)
+		if err := rows.Scan(&id, &n.CreatedAt, &n.UpdatedAt, &n.Segments, &n.FailureClass, &n.FailureReason, &history); err != nil {
+			return fmt.Errorf("error scanning notification target: %w", err)
+		}
+		t, ok := byID[id]
+		if !ok {
+			continue
+		}
+		t.CreatedAt, t.UpdatedAt, t.Segments, t.FailureClass, t.FailureReason = n.CreatedAt, n.UpdatedAt, n.Segments, n.FailureClass, n.FailureReason
+		if err := json.Unmarshal(history, &t.History); err != nil {
+			return fmt.Errorf("error decoding status history: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating through rows: %w", err)
+	}
+	return nil
+}
+
+func fetchExportBatch(ctx context.Context, tx *sql.Tx, query string) ([]*models.Notification, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching export cursor: %w", err)
+	}
+	defer rows.Close()
+
+	batch := make([]*models.Notification, 0, exportBatchSize)
+	for rows.Next() {
+		var (
+			n       models.Notification
+			history []byte
+		)
+		if err := rows.Scan(
+			&n.ID, &n.Type, &n.Status, &n.ScheduledAt, &n.Retries, &n.CreatedAt, &n.UpdatedAt, &n.Segments,
+			&n.FailureClass, &n.FailureReason, &history,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning notification: %w", err)
+		}
+		if err := json.Unmarshal(history, &n.History); err != nil {
+			return nil, fmt.Errorf("error decoding status history: %w", err)
+		}
+		batch = append(batch, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+	return batch, nil
+}
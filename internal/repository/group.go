@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// groupStorage хранит уведомления с несколькими каналами: режим доставки в таблице
+// notification_groups, а попытку по каждому каналу — дочерним уведомлением в notifications
+// (parent_id, position). Данные дочерних уведомлений сохраняют хранилища их каналов.
+type groupStorage struct {
+	storages map[models.NotificationType]ChannelStorage
+}
+
+// storage возвращает хранилище канала дочернего уведомления.
+func (s groupStorage) storage(t models.NotificationType) (ChannelStorage, error) {
+	storage, ok := s.storages[t]
+	if !ok || t == models.NotificationTypeMulti {
+		return nil, fmt.Errorf("unsupported target type: %s", t)
+	}
+	return storage, nil
+}
+
+// Insert сохраняет режим доставки и дочерние уведомления по каналам.
+func (s groupStorage) Insert(ctx context.Context, tx *sql.Tx, n *models.Notification) error {
+	if n.Group == nil || len(n.Group.Targets) == 0 {
+		return fmt.Errorf("notification targets are missing")
+	}
+	groupQuery := `
+   INSERT INTO notification_groups (notification_id, mode, fallback_after)
+   VALUES ($1, $2, $3)
+  `
+	if _, err := tx.ExecContext(ctx, groupQuery, n.ID, n.Group.Mode, n.Group.FallbackAfter); err != nil {
+		return fmt.Errorf("error inserting into notification_groups: %w", err)
+	}
+
+	targetQuery := `
+   INSERT INTO notifications (type, status, scheduled_at, retries, parent_id, position, category, template_id, template_version, variables)
+   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+   RETURNING id
+  `
+	for i, target := range n.Group.Targets {
+		storage, err := s.storage(target.Type)
+		if err != nil {
+			return err
+		}
+		tmpl, err := templateColumns(target)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, targetQuery, append([]any{target.Type, target.Status, target.ScheduledAt, target.Retries, n.ID, i, target.Category}, tmpl...)...).Scan(&target.ID)
+		if err != nil {
+			return fmt.Errorf("error inserting notification target: %w", err)
+		}
+		target.ParentID = n.ID
+		if err := storage.Insert(ctx, tx, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load загружает режим доставки и дочерние уведомления в порядке каналов.
+func (s groupStorage) Load(ctx context.Context, q Querier, n *models.Notification) error {
+	group := &models.NotificationGroup{}
+	groupQuery := `
+            SELECT mode, fallback_after
+            FROM notification_groups
+            WHERE notification_id = $1
+        `
+	if err := q.QueryRowContext(ctx, groupQuery, n.ID).Scan(&group.Mode, &group.FallbackAfter); err != nil {
+		return fmt.Errorf("error getting notification group: %w", err)
+	}
+
+	targets, err := queryTargets(ctx, q, n.ID)
+	if err != nil {
+		return err
+	}
+	// данные каналов читаем после закрытия курсора: в транзакции одновременно открыт только один запрос
+	for _, target := range targets {
+		storage, err := s.storage(target.Type)
+		if err != nil {
+			return err
+		}
+		if err := storage.Load(ctx, q, target); err != nil {
+			return err
+		}
+	}
+	group.Targets = targets
+	n.Group = group
+	return nil
+}
+
+// RecipientCondition отбирает уведомления, у которых хотя бы один канал адресован получателю.
+func (s groupStorage) RecipientCondition(arg string) string {
+	types := make([]models.NotificationType, 0, len(s.storages))
+	for t := range s.storages {
+		if t != models.NotificationTypeMulti {
+			types = append(types, t)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return s.targetCondition(types, arg)
+}
+
+// targetCondition отбирает уведомления, у которых канал одного из типов types адресован получателю.
+// Внутри подзапроса notifications ссылается на дочерние уведомления, поэтому условия каналов
+// используются без изменений.
+func (s groupStorage) targetCondition(types []models.NotificationType, arg string) string {
+	conds := make([]string, 0, len(types))
+	for _, t := range types {
+		if storage, ok := s.storages[t]; ok {
+			conds = append(conds, storage.RecipientCondition(arg))
+		}
+	}
+	if len(conds) == 0 {
+		return "FALSE"
+	}
+	return "notifications.id IN (SELECT notifications.parent_id FROM notifications WHERE notifications.parent_id IS NOT NULL AND (" +
+		strings.Join(conds, " OR ") + "))"
+}
+
+// queryTargets читает дочерние уведомления группы без данных каналов.
+func queryTargets(ctx context.Context, q Querier, parentID string) ([]*models.Notification, error) {
+	query := `
+            SELECT id, type, status, scheduled_at, retries
+            FROM notifications
+            WHERE parent_id = $1
+            ORDER BY position
+        `
+	rows, err := q.QueryContext(ctx, query, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notification targets: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []*models.Notification
+	for rows.Next() {
+		target := &models.Notification{ParentID: parentID}
+		if err := rows.Scan(&target.ID, &target.Type, &target.Status, &target.ScheduledAt, &target.Retries); err != nil {
+			return nil, fmt.Errorf("error scanning notification target: %w", err)
+		}
+		targets = append(targets, target)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+	return targets, nil
+}
+
+// SyncGroup пересчитывает статус уведомления с несколькими каналами по статусам дочерних.
+// В режиме fallback, пока ни один канал не доставил уведомление, запускает следующий канал,
+// если текущий завершился неудачей или не доставил уведомление за fallback_after секунд.
+// Строка группы блокируется, поэтому одновременные вызовы воркера и планировщика
+// не запускают один канал дважды. Возвращает новый статус родителя.
+func (r *notificationRepo) SyncGroup(ctx context.Context, parentID string) (status models.Status, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var (
+		group   models.NotificationGroup
+		current models.Status
+	)
+	err = tx.QueryRowContext(ctx, `
+  SELECT g.mode, g.fallback_after, p.status
+  FROM notification_groups g
+  JOIN notifications p ON p.id = g.notification_id
+  WHERE g.notification_id = $1
+  FOR UPDATE OF g
+ `, parentID).Scan(&group.Mode, &group.FallbackAfter, &current)
+	if err != nil {
+		return "", fmt.Errorf("error locking notification group: %w", err)
+	}
+	// отмененное пользователем уведомление остается отмененным, следующие каналы не запускаются
+	if current == models.StatusCanceled {
+		if err = tx.Commit(); err != nil {
+			return "", fmt.Errorf("error committing transaction: %w", err)
+		}
+		return current, nil
+	}
+
+	targets, err := queryTargets(ctx, tx, parentID)
+	if err != nil {
+		return "", err
+	}
+	if group.Mode == models.DeliveryModeFallback {
+		if next := nextFallback(targets, time.Duration(group.FallbackAfter)*time.Second, time.Now()); next != nil {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE notifications SET status=$1, scheduled_at=now(), updated_at=now() WHERE id=$2 AND status=$3`,
+				models.StatusScheduled, next.ID, models.StatusStandby)
+			if err != nil {
+				return "", fmt.Errorf("error activating fallback target: %w", err)
+			}
+			next.Status = models.StatusScheduled
+		}
+	}
+
+	statuses := make([]models.Status, 0, len(targets))
+	for _, target := range targets {
+		statuses = append(statuses, target.Status)
+	}
+	status = models.GroupStatus(group.Mode, statuses)
+	_, err = tx.ExecContext(ctx, `UPDATE notifications SET status=$1, updated_at=now() WHERE id=$2`, status, parentID)
+	if err != nil {
+		return "", fmt.Errorf("error updating group status: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %w", err)
+	}
+	return status, nil
+}
+
+// nextFallback возвращает канал в статусе standby, который пора запустить, или nil.
+// Каналы запускаются по порядку, поэтому решение принимается по последнему запущенному.
+func nextFallback(targets []*models.Notification, after time.Duration, now time.Time) *models.Notification {
+	for i, target := range targets {
+		if target.Status == models.StatusSent {
+			return nil
+		}
+		if target.Status != models.StatusStandby {
+			continue
+		}
+		if i == 0 {
+			return target
+		}
+		switch prev := targets[i-1]; prev.Status {
+		case models.StatusFailed, models.StatusCanceled, models.StatusBounced, models.StatusSuppressed:
+			return target
+		case models.StatusScheduled, models.StatusProcessing:
+			if after > 0 && !now.Before(prev.ScheduledAt.Add(after)) {
+				return target
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// PendingGroups возвращает ID незавершенных групп, статус которых нужно пересчитать:
+// ни один канал не ожидает отправки (например, канал отменили отдельно или воркер
+// не успел пересчитать статус), либо в режиме fallback текущий канал не доставил
+// уведомление за fallback_after секунд и есть канал в ожидании.
+func (r *notificationRepo) PendingGroups(ctx context.Context) ([]string, error) {
+	query := `
+  SELECT g.notification_id
+  FROM notification_groups g
+  JOIN notifications p ON p.id = g.notification_id
+  WHERE p.status IN ($1, $2)
+   AND (
+    NOT EXISTS (SELECT 1 FROM notifications c WHERE c.parent_id = g.notification_id AND c.status IN ($1, $2))
+    OR (
+     g.mode = $3 AND g.fallback_after > 0
+     AND EXISTS (SELECT 1 FROM notifications c WHERE c.parent_id = g.notification_id AND c.status = $4)
+     AND EXISTS (
+      SELECT 1 FROM notifications c
+      WHERE c.parent_id = g.notification_id AND c.status IN ($1, $2)
+       AND c.scheduled_at + make_interval(secs => g.fallback_after) <= now()
+     )
+    )
+   )
+ `
+	rows, err := r.db.QueryContext(ctx, query, models.StatusScheduled, models.StatusProcessing, models.DeliveryModeFallback, models.StatusStandby)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pending groups: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning group id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+	return ids, nil
+}
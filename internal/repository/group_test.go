@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+func TestNotificationRepo_CreateGroup(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	scheduledAt := time.Now().Add(time.Hour)
+	telegram := &models.Notification{
+		Type: models.NotificationTypeTelegram, Status: models.StatusScheduled, ScheduledAt: scheduledAt,
+		Payload: &models.TelegramNotification{ChatID: "123", Message: "Hello"},
+	}
+	email := &models.Notification{
+		Type: models.NotificationTypeEmail, Status: models.StatusStandby, ScheduledAt: scheduledAt,
+		Payload: &models.EmailNotification{Email: "user@example.com", Subject: "Hi", Message: "Hello"},
+	}
+	n := &models.Notification{
+		Type: models.NotificationTypeMulti, Status: models.StatusScheduled, ScheduledAt: scheduledAt,
+		Group: &models.NotificationGroup{Mode: models.DeliveryModeFallback, FallbackAfter: 600, Targets: []*models.Notification{telegram, email}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO notifications (type, status, scheduled_at, retries, category, template_id, template_version, variables)`)).
+		WithArgs(models.NotificationTypeMulti, models.StatusScheduled, scheduledAt, 0, "", nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("parent-1"))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO notification_groups (notification_id, mode, fallback_after)`)).
+		WithArgs("parent-1", models.DeliveryModeFallback, 600).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO notifications (type, status, scheduled_at, retries, parent_id, position, category, template_id, template_version, variables)`)).
+		WithArgs(models.NotificationTypeTelegram, models.StatusScheduled, scheduledAt, 0, "parent-1", 0, "", nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("child-1"))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO telegram_notifications`)).
+		WithArgs(sqlmock.AnyArg(), "child-1", "123", "Hello", []byte("{}")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO notifications (type, status, scheduled_at, retries, parent_id, position, category, template_id, template_version, variables)`)).
+		WithArgs(models.NotificationTypeEmail, models.StatusStandby, scheduledAt, 0, "parent-1", 1, "", nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("child-2"))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO email_notifications`)).
+		WithArgs(sqlmock.AnyArg(), "child-2", "user@example.com", "Hi", "Hello", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	id, err := repo.Create(context.Background(), n)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "parent-1", id)
+	assert.Equal(t, "parent-1", email.ParentID)
+	assert.Equal(t, "child-2", email.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_GetAllGroup(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	scheduledAt := time.Now().Add(time.Hour)
+	// дочерние уведомления не попадают в список: получатель канала находит группу
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		FROM notifications
+		WHERE notifications.parent_id IS NULL AND (EXISTS (SELECT 1 FROM email_notifications e WHERE e.notification_id = notifications.id AND lower(e.email) = lower($1))
+		OR notifications.id IN (SELECT notifications.parent_id FROM notifications WHERE notifications.parent_id IS NOT NULL AND (EXISTS (SELECT 1 FROM email_notifications e WHERE e.notification_id = notifications.id AND lower(e.email) = lower($1))
+	`)).WithArgs("123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"}).
+			AddRow("parent-1", "multi", "scheduled", scheduledAt, 0, "", "", "", "", 0, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT mode, fallback_after FROM notification_groups WHERE notification_id = $1`)).
+		WithArgs("parent-1").
+		WillReturnRows(sqlmock.NewRows([]string{"mode", "fallback_after"}).AddRow("all", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, type, status, scheduled_at, retries FROM notifications WHERE parent_id = $1 ORDER BY position`)).
+		WithArgs("parent-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries"}).
+			AddRow("child-1", "telegram", "scheduled", scheduledAt, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT chat_id, message, options FROM telegram_notifications WHERE notification_id = $1`)).
+		WithArgs("child-1").
+		WillReturnRows(sqlmock.NewRows([]string{"chat_id", "message", "options"}).AddRow("123", "Hello", []byte("{}")))
+
+	notifications, err := repo.GetAll(context.Background(), models.NotificationFilter{Recipient: "123"})
+	if !assert.NoError(t, err) || !assert.Len(t, notifications, 1) {
+		return
+	}
+	assert.Equal(t, "parent-1", notifications[0].ID)
+	if assert.NotNil(t, notifications[0].Group) && assert.Len(t, notifications[0].Group.Targets, 1) {
+		assert.Equal(t, "123", models.PayloadOf[models.TelegramNotification](notifications[0].Group.Targets[0]).ChatID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_SyncGroup(t *testing.T) {
+	lockQuery := regexp.QuoteMeta(`SELECT g.mode, g.fallback_after, p.status FROM notification_groups g`)
+	targetsQuery := regexp.QuoteMeta(`SELECT id, type, status, scheduled_at, retries FROM notifications WHERE parent_id = $1 ORDER BY position`)
+	targetColumns := []string{"id", "type", "status", "scheduled_at", "retries"}
+	now := time.Now()
+
+	t.Run("FallbackAfterFailure", func(t *testing.T) {
+		repo, mock, cleanup := newTestRepo(t)
+		defer cleanup()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs("parent-1").
+			WillReturnRows(sqlmock.NewRows([]string{"mode", "fallback_after", "status"}).AddRow("fallback", 600, "scheduled"))
+		mock.ExpectQuery(targetsQuery).WithArgs("parent-1").
+			WillReturnRows(sqlmock.NewRows(targetColumns).
+				AddRow("child-1", "telegram", "failed", now, 3).
+				AddRow("child-2", "email", "standby", now, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE notifications SET status=$1, scheduled_at=now(), updated_at=now() WHERE id=$2 AND status=$3`)).
+			WithArgs(models.StatusScheduled, "child-2", models.StatusStandby).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE notifications SET status=$1, updated_at=now() WHERE id=$2`)).
+			WithArgs(models.StatusProcessing, "parent-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		status, err := repo.SyncGroup(context.Background(), "parent-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusProcessing, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("AllPartiallySent", func(t *testing.T) {
+		repo, mock, cleanup := newTestRepo(t)
+		defer cleanup()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs("parent-1").
+			WillReturnRows(sqlmock.NewRows([]string{"mode", "fallback_after", "status"}).AddRow("all", 0, "processing"))
+		mock.ExpectQuery(targetsQuery).WithArgs("parent-1").
+			WillReturnRows(sqlmock.NewRows(targetColumns).
+				AddRow("child-1", "telegram", "sent", now, 0).
+				AddRow("child-2", "email", "failed", now, 3))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE notifications SET status=$1, updated_at=now() WHERE id=$2`)).
+			WithArgs(models.StatusPartiallySent, "parent-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		status, err := repo.SyncGroup(context.Background(), "parent-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusPartiallySent, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		repo, mock, cleanup := newTestRepo(t)
+		defer cleanup()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs("parent-1").
+			WillReturnRows(sqlmock.NewRows([]string{"mode", "fallback_after", "status"}).AddRow("fallback", 0, "canceled"))
+		mock.ExpectCommit()
+
+		status, err := repo.SyncGroup(context.Background(), "parent-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusCanceled, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestNextFallback(t *testing.T) {
+	now := time.Now()
+	targets := func(statuses ...models.Status) []*models.Notification {
+		var ns []*models.Notification
+		for i, s := range statuses {
+			ns = append(ns, &models.Notification{ID: string(rune('a' + i)), Status: s, ScheduledAt: now.Add(-time.Minute)})
+		}
+		return ns
+	}
+
+	assert.Nil(t, nextFallback(targets(models.StatusProcessing, models.StatusStandby), 0, now))
+	assert.Nil(t, nextFallback(targets(models.StatusProcessing, models.StatusStandby), time.Hour, now))
+	assert.Nil(t, nextFallback(targets(models.StatusSent, models.StatusStandby), 0, now))
+	assert.Equal(t, "b", nextFallback(targets(models.StatusProcessing, models.StatusStandby), time.Minute, now).ID)
+	assert.Equal(t, "c", nextFallback(targets(models.StatusFailed, models.StatusCanceled, models.StatusStandby), 0, now).ID)
+	assert.Nil(t, nextFallback(targets(models.StatusFailed, models.StatusScheduled, models.StatusStandby), 0, now))
+	// письмо вернулось после отправки — запускается следующий канал
+	assert.Equal(t, "b", nextFallback(targets(models.StatusBounced, models.StatusStandby), 0, now).ID)
+	assert.Equal(t, "b", nextFallback(targets(models.StatusSuppressed, models.StatusStandby), 0, now).ID)
+}
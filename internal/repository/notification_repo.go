@@ -5,33 +5,79 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
-	"github.com/google/uuid"
 )
 
-// NotificationRepository определяет методы для работы с уведомлениями в базе данных.
-type NotificationRepository interface {
+// NotificationStore хранит уведомления и отдает их API.
+type NotificationStore interface {
 	Create(ctx context.Context, n *models.Notification) (string, error)
 	GetByID(ctx context.Context, id string) (*models.Notification, error)
-	GetAll(ctx context.Context) ([]*models.Notification, error)
+	GetAll(ctx context.Context, filter models.NotificationFilter) ([]*models.Notification, error)
+	Export(ctx context.Context, filter models.NotificationFilter, fn func(n *models.Notification) error) error
+	CountByStatus(ctx context.Context, filter models.NotificationFilter) (map[models.Status]int, error)
 	UpdateStatus(ctx context.Context, id string, status models.Status) error
 	Cancel(ctx context.Context, id string) error
+}
+
+// DeliveryStore хранит состояние доставки уведомлений: резервирование планировщиком,
+// попытки, ошибки, недоставки и статусы групп.
+type DeliveryStore interface {
 	ReservePending(ctx context.Context, limit int) ([]*models.Notification, error)
 	IncrementRetries(ctx context.Context, id string) error
+	RecordSegments(ctx context.Context, id string, segments int) error
+	RecordFailure(ctx context.Context, id, class, reason string) error
+	RecordProviderMessageID(ctx context.Context, id, messageID string) error
+	RecordBounce(ctx context.Context, messageID, class, reason string) (*models.Notification, error)
+	SyncGroup(ctx context.Context, parentID string) (models.Status, error)
+	PendingGroups(ctx context.Context) ([]string, error)
+	Reschedule(ctx context.Context, id string, at time.Time) error
+}
+
+// NotificationRepository определяет методы для работы с уведомлениями в базе данных.
+type NotificationRepository interface {
+	NotificationStore
+	DeliveryStore
+	SuppressionStore
+	PreferenceStore
+	TemplateStore
+	TelegramLinkStore
 }
 
 type notificationRepo struct {
-	db *sql.DB
+	db       *sql.DB
+	storages map[models.NotificationType]ChannelStorage
 }
 
 // NewNotificationRepo создает новый экземпляр NotificationRepository.
-func NewNotificationRepo(db *sql.DB) NotificationRepository {
-	return &notificationRepo{db: db}
+// storages задает хранилища данных для каждого зарегистрированного канала доставки;
+// хранилище уведомлений с несколькими каналами добавляется автоматически.
+func NewNotificationRepo(db *sql.DB, storages map[models.NotificationType]ChannelStorage) NotificationRepository {
+	all := make(map[models.NotificationType]ChannelStorage, len(storages)+1)
+	for t, s := range storages {
+		all[t] = s
+	}
+	all[models.NotificationTypeMulti] = groupStorage{storages: storages}
+	return &notificationRepo{db: db, storages: all}
+}
+
+// storage возвращает хранилище данных канала уведомления.
+func (r *notificationRepo) storage(t models.NotificationType) (ChannelStorage, error) {
+	s, ok := r.storages[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown notification type: %s", t)
+	}
+	return s, nil
 }
 
 // Create создает новое уведомление в базе данных.
 func (r *notificationRepo) Create(ctx context.Context, req *models.Notification) (string, error) {
+	storage, err := r.storage(req.Type)
+	if err != nil {
+		return "", err
+	}
+
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return "", fmt.Errorf("error starting transaction: %w", err)
@@ -43,40 +89,24 @@ func (r *notificationRepo) Create(ctx context.Context, req *models.Notification)
 	}()
 	// 1. Вставляем данные в таблицу notifications
 	notificationQuery := `
-  INSERT INTO notifications (type, status, scheduled_at, retries)
-  VALUES ($1, $2, $3, $4)
+  INSERT INTO notifications (type, status, scheduled_at, retries, category, template_id, template_version, variables)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
   RETURNING id
  `
+	tmpl, err := templateColumns(req)
+	if err != nil {
+		return "", err
+	}
 	var notificationID string
-	err = tx.QueryRowContext(ctx, notificationQuery, req.Type, req.Status, req.ScheduledAt, req.Retries).Scan(&notificationID)
+	err = tx.QueryRowContext(ctx, notificationQuery, append([]any{req.Type, req.Status, req.ScheduledAt, req.Retries, req.Category}, tmpl...)...).Scan(&notificationID)
 	if err != nil {
 		return "", fmt.Errorf("error inserting into notifications: %w", err)
 	}
 
-	// 2. В зависимости от типа уведомления, вставляем данные в соответствующую таблицу
-	switch req.Type {
-	case "email":
-		emailID := uuid.New().String()
-		emailQuery := `
-   INSERT INTO email_notifications (id, notification_id, email, subject, message)
-   VALUES ($1, $2, $3, $4, $5)
-  `
-		_, err = tx.ExecContext(ctx, emailQuery, emailID, notificationID, req.EmailNotification.Email, req.EmailNotification.Subject, req.EmailNotification.Message)
-		if err != nil {
-			return "", fmt.Errorf("error inserting into email_notifications: %w", err)
-		}
-	case "telegram":
-		telegramID := uuid.New().String()
-		telegramQuery := `
-   INSERT INTO telegram_notifications (id, notification_id, chat_id, message)
-   VALUES ($1, $2, $3, $4)
-  `
-		_, err = tx.ExecContext(ctx, telegramQuery, telegramID, notificationID, req.TelegramNotification.ChatID, req.TelegramNotification.Message)
-		if err != nil {
-			return "", fmt.Errorf("error inserting into telegram_notifications: %w", err)
-		}
-	default:
-		return "", fmt.Errorf("unknown notification type: %s", req.Type)
+	// 2. Данные канала сохраняет хранилище соответствующего канала
+	req.ID = notificationID
+	if err = storage.Insert(ctx, tx, req); err != nil {
+		return "", err
 	}
 
 	// Фиксируем транзакцию
@@ -92,63 +122,48 @@ func (r *notificationRepo) Create(ctx context.Context, req *models.Notification)
 func (r *notificationRepo) GetByID(ctx context.Context, id string) (*models.Notification, error) {
 	// 1. Получаем данные из таблицы notifications
 	notificationQuery := `
-        SELECT id, type, status, scheduled_at, retries
+        SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+               COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
         FROM notifications
         WHERE id = $1
     `
 	var n models.Notification
+	var variables []byte
 	err := r.db.QueryRowContext(ctx, notificationQuery, id).Scan(
-		&n.ID, &n.Type, &n.Status, &n.ScheduledAt, &n.Retries,
+		&n.ID, &n.Type, &n.Status, &n.ScheduledAt, &n.Retries, &n.FailureClass, &n.FailureReason, &n.Category,
+		&n.TemplateID, &n.TemplateVersion, &variables,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error getting notification by id: %w", err)
 	}
+	if err := decodeVariables(variables, &n); err != nil {
+		return nil, err
+	}
 
-	// 2. В зависимости от типа уведомления, получаем дополнительные данные из соответствующей таблицы
-	switch n.Type {
-	case "email":
-		n.EmailNotification = &models.EmailNotification{}
-		emailQuery := `
-            SELECT email, subject, message
-            FROM email_notifications
-            WHERE notification_id = $1
-        `
-		err = r.db.QueryRowContext(ctx, emailQuery, id).Scan(
-			&n.EmailNotification.Email, &n.EmailNotification.Subject, &n.EmailNotification.Message,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error getting email notification details: %w", err)
-		}
-	case "telegram":
-		n.TelegramNotification = &models.TelegramNotification{}
-		telegramQuery := `
-            SELECT chat_id, message
-            FROM telegram_notifications
-            WHERE notification_id = $1
-        `
-		err = r.db.QueryRowContext(ctx, telegramQuery, id).Scan(
-			&n.TelegramNotification.ChatID, &n.TelegramNotification.Message,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error getting telegram notification details: %w", err)
-		}
-	default:
-		return nil, fmt.Errorf("unknown notification type: %s", n.Type)
+	// 2. Дополнительные данные загружает хранилище канала
+	storage, err := r.storage(n.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.Load(ctx, r.db, &n); err != nil {
+		return nil, err
 	}
 
 	return &n, nil
 }
 
-// GetAll возвращает все уведомления из базы данных.
-func (r *notificationRepo) GetAll(ctx context.Context) ([]*models.Notification, error) {
+// GetAll возвращает уведомления из базы данных, подходящие под фильтр.
+func (r *notificationRepo) GetAll(ctx context.Context, filter models.NotificationFilter) ([]*models.Notification, error) {
 	notifications := []*models.Notification{}
 
 	// Получаем базовую информацию из таблицы notifications
+	where, args := r.filterCondition(filter)
 	notificationQuery := `
-        SELECT id, type, status, scheduled_at, retries
+        SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+               COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
         FROM notifications
-    `
-	rows, err := r.db.QueryContext(ctx, notificationQuery)
+    ` + where + pageClause(filter, &args)
+	rows, err := r.db.QueryContext(ctx, notificationQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying notifications: %w", err)
 	}
@@ -156,42 +171,24 @@ func (r *notificationRepo) GetAll(ctx context.Context) ([]*models.Notification,
 
 	for rows.Next() {
 		var notif models.Notification
+		var variables []byte
 		err := rows.Scan(
-			&notif.ID, &notif.Type, &notif.Status, &notif.ScheduledAt, &notif.Retries,
+			&notif.ID, &notif.Type, &notif.Status, &notif.ScheduledAt, &notif.Retries, &notif.FailureClass, &notif.FailureReason, &notif.Category,
+			&notif.TemplateID, &notif.TemplateVersion, &variables,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning notification: %w", err)
 		}
+		if err := decodeVariables(variables, &notif); err != nil {
+			return nil, err
+		}
 
-		// Получаем дополнительные детали в зависимости от типа
-		switch notif.Type {
-		case "email":
-			notif.EmailNotification = &models.EmailNotification{}
-			emailQuery := `
-                SELECT email, subject, message
-                FROM email_notifications
-                WHERE notification_id = $1
-            `
-			err = r.db.QueryRowContext(ctx, emailQuery, notif.ID).Scan(
-				&notif.EmailNotification.Email, &notif.EmailNotification.Subject, &notif.EmailNotification.Message,
-			)
-			if err != nil {
-				return nil, fmt.Errorf("error getting email notification details: %w", err)
-			}
-		case "telegram":
-			notif.TelegramNotification = &models.TelegramNotification{}
-			telegramQuery := `
-                SELECT chat_id, message
-                FROM telegram_notifications
-                WHERE notification_id = $1
-            `
-			err = r.db.QueryRowContext(ctx, telegramQuery, notif.ID).Scan(
-				&notif.TelegramNotification.ChatID, &notif.TelegramNotification.Message,
-			)
-			if err != nil {
-				return nil, fmt.Errorf("error getting telegram notification details: %w", err)
+		// Получаем дополнительные детали из хранилища канала
+		if storage, ok := r.storages[notif.Type]; ok {
+			if err := storage.Load(ctx, r.db, &notif); err != nil {
+				return nil, err
 			}
-		default:
+		} else {
 			log.Printf("Unknown notification type: %s", notif.Type)
 		}
 
@@ -210,18 +207,21 @@ func (r *notificationRepo) GetAll(ctx context.Context) ([]*models.Notification,
 	return notifications, nil
 }
 
+// Cancel отменяет уведомление вместе с еще не отправленными каналами, если это группа.
 func (r *notificationRepo) Cancel(ctx context.Context, id string) error {
-	query := `UPDATE notifications SET status=$1, updated_at=now() WHERE id=$2`
+	query := `UPDATE notifications SET status=$1, updated_at=now() WHERE id=$2 OR (parent_id=$2 AND status IN ('scheduled', 'standby'))`
 	_, err := r.db.ExecContext(ctx, query, models.StatusCanceled, id)
 	return err
 }
 
+// ReservePending переводит в processing пачку уведомлений, время отправки которых наступило.
+// Уведомления, перенесенные на будущее (например, после 429 с retry_after), ждут своего времени.
 func (r *notificationRepo) ReservePending(ctx context.Context, limit int) ([]*models.Notification, error) {
 	query := `
   WITH selected_notifications AS (
    SELECT id
    FROM notifications
-   WHERE status = $1 
+   WHERE status = $1 AND type <> $4 AND scheduled_at <= now()
    ORDER BY scheduled_at
    LIMIT $2
    FOR UPDATE SKIP LOCKED
@@ -230,10 +230,12 @@ func (r *notificationRepo) ReservePending(ctx context.Context, limit int) ([]*mo
   SET status = $3,  
    updated_at = NOW()
   WHERE id IN (SELECT id FROM selected_notifications)
-  RETURNING id, type, status, scheduled_at, retries, created_at, updated_at;
+  RETURNING id, type, status, scheduled_at, retries, rate_limits, created_at, updated_at, parent_id, category,
+   COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables;
  `
 
-	rows, err := r.db.QueryContext(ctx, query, models.StatusScheduled, limit, models.StatusProcessing)
+	// родитель группы сам не отправляется: его статус выводится из статусов каналов
+	rows, err := r.db.QueryContext(ctx, query, models.StatusScheduled, limit, models.StatusProcessing, models.NotificationTypeMulti)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query for pending notifications: %w", err)
 	}
@@ -242,82 +244,59 @@ func (r *notificationRepo) ReservePending(ctx context.Context, limit int) ([]*mo
 	notifications := []*models.Notification{}
 	for rows.Next() {
 		n := &models.Notification{}
+		var parentID sql.NullString
+		var variables []byte
 		if err := rows.Scan(
-			&n.ID, &n.Type, &n.Status, &n.ScheduledAt, &n.Retries, &n.CreatedAt, &n.UpdatedAt,
+			&n.ID, &n.Type, &n.Status, &n.ScheduledAt, &n.Retries, &n.RateLimits, &n.CreatedAt, &n.UpdatedAt, &parentID, &n.Category,
+			&n.TemplateID, &n.TemplateVersion, &variables,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan notification: %w", err)
 		}
-
-		// Fetch additional details based on notification type.
-		switch n.Type {
-		case "email":
-			emailNotification, err := r.getEmailNotificationDetails(ctx, n.ID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get email notification details: %w", err)
-			}
-			log.Println("Email notification details:", emailNotification)
-			n.EmailNotification = emailNotification
-		case "telegram":
-			telegramNotification, err := r.getTelegramNotificationDetails(ctx, n.ID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get telegram notification details: %w", err)
-			}
-			log.Println("Telegram notification details:", telegramNotification)
-			n.TelegramNotification = telegramNotification
-		default:
-			return nil, fmt.Errorf("unknown notification type: %s", n.Type)
+		n.ParentID = parentID.String
+		if err := decodeVariables(variables, n); err != nil {
+			return nil, err
 		}
-
 		notifications = append(notifications, n)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating through rows: %w", err)
 	}
-
-	return notifications, nil
-}
-
-func (r *notificationRepo) getEmailNotificationDetails(ctx context.Context, notificationID string) (*models.EmailNotification, error) {
-	query := `
-  SELECT id, notification_id, email, subject, message
-  FROM email_notifications
-  WHERE notification_id = $1;
- `
-
-	row := r.db.QueryRowContext(ctx, query, notificationID)
-	emailNotification := &models.EmailNotification{}
-	err := row.Scan(&emailNotification.ID, &emailNotification.NotificationID, &emailNotification.Email, &emailNotification.Subject, &emailNotification.Message)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // No email details found is not an error. Can happen if the type is not email.
+	rows.Close()
+
+	// Данные каналов загружаем после чтения всех строк. Уведомление, данные которого загрузить
+	// не удалось, возвращается в scheduled, а не публикуется без получателя и текста: ошибка
+	// чтения обычно временная, и следующий опрос заберет его снова.
+	reserved := notifications[:0]
+	for _, n := range notifications {
+		storage, err := r.storage(n.Type)
+		if err != nil {
+			// канал не зарегистрирован: воркер отметит уведомление как failed
+			reserved = append(reserved, n)
+			continue
+		}
+		if err := storage.Load(ctx, r.db, n); err != nil {
+			log.Printf("failed to load details of notification %s: %v", n.ID, err)
+			// остальные уведомления пачки уже в processing, поэтому ошибку возврата не поднимаем выше
+			if err := r.release(ctx, n.ID); err != nil {
+				log.Printf("%v", err)
+			}
+			continue
 		}
-		return nil, fmt.Errorf("failed to scan email notification details: %w", err)
+		reserved = append(reserved, n)
 	}
 
-	return emailNotification, nil
+	return reserved, nil
 }
 
-func (r *notificationRepo) getTelegramNotificationDetails(ctx context.Context, notificationID string) (*models.TelegramNotification, error) {
-	query := `
-  SELECT id, notification_id, chat_id, message
-  FROM telegram_notifications
-  WHERE notification_id = $1;
- `
-
-	row := r.db.QueryRowContext(ctx, query, notificationID)
-	telegramNotification := &models.TelegramNotification{}
-	err := row.Scan(&telegramNotification.ID, &telegramNotification.NotificationID, &telegramNotification.ChatID, &telegramNotification.Message)
-
+// release возвращает зарезервированное уведомление в scheduled.
+func (r *notificationRepo) release(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE notifications SET status=$1, updated_at=now() WHERE id=$2 AND status=$3`,
+		models.StatusScheduled, id, models.StatusProcessing)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // No telegram details found.
-		}
-		return nil, fmt.Errorf("failed to scan telegram notification details: %w", err)
+		return fmt.Errorf("failed to release notification %s: %w", id, err)
 	}
-
-	return telegramNotification, nil
+	return nil
 }
 
 func (r *notificationRepo) IncrementRetries(ctx context.Context, id string) error {
@@ -329,3 +308,15 @@ func (r *notificationRepo) UpdateStatus(ctx context.Context, id string, status m
 	_, err := r.db.ExecContext(ctx, `UPDATE notifications SET status=$1, updated_at=now() WHERE id=$2`, status, id)
 	return err
 }
+
+// RecordSegments сохраняет количество сегментов, за которые провайдер выставит счет за уведомление.
+func (r *notificationRepo) RecordSegments(ctx context.Context, id string, segments int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE notifications SET segments=$1, updated_at=now() WHERE id=$2`, segments, id)
+	return err
+}
+
+// RecordFailure сохраняет класс и текст ошибки, после которой доставка уведомления завершилась неудачей.
+func (r *notificationRepo) RecordFailure(ctx context.Context, id, class, reason string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE notifications SET failure_class=$1, failure_reason=$2, updated_at=now() WHERE id=$3`, class, reason, id)
+	return err
+}
@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"regexp"
 	"testing"
@@ -14,6 +16,14 @@ import (
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
 )
 
+// telegramOptionsArg проверяет, что в параметрах Telegram сохраняются ссылка на файл, но не его содержимое.
+type telegramOptionsArg struct{}
+
+func (telegramOptionsArg) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	return ok && bytes.Contains(data, []byte(`"file":{"id":`)) && !bytes.Contains(data, []byte(`"content"`))
+}
+
 // helper для инициализации моков
 func newTestRepo(t *testing.T) (NotificationRepository, sqlmock.Sqlmock, func()) {
 	db, mock, err := sqlmock.New()
@@ -21,7 +31,10 @@ func newTestRepo(t *testing.T) (NotificationRepository, sqlmock.Sqlmock, func())
 		t.Fatalf("failed to create mock db: %v", err)
 	}
 
-	repo := NewNotificationRepo(db)
+	repo := NewNotificationRepo(db, map[models.NotificationType]ChannelStorage{
+		models.NotificationTypeEmail:    EmailStorage{},
+		models.NotificationTypeTelegram: TelegramStorage{},
+	})
 
 	cleanup := func() {
 		db.Close()
@@ -40,7 +53,7 @@ func TestNotificationRepo_Create(t *testing.T) {
 			Status:      models.Status("scheduled"),
 			ScheduledAt: time.Now().Add(time.Hour),
 			Retries:     0, // Добавлено поле Retries
-			EmailNotification: &models.EmailNotification{
+			Payload: &models.EmailNotification{
 				Email:   "test@example.com",
 				Subject: "Test subject",
 				Message: "Test message",
@@ -51,15 +64,16 @@ func TestNotificationRepo_Create(t *testing.T) {
 
 		mock.ExpectBegin()
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			INSERT INTO notifications (type, status, scheduled_at, retries)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO notifications (type, status, scheduled_at, retries, category, template_id, template_version, variables)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			RETURNING id
-		`)).WithArgs(req.Type, req.Status, req.ScheduledAt, req.Retries).
+		`)).WithArgs(req.Type, req.Status, req.ScheduledAt, req.Retries, req.Category, nil, nil, nil).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(expectedNotificationID))
+		email := models.PayloadOf[models.EmailNotification](req)
 		mock.ExpectExec(regexp.QuoteMeta(`
-			INSERT INTO email_notifications (id, notification_id, email, subject, message)
-			VALUES ($1, $2, $3, $4, $5)
-		`)).WithArgs(sqlmock.AnyArg(), expectedNotificationID, req.EmailNotification.Email, req.EmailNotification.Subject, req.EmailNotification.Message).
+			INSERT INTO email_notifications (id, notification_id, email, subject, message, html)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`)).WithArgs(sqlmock.AnyArg(), expectedNotificationID, email.Email, email.Subject, email.Message, email.HTML).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -77,7 +91,7 @@ func TestNotificationRepo_Create(t *testing.T) {
 			Status:      models.Status("scheduled"),
 			ScheduledAt: time.Now().Add(time.Hour),
 			Retries:     0, // Добавлено поле Retries
-			TelegramNotification: &models.TelegramNotification{
+			Payload: &models.TelegramNotification{
 				ChatID:  "123456789",
 				Message: "Test message",
 			},
@@ -87,15 +101,16 @@ func TestNotificationRepo_Create(t *testing.T) {
 
 		mock.ExpectBegin()
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			INSERT INTO notifications (type, status, scheduled_at, retries)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO notifications (type, status, scheduled_at, retries, category, template_id, template_version, variables)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			RETURNING id
-		`)).WithArgs(req.Type, req.Status, req.ScheduledAt, req.Retries).
+		`)).WithArgs(req.Type, req.Status, req.ScheduledAt, req.Retries, req.Category, nil, nil, nil).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(expectedNotificationID))
+		tg := models.PayloadOf[models.TelegramNotification](req)
 		mock.ExpectExec(regexp.QuoteMeta(`
-			INSERT INTO telegram_notifications (id, notification_id, chat_id, message)
-			VALUES ($1, $2, $3, $4)
-		`)).WithArgs(sqlmock.AnyArg(), expectedNotificationID, req.TelegramNotification.ChatID, req.TelegramNotification.Message).
+			INSERT INTO telegram_notifications (id, notification_id, chat_id, message, options)
+			VALUES ($1, $2, $3, $4, $5)
+		`)).WithArgs(sqlmock.AnyArg(), expectedNotificationID, tg.ChatID, tg.Message, []byte("{}")).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -104,6 +119,40 @@ func TestNotificationRepo_Create(t *testing.T) {
 		assert.Equal(t, expectedNotificationID, id)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+	t.Run("Success_TelegramMedia", func(t *testing.T) {
+		repo, mock, cleanup := newTestRepo(t)
+		defer cleanup()
+
+		file := &models.Attachment{Filename: "invoice.pdf", ContentType: "application/pdf", Size: 3, Content: []byte("pdf")}
+		req := &models.Notification{
+			Type:        models.NotificationTypeTelegram,
+			Status:      models.StatusScheduled,
+			ScheduledAt: time.Now().Add(time.Hour),
+			Payload: &models.TelegramNotification{
+				ChatID: "123456789",
+				TelegramOptions: models.TelegramOptions{Media: []models.TelegramMedia{
+					{Type: models.TelegramMediaPhoto, URL: "https://example.com/a.png"},
+					{Type: models.TelegramMediaDocument, File: file},
+				}},
+			},
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO notifications (type, status, scheduled_at, retries, category, template_id, template_version, variables)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("notif-789"))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO attachments (id, notification_id, position, filename, content_type, content_id, size, content)`)).
+			WithArgs(sqlmock.AnyArg(), "notif-789", 1, "invoice.pdf", "application/pdf", "", 3, []byte("pdf")).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO telegram_notifications (id, notification_id, chat_id, message, options)`)).
+			WithArgs(sqlmock.AnyArg(), "notif-789", "123456789", "", telegramOptionsArg{}).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		_, err := repo.Create(context.Background(), req)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, file.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 
 	t.Run("Error_BeginTransaction", func(t *testing.T) {
 		repo, mock, cleanup := newTestRepo(t)
@@ -114,7 +163,7 @@ func TestNotificationRepo_Create(t *testing.T) {
 			Status:      models.Status("scheduled"),
 			ScheduledAt: time.Now().Add(time.Hour),
 			Retries:     0,
-			EmailNotification: &models.EmailNotification{
+			Payload: &models.EmailNotification{
 				Email:   "test@example.com",
 				Subject: "Test subject",
 				Message: "Test message",
@@ -138,7 +187,7 @@ func TestNotificationRepo_Create(t *testing.T) {
 			Status:      models.Status("scheduled"),
 			ScheduledAt: time.Now().Add(time.Hour),
 			Retries:     0,
-			EmailNotification: &models.EmailNotification{
+			Payload: &models.EmailNotification{
 				Email:   "test@example.com",
 				Subject: "Test subject",
 				Message: "Test message",
@@ -147,10 +196,10 @@ func TestNotificationRepo_Create(t *testing.T) {
 
 		mock.ExpectBegin()
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			INSERT INTO notifications (type, status, scheduled_at, retries)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO notifications (type, status, scheduled_at, retries, category, template_id, template_version, variables)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			RETURNING id
-		`)).WithArgs(req.Type, req.Status, req.ScheduledAt, req.Retries).
+		`)).WithArgs(req.Type, req.Status, req.ScheduledAt, req.Retries, req.Category, nil, nil, nil).
 			WillReturnError(fmt.Errorf("insert error"))
 		mock.ExpectRollback()
 
@@ -169,7 +218,7 @@ func TestNotificationRepo_Create(t *testing.T) {
 			Status:      models.Status("scheduled"),
 			ScheduledAt: time.Now().Add(time.Hour),
 			Retries:     0,
-			EmailNotification: &models.EmailNotification{
+			Payload: &models.EmailNotification{
 				Email:   "test@example.com",
 				Subject: "Test subject",
 				Message: "Test message",
@@ -180,15 +229,16 @@ func TestNotificationRepo_Create(t *testing.T) {
 
 		mock.ExpectBegin()
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			INSERT INTO notifications (type, status, scheduled_at, retries)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO notifications (type, status, scheduled_at, retries, category, template_id, template_version, variables)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			RETURNING id
-		`)).WithArgs(req.Type, req.Status, req.ScheduledAt, req.Retries).
+		`)).WithArgs(req.Type, req.Status, req.ScheduledAt, req.Retries, req.Category, nil, nil, nil).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(expectedNotificationID))
+		email := models.PayloadOf[models.EmailNotification](req)
 		mock.ExpectExec(regexp.QuoteMeta(`
-			INSERT INTO email_notifications (id, notification_id, email, subject, message)
-			VALUES ($1, $2, $3, $4, $5)
-		`)).WithArgs(sqlmock.AnyArg(), expectedNotificationID, req.EmailNotification.Email, req.EmailNotification.Subject, req.EmailNotification.Message).
+			INSERT INTO email_notifications (id, notification_id, email, subject, message, html)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`)).WithArgs(sqlmock.AnyArg(), expectedNotificationID, email.Email, email.Subject, email.Message, email.HTML).
 			WillReturnError(fmt.Errorf("email insert error"))
 		mock.ExpectRollback()
 
@@ -213,7 +263,7 @@ func TestNotificationRepo_GetByID(t *testing.T) {
 			Status:      "scheduled",
 			ScheduledAt: time.Now().Add(time.Hour),
 			Retries:     0,
-			EmailNotification: &models.EmailNotification{
+			Payload: &models.EmailNotification{
 				Email:   "test@example.com",
 				Subject: "Test Subject",
 				Message: "Test Message",
@@ -223,20 +273,18 @@ func TestNotificationRepo_GetByID(t *testing.T) {
 
 		// Описываем ожидаемые запросы и возвращаемые значения
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 			WHERE id = $1
 		`)).WithArgs(notificationID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries"}).
-				AddRow(expectedNotification.ID, expectedNotification.Type, expectedNotification.Status, scheduledAt, expectedNotification.Retries))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"}).
+				AddRow(expectedNotification.ID, expectedNotification.Type, expectedNotification.Status, scheduledAt, expectedNotification.Retries, "", "", "", "", 0, nil))
 
-		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT email, subject, message
-			FROM email_notifications
-			WHERE notification_id = $1
-		`)).WithArgs(notificationID).
-			WillReturnRows(sqlmock.NewRows([]string{"email", "subject", "message"}).
-				AddRow(expectedNotification.EmailNotification.Email, expectedNotification.EmailNotification.Subject, expectedNotification.EmailNotification.Message))
+		email := models.PayloadOf[models.EmailNotification](expectedNotification)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT email, subject, message, html,`)).WithArgs(notificationID).
+			WillReturnRows(sqlmock.NewRows([]string{"email", "subject", "message", "html", "attachments"}).
+				AddRow(email.Email, email.Subject, email.Message, "", []byte("[]")))
 
 		// Вызываем тестируемую функцию
 		notification, err := repo.GetByID(context.Background(), notificationID)
@@ -260,7 +308,7 @@ func TestNotificationRepo_GetByID(t *testing.T) {
 			Status:      "scheduled",
 			ScheduledAt: time.Now().Add(time.Hour),
 			Retries:     0,
-			TelegramNotification: &models.TelegramNotification{
+			Payload: &models.TelegramNotification{
 				ChatID:  "123456789",
 				Message: "Telegram Message",
 			},
@@ -269,20 +317,22 @@ func TestNotificationRepo_GetByID(t *testing.T) {
 
 		// Описываем ожидаемые запросы и возвращаемые значения
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 			WHERE id = $1
 		`)).WithArgs(notificationID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries"}).
-				AddRow(expectedNotification.ID, expectedNotification.Type, expectedNotification.Status, scheduledAt, expectedNotification.Retries))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"}).
+				AddRow(expectedNotification.ID, expectedNotification.Type, expectedNotification.Status, scheduledAt, expectedNotification.Retries, "", "", "", "", 0, nil))
 
+		tg := models.PayloadOf[models.TelegramNotification](expectedNotification)
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT chat_id, message
+			SELECT chat_id, message, options
 			FROM telegram_notifications
 			WHERE notification_id = $1
 		`)).WithArgs(notificationID).
-			WillReturnRows(sqlmock.NewRows([]string{"chat_id", "message"}).
-				AddRow(expectedNotification.TelegramNotification.ChatID, expectedNotification.TelegramNotification.Message))
+			WillReturnRows(sqlmock.NewRows([]string{"chat_id", "message", "options"}).
+				AddRow(tg.ChatID, tg.Message, []byte("{}")))
 
 		// Вызываем тестируемую функцию
 		notification, err := repo.GetByID(context.Background(), notificationID)
@@ -301,7 +351,8 @@ func TestNotificationRepo_GetByID(t *testing.T) {
 
 		// Ожидаем, что QueryRowContext вернет sql.ErrNoRows
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 			WHERE id = $1
 		`)).WithArgs(notificationID).
@@ -325,7 +376,8 @@ func TestNotificationRepo_GetByID(t *testing.T) {
 
 		// Мокируем ошибку БД
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 			WHERE id = $1
 		`)).WithArgs(notificationID).
@@ -350,12 +402,13 @@ func TestNotificationRepo_GetByID(t *testing.T) {
 
 		// Мокируем возврат уведомления с неизвестным типом
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 			WHERE id = $1
 		`)).WithArgs(notificationID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries"}).
-				AddRow(notificationID, "unknown", "scheduled", time.Now(), 0)) // "unknown" тип
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"}).
+				AddRow(notificationID, "unknown", "scheduled", time.Now(), 0, "", "", "", "", 0, nil)) // "unknown" тип
 
 		// Вызываем тестируемую функцию
 		notification, err := repo.GetByID(context.Background(), notificationID)
@@ -381,7 +434,7 @@ func TestNotificationRepo_GetAll(t *testing.T) {
 				Status:      "scheduled",
 				ScheduledAt: time.Now().Add(time.Hour),
 				Retries:     0,
-				EmailNotification: &models.EmailNotification{
+				Payload: &models.EmailNotification{
 					Email:   "test1@example.com",
 					Subject: "Subject 1",
 					Message: "Message 1",
@@ -393,7 +446,7 @@ func TestNotificationRepo_GetAll(t *testing.T) {
 				Status:      "pending",
 				ScheduledAt: time.Now().Add(2 * time.Hour),
 				Retries:     1,
-				TelegramNotification: &models.TelegramNotification{
+				Payload: &models.TelegramNotification{
 					ChatID:  "12345",
 					Message: "Telegram Message 1",
 				},
@@ -401,38 +454,37 @@ func TestNotificationRepo_GetAll(t *testing.T) {
 		}
 
 		// Мокируем первый запрос (получение базовой информации)
-		rows := sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries"})
+		rows := sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"})
 		scheduledAtEmail := expectedNotifications[0].ScheduledAt
 		scheduledAtTelegram := expectedNotifications[1].ScheduledAt
 
-		rows.AddRow(expectedNotifications[0].ID, expectedNotifications[0].Type, expectedNotifications[0].Status, scheduledAtEmail, expectedNotifications[0].Retries)
-		rows.AddRow(expectedNotifications[1].ID, expectedNotifications[1].Type, expectedNotifications[1].Status, scheduledAtTelegram, expectedNotifications[1].Retries)
+		rows.AddRow(expectedNotifications[0].ID, expectedNotifications[0].Type, expectedNotifications[0].Status, scheduledAtEmail, expectedNotifications[0].Retries, "", "", "", "", 0, nil)
+		rows.AddRow(expectedNotifications[1].ID, expectedNotifications[1].Type, expectedNotifications[1].Status, scheduledAtTelegram, expectedNotifications[1].Retries, "", "", "", "", 0, nil)
 
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 		`)).WillReturnRows(rows)
 
 		// Мокируем запросы для email уведомления
-		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT email, subject, message
-			FROM email_notifications
-			WHERE notification_id = $1
-		`)).WithArgs(expectedNotifications[0].ID).
-			WillReturnRows(sqlmock.NewRows([]string{"email", "subject", "message"}).
-				AddRow(expectedNotifications[0].EmailNotification.Email, expectedNotifications[0].EmailNotification.Subject, expectedNotifications[0].EmailNotification.Message))
+		email := models.PayloadOf[models.EmailNotification](expectedNotifications[0])
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT email, subject, message, html,`)).WithArgs(expectedNotifications[0].ID).
+			WillReturnRows(sqlmock.NewRows([]string{"email", "subject", "message", "html", "attachments"}).
+				AddRow(email.Email, email.Subject, email.Message, "", []byte("[]")))
 
 		// Мокируем запросы для telegram уведомления
+		tg := models.PayloadOf[models.TelegramNotification](expectedNotifications[1])
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT chat_id, message
+			SELECT chat_id, message, options
 			FROM telegram_notifications
 			WHERE notification_id = $1
 		`)).WithArgs(expectedNotifications[1].ID).
-			WillReturnRows(sqlmock.NewRows([]string{"chat_id", "message"}).
-				AddRow(expectedNotifications[1].TelegramNotification.ChatID, expectedNotifications[1].TelegramNotification.Message))
+			WillReturnRows(sqlmock.NewRows([]string{"chat_id", "message", "options"}).
+				AddRow(tg.ChatID, tg.Message, []byte("{}")))
 
 		// Вызываем тестируемую функцию
-		notifications, err := repo.GetAll(context.Background())
+		notifications, err := repo.GetAll(context.Background(), models.NotificationFilter{})
 
 		// Проверяем результаты
 		assert.NoError(t, err)
@@ -446,12 +498,13 @@ func TestNotificationRepo_GetAll(t *testing.T) {
 
 		// Мокируем запрос, возвращающий пустой результат
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
-		`)).WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries"}))
+		`)).WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"}))
 
 		// Вызываем тестируемую функцию
-		notifications, err := repo.GetAll(context.Background())
+		notifications, err := repo.GetAll(context.Background(), models.NotificationFilter{})
 
 		// Проверяем результаты
 		assert.Error(t, err)
@@ -467,12 +520,13 @@ func TestNotificationRepo_GetAll(t *testing.T) {
 
 		// Мокируем ошибку при запросе notifications
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 		`)).WillReturnError(fmt.Errorf("database connection error"))
 
 		// Вызываем тестируемую функцию
-		notifications, err := repo.GetAll(context.Background())
+		notifications, err := repo.GetAll(context.Background(), models.NotificationFilter{})
 
 		// Проверяем результаты
 		assert.Error(t, err)
@@ -487,22 +541,19 @@ func TestNotificationRepo_GetAll(t *testing.T) {
 		defer cleanup()
 
 		// Мокируем основной запрос
-		rows := sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries"})
-		rows.AddRow("email-1", "email", "scheduled", time.Now(), 0) // Тип "email"
+		rows := sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"})
+		rows.AddRow("email-1", "email", "scheduled", time.Now(), 0, "", "", "", "", 0, nil) // Тип "email"
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 		`)).WillReturnRows(rows)
 
 		// Мокируем ошибку при запросе деталей email
-		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT email, subject, message
-			FROM email_notifications
-			WHERE notification_id = $1
-		`)).WithArgs("email-1").WillReturnError(fmt.Errorf("database connection error"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT email, subject, message, html,`)).WithArgs("email-1").WillReturnError(fmt.Errorf("database connection error"))
 
 		// Вызываем тестируемую функцию
-		notifications, err := repo.GetAll(context.Background())
+		notifications, err := repo.GetAll(context.Background(), models.NotificationFilter{})
 
 		// Проверяем результаты
 		assert.Error(t, err)
@@ -517,14 +568,15 @@ func TestNotificationRepo_GetAll(t *testing.T) {
 		defer cleanup()
 
 		// Мокируем основной запрос, но с ошибкой при сканировании
-		rows := sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries"}).AddRow("id", "type", "status", "not-a-time", 0) // Некорректный тип для scheduled_at
+		rows := sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"}).AddRow("id", "type", "status", "not-a-time", 0, "", "", "", "", 0, nil) // Некорректный тип для scheduled_at
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 		`)).WillReturnRows(rows)
 
 		// Вызываем тестируемую функцию
-		notifications, err := repo.GetAll(context.Background())
+		notifications, err := repo.GetAll(context.Background(), models.NotificationFilter{})
 
 		// Проверяем результаты
 		assert.Error(t, err)
@@ -538,21 +590,22 @@ func TestNotificationRepo_GetAll(t *testing.T) {
 		defer cleanup()
 
 		// Мокируем основной запрос
-		rows := sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries"})
-		rows.AddRow("unknown-1", "unknown", "scheduled", time.Now(), 0) // Неизвестный тип
+		rows := sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"})
+		rows.AddRow("unknown-1", "unknown", "scheduled", time.Now(), 0, "", "", "", "", 0, nil) // Неизвестный тип
 		mock.ExpectQuery(regexp.QuoteMeta(`
-			SELECT id, type, status, scheduled_at, retries
+			SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
 			FROM notifications
 		`)).WillReturnRows(rows)
 
 		// Вызываем тестируемую функцию
-		notifications, err := repo.GetAll(context.Background())
+		notifications, err := repo.GetAll(context.Background(), models.NotificationFilter{})
 
 		// Проверяем результаты
-		assert.NoError(t, err)                            //  Функция не должна возвращать ошибку, она логирует
-		assert.Len(t, notifications, 1)                   // Возвращает уведомление с заполненными базовыми полями
-		assert.Nil(t, notifications[0].EmailNotification) // Убедимся, что дополнительные поля не заполнены
-		assert.Nil(t, notifications[0].TelegramNotification)
+		assert.NoError(t, err)                                                      //  Функция не должна возвращать ошибку, она логирует
+		assert.Len(t, notifications, 1)                                             // Возвращает уведомление с заполненными базовыми полями
+		assert.Nil(t, models.PayloadOf[models.EmailNotification](notifications[0])) // Убедимся, что дополнительные поля не заполнены
+		assert.Nil(t, models.PayloadOf[models.TelegramNotification](notifications[0]))
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
@@ -641,3 +694,203 @@ func TestNotificationRepo_Cancel(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
+
+func TestNotificationRepo_ReservePending(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	now := time.Now()
+	columns := []string{"id", "type", "status", "scheduled_at", "retries", "rate_limits", "created_at", "updated_at", "parent_id", "category", "template_id", "template_version", "variables"}
+	// уведомления, перенесенные на будущее, не резервируются раньше времени
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE status = $1 AND type <> $4 AND scheduled_at <= now()
+   ORDER BY scheduled_at
+   LIMIT $2
+   FOR UPDATE SKIP LOCKED`)).
+		WithArgs(models.StatusScheduled, 10, models.StatusProcessing, models.NotificationTypeMulti).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("n-1", "email", "processing", now, 3, 2, now, now, nil, "", "", 0, nil).
+			AddRow("n-2", "email", "processing", now, 0, 0, now, now, nil, "", "", 0, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT email, subject, message, html,`)).WithArgs("n-1").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "subject", "message", "html", "attachments"}).
+			AddRow("test@example.com", "Subject", "Message", "", []byte("[]")))
+	// данные второго уведомления не загрузились: оно возвращается в scheduled и не публикуется
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT email, subject, message, html,`)).WithArgs("n-2").
+		WillReturnError(fmt.Errorf("connection reset"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE notifications SET status=$1, updated_at=now() WHERE id=$2 AND status=$3`)).
+		WithArgs(models.StatusScheduled, "n-2", models.StatusProcessing).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	notifications, err := repo.ReservePending(context.Background(), 10)
+
+	assert.NoError(t, err)
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, "n-1", notifications[0].ID)
+		assert.Equal(t, 3, notifications[0].Retries)
+		assert.Equal(t, 2, notifications[0].RateLimits)
+		assert.Equal(t, "test@example.com", models.PayloadOf[models.EmailNotification](notifications[0]).Email)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_RecordFailure(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE notifications SET failure_class=$1, failure_reason=$2, updated_at=now() WHERE id=$3`)).
+		WithArgs("permanent", "telegram api error 400: Bad Request: chat not found", "notification-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,`)).
+		WithArgs("notification-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"}).
+			AddRow("notification-1", "telegram", "failed", time.Now(), 1, "permanent", "telegram api error 400: Bad Request: chat not found", "", "", 0, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT chat_id, message, options FROM telegram_notifications WHERE notification_id = $1`)).
+		WithArgs("notification-1").
+		WillReturnRows(sqlmock.NewRows([]string{"chat_id", "message", "options"}).AddRow("12345", "Hello", []byte("{}")))
+
+	err := repo.RecordFailure(context.Background(), "notification-1", "permanent", "telegram api error 400: Bad Request: chat not found")
+	assert.NoError(t, err)
+	n, err := repo.GetByID(context.Background(), "notification-1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "permanent", n.FailureClass)
+	assert.Equal(t, "telegram api error 400: Bad Request: chat not found", n.FailureReason)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_GetAllWithFilter(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	from := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,
+			       COALESCE(template_id::text, ''), COALESCE(template_version, 0), variables
+		FROM notifications
+		WHERE notifications.parent_id IS NULL
+		AND (notifications.type = $1 OR notifications.id IN (SELECT notifications.parent_id FROM notifications WHERE notifications.parent_id IS NOT NULL AND notifications.type = $1))
+		AND notifications.status = $2
+		AND (EXISTS (SELECT 1 FROM email_notifications e WHERE e.notification_id = notifications.id AND lower(e.email) = lower($3))
+		OR notifications.id IN (SELECT notifications.parent_id FROM notifications WHERE notifications.parent_id IS NOT NULL AND (EXISTS (SELECT 1 FROM email_notifications e WHERE e.notification_id = notifications.id AND lower(e.email) = lower($3)))))
+		AND notifications.scheduled_at >= $4 AND notifications.scheduled_at < $5
+	`)).WithArgs(models.NotificationTypeEmail, models.StatusSent, "test@example.com", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"}))
+
+	_, err := repo.GetAll(context.Background(), models.NotificationFilter{
+		Type:      models.NotificationTypeEmail,
+		Status:    models.StatusSent,
+		Recipient: "test@example.com",
+		From:      from,
+		To:        to,
+	})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_Export(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	scheduledAt := time.Date(2025, 11, 10, 10, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DECLARE notifications_export NO SCROLL CURSOR FOR`)).
+		WithArgs("12345").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FETCH 500 FROM notifications_export`)).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "type", "status", "scheduled_at", "retries", "created_at", "updated_at", "segments",
+			"failure_class", "failure_reason", "history",
+		}).AddRow(
+			"telegram-1", "telegram", "sent", scheduledAt, 0, scheduledAt, scheduledAt, 0, "", "",
+			`[{"status":"scheduled","changed_at":"2025-11-09T10:00:00Z"},{"status":"sent","changed_at":"2025-11-10T10:00:01Z"}]`,
+		))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT chat_id, message, options FROM telegram_notifications WHERE notification_id = $1`)).
+		WithArgs("telegram-1").
+		WillReturnRows(sqlmock.NewRows([]string{"chat_id", "message", "options"}).AddRow("12345", "Hello", []byte("{}")))
+	mock.ExpectRollback()
+
+	var exported []*models.Notification
+	err := repo.Export(context.Background(), models.NotificationFilter{Recipient: "12345"}, func(n *models.Notification) error {
+		exported = append(exported, n)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, exported, 1) {
+		assert.Nil(t, models.PayloadOf[models.EmailNotification](exported[0]))
+		assert.Equal(t, "12345", models.PayloadOf[models.TelegramNotification](exported[0]).ChatID)
+		assert.Len(t, exported[0].History, 2)
+		assert.Equal(t, models.StatusSent, exported[0].History[1].Status)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPayloadStorage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	type payload struct {
+		URL string `json:"url"`
+	}
+	storage := PayloadStorage{
+		New:          func() any { return &payload{} },
+		RecipientKey: "url",
+	}
+	repo := NewNotificationRepo(db, map[models.NotificationType]ChannelStorage{"webhook": storage})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO notifications (type, status, scheduled_at, retries, category, template_id, template_version, variables)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("hook-1"))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO notification_payloads (notification_id, payload) VALUES ($1, $2)`)).
+		WithArgs("hook-1", []byte(`{"url":"https://example.com/hook"}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	id, err := repo.Create(context.Background(), &models.Notification{Type: "webhook", Status: models.StatusScheduled, Payload: &payload{URL: "https://example.com/hook"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "hook-1", id)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, type, status, scheduled_at, retries, COALESCE(failure_class, ''), COALESCE(failure_reason, ''), category,`)).
+		WithArgs("hook-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "scheduled_at", "retries", "failure_class", "failure_reason", "category", "template_id", "template_version", "variables"}).
+			AddRow("hook-1", "webhook", "scheduled", time.Now(), 0, "", "", "", "", 0, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT payload FROM notification_payloads WHERE notification_id = $1`)).
+		WithArgs("hook-1").
+		WillReturnRows(sqlmock.NewRows([]string{"payload"}).AddRow([]byte(`{"url":"https://example.com/hook"}`)))
+
+	n, err := repo.GetByID(context.Background(), "hook-1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com/hook", models.PayloadOf[payload](n).URL)
+	}
+	assert.Equal(t, "EXISTS (SELECT 1 FROM notification_payloads p WHERE p.notification_id = notifications.id AND p.payload->>'url' = $1)", storage.RecipientCondition("$1"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_RecordBounce(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	query := regexp.QuoteMeta(`UPDATE notifications SET status = $1, failure_class = $2, failure_reason = $3, updated_at = now() WHERE provider_message_id = $4 AND type = $5 AND status IN ($6, $1) RETURNING id, type, status, parent_id`)
+	mock.ExpectQuery(query).
+		WithArgs(models.StatusBounced, "permanent", "hard bounce 5.1.1", "<abc@example.com>", models.NotificationTypeEmail, models.StatusSent).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "status", "parent_id"}).AddRow("notification-1", "email", "bounced", "parent-1"))
+	mock.ExpectQuery(query).
+		WithArgs(models.StatusBounced, "permanent", "hard bounce 5.1.1", "<unknown@example.com>", models.NotificationTypeEmail, models.StatusSent).
+		WillReturnError(sql.ErrNoRows)
+
+	n, err := repo.RecordBounce(context.Background(), "<abc@example.com>", "permanent", "hard bounce 5.1.1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "notification-1", n.ID)
+		assert.Equal(t, models.StatusBounced, n.Status)
+		assert.Equal(t, "parent-1", n.ParentID)
+	}
+
+	_, err = repo.RecordBounce(context.Background(), "<unknown@example.com>", "permanent", "hard bounce 5.1.1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
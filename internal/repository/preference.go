@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// PreferenceStore хранит подписки получателей на категории уведомлений.
+type PreferenceStore interface {
+	SetPreference(ctx context.Context, p *models.Preference) error
+	GetPreferences(ctx context.Context, t models.NotificationType, recipient string) ([]models.Preference, error)
+}
+
+// SetPreference сохраняет подписку получателя канала на категорию (пустая — на весь канал).
+// Повторное сохранение обновляет значение.
+func (r *notificationRepo) SetPreference(ctx context.Context, p *models.Preference) error {
+	query := `
+  INSERT INTO preferences (type, recipient, category, enabled)
+  VALUES ($1, $2, $3, $4)
+  ON CONFLICT (type, recipient, category) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = now()
+  RETURNING updated_at
+ `
+	if err := r.db.QueryRowContext(ctx, query, p.Type, p.Recipient, p.Category, p.Enabled).Scan(&p.UpdatedAt); err != nil {
+		return fmt.Errorf("error saving preference: %w", err)
+	}
+	return nil
+}
+
+// GetPreferences возвращает настройки получателя канала, сначала общую настройку канала.
+// Если настроек нет, возвращает пустой список.
+func (r *notificationRepo) GetPreferences(ctx context.Context, t models.NotificationType, recipient string) ([]models.Preference, error) {
+	query := `
+  SELECT category, enabled, updated_at
+  FROM preferences
+  WHERE type = $1 AND recipient = $2
+  ORDER BY category
+ `
+	rows, err := r.db.QueryContext(ctx, query, t, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("error querying preferences: %w", err)
+	}
+	defer rows.Close()
+
+	prefs := []models.Preference{}
+	for rows.Next() {
+		p := models.Preference{Type: t, Recipient: recipient}
+		if err := rows.Scan(&p.Category, &p.Enabled, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning preference: %w", err)
+		}
+		prefs = append(prefs, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+	return prefs, nil
+}
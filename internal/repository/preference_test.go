@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+func TestNotificationRepo_Preferences(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	updatedAt := time.Date(2025, 11, 10, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (type, recipient, category) DO UPDATE SET enabled = EXCLUDED.enabled`)).
+		WithArgs(models.NotificationTypeEmail, "user@example.com", "newsletter", false).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(updatedAt))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT category, enabled, updated_at`)).
+		WithArgs(models.NotificationTypeEmail, "user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"category", "enabled", "updated_at"}).
+			AddRow("", true, updatedAt).
+			AddRow("newsletter", false, updatedAt))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT category, enabled, updated_at`)).
+		WithArgs(models.NotificationTypeEmail, "new@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"category", "enabled", "updated_at"}))
+
+	p := &models.Preference{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Category: "newsletter"}
+	assert.NoError(t, repo.SetPreference(context.Background(), p))
+	assert.Equal(t, updatedAt, p.UpdatedAt)
+
+	prefs, err := repo.GetPreferences(context.Background(), models.NotificationTypeEmail, "user@example.com")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []models.Preference{
+		{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Enabled: true, UpdatedAt: updatedAt},
+		{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Category: "newsletter", UpdatedAt: updatedAt},
+	}, prefs)
+
+	prefs, err = repo.GetPreferences(context.Background(), models.NotificationTypeEmail, "new@example.com")
+	assert.NoError(t, err)
+	assert.Empty(t, prefs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// PushSubscriptionRepository определяет методы для работы с подписками Web Push.
+type PushSubscriptionRepository interface {
+	Save(ctx context.Context, s *models.PushSubscription) error
+	ListBySubscriber(ctx context.Context, subscriber string) ([]models.PushSubscription, error)
+	DeleteByEndpoint(ctx context.Context, endpoint string) error
+	Delete(ctx context.Context, subscriber, endpoint string) error
+}
+
+type pushSubscriptionRepo struct {
+	db *sql.DB
+}
+
+// NewPushSubscriptionRepo создает новый экземпляр PushSubscriptionRepository.
+func NewPushSubscriptionRepo(db *sql.DB) PushSubscriptionRepository {
+	return &pushSubscriptionRepo{db: db}
+}
+
+// Save сохраняет подписку. Endpoint уникален: повторная подписка того же браузера
+// обновляет ключи и подписчика.
+func (r *pushSubscriptionRepo) Save(ctx context.Context, s *models.PushSubscription) error {
+	query := `
+  INSERT INTO push_subscriptions (subscriber, endpoint, p256dh, auth)
+  VALUES ($1, $2, $3, $4)
+  ON CONFLICT (endpoint) DO UPDATE
+  SET subscriber = EXCLUDED.subscriber, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth
+  RETURNING id, created_at
+ `
+	err := r.db.QueryRowContext(ctx, query, s.Subscriber, s.Endpoint, s.Keys.P256DH, s.Keys.Auth).
+		Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving push subscription: %w", err)
+	}
+	return nil
+}
+
+// ListBySubscriber возвращает все подписки подписчика.
+func (r *pushSubscriptionRepo) ListBySubscriber(ctx context.Context, subscriber string) ([]models.PushSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, `
+  SELECT id, subscriber, endpoint, p256dh, auth, created_at
+  FROM push_subscriptions
+  WHERE subscriber = $1
+  ORDER BY created_at
+ `, subscriber)
+	if err != nil {
+		return nil, fmt.Errorf("error querying push subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []models.PushSubscription{}
+	for rows.Next() {
+		var s models.PushSubscription
+		if err := rows.Scan(&s.ID, &s.Subscriber, &s.Endpoint, &s.Keys.P256DH, &s.Keys.Auth, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning push subscription: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+	return subs, nil
+}
+
+// DeleteByEndpoint удаляет подписку. Отсутствие подписки ошибкой не считается.
+func (r *pushSubscriptionRepo) DeleteByEndpoint(ctx context.Context, endpoint string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM push_subscriptions WHERE endpoint = $1`, endpoint); err != nil {
+		return fmt.Errorf("error deleting push subscription: %w", err)
+	}
+	return nil
+}
+
+// Delete удаляет подписку подписчика. Если у подписчика нет подписки с таким endpoint,
+// возвращает ErrNotFound.
+func (r *pushSubscriptionRepo) Delete(ctx context.Context, subscriber, endpoint string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM push_subscriptions WHERE subscriber = $1 AND endpoint = $2`, subscriber, endpoint)
+	if err != nil {
+		return fmt.Errorf("error deleting push subscription: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
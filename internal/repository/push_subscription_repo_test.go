@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+func TestPushSubscriptionRepoSave(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+	repo := NewPushSubscriptionRepo(db)
+
+	createdAt := time.Date(2025, 11, 10, 10, 0, 0, 0, time.UTC)
+	sub := &models.PushSubscription{
+		Subscriber: "user-1",
+		Endpoint:   "https://push.example.com/abc",
+		Keys:       models.PushSubscriptionKeys{P256DH: "key", Auth: "auth"},
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (endpoint) DO UPDATE`)).
+		WithArgs("user-1", "https://push.example.com/abc", "key", "auth").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("sub-1", createdAt))
+
+	err = repo.Save(context.Background(), sub)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "sub-1", sub.ID)
+	assert.Equal(t, createdAt, sub.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPushSubscriptionRepoListBySubscriber(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+	repo := NewPushSubscriptionRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM push_subscriptions WHERE subscriber = $1`)).
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscriber", "endpoint", "p256dh", "auth", "created_at"}).
+			AddRow("sub-1", "user-1", "https://push.example.com/abc", "key", "auth", time.Now()))
+
+	subs, err := repo.ListBySubscriber(context.Background(), "user-1")
+
+	assert.NoError(t, err)
+	if assert.Len(t, subs, 1) {
+		assert.Equal(t, "https://push.example.com/abc", subs[0].Endpoint)
+		assert.Equal(t, "key", subs[0].Keys.P256DH)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPushSubscriptionRepoDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+	repo := NewPushSubscriptionRepo(db)
+
+	query := regexp.QuoteMeta(`DELETE FROM push_subscriptions WHERE subscriber = $1 AND endpoint = $2`)
+	mock.ExpectExec(query).WithArgs("user-1", "https://push.example.com/abc").WillReturnResult(sqlmock.NewResult(0, 1))
+	// подписка другого подписчика не удаляется
+	mock.ExpectExec(query).WithArgs("user-2", "https://push.example.com/abc").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.Delete(context.Background(), "user-1", "https://push.example.com/abc"))
+	assert.ErrorIs(t, repo.Delete(context.Background(), "user-2", "https://push.example.com/abc"), ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
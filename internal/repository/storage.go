@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/google/uuid"
+)
+
+// Querier общий интерфейс *sql.DB и *sql.Tx для чтения данных.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// ChannelStorage описывает, как данные конкретного канала доставки хранятся в БД.
+// Репозиторий работает только с общей таблицей notifications и делегирует данные канала его хранилищу.
+type ChannelStorage interface {
+	// Insert сохраняет данные канала в рамках транзакции создания уведомления.
+	Insert(ctx context.Context, tx *sql.Tx, n *models.Notification) error
+	// Load загружает данные канала в уведомление.
+	Load(ctx context.Context, q Querier, n *models.Notification) error
+	// RecipientCondition возвращает SQL-условие отбора уведомлений по получателю.
+	// arg — плейсхолдер параметра ($N), таблица notifications доступна под своим именем.
+	RecipientCondition(arg string) string
+}
+
+// EmailStorage хранит данные email-уведомлений в таблице email_notifications.
+type EmailStorage struct{}
+
+// Insert сохраняет данные email-уведомления.
+func (EmailStorage) Insert(ctx context.Context, tx *sql.Tx, n *models.Notification) error {
+	email := models.PayloadOf[models.EmailNotification](n)
+	if email == nil {
+		return fmt.Errorf("email notification details are missing")
+	}
+	emailID := uuid.New().String()
+	emailQuery := `
+   INSERT INTO email_notifications (id, notification_id, email, subject, message, html)
+   VALUES ($1, $2, $3, $4, $5, $6)
+  `
+	_, err := tx.ExecContext(ctx, emailQuery, emailID, n.ID, email.Email, email.Subject, email.Message, email.HTML)
+	if err != nil {
+		return fmt.Errorf("error inserting into email_notifications: %w", err)
+	}
+
+	for i := range email.Attachments {
+		if err := insertAttachment(ctx, tx, n.ID, i, &email.Attachments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertAttachment сохраняет вложение уведомления в таблицу attachments и заполняет его ID.
+func insertAttachment(ctx context.Context, tx *sql.Tx, notificationID string, position int, a *models.Attachment) error {
+	a.ID = uuid.New().String()
+	attachmentQuery := `
+   INSERT INTO attachments (id, notification_id, position, filename, content_type, content_id, size, content)
+   VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+  `
+	_, err := tx.ExecContext(ctx, attachmentQuery, a.ID, notificationID, position, a.Filename, a.ContentType, a.ContentID, len(a.Content), a.Content)
+	if err != nil {
+		return fmt.Errorf("error inserting into attachments: %w", err)
+	}
+	return nil
+}
+
+// Load загружает данные email-уведомления и метаданные вложений без их содержимого.
+func (EmailStorage) Load(ctx context.Context, q Querier, n *models.Notification) error {
+	email := &models.EmailNotification{}
+	var attachments []byte
+	emailQuery := `
+            SELECT email, subject, message, html,
+             COALESCE((
+              SELECT json_agg(json_build_object('id', a.id, 'filename', a.filename, 'content_type', a.content_type,
+               'content_id', a.content_id, 'size', a.size) ORDER BY a.position)
+              FROM attachments a
+              WHERE a.notification_id = email_notifications.notification_id
+             ), '[]')
+            FROM email_notifications
+            WHERE notification_id = $1
+        `
+	err := q.QueryRowContext(ctx, emailQuery, n.ID).Scan(
+		&email.Email, &email.Subject, &email.Message, &email.HTML, &attachments,
+	)
+	if err != nil {
+		return fmt.Errorf("error getting email notification details: %w", err)
+	}
+	if err := json.Unmarshal(attachments, &email.Attachments); err != nil {
+		return fmt.Errorf("error decoding email attachments: %w", err)
+	}
+	if len(email.Attachments) == 0 {
+		email.Attachments = nil
+	}
+	n.Payload = email
+	return nil
+}
+
+// RecipientCondition отбирает уведомления по адресу получателя без учета регистра, как в списке
+// подавления и настройках.
+func (EmailStorage) RecipientCondition(arg string) string {
+	return "EXISTS (SELECT 1 FROM email_notifications e WHERE e.notification_id = notifications.id AND lower(e.email) = lower(" + arg + "))"
+}
+
+// TelegramStorage хранит данные telegram-уведомлений в таблице telegram_notifications.
+type TelegramStorage struct{}
+
+// Insert сохраняет данные telegram-уведомления.
+func (TelegramStorage) Insert(ctx context.Context, tx *sql.Tx, n *models.Notification) error {
+	tg := models.PayloadOf[models.TelegramNotification](n)
+	if tg == nil {
+		return fmt.Errorf("telegram notification details are missing")
+	}
+	// загруженные файлы сохраняются в attachments, а в options остаются только их метаданные
+	options := tg.TelegramOptions
+	options.Media = make([]models.TelegramMedia, len(tg.Media))
+	for i, m := range tg.Media {
+		if m.File != nil {
+			if err := insertAttachment(ctx, tx, n.ID, i, m.File); err != nil {
+				return err
+			}
+			file := *m.File
+			file.Content = nil
+			m.File = &file
+		}
+		options.Media[i] = m
+	}
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("error encoding telegram options: %w", err)
+	}
+	telegramID := uuid.New().String()
+	telegramQuery := `
+   INSERT INTO telegram_notifications (id, notification_id, chat_id, message, options)
+   VALUES ($1, $2, $3, $4, $5)
+  `
+	_, err = tx.ExecContext(ctx, telegramQuery, telegramID, n.ID, tg.ChatID, tg.Message, optionsJSON)
+	if err != nil {
+		return fmt.Errorf("error inserting into telegram_notifications: %w", err)
+	}
+	return nil
+}
+
+// Load загружает данные telegram-уведомления.
+func (TelegramStorage) Load(ctx context.Context, q Querier, n *models.Notification) error {
+	telegram := &models.TelegramNotification{}
+	var options []byte
+	telegramQuery := `
+            SELECT chat_id, message, options
+            FROM telegram_notifications
+            WHERE notification_id = $1
+        `
+	err := q.QueryRowContext(ctx, telegramQuery, n.ID).Scan(
+		&telegram.ChatID, &telegram.Message, &options,
+	)
+	if err != nil {
+		return fmt.Errorf("error getting telegram notification details: %w", err)
+	}
+	if err := json.Unmarshal(options, &telegram.TelegramOptions); err != nil {
+		return fmt.Errorf("error decoding telegram options: %w", err)
+	}
+	n.Payload = telegram
+	return nil
+}
+
+// RecipientCondition отбирает уведомления по chat_id.
+func (TelegramStorage) RecipientCondition(arg string) string {
+	return "EXISTS (SELECT 1 FROM telegram_notifications t WHERE t.notification_id = notifications.id AND t.chat_id = " + arg + ")"
+}
+
+// PayloadStorage хранит данные канала (Notification.Payload) как JSON в общей таблице
+// notification_payloads, поэтому новому каналу не нужна собственная таблица.
+type PayloadStorage struct {
+	// New создает пустые данные канала, в которые разбирается сохраненный JSON.
+	New func() any
+	// RecipientKey ключ JSON-объекта с получателем, используется для поиска по получателю.
+	RecipientKey string
+}
+
+// Insert сохраняет данные канала.
+func (s PayloadStorage) Insert(ctx context.Context, tx *sql.Tx, n *models.Notification) error {
+	payload, err := json.Marshal(n.Payload)
+	if err != nil {
+		return fmt.Errorf("error encoding notification payload: %w", err)
+	}
+	if string(payload) == "null" {
+		return fmt.Errorf("%s notification details are missing", n.Type)
+	}
+	query := `
+   INSERT INTO notification_payloads (notification_id, payload)
+   VALUES ($1, $2)
+  `
+	if _, err := tx.ExecContext(ctx, query, n.ID, payload); err != nil {
+		return fmt.Errorf("error inserting into notification_payloads: %w", err)
+	}
+	return nil
+}
+
+// Load загружает данные канала.
+func (s PayloadStorage) Load(ctx context.Context, q Querier, n *models.Notification) error {
+	var payload []byte
+	query := `
+            SELECT payload
+            FROM notification_payloads
+            WHERE notification_id = $1
+        `
+	if err := q.QueryRowContext(ctx, query, n.ID).Scan(&payload); err != nil {
+		return fmt.Errorf("error getting notification payload: %w", err)
+	}
+	p := s.New()
+	if err := json.Unmarshal(payload, p); err != nil {
+		return fmt.Errorf("error decoding notification payload: %w", err)
+	}
+	n.Payload = p
+	return nil
+}
+
+// RecipientCondition отбирает уведомления по значению RecipientKey в JSON.
+func (s PayloadStorage) RecipientCondition(arg string) string {
+	if s.RecipientKey == "" {
+		return "FALSE"
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM notification_payloads p WHERE p.notification_id = notifications.id AND p.payload->>'%s' = %s)", s.RecipientKey, arg)
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// SuppressionStore хранит список подавления получателей.
+type SuppressionStore interface {
+	Suppress(ctx context.Context, s *models.Suppression) error
+	GetSuppression(ctx context.Context, t models.NotificationType, recipient string) (*models.Suppression, error)
+	Unsuppress(ctx context.Context, t models.NotificationType, recipient string) error
+}
+
+// Reschedule возвращает уведомление в очередь планировщика с новым временем отправки после
+// ограничения частоты запросов провайдером и увеличивает счетчик таких переносов.
+func (r *notificationRepo) Reschedule(ctx context.Context, id string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE notifications SET status=$1, scheduled_at=$2, rate_limits=rate_limits+1, updated_at=now() WHERE id=$3`, models.StatusScheduled, at, id)
+	return err
+}
+
+// Suppress добавляет получателя канала в список подавления. Повторное добавление обновляет причину.
+func (r *notificationRepo) Suppress(ctx context.Context, s *models.Suppression) error {
+	query := `
+  INSERT INTO suppressions (type, recipient, reason)
+  VALUES ($1, $2, $3)
+  ON CONFLICT (type, recipient) DO UPDATE SET reason = EXCLUDED.reason
+  RETURNING created_at
+ `
+	if err := r.db.QueryRowContext(ctx, query, s.Type, s.Recipient, s.Reason).Scan(&s.CreatedAt); err != nil {
+		return fmt.Errorf("error saving suppression: %w", err)
+	}
+	return nil
+}
+
+// GetSuppression возвращает запись списка подавления или ErrNotFound, если получатель в нем не числится.
+func (r *notificationRepo) GetSuppression(ctx context.Context, t models.NotificationType, recipient string) (*models.Suppression, error) {
+	s := &models.Suppression{Type: t, Recipient: recipient}
+	err := r.db.QueryRowContext(ctx, `SELECT reason, created_at FROM suppressions WHERE type = $1 AND recipient = $2`, t, recipient).
+		Scan(&s.Reason, &s.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting suppression: %w", err)
+	}
+	return s, nil
+}
+
+// Unsuppress удаляет получателя из списка подавления. Возвращает ErrNotFound, если записи не было.
+func (r *notificationRepo) Unsuppress(ctx context.Context, t models.NotificationType, recipient string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM suppressions WHERE type = $1 AND recipient = $2`, t, recipient)
+	if err != nil {
+		return fmt.Errorf("error deleting suppression: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+func TestNotificationRepo_Suppression(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	createdAt := time.Date(2025, 11, 10, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (type, recipient) DO UPDATE SET reason = EXCLUDED.reason`)).
+		WithArgs(models.NotificationTypeTelegram, "42", "Forbidden: bot was blocked by the user").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT reason, created_at FROM suppressions WHERE type = $1 AND recipient = $2`)).
+		WithArgs(models.NotificationTypeTelegram, "43").
+		WillReturnRows(sqlmock.NewRows([]string{"reason", "created_at"}))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM suppressions WHERE type = $1 AND recipient = $2`)).
+		WithArgs(models.NotificationTypeTelegram, "42").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM suppressions`)).
+		WithArgs(models.NotificationTypeTelegram, "42").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	sup := &models.Suppression{Type: models.NotificationTypeTelegram, Recipient: "42", Reason: "Forbidden: bot was blocked by the user"}
+	assert.NoError(t, repo.Suppress(context.Background(), sup))
+	assert.Equal(t, createdAt, sup.CreatedAt)
+
+	_, err := repo.GetSuppression(context.Background(), models.NotificationTypeTelegram, "43")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, repo.Unsuppress(context.Background(), models.NotificationTypeTelegram, "42"))
+	assert.ErrorIs(t, repo.Unsuppress(context.Background(), models.NotificationTypeTelegram, "42"), ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_Reschedule(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	at := time.Now().Add(35 * time.Second)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE notifications SET status=$1, scheduled_at=$2, rate_limits=rate_limits+1, updated_at=now() WHERE id=$3`)).
+		WithArgs(models.StatusScheduled, at, "notification-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.Reschedule(context.Background(), "notification-1", at))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
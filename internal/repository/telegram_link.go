@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// TelegramLinkStore хранит токены deep link и связи чатов Telegram с пользователями.
+type TelegramLinkStore interface {
+	CreateTelegramLinkToken(ctx context.Context, t *models.TelegramLinkToken) error
+	LinkTelegramChat(ctx context.Context, token string, link *models.TelegramLink) error
+	GetTelegramLink(ctx context.Context, userID string) (*models.TelegramLink, error)
+	DeleteTelegramLink(ctx context.Context, userID string) error
+}
+
+// CreateTelegramLinkToken сохраняет токен deep link. Заодно удаляются истекшие токены.
+func (r *notificationRepo) CreateTelegramLinkToken(ctx context.Context, t *models.TelegramLinkToken) error {
+	query := `
+  WITH expired AS (DELETE FROM telegram_link_tokens WHERE expires_at <= now())
+  INSERT INTO telegram_link_tokens (token, user_id, expires_at)
+  VALUES ($1, $2, $3)
+ `
+	if _, err := r.db.ExecContext(ctx, query, t.Token, t.UserID, t.ExpiresAt); err != nil {
+		return fmt.Errorf("error saving telegram link token: %w", err)
+	}
+	return nil
+}
+
+// LinkTelegramChat погашает токен deep link и связывает чат с пользователем, которому был выдан токен.
+// Предыдущий чат пользователя заменяется. Возвращает ErrNotFound, если токен не найден или истек.
+func (r *notificationRepo) LinkTelegramChat(ctx context.Context, token string, link *models.TelegramLink) error {
+	query := `
+  WITH t AS (
+   DELETE FROM telegram_link_tokens WHERE token = $1 AND expires_at > now() RETURNING user_id
+  )
+  INSERT INTO telegram_links (user_id, chat_id, username)
+  SELECT user_id, $2, $3 FROM t
+  ON CONFLICT (user_id) DO UPDATE SET chat_id = EXCLUDED.chat_id, username = EXCLUDED.username, linked_at = now()
+  RETURNING user_id, linked_at
+ `
+	err := r.db.QueryRowContext(ctx, query, token, link.ChatID, link.Username).Scan(&link.UserID, &link.LinkedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error linking telegram chat: %w", err)
+	}
+	return nil
+}
+
+// GetTelegramLink возвращает чат пользователя или ErrNotFound, если пользователь не связал чат.
+func (r *notificationRepo) GetTelegramLink(ctx context.Context, userID string) (*models.TelegramLink, error) {
+	link := &models.TelegramLink{UserID: userID}
+	err := r.db.QueryRowContext(ctx, `SELECT chat_id, username, linked_at FROM telegram_links WHERE user_id = $1`, userID).
+		Scan(&link.ChatID, &link.Username, &link.LinkedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting telegram link: %w: %w", ErrUnavailable, err)
+	}
+	return link, nil
+}
+
+// DeleteTelegramLink удаляет связь пользователя с чатом. Возвращает ErrNotFound, если связи не было.
+func (r *notificationRepo) DeleteTelegramLink(ctx context.Context, userID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM telegram_links WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting telegram link: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
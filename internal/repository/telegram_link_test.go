@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+func TestNotificationRepo_TelegramLinks(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	expiresAt := time.Now().Add(time.Hour)
+	linkedAt := time.Date(2025, 11, 10, 10, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO telegram_link_tokens (token, user_id, expires_at)`)).
+		WithArgs("token-1", "user-1", expiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM telegram_link_tokens WHERE token = $1 AND expires_at > now() RETURNING user_id`)).
+		WithArgs("token-1", "42", "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "linked_at"}).AddRow("user-1", linkedAt))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO telegram_links (user_id, chat_id, username)`)).
+		WithArgs("token-1", "42", "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "linked_at"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT chat_id, username, linked_at FROM telegram_links WHERE user_id = $1`)).
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"chat_id", "username", "linked_at"}).AddRow("42", "alice", linkedAt))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM telegram_links WHERE user_id = $1`)).
+		WithArgs("user-2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.CreateTelegramLinkToken(context.Background(), &models.TelegramLinkToken{Token: "token-1", UserID: "user-1", ExpiresAt: expiresAt}))
+
+	link := &models.TelegramLink{ChatID: "42", Username: "alice"}
+	assert.NoError(t, repo.LinkTelegramChat(context.Background(), "token-1", link))
+	assert.Equal(t, "user-1", link.UserID)
+	assert.Equal(t, linkedAt, link.LinkedAt)
+
+	// токен уже погашен
+	assert.ErrorIs(t, repo.LinkTelegramChat(context.Background(), "token-1", &models.TelegramLink{ChatID: "42", Username: "alice"}), ErrNotFound)
+
+	got, err := repo.GetTelegramLink(context.Background(), "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, &models.TelegramLink{UserID: "user-1", ChatID: "42", Username: "alice", LinkedAt: linkedAt}, got)
+
+	assert.ErrorIs(t, repo.DeleteTelegramLink(context.Background(), "user-2"), ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
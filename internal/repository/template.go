@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/google/uuid"
+)
+
+// TemplateStore хранит версии шаблонов сообщений.
+type TemplateStore interface {
+	CreateTemplate(ctx context.Context, t *models.Template) error
+	UpdateTemplate(ctx context.Context, t *models.Template) error
+	GetTemplate(ctx context.Context, id string, version int) (*models.Template, error)
+	GetTemplateVersion(ctx context.Context, id string, version int) (*models.Template, error)
+	ListTemplates(ctx context.Context) ([]models.Template, error)
+	ListTemplateVersions(ctx context.Context, id string) ([]models.Template, error)
+	DeleteTemplate(ctx context.Context, id string) error
+}
+
+// CreateTemplate сохраняет новый шаблон с версией 1 и заполняет его ID, версию и время создания.
+func (r *notificationRepo) CreateTemplate(ctx context.Context, t *models.Template) (err error) {
+	channels, err := json.Marshal(t.Channels)
+	if err != nil {
+		return fmt.Errorf("error encoding template channels: %w", err)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `INSERT INTO templates (name) VALUES ($1) RETURNING id, version, created_at, updated_at`
+	if err = tx.QueryRowContext(ctx, query, t.Name).Scan(&t.ID, &t.Version, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return fmt.Errorf("error inserting into templates: %w", err)
+	}
+	if err = insertTemplateVersion(ctx, tx, t.ID, t.Version, channels); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateTemplate сохраняет тексты шаблона новой версией; предыдущие версии не меняются, поэтому
+// уже созданные по ним уведомления отправляются с прежним текстом. Возвращает ErrNotFound,
+// если шаблона нет или он удален.
+func (r *notificationRepo) UpdateTemplate(ctx context.Context, t *models.Template) (err error) {
+	if uuid.Validate(t.ID) != nil {
+		return ErrNotFound
+	}
+	channels, err := json.Marshal(t.Channels)
+	if err != nil {
+		return fmt.Errorf("error encoding template channels: %w", err)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+  UPDATE templates SET name = $1, version = version + 1, updated_at = now()
+  WHERE id = $2 AND deleted_at IS NULL
+  RETURNING version, created_at, updated_at
+ `
+	err = tx.QueryRowContext(ctx, query, t.Name, t.ID).Scan(&t.Version, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error updating template: %w", err)
+	}
+	if err = insertTemplateVersion(ctx, tx, t.ID, t.Version, channels); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// insertTemplateVersion сохраняет тексты версии шаблона.
+func insertTemplateVersion(ctx context.Context, tx *sql.Tx, id string, version int, channels []byte) error {
+	query := `INSERT INTO template_versions (template_id, version, channels) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, id, version, channels); err != nil {
+		return fmt.Errorf("error inserting into template_versions: %w", err)
+	}
+	return nil
+}
+
+// GetTemplate возвращает версию шаблона (0 — последнюю) или ErrNotFound, если шаблона или версии нет
+// либо шаблон удален.
+func (r *notificationRepo) GetTemplate(ctx context.Context, id string, version int) (*models.Template, error) {
+	return r.getTemplate(ctx, id, version, false)
+}
+
+// GetTemplateVersion возвращает версию шаблона, даже если шаблон удален: уведомления, созданные
+// до удаления, отправляются по сохраненной версии.
+func (r *notificationRepo) GetTemplateVersion(ctx context.Context, id string, version int) (*models.Template, error) {
+	return r.getTemplate(ctx, id, version, true)
+}
+
+// getTemplate читает версию шаблона; UpdatedAt — время создания версии.
+func (r *notificationRepo) getTemplate(ctx context.Context, id string, version int, withDeleted bool) (*models.Template, error) {
+	if uuid.Validate(id) != nil {
+		return nil, ErrNotFound
+	}
+	query := `
+  SELECT t.name, v.version, v.channels, t.created_at, v.created_at
+  FROM templates t
+  JOIN template_versions v ON v.template_id = t.id AND v.version = COALESCE(NULLIF($2::int, 0), t.version)
+  WHERE t.id = $1 AND ($3 OR t.deleted_at IS NULL)
+ `
+	t := &models.Template{ID: id}
+	var channels []byte
+	err := r.db.QueryRowContext(ctx, query, id, version, withDeleted).Scan(&t.Name, &t.Version, &channels, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting template: %w: %w", ErrUnavailable, err)
+	}
+	if err := json.Unmarshal(channels, &t.Channels); err != nil {
+		return nil, fmt.Errorf("error decoding template channels: %w", err)
+	}
+	return t, nil
+}
+
+// ListTemplates возвращает последние версии шаблонов, кроме удаленных, по названию.
+func (r *notificationRepo) ListTemplates(ctx context.Context) ([]models.Template, error) {
+	query := `
+  SELECT t.id, t.name, v.version, v.channels, t.created_at, v.created_at
+  FROM templates t
+  JOIN template_versions v ON v.template_id = t.id AND v.version = t.version
+  WHERE t.deleted_at IS NULL
+  ORDER BY t.name, t.id
+ `
+	return r.queryTemplates(ctx, query)
+}
+
+// ListTemplateVersions возвращает все версии шаблона, начиная с последней,
+// или ErrNotFound, если шаблона нет или он удален.
+func (r *notificationRepo) ListTemplateVersions(ctx context.Context, id string) ([]models.Template, error) {
+	if uuid.Validate(id) != nil {
+		return nil, ErrNotFound
+	}
+	query := `
+  SELECT t.id, t.name, v.version, v.channels, t.created_at, v.created_at
+  FROM templates t
+  JOIN template_versions v ON v.template_id = t.id
+  WHERE t.id = $1 AND t.deleted_at IS NULL
+  ORDER BY v.version DESC
+ `
+	versions, err := r.queryTemplates(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	return versions, nil
+}
+
+// queryTemplates читает список версий шаблонов.
+func (r *notificationRepo) queryTemplates(ctx context.Context, query string, args ...any) ([]models.Template, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []models.Template{}
+	for rows.Next() {
+		var t models.Template
+		var channels []byte
+		if err := rows.Scan(&t.ID, &t.Name, &t.Version, &channels, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning template: %w", err)
+		}
+		if err := json.Unmarshal(channels, &t.Channels); err != nil {
+			return nil, fmt.Errorf("error decoding template channels: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+	return templates, nil
+}
+
+// DeleteTemplate помечает шаблон удаленным: новые уведомления на него ссылаться не могут.
+// Возвращает ErrNotFound, если шаблона нет или он уже удален.
+func (r *notificationRepo) DeleteTemplate(ctx context.Context, id string) error {
+	if uuid.Validate(id) != nil {
+		return ErrNotFound
+	}
+	res, err := r.db.ExecContext(ctx, `UPDATE templates SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting template: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// templateColumns возвращает значения колонок template_id, template_version и variables уведомления;
+// у уведомления без шаблона они NULL.
+func templateColumns(n *models.Notification) ([]any, error) {
+	if n.TemplateID == "" {
+		return []any{nil, nil, nil}, nil
+	}
+	variables, err := json.Marshal(n.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding template variables: %w", err)
+	}
+	return []any{n.TemplateID, n.TemplateVersion, variables}, nil
+}
+
+// decodeVariables разбирает колонку variables уведомления.
+func decodeVariables(raw []byte, n *models.Notification) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, &n.Variables); err != nil {
+		return fmt.Errorf("error decoding template variables: %w", err)
+	}
+	return nil
+}
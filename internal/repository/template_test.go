@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+const testTemplateID = "3f1c2a7e-5b1d-4c8e-9a2f-6d7e8f901234"
+
+func TestNotificationRepo_CreateTemplate(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	now := time.Date(2025, 11, 10, 10, 0, 0, 0, time.UTC)
+	tmpl := &models.Template{Name: "order", Channels: map[models.NotificationType]models.TemplateContent{
+		models.NotificationTypeEmail: {Subject: "Заказ {{.order}}", Message: "Здравствуйте, {{.name}}!"},
+	}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO templates (name) VALUES ($1) RETURNING id, version, created_at, updated_at`)).
+		WithArgs("order").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version", "created_at", "updated_at"}).AddRow(testTemplateID, 1, now, now))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO template_versions (template_id, version, channels) VALUES ($1, $2, $3)`)).
+		WithArgs(testTemplateID, 1, []byte(`{"email":{"subject":"Заказ {{.order}}","message":"Здравствуйте, {{.name}}!"}}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.CreateTemplate(context.Background(), tmpl))
+	assert.Equal(t, testTemplateID, tmpl.ID)
+	assert.Equal(t, 1, tmpl.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_UpdateTemplateNotFound(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE templates SET name = $1, version = version + 1`)).
+		WithArgs("order", testTemplateID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err := repo.UpdateTemplate(context.Background(), &models.Template{ID: testTemplateID, Name: "order"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	// ID не UUID — запроса к БД нет
+	err = repo.UpdateTemplate(context.Background(), &models.Template{ID: "order", Name: "order"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_GetTemplate(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	createdAt := time.Date(2025, 11, 10, 10, 0, 0, 0, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.name, v.version, v.channels, t.created_at, v.created_at FROM templates t`)).
+		WithArgs(testTemplateID, 0, false).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "version", "channels", "created_at", "updated_at"}).
+			AddRow("order", 2, []byte(`{"telegram":{"message":"Заказ {{.order}}"}}`), createdAt, updatedAt))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.name, v.version, v.channels, t.created_at, v.created_at FROM templates t`)).
+		WithArgs(testTemplateID, 1, true).
+		WillReturnError(sql.ErrNoRows)
+
+	tmpl, err := repo.GetTemplate(context.Background(), testTemplateID, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &models.Template{
+		ID:        testTemplateID,
+		Name:      "order",
+		Version:   2,
+		Channels:  map[models.NotificationType]models.TemplateContent{models.NotificationTypeTelegram: {Message: "Заказ {{.order}}"}},
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}, tmpl)
+
+	_, err = repo.GetTemplateVersion(context.Background(), testTemplateID, 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationRepo_DeleteTemplate(t *testing.T) {
+	repo, mock, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE templates SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`)).
+		WithArgs(testTemplateID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE templates SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`)).
+		WithArgs(testTemplateID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.DeleteTemplate(context.Background(), testTemplateID))
+	assert.ErrorIs(t, repo.DeleteTemplate(context.Background(), testTemplateID), ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
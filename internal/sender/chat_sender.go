@@ -0,0 +1,294 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+const (
+	// SlackMaxMessageLength максимальная длина текста сообщения Slack (в символах).
+	SlackMaxMessageLength = 40000
+	// DiscordMaxMessageLength максимальная длина content сообщения Discord (в символах).
+	DiscordMaxMessageLength = 2000
+	// MattermostMaxMessageLength максимальная длина текста сообщения Mattermost (в символах).
+	MattermostMaxMessageLength = 16383
+
+	// chatTimeout таймаут запроса к входящему вебхуку
+	chatTimeout = 10 * time.Second
+	// chatMaxRateLimitWait дольше этого отправитель сам не ждет снятия ограничения
+	chatMaxRateLimitWait = 30 * time.Second
+	// chatRateLimitRetries сколько раз отправитель повторяет запрос после 429
+	chatRateLimitRetries = 2
+	// chatDefaultRateLimitWait ожидание после 429, в котором платформа не указала время
+	chatDefaultRateLimitWait = time.Second
+)
+
+// chatPlatform особенности конкретной платформы.
+type chatPlatform struct {
+	maxLength int
+	// payload собирает тело запроса из уже обрезанного текста
+	payload func(text string, n *models.ChatNotification) map[string]any
+	// text собирает текст сообщения из темы и текста уведомления
+	text func(n *models.ChatNotification) string
+	// retryAfter читает время ожидания из ответа 429
+	retryAfter func(resp *http.Response, body []byte) time.Duration
+}
+
+// ChatSender реализует отправку уведомлений во входящие вебхуки Slack, Discord и Mattermost.
+type ChatSender struct {
+	platform chatPlatform
+	baseURL  string
+	client   *http.Client
+	// sleep ожидание между повторами после 429, прерываемое отменой ctx; подменяется в тестах
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewSlackSender создает отправителя во входящие вебхуки Slack.
+// baseURL — адрес сервера вебхуков (https://hooks.slack.com или локальная заглушка).
+func NewSlackSender(baseURL string) *ChatSender {
+	return newChatSender(baseURL, chatPlatform{
+		maxLength: SlackMaxMessageLength,
+		text: func(n *models.ChatNotification) string {
+			text := markdownToMrkdwn(n.Message)
+			if n.Subject != "" {
+				text = "*" + escapeMrkdwn(n.Subject) + "*\n" + text
+			}
+			return text
+		},
+		payload: func(text string, n *models.ChatNotification) map[string]any {
+			p := map[string]any{"text": text, "mrkdwn": true}
+			if len(n.Blocks) > 0 {
+				p["blocks"] = n.Blocks
+			}
+			return p
+		},
+		retryAfter: func(resp *http.Response, _ []byte) time.Duration {
+			return parseSeconds(resp.Header.Get("Retry-After"))
+		},
+	})
+}
+
+// NewDiscordSender создает отправителя во входящие вебхуки Discord.
+// baseURL — адрес API (https://discord.com или локальная заглушка).
+func NewDiscordSender(baseURL string) *ChatSender {
+	return newChatSender(baseURL, chatPlatform{
+		maxLength: DiscordMaxMessageLength,
+		text:      markdownText("**"),
+		payload: func(text string, n *models.ChatNotification) map[string]any {
+			p := map[string]any{"content": text}
+			if len(n.Blocks) > 0 {
+				p["embeds"] = n.Blocks
+			}
+			return p
+		},
+		retryAfter: func(resp *http.Response, body []byte) time.Duration {
+			// Discord возвращает retry_after в секундах (дробное число) в теле ответа
+			var rl struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			if json.Unmarshal(body, &rl) == nil && rl.RetryAfter > 0 {
+				return time.Duration(rl.RetryAfter * float64(time.Second))
+			}
+			if d := parseSeconds(resp.Header.Get("X-RateLimit-Reset-After")); d > 0 {
+				return d
+			}
+			return parseSeconds(resp.Header.Get("Retry-After"))
+		},
+	})
+}
+
+// NewMattermostSender создает отправителя во входящие вебхуки Mattermost.
+// baseURL — адрес сервера Mattermost; если пустой, вебхук должен быть задан полным URL.
+func NewMattermostSender(baseURL string) *ChatSender {
+	return newChatSender(baseURL, chatPlatform{
+		maxLength: MattermostMaxMessageLength,
+		text:      markdownText("#### "),
+		payload: func(text string, n *models.ChatNotification) map[string]any {
+			p := map[string]any{"text": text}
+			if len(n.Blocks) > 0 {
+				p["attachments"] = n.Blocks
+			}
+			return p
+		},
+		retryAfter: func(resp *http.Response, _ []byte) time.Duration {
+			// X-Ratelimit-Reset — через сколько секунд ограничение будет снято
+			if d := parseSeconds(resp.Header.Get("X-Ratelimit-Reset")); d > 0 {
+				return d
+			}
+			return parseSeconds(resp.Header.Get("Retry-After"))
+		},
+	})
+}
+
+func newChatSender(baseURL string, platform chatPlatform) *ChatSender {
+	return &ChatSender{
+		platform: platform,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		client:   &http.Client{Timeout: chatTimeout},
+		sleep:    sleepContext,
+	}
+}
+
+// Send отправляет уведомление во входящий вебхук. При ответе 429 отправитель ждет
+// указанное платформой время (не дольше chatMaxRateLimitWait) и повторяет запрос;
+// если ограничение не снято, ожидание не укладывается в дедлайн ctx или ctx отменен во время
+// ожидания, возвращается *RateLimitError.
+// Входящие вебхуки не возвращают идентификатор сообщения, поэтому ProviderMessageID пустой.
+func (s *ChatSender) Send(ctx context.Context, n *models.Notification) (*Result, error) {
+	rendered, err := s.Render(n)
+	if err != nil {
+		return failed(nil, err)
+	}
+	body, err := json.Marshal(rendered.Payload)
+	if err != nil {
+		return failed(nil, fmt.Errorf("failed to encode %s message: %w", n.Type, err))
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, rendered.Recipient, bytes.NewReader(body))
+		if err != nil {
+			return failed(nil, fmt.Errorf("%s request error: %w", n.Type, err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return failed(nil, fmt.Errorf("%s send error: %w", n.Type, err))
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return delivered("", respBody), nil
+		case resp.StatusCode == http.StatusTooManyRequests:
+			rl := &RateLimitError{Channel: n.Type, RetryAfter: s.platform.retryAfter(resp, respBody)}
+			if rl.RetryAfter <= 0 {
+				rl.RetryAfter = chatDefaultRateLimitWait
+			}
+			if attempt >= chatRateLimitRetries || rl.RetryAfter > chatMaxRateLimitWait || !fitsDeadline(ctx, rl.RetryAfter) {
+				return failed(respBody, rl)
+			}
+			if err := s.sleep(ctx, rl.RetryAfter); err != nil {
+				return failed(respBody, rl)
+			}
+		default:
+			return failed(respBody, httpStatusError(resp.StatusCode, fmt.Errorf("%s send failed: status %d: %s", n.Type, resp.StatusCode, string(respBody))))
+		}
+	}
+}
+
+// sleepContext ждет d или отмены ctx.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fitsDeadline проверяет, что после ожидания wait у ctx еще останется время.
+func fitsDeadline(ctx context.Context, wait time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > wait
+}
+
+// Render возвращает тело запроса к входящему вебхуку в том виде, в котором оно будет отправлено.
+func (s *ChatSender) Render(n *models.Notification) (*models.RenderedMessage, error) {
+	c := models.PayloadOf[models.ChatNotification](n)
+	if c == nil {
+		return nil, fmt.Errorf("%s notification details are missing", n.Type)
+	}
+	target, err := s.webhookURL(c.Webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	text, truncated := truncateText(strings.ToValidUTF8(s.platform.text(c), "�"), s.platform.maxLength)
+	return &models.RenderedMessage{
+		Type:      n.Type,
+		Recipient: target,
+		Subject:   c.Subject,
+		Body:      text,
+		Payload:   s.platform.payload(text, c),
+		Truncated: truncated,
+	}, nil
+}
+
+// webhookURL строит адрес вебхука: путь из уведомления относительно базового URL.
+// Из полного URL берутся только путь и параметры, чтобы запросы шли на настроенный сервер.
+func (s *ChatSender) webhookURL(webhook string) (string, error) {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook: %w", err)
+	}
+	if s.baseURL == "" {
+		if !u.IsAbs() {
+			return "", errors.New("webhook base URL is not configured, full webhook URL is required")
+		}
+		return u.String(), nil
+	}
+	path := "/" + strings.TrimLeft(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return s.baseURL + path, nil
+}
+
+// markdownText собирает текст для платформ с поддержкой Markdown: тема выделяется prefix.
+func markdownText(prefix string) func(n *models.ChatNotification) string {
+	return func(n *models.ChatNotification) string {
+		if n.Subject == "" {
+			return n.Message
+		}
+		if strings.HasSuffix(prefix, " ") {
+			return prefix + n.Subject + "\n" + n.Message
+		}
+		return prefix + n.Subject + prefix + "\n" + n.Message
+	}
+}
+
+var (
+	mdLink    = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	mdBold    = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdStrike  = regexp.MustCompile(`~~(.+?)~~`)
+	mdHeading = regexp.MustCompile(`(?m)^#{1,6}[ \t]+(.+)$`)
+)
+
+// markdownToMrkdwn переводит основные элементы Markdown в разметку Slack (mrkdwn):
+// жирный текст, зачеркивание, заголовки и ссылки. Спецсимволы &, <, > экранируются.
+func markdownToMrkdwn(text string) string {
+	text = escapeMrkdwn(text)
+	text = mdLink.ReplaceAllString(text, "<$2|$1>")
+	text = mdBold.ReplaceAllString(text, "*$1$2*")
+	text = mdStrike.ReplaceAllString(text, "~$1~")
+	return mdHeading.ReplaceAllString(text, "*$1*")
+}
+
+// escapeMrkdwn экранирует управляющие символы Slack.
+func escapeMrkdwn(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// parseSeconds разбирает количество секунд (целое или дробное) из заголовка.
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
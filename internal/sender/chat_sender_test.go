@@ -0,0 +1,130 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newChatNotification(t models.NotificationType, webhook string) *models.Notification {
+	return &models.Notification{
+		ID:   "notif-1",
+		Type: t,
+		Payload: &models.ChatNotification{
+			ChatOptions: models.ChatOptions{Webhook: webhook},
+			Subject:     "Deploy",
+			Message:     "**Done** in [CI](https://ci.example.com/1) & ~~slow~~",
+		},
+	}
+}
+
+func TestSlackSenderRender(t *testing.T) {
+	n := newChatNotification(models.NotificationTypeSlack, "https://hooks.slack.com/services/T/B/X")
+	rendered, err := NewSlackSender("http://localhost:9000/").Render(n)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:9000/services/T/B/X", rendered.Recipient)
+	assert.Equal(t, "*Deploy*\n*Done* in <https://ci.example.com/1|CI> &amp; ~slow~", rendered.Body)
+	assert.Equal(t, true, rendered.Payload["mrkdwn"])
+}
+
+func TestDiscordSenderRateLimit(t *testing.T) {
+	calls := 0
+	var payload map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "/api/webhooks/1/token", r.URL.Path)
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message":"You are being rate limited.","retry_after":0.25,"global":false}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := NewDiscordSender(srv.URL)
+	var waited []time.Duration
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		waited = append(waited, d)
+		return nil
+	}
+
+	_, err := s.Send(context.Background(), newChatNotification(models.NotificationTypeDiscord, "api/webhooks/1/token"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []time.Duration{250 * time.Millisecond}, waited)
+	assert.Equal(t, "**Deploy**\n**Done** in [CI](https://ci.example.com/1) & ~~slow~~", payload["content"])
+}
+
+func TestChatSenderRateLimitWaitCanceled(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	// остановка воркера прерывает ожидание, а не держит его до конца Retry-After
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	started := time.Now()
+	_, err := NewSlackSender(srv.URL).Send(ctx, newChatNotification(models.NotificationTypeSlack, "/services/T000/B000/XXX"))
+
+	var rl *RateLimitError
+	if assert.ErrorAs(t, err, &rl) {
+		assert.Equal(t, 5*time.Second, rl.RetryAfter)
+	}
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+func TestChatSenderRateLimitWithoutRetryAfter(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	s := NewSlackSender(srv.URL)
+	var waited []time.Duration
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		waited = append(waited, d)
+		return nil
+	}
+
+	// без Retry-After отправитель не повторяет запрос сразу, а ждет минимальную паузу
+	_, err := s.Send(context.Background(), newChatNotification(models.NotificationTypeSlack, "/services/T000/B000/XXX"))
+
+	var rl *RateLimitError
+	if assert.ErrorAs(t, err, &rl) {
+		assert.Equal(t, time.Second, rl.RetryAfter)
+	}
+	assert.Equal(t, chatRateLimitRetries+1, calls)
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, waited)
+}
+
+func TestMattermostSenderRateLimitExceeded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Ratelimit-Reset", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	_, err := NewMattermostSender(srv.URL).Send(context.Background(), newChatNotification(models.NotificationTypeMattermost, "/hooks/abc"))
+
+	var rl *RateLimitError
+	if assert.ErrorAs(t, err, &rl) {
+		assert.Equal(t, 2*time.Minute, rl.RetryAfter)
+	}
+}
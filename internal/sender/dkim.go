@@ -0,0 +1,177 @@
+package sender
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dkimSignedHeaders заголовки, которые подписываются, если они есть в письме.
+var dkimSignedHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"MIME-Version", "Content-Type", "Content-Transfer-Encoding", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// dkimWSP последовательность пробелов и табуляций, которая при relaxed-канонизации сводится к одному пробелу.
+var dkimWSP = regexp.MustCompile(`[ \t]+`)
+
+// DKIMSigner подписывает письма DKIM (RFC 6376) с канонизацией relaxed/relaxed.
+// Поддерживаются ключи RSA (rsa-sha256) и Ed25519 (ed25519-sha256, RFC 8463).
+type DKIMSigner struct {
+	domain    string
+	selector  string
+	key       crypto.Signer
+	algorithm string
+}
+
+// NewDKIMSigner создает подписывающий объект для домена и селектора. Открытый ключ публикуется
+// в DNS-записи TXT <selector>._domainkey.<domain>. keyPEM — закрытый ключ в формате PKCS#1 или PKCS#8.
+func NewDKIMSigner(domain, selector string, keyPEM []byte) (*DKIMSigner, error) {
+	if domain == "" || selector == "" {
+		return nil, errors.New("dkim domain and selector are required")
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("dkim private key is not PEM encoded")
+	}
+
+	var key any
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid dkim private key: %w", err)
+	}
+
+	s := &DKIMSigner{domain: domain, selector: selector}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		s.key, s.algorithm = k, "rsa-sha256"
+	case ed25519.PrivateKey:
+		s.key, s.algorithm = k, "ed25519-sha256"
+	default:
+		return nil, fmt.Errorf("unsupported dkim key type %T", key)
+	}
+	return s, nil
+}
+
+// Sign возвращает письмо с добавленным в начало заголовком DKIM-Signature.
+// Письмо должно использовать CRLF в качестве разделителя строк.
+func (s *DKIMSigner) Sign(msg []byte) ([]byte, error) {
+	header, body, ok := bytes.Cut(msg, []byte("\r\n\r\n"))
+	if !ok {
+		return nil, errors.New("dkim: message has no header/body separator")
+	}
+	header = append(header, "\r\n"...)
+	fields := dkimHeaderFields(header)
+
+	bodyHash := sha256.Sum256(dkimRelaxedBody(body))
+
+	var (
+		names  []string
+		signed strings.Builder
+	)
+	for _, name := range dkimSignedHeaders {
+		// подписывается последнее вхождение заголовка (RFC 6376, 5.4.2)
+		for i := len(fields) - 1; i >= 0; i-- {
+			if strings.EqualFold(fields[i].name, name) {
+				names = append(names, strings.ToLower(name))
+				signed.WriteString(dkimRelaxedHeader(fields[i].name, fields[i].value))
+				break
+			}
+		}
+	}
+	if len(names) == 0 || names[0] != "from" {
+		return nil, errors.New("dkim: message has no From header")
+	}
+
+	value := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s;\r\n t=%s; h=%s;\r\n bh=%s;\r\n b=",
+		s.algorithm, s.domain, s.selector, strconv.FormatInt(time.Now().Unix(), 10),
+		strings.Join(names, ":"), base64.StdEncoding.EncodeToString(bodyHash[:]))
+	// сам заголовок подписи входит в подпись с пустым b= и без завершающего CRLF
+	signed.WriteString(strings.TrimSuffix(dkimRelaxedHeader("DKIM-Signature", value), "\r\n"))
+
+	signature, err := s.sign([]byte(signed.String()))
+	if err != nil {
+		return nil, fmt.Errorf("dkim: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(msg) + 512)
+	out.WriteString("DKIM-Signature: " + value + base64.StdEncoding.EncodeToString(signature) + "\r\n")
+	out.Write(msg)
+	return out.Bytes(), nil
+}
+
+// sign подписывает SHA-256 хеш данных ключом DKIM.
+func (s *DKIMSigner) sign(data []byte) ([]byte, error) {
+	hash := sha256.Sum256(data)
+	if s.algorithm == "ed25519-sha256" {
+		// RFC 8463: Ed25519 подписывает сам хеш SHA-256
+		return s.key.Sign(rand.Reader, hash[:], crypto.Hash(0))
+	}
+	return s.key.Sign(rand.Reader, hash[:], crypto.SHA256)
+}
+
+// dkimField заголовок письма с необработанным (возможно, свернутым) значением.
+type dkimField struct {
+	name  string
+	value string
+}
+
+// dkimHeaderFields разбирает заголовки письма, сохраняя строки продолжения в значении.
+func dkimHeaderFields(header []byte) []dkimField {
+	var fields []dkimField
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].value += line
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields = append(fields, dkimField{name: name, value: value})
+	}
+	return fields
+}
+
+// dkimRelaxedHeader канонизирует заголовок по алгоритму relaxed (RFC 6376, 3.4.2).
+func dkimRelaxedHeader(name, value string) string {
+	value = strings.NewReplacer("\r\n", "", "\n", "").Replace(value)
+	value = strings.TrimSpace(dkimWSP.ReplaceAllString(value, " "))
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value + "\r\n"
+}
+
+// dkimRelaxedBody канонизирует тело письма по алгоритму relaxed (RFC 6376, 3.4.4).
+func dkimRelaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(dkimWSP.ReplaceAllString(line, " "), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
@@ -0,0 +1,117 @@
+package sender
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDKIMRelaxedCanonicalization(t *testing.T) {
+	// пример из RFC 6376, 3.4.5
+	assert.Equal(t, "a:X\r\n", dkimRelaxedHeader("A", " X"))
+	assert.Equal(t, "b:Y Z\r\n", dkimRelaxedHeader("B ", " Y\t\r\n\tZ  "))
+	assert.Equal(t, " C\r\nD E\r\n", string(dkimRelaxedBody([]byte(" C \r\nD \t E\r\n\r\n\r\n"))))
+	assert.Nil(t, dkimRelaxedBody([]byte("\r\n\r\n")))
+}
+
+func TestDKIMSignerSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	edKeyPEM := testEd25519KeyPEM(t)
+	block, _ := pem.Decode(edKeyPEM)
+	edKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if !assert.NoError(t, err) {
+		return
+	}
+	edPub := edKey.(ed25519.PrivateKey).Public().(ed25519.PublicKey)
+
+	msg := "From: no-reply@example.com\r\nTo: user@example.com\r\nSubject: Hello\r\n  world\r\n" +
+		"Mime-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nHi  there \r\n\r\n"
+
+	tests := []struct {
+		name      string
+		keyPEM    []byte
+		algorithm string
+		verify    func(hash, signature []byte) bool
+	}{
+		{
+			name:      "RSA",
+			keyPEM:    pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			algorithm: "rsa-sha256",
+			verify: func(hash, signature []byte) bool {
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, hash, signature) == nil
+			},
+		},
+		{
+			name:      "Ed25519",
+			keyPEM:    edKeyPEM,
+			algorithm: "ed25519-sha256",
+			verify: func(hash, signature []byte) bool {
+				return ed25519.Verify(edPub, hash, signature)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewDKIMSigner("example.com", "mail", tt.keyPEM)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			signed, err := signer.Sign([]byte(msg))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.True(t, strings.HasSuffix(string(signed), msg))
+
+			fields := dkimHeaderFields([]byte(strings.TrimSuffix(string(signed), msg)))
+			if !assert.Len(t, fields, 1) {
+				return
+			}
+			tags := map[string]string{}
+			for _, tag := range strings.Split(dkimRelaxedHeader("", fields[0].value)[1:], ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(tag), "=")
+				tags[k] = strings.TrimSpace(v)
+			}
+			assert.Equal(t, tt.algorithm, tags["a"])
+			assert.Equal(t, "example.com", tags["d"])
+			assert.Equal(t, "mail", tags["s"])
+			assert.Equal(t, "from:subject:to:mime-version:content-type", tags["h"])
+
+			bodyHash := sha256.Sum256([]byte("Hi there\r\n"))
+			assert.Equal(t, base64.StdEncoding.EncodeToString(bodyHash[:]), tags["bh"])
+
+			// проверяем подпись так же, как это делает получатель
+			data := "from:no-reply@example.com\r\nsubject:Hello world\r\nto:user@example.com\r\n" +
+				"mime-version:1.0\r\ncontent-type:text/plain; charset=UTF-8\r\n" +
+				strings.TrimSuffix(dkimRelaxedHeader("DKIM-Signature", strings.TrimSuffix(fields[0].value, tags["b"]+"\r\n")), "\r\n")
+			hash := sha256.Sum256([]byte(data))
+			signature, err := base64.StdEncoding.DecodeString(tags["b"])
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.True(t, tt.verify(hash[:], signature))
+		})
+	}
+}
+
+// testEd25519KeyPEM создает закрытый ключ Ed25519 в формате PKCS#8.
+func testEd25519KeyPEM(t *testing.T) []byte {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
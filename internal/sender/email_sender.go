@@ -1,47 +1,264 @@
 package sender
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
 
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
 	"gopkg.in/gomail.v2"
 )
 
+// emailContentType тип содержимого письма.
+const emailContentType = "text/plain"
+
+// emailHTMLContentType тип HTML-версии письма.
+const emailHTMLContentType = "text/html"
+
+// AttachmentStore загружает содержимое сохраненных вложений.
+type AttachmentStore interface {
+	Content(ctx context.Context, id string) ([]byte, error)
+}
+
+// SMTPConfig настройки подключения к SMTP-серверу, шифрования, подписи DKIM и пула соединений.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+
+	// TLSMode режим шифрования соединения (по умолчанию SMTPTLSOpportunistic)
+	TLSMode SMTPTLSMode
+	// RootCAs корневые сертификаты для проверки сервера (nil — системные)
+	RootCAs *x509.CertPool
+	// InsecureSkipVerify отключает проверку сертификата сервера; только для тестовых стендов
+	InsecureSkipVerify bool
+	// DKIM подписывает исходящие письма; nil — письма не подписываются
+	DKIM *DKIMSigner
+
+	// PoolSize максимальное число одновременно открытых соединений
+	PoolSize int
+	// IdleTimeout через сколько простоя соединение закрывается (0 — не закрывается по простою)
+	IdleTimeout time.Duration
+	// MaxMessagesPerConn после скольких писем соединение открывается заново (0 — без ограничения)
+	MaxMessagesPerConn int
+
+	// Unsubscribe выдает ссылки отписки для заголовка List-Unsubscribe; nil — заголовок не добавляется
+	Unsubscribe UnsubscribeLinker
+}
+
+// UnsubscribeLinker возвращает ссылку отписки получателя канала от категории уведомлений.
+type UnsubscribeLinker interface {
+	URL(t models.NotificationType, recipient, category string) string
+}
+
 // EmailSender реализует отправку уведомлений по email.
 type EmailSender struct {
-	host     string
-	port     int
-	username string
-	password string
-	from     string
+	from        string
+	pool        *smtpPool
+	attachments AttachmentStore
+	unsubscribe UnsubscribeLinker
 }
 
-// NewEmailSender создает новый экземпляр EmailSender.
-func NewEmailSender(host string, port int, username, password, from string) *EmailSender {
+// NewEmailSender создает новый экземпляр EmailSender. Письма отправляются через пул
+// постоянных SMTP-соединений; соединения открываются при первой отправке.
+// attachments загружает содержимое вложений; может быть nil, если процесс не отправляет письма с вложениями.
+func NewEmailSender(cfg SMTPConfig, attachments AttachmentStore) *EmailSender {
+	d := newSMTPDialer(cfg)
+
 	return &EmailSender{
-		host:     host,
-		port:     port,
-		username: username,
-		password: password,
-		from:     from,
+		from:        cfg.From,
+		pool:        newSMTPPool(d.Dial, cfg.PoolSize, cfg.IdleTimeout, cfg.MaxMessagesPerConn),
+		attachments: attachments,
+		unsubscribe: cfg.Unsubscribe,
+	}
+}
+
+// Send отправляет уведомление по email. ProviderMessageID — заголовок Message-ID письма,
+// по нему письмо находится в логах SMTP-сервера и в уведомлениях о недоставке.
+func (s *EmailSender) Send(ctx context.Context, n *models.Notification) (*Result, error) {
+	m, err := s.message(ctx, n)
+	if err != nil {
+		return failed(nil, err)
+	}
+	id, err := emailMessageID(s.from)
+	if err != nil {
+		return failed(nil, err)
+	}
+	m.SetHeader("Message-ID", id)
+
+	if err := s.pool.Send(ctx, m); err != nil {
+		return failed(nil, fmt.Errorf("failed to send email: %w", classifySMTPError(err)))
+	}
+
+	return delivered(id, nil), nil
+}
+
+// classifySMTPError помечает ответ SMTP-сервера классом по коду: 5xx (несуществующий ящик,
+// отклоненное письмо) — постоянная ошибка, 4xx (ящик переполнен, greylisting) — временная.
+func classifySMTPError(err error) error {
+	var smtpErr *textproto.Error
+	if !errors.As(err, &smtpErr) {
+		return err
+	}
+	switch {
+	case smtpErr.Code >= 500:
+		return WithClass(ErrorClassPermanent, err)
+	case smtpErr.Code >= 400:
+		return WithClass(ErrorClassTransient, err)
+	default:
+		return err
+	}
+}
+
+// emailMessageID генерирует уникальный Message-ID в домене адреса отправителя.
+func emailMessageID(from string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate message id: %w", err)
 	}
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">", nil
+}
+
+// Close закрывает простаивающие SMTP-соединения.
+func (s *EmailSender) Close() error {
+	return s.pool.Close()
 }
 
-// Send отправляет уведомление по email.
-func (s *EmailSender) Send(n *models.Notification) error {
+// Render возвращает письмо в том виде, в котором оно будет отправлено.
+func (s *EmailSender) Render(n *models.Notification) (*models.RenderedMessage, error) {
+	m, err := s.message(context.Background(), n)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw bytes.Buffer
+	if _, err := m.WriteTo(&raw); err != nil {
+		return nil, fmt.Errorf("failed to render email: %w", err)
+	}
+
+	headers := map[string]string{}
+	for _, name := range []string{"From", "To", "Subject", "List-Unsubscribe"} {
+		if v := m.GetHeader(name); len(v) > 0 {
+			headers[name] = v[0]
+		}
+	}
+	headers["Content-Type"] = emailContentType + "; charset=UTF-8"
+	email := models.PayloadOf[models.EmailNotification](n)
+	if email.HTML != "" || len(email.Attachments) > 0 {
+		headers["Content-Type"] = "multipart/mixed"
+	}
+
+	return &models.RenderedMessage{
+		Type:      n.Type,
+		Recipient: email.Email,
+		Subject:   email.Subject,
+		Body:      emailText(email),
+		HTML:      email.HTML,
+		Headers:   headers,
+		Raw:       raw.String(),
+	}, nil
+}
+
+// message собирает письмо для уведомления.
+func (s *EmailSender) message(ctx context.Context, n *models.Notification) (*gomail.Message, error) {
+	email := models.PayloadOf[models.EmailNotification](n)
+	if email == nil {
+		return nil, errors.New("email notification details are missing")
+	}
 
 	m := gomail.NewMessage()
 	m.SetHeader("From", s.from)
-	m.SetHeader("To", n.EmailNotification.Email)
-	m.SetHeader("Subject", n.EmailNotification.Subject)
-	m.SetBody("text/plain", n.EmailNotification.Message)
+	m.SetHeader("To", email.Email)
+	m.SetHeader("Subject", email.Subject)
+	if s.unsubscribe != nil {
+		// RFC 8058: почтовый клиент отписывает получателя одним POST-запросом на эту ссылку
+		m.SetHeader("List-Unsubscribe", "<"+s.unsubscribe.URL(n.Type, email.Email, n.Category)+">")
+		m.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	// текст и HTML — альтернативы одного письма (multipart/alternative)
+	m.SetBody(emailContentType, emailText(email))
+	if email.HTML != "" {
+		m.AddAlternative(emailHTMLContentType, email.HTML)
+	}
 
-	d := gomail.NewDialer(s.host, s.port, s.username, s.password)
-	d.SSL = false // MailHog не использует TLS
+	for _, a := range email.Attachments {
+		content, err := loadAttachment(ctx, s.attachments, a)
+		if err != nil {
+			return nil, err
+		}
+		copyContent := gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(content)
+			return err
+		})
+		if a.Inline() {
+			// встроенные изображения попадают в multipart/related вместе с HTML
+			headers := attachmentHeaders(a, "inline")
+			headers["Content-ID"] = []string{"<" + a.ContentID + ">"}
+			m.Embed(a.Filename, copyContent, gomail.SetHeader(headers))
+		} else {
+			m.Attach(a.Filename, copyContent, gomail.SetHeader(attachmentHeaders(a, "attachment")))
+		}
+	}
+	return m, nil
+}
 
-	if err := d.DialAndSend(m); err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+// attachmentHeaders возвращает заголовки части письма с вложением. Имя файла кодируется
+// по RFC 2231, поэтому допускаются не-ASCII имена.
+func attachmentHeaders(a models.Attachment, disposition string) map[string][]string {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
 	}
+	if mediaType, params, err := mime.ParseMediaType(contentType); err == nil {
+		params["name"] = a.Filename
+		contentType = mime.FormatMediaType(mediaType, params)
+	}
+	return map[string][]string{
+		"Content-Type":        {contentType},
+		"Content-Disposition": {mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename})},
+	}
+}
 
-	return nil
+// loadAttachment возвращает содержимое вложения: из уведомления, если оно еще не сохранено
+// (например, при предпросмотре), иначе из хранилища вложений.
+func loadAttachment(ctx context.Context, store AttachmentStore, a models.Attachment) ([]byte, error) {
+	if a.Content != nil {
+		return a.Content, nil
+	}
+	if store == nil || a.ID == "" {
+		return nil, fmt.Errorf("content of attachment %q is not available", a.Filename)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	content, err := store.Content(ctx, a.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attachment %q: %w", a.Filename, err)
+	}
+	return content, nil
+}
+
+// emailText возвращает текстовую версию письма; для HTML-письма без текста она строится из HTML.
+func emailText(email *models.EmailNotification) string {
+	if email.Message == "" && email.HTML != "" {
+		return HTMLToText(email.HTML)
+	}
+	return email.Message
 }
@@ -0,0 +1,130 @@
+package sender
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAttachmentStore map[string][]byte
+
+func (f fakeAttachmentStore) Content(_ context.Context, id string) ([]byte, error) {
+	return f[id], nil
+}
+
+func TestHTMLToText(t *testing.T) {
+	html := `<html><head><style>p {color: red}</style></head><body>
+<h1>Заказ&nbsp;42</h1>
+<p>Здравствуйте!<br>Ваш заказ <b>готов</b>.</p>
+<ul><li>Пицца</li><li>Сок</li></ul>
+<p><a href="https://shop.example.com/orders/42">Открыть заказ</a> или <a href="mailto:help@example.com">help@example.com</a></p>
+<img src="cid:logo" alt="Логотип"><script>alert(1)</script>
+</body></html>`
+
+	assert.Equal(t, "Заказ 42\n\nЗдравствуйте!\nВаш заказ готов.\n\n- Пицца\n- Сок\n\nОткрыть заказ (https://shop.example.com/orders/42) или help@example.com\n\nЛоготип", HTMLToText(html))
+}
+
+func TestEmailSenderRenderHTMLWithAttachments(t *testing.T) {
+	s := NewEmailSender(SMTPConfig{Host: "localhost", Port: 1025, From: "no-reply@example.com"}, fakeAttachmentStore{"att-1": []byte("%PDF-1.4")})
+
+	rendered, err := s.Render(&models.Notification{
+		Type: models.NotificationTypeEmail,
+		Payload: &models.EmailNotification{
+			Email:   "user@example.com",
+			Subject: "Счет",
+			HTML:    `<p>Счет во вложении</p><img src="cid:logo">`,
+			Attachments: []models.Attachment{
+				{ID: "att-1", Filename: "счет.pdf", ContentType: "application/pdf"},
+				{Filename: "logo.png", ContentType: "image/png", ContentID: "logo", Content: []byte("\x89PNG")},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Счет во вложении", rendered.Body)
+
+	msg, err := mail.ReadMessage(strings.NewReader(rendered.Raw))
+	if !assert.NoError(t, err) {
+		return
+	}
+	parts := map[string]string{}
+	collectParts(t, msg.Header.Get("Content-Type"), msg.Body, parts)
+
+	assert.Equal(t, "Счет во вложении", parts["text/plain"])
+	assert.Equal(t, `<p>Счет во вложении</p><img src="cid:logo">`, parts["text/html"])
+	assert.Equal(t, "%PDF-1.4", parts["application/pdf"])
+	assert.Equal(t, "\x89PNG", parts["image/png <logo>"])
+}
+
+// collectParts обходит MIME-дерево письма и сохраняет содержимое листовых частей по типу
+// (для встроенных частей — вместе с Content-ID).
+func collectParts(t *testing.T, contentType string, body io.Reader, parts map[string]string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		data, _ := io.ReadAll(body)
+		parts[mediaType] = string(data)
+		return
+	}
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		partType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if strings.HasPrefix(partType, "multipart/") {
+			collectParts(t, p.Header.Get("Content-Type"), p, parts)
+			continue
+		}
+		var data []byte
+		if p.Header.Get("Content-Transfer-Encoding") == "base64" {
+			data, _ = io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		} else {
+			data, _ = io.ReadAll(p)
+		}
+		key := partType
+		if cid := p.Header.Get("Content-Id"); cid != "" {
+			key += " " + cid
+		}
+		parts[key] = string(data)
+	}
+}
+
+type fakeUnsubscribeLinker struct{}
+
+func (fakeUnsubscribeLinker) URL(t models.NotificationType, recipient, category string) string {
+	return "https://notify.example.com/v1/unsubscribe?token=" + string(t) + "-" + recipient + "-" + category
+}
+
+func TestEmailSenderListUnsubscribe(t *testing.T) {
+	s := NewEmailSender(SMTPConfig{Host: "localhost", Port: 1025, From: "no-reply@example.com", Unsubscribe: fakeUnsubscribeLinker{}}, nil)
+
+	rendered, err := s.Render(&models.Notification{
+		Type:     models.NotificationTypeEmail,
+		Category: "newsletter",
+		Payload:  &models.EmailNotification{Email: "user@example.com", Subject: "Новости", Message: "Привет"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(rendered.Raw))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "<https://notify.example.com/v1/unsubscribe?token=email-user@example.com-newsletter>", msg.Header.Get("List-Unsubscribe"))
+	assert.Equal(t, "List-Unsubscribe=One-Click", msg.Header.Get("List-Unsubscribe-Post"))
+	assert.Equal(t, msg.Header.Get("List-Unsubscribe"), rendered.Headers["List-Unsubscribe"])
+}
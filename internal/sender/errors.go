@@ -0,0 +1,128 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// ErrPermanent помечает ошибки доставки, которые не исправятся повтором (неверный получатель,
+// отклоненный запрос); уведомление сразу переводится в failed.
+var ErrPermanent = errors.New("permanent delivery error")
+
+// RateLimitError возвращается, если платформа ограничила частоту запросов.
+// RetryAfter — через сколько, по данным платформы, можно повторить запрос;
+// воркер переносит отправку на это время вместо немедленных повторов.
+type RateLimitError struct {
+	Channel    models.NotificationType
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limit exceeded, retry after %s", e.Channel, e.RetryAfter)
+}
+
+// BlockedError получатель заблокировал отправителя или больше не существует. Ошибка постоянная,
+// а получатель попадает в список подавления, чтобы ему больше не отправлять.
+type BlockedError struct {
+	Recipient string
+	Reason    string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("recipient %s is blocked: %s", e.Recipient, e.Reason)
+}
+
+// Is позволяет проверять BlockedError через errors.Is(err, ErrPermanent).
+func (e *BlockedError) Is(target error) bool { return target == ErrPermanent }
+
+// ErrorClass класс ошибки доставки: определяет, повторять ли отправку и когда.
+type ErrorClass string
+
+const (
+	// ErrorClassTransient временная ошибка (сеть, таймаут, ошибка сервера провайдера); отправку можно повторить
+	ErrorClassTransient ErrorClass = "transient"
+	// ErrorClassRateLimited провайдер ограничил частоту запросов; повтор — после RateLimitError.RetryAfter
+	ErrorClassRateLimited ErrorClass = "rate_limited"
+	// ErrorClassPermanent повтор не поможет: неверный получатель, отклоненное сообщение, нет отправителя для канала
+	ErrorClassPermanent ErrorClass = "permanent"
+	// ErrorClassUnknown отправитель не смог определить, поможет ли повтор
+	ErrorClassUnknown ErrorClass = "unknown"
+)
+
+// ErrorClasses все классы ошибок доставки.
+var ErrorClasses = []ErrorClass{ErrorClassTransient, ErrorClassRateLimited, ErrorClassPermanent, ErrorClassUnknown}
+
+// ClassifiedError реализуют ошибки, которые сами знают свой класс (например, ошибки протокола провайдера).
+type ClassifiedError interface {
+	error
+	Class() ErrorClass
+}
+
+// classifiedError ошибка, класс которой указал отправитель.
+type classifiedError struct {
+	class ErrorClass
+	err   error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+
+func (e *classifiedError) Class() ErrorClass { return e.class }
+
+func (e *classifiedError) Unwrap() error { return e.err }
+
+// Is позволяет проверять постоянные ошибки через errors.Is(err, ErrPermanent).
+func (e *classifiedError) Is(target error) bool {
+	return target == ErrPermanent && e.class == ErrorClassPermanent
+}
+
+// WithClass помечает ошибку классом class; текст ошибки не меняется.
+// Для ограничения частоты нужен *RateLimitError, иначе неизвестно, когда повторять.
+func WithClass(class ErrorClass, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// Classify определяет класс ошибки доставки; nil — пустой класс.
+// Сетевые ошибки и истекший дедлайн считаются временными, ошибки без признаков класса — неизвестными.
+func Classify(err error) ErrorClass {
+	var (
+		rateLimit  *RateLimitError
+		classified ClassifiedError
+		netErr     net.Error
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &rateLimit):
+		return ErrorClassRateLimited
+	case errors.As(err, &classified) && classified.Class() != "":
+		return classified.Class()
+	case errors.Is(err, ErrPermanent), errors.Is(err, ErrUnsupportedType):
+		return ErrorClassPermanent
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return ErrorClassTransient
+	default:
+		return ErrorClassUnknown
+	}
+}
+
+// httpStatusError помечает ошибку ответа HTTP-провайдера классом по коду ответа: 408 и 5xx — временная,
+// остальные 4xx — постоянная ошибка запроса. 429 отправители обрабатывают отдельно как *RateLimitError.
+func httpStatusError(status int, err error) error {
+	switch {
+	case status == http.StatusRequestTimeout || status >= 500:
+		return WithClass(ErrorClassTransient, err)
+	case status >= 400:
+		return WithClass(ErrorClassPermanent, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,57 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	rateLimit := &RateLimitError{Channel: models.NotificationTypeTelegram, RetryAfter: time.Second}
+
+	assert.Equal(t, ErrorClass(""), Classify(nil))
+	assert.Equal(t, ErrorClassRateLimited, Classify(fmt.Errorf("send: %w", rateLimit)))
+	assert.Equal(t, ErrorClassPermanent, Classify(&BlockedError{Recipient: "42", Reason: "blocked"}))
+	assert.Equal(t, ErrorClassPermanent, Classify(fmt.Errorf("%w: bad request", ErrPermanent)))
+	assert.Equal(t, ErrorClassPermanent, Classify(ErrUnsupportedType))
+	assert.Equal(t, ErrorClassTransient, Classify(context.DeadlineExceeded))
+	assert.Equal(t, ErrorClassTransient, Classify(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.Equal(t, ErrorClassUnknown, Classify(errors.New("unexpected response")))
+
+	// класс, указанный отправителем, не меняет текст ошибки
+	err := WithClass(ErrorClassTransient, errors.New("mailbox busy"))
+	assert.EqualError(t, err, "mailbox busy")
+	assert.Equal(t, ErrorClassTransient, Classify(fmt.Errorf("send: %w", err)))
+	assert.ErrorIs(t, WithClass(ErrorClassPermanent, errors.New("bad address")), ErrPermanent)
+	assert.False(t, errors.Is(err, ErrPermanent))
+}
+
+func TestClassifyProviderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"SMTPMailboxNotFound", classifySMTPError(&textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}), ErrorClassPermanent},
+		{"SMTPGreylisting", classifySMTPError(&textproto.Error{Code: 451, Msg: "4.7.1 try again later"}), ErrorClassTransient},
+		{"HTTPServerError", httpStatusError(http.StatusBadGateway, errors.New("bad gateway")), ErrorClassTransient},
+		{"HTTPRequestTimeout", httpStatusError(http.StatusRequestTimeout, errors.New("timeout")), ErrorClassTransient},
+		{"HTTPBadRequest", httpStatusError(http.StatusNotFound, errors.New("not found")), ErrorClassPermanent},
+		{"SMPPThrottled", &SMPPError{Command: 0x4, Status: smppStatusThrottled}, ErrorClassTransient},
+		{"SMPPInvalidDestination", &SMPPError{Command: 0x4, Status: smppStatusInvalidDestAddr}, ErrorClassPermanent},
+		{"SMPPOther", &SMPPError{Command: 0x4, Status: 0x45}, ErrorClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.err))
+		})
+	}
+}
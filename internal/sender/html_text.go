@@ -0,0 +1,100 @@
+package sender
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlSkipped содержимое, которое не отображается в письме
+	htmlSkipped = regexp.MustCompile(`(?is)<!--.*?-->|<(script|style|head)\b[^>]*>.*?</(script|style|head)\s*>`)
+	htmlTag     = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9]*)\b([^>]*)>`)
+	htmlAttr    = regexp.MustCompile(`(?is)\b([a-z-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	spaces      = regexp.MustCompile(`\s+`)
+	blankLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlBlocks теги, которые начинают новую строку (true — новый абзац)
+var htmlBlocks = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"table": true, "ul": true, "ol": true, "blockquote": true, "pre": true, "hr": true,
+	"div": false, "tr": false, "section": false, "article": false, "header": false, "footer": false,
+}
+
+// HTMLToText строит текстовую альтернативу HTML-письма: убирает разметку, скрипты
+// и стили, сохраняет абзацы, переносы строк и пункты списков, а у ссылок
+// дописывает адрес в скобках.
+func HTMLToText(s string) string {
+	s = htmlSkipped.ReplaceAllString(s, "")
+
+	var (
+		b        strings.Builder
+		href     string
+		linkText strings.Builder
+	)
+	text := func(t string) {
+		t = html.UnescapeString(spaces.ReplaceAllString(t, " "))
+		b.WriteString(t)
+		if href != "" {
+			linkText.WriteString(t)
+		}
+	}
+
+	pos := 0
+	for _, m := range htmlTag.FindAllStringSubmatchIndex(s, -1) {
+		text(s[pos:m[0]])
+		pos = m[1]
+
+		closing := m[3] > m[2]
+		name := strings.ToLower(s[m[4]:m[5]])
+		attrs := s[m[6]:m[7]]
+		switch {
+		case name == "br":
+			b.WriteString("\n")
+		case name == "li" && !closing:
+			b.WriteString("\n- ")
+		case name == "td" || name == "th":
+			if closing {
+				b.WriteString(" ")
+			}
+		case name == "img" && !closing:
+			if alt := htmlAttribute(attrs, "alt"); alt != "" {
+				text(alt)
+			}
+		case name == "a" && !closing:
+			href = htmlAttribute(attrs, "href")
+			linkText.Reset()
+		case name == "a" && closing:
+			label := strings.TrimSpace(linkText.String())
+			if href != "" && !strings.HasPrefix(href, "#") && label != href && "mailto:"+label != href {
+				b.WriteString(" (" + href + ")")
+			}
+			href = ""
+		default:
+			if paragraph, ok := htmlBlocks[name]; ok {
+				b.WriteString("\n")
+				if paragraph {
+					b.WriteString("\n")
+				}
+			}
+		}
+	}
+	text(s[pos:])
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// htmlAttribute возвращает значение атрибута тега (без разбора сущностей в имени).
+func htmlAttribute(attrs, name string) string {
+	for _, m := range htmlAttr.FindAllStringSubmatch(attrs, -1) {
+		if strings.EqualFold(m[1], name) {
+			return html.UnescapeString(m[2] + m[3] + m[4])
+		}
+	}
+	return ""
+}
@@ -1,30 +1,52 @@
 package sender
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
 )
 
-// MultiSender реализует отправку уведомлений через несколько каналов (email и telegram).
+// ErrUnsupportedType возвращается, если для типа уведомления не зарегистрирован отправитель.
+var ErrUnsupportedType = errors.New("unsupported notification type")
+
+// MultiSender реализует отправку уведомлений через несколько каналов,
+// выбирая отправителя по типу уведомления.
 type MultiSender struct {
-	emailSender    Sender
-	telegramSender Sender
+	senders map[models.NotificationType]Sender
 }
 
-// NewMultiSender создает новый экземпляр MultiSender.
-func NewMultiSender(emailSender, telegramSender Sender) *MultiSender {
-	return &MultiSender{emailSender: emailSender, telegramSender: telegramSender}
+// NewMultiSender создает новый экземпляр MultiSender с отправителями по типам уведомлений.
+func NewMultiSender(senders map[models.NotificationType]Sender) *MultiSender {
+	return &MultiSender{senders: senders}
 }
 
 // Send отправляет уведомление через соответствующий канал в зависимости от типа уведомления.
-func (m *MultiSender) Send(n *models.Notification) error {
-	switch n.Type {
-	case "email":
-		return m.emailSender.Send(n)
-	case "telegram":
-		return m.telegramSender.Send(n)
-	default:
-		return fmt.Errorf("unsupported notification type: %s", n.Type)
+func (m *MultiSender) Send(ctx context.Context, n *models.Notification) (*Result, error) {
+	s, err := m.sender(n)
+	if err != nil {
+		return failed(nil, err)
+	}
+	return s.Send(ctx, n)
+}
+
+// Segments возвращает количество сегментов уведомления, если его канал тарифицируется сегментами.
+func (m *MultiSender) Segments(n *models.Notification) int {
+	s, err := m.sender(n)
+	if err != nil {
+		return 0
+	}
+	if counter, ok := s.(SegmentCounter); ok {
+		return counter.Segments(n)
+	}
+	return 0
+}
+
+func (m *MultiSender) sender(n *models.Notification) (Sender, error) {
+	s, ok := m.senders[n.Type]
+	if !ok || s == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, n.Type)
 	}
+	return s, nil
 }
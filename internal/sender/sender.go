@@ -1,8 +1,56 @@
 package sender
 
-import "github.com/PavelBradnitski/WbTechL3.1/internal/models"
+import (
+	"context"
+	"unicode/utf8"
 
-// Sender описывает метод для отправки уведомлений.
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// maxResponseLength сколько байт ответа провайдера сохраняется в Result.Response.
+const maxResponseLength = 4096
+
+// Sender описывает метод для отправки уведомлений. Отправка прерывается при отмене ctx
+// или по истечении его дедлайна.
 type Sender interface {
-	Send(n *models.Notification) error
+	Send(ctx context.Context, n *models.Notification) (*Result, error)
+}
+
+// Result результат попытки доставки. При ошибке Send тоже возвращает Result,
+// чтобы вызывающий знал класс ошибки и ответ провайдера.
+type Result struct {
+	// ProviderMessageID идентификатор сообщения у провайдера (Message-ID письма, message_id в Telegram, ID SMS)
+	ProviderMessageID string
+	// Response ответ провайдера, обрезанный до maxResponseLength байт
+	Response string
+	// ErrorClass класс ошибки; пустой при успешной доставке
+	ErrorClass ErrorClass
+}
+
+// Renderer описывает метод для подготовки уведомления к отправке без фактической доставки.
+// Отправители используют тот же код при Send, поэтому результат совпадает с доставленным сообщением.
+type Renderer interface {
+	Render(n *models.Notification) (*models.RenderedMessage, error)
+}
+
+// delivered возвращает результат успешной доставки.
+func delivered(messageID string, response []byte) *Result {
+	return &Result{ProviderMessageID: messageID, Response: truncateResponse(response)}
+}
+
+// failed возвращает результат неудачной доставки с классом ошибки err.
+func failed(response []byte, err error) (*Result, error) {
+	return &Result{Response: truncateResponse(response), ErrorClass: Classify(err)}, err
+}
+
+// truncateResponse обрезает ответ провайдера до maxResponseLength байт, не разрывая символы UTF-8.
+func truncateResponse(response []byte) string {
+	if len(response) <= maxResponseLength {
+		return string(response)
+	}
+	n := maxResponseLength
+	for n > 0 && !utf8.RuneStart(response[n]) {
+		n--
+	}
+	return string(response[:n])
 }
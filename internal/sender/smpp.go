@@ -0,0 +1,278 @@
+package sender
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+)
+
+// Команды и параметры SMPP 3.4, используемые клиентом.
+const (
+	smppGenericNack     uint32 = 0x80000000
+	smppBindTransmitter uint32 = 0x00000002
+	smppSubmitSM        uint32 = 0x00000004
+	smppUnbind          uint32 = 0x00000006
+	smppEnquireLink     uint32 = 0x00000015
+	smppResponse        uint32 = 0x80000000
+
+	smppInterfaceVersion = 0x34
+	// smppESMClassUDHI в short_message есть заголовок пользовательских данных (UDH)
+	smppESMClassUDHI = 0x40
+	// smppDataCodingDefault алфавит SMSC по умолчанию (GSM-7)
+	smppDataCodingDefault = 0x00
+	// smppDataCodingUCS2 UCS-2
+	smppDataCodingUCS2 = 0x08
+
+	smppTONInternational = 0x01
+	smppTONAlphanumeric  = 0x05
+	smppNPIISDN          = 0x01
+
+	// smppHeaderLength длина заголовка PDU
+	smppHeaderLength = 16
+	// command_status ответов SMSC, по которым определяется класс ошибки
+	smppStatusInvalidMsgLength  = 0x00000001
+	smppStatusSysErr            = 0x00000008
+	smppStatusInvalidSourceAddr = 0x0000000A
+	smppStatusInvalidDestAddr   = 0x0000000B
+	smppStatusMsgQueueFull      = 0x00000014
+	smppStatusThrottled         = 0x00000058
+
+	// smppMaxPDULength ограничение размера входящего PDU
+	smppMaxPDULength = 64 * 1024
+	// smppDialTimeout таймаут сессии, если в контексте нет дедлайна
+	smppDialTimeout = 30 * time.Second
+)
+
+// SMPPError ошибка, которую SMSC вернул в command_status ответа.
+type SMPPError struct {
+	Command uint32
+	Status  uint32
+}
+
+func (e *SMPPError) Error() string {
+	return fmt.Sprintf("smpp command 0x%08x failed with status 0x%08x", e.Command, e.Status)
+}
+
+// Class возвращает класс ошибки по command_status: перегрузка SMSC — временная ошибка,
+// неверные адреса и длина сообщения — постоянная.
+func (e *SMPPError) Class() ErrorClass {
+	switch e.Status {
+	case smppStatusSysErr, smppStatusMsgQueueFull, smppStatusThrottled:
+		return ErrorClassTransient
+	case smppStatusInvalidMsgLength, smppStatusInvalidSourceAddr, smppStatusInvalidDestAddr:
+		return ErrorClassPermanent
+	default:
+		return ErrorClassUnknown
+	}
+}
+
+// SMPPClient провайдер SMS по протоколу SMPP 3.4 в режиме transmitter.
+// Для каждого сообщения открывается отдельная сессия: bind_transmitter, submit_sm
+// на каждую часть и unbind. Длинные сообщения отправляются частями с UDH-заголовком склейки.
+type SMPPClient struct {
+	addr       string
+	systemID   string
+	password   string
+	systemType string
+}
+
+// NewSMPPClient создает клиента SMSC по адресу addr (host:port).
+func NewSMPPClient(addr, systemID, password string) *SMPPClient {
+	return &SMPPClient{addr: addr, systemID: systemID, password: password}
+}
+
+// SendSMS отправляет сообщение. Возвращает идентификаторы частей, выданные SMSC, через запятую.
+func (c *SMPPClient) SendSMS(ctx context.Context, msg SMSMessage) (string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return "", fmt.Errorf("smpp connect error: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smppDialTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	s := &smppSession{conn: conn, r: bufio.NewReader(conn)}
+	if _, err := s.call(smppBindTransmitter, smppBindBody(c.systemID, c.password, c.systemType)); err != nil {
+		return "", fmt.Errorf("smpp bind error: %w", err)
+	}
+
+	parts, err := smppParts(msg)
+	if err != nil {
+		return "", err
+	}
+	ids := make([]string, 0, len(parts))
+	for _, part := range parts {
+		resp, err := s.call(smppSubmitSM, part)
+		if err != nil {
+			return "", fmt.Errorf("smpp submit error: %w", err)
+		}
+		id, _ := readCString(bytes.NewReader(resp))
+		ids = append(ids, id)
+	}
+
+	// ошибка unbind не влияет на уже принятые SMSC сообщения
+	s.call(smppUnbind, nil)
+	return strings.Join(ids, ","), nil
+}
+
+// smppSession SMPP-сессия поверх одного соединения.
+type smppSession struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	sequence uint32
+}
+
+// call отправляет запрос и ждет ответ с тем же sequence_number.
+// Возвращает тело ответа; ненулевой command_status — *SMPPError.
+func (s *smppSession) call(command uint32, body []byte) ([]byte, error) {
+	s.sequence++
+	if err := s.write(command, 0, s.sequence, body); err != nil {
+		return nil, err
+	}
+	for {
+		respCommand, status, sequence, respBody, err := s.read()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case respCommand == smppEnquireLink:
+			// SMSC проверяет соединение во время сессии
+			if err := s.write(smppEnquireLink|smppResponse, 0, sequence, nil); err != nil {
+				return nil, err
+			}
+		case sequence != s.sequence:
+			continue
+		case respCommand == smppGenericNack:
+			return nil, &SMPPError{Command: command, Status: status}
+		case respCommand != command|smppResponse:
+			return nil, fmt.Errorf("unexpected smpp response 0x%08x to 0x%08x", respCommand, command)
+		case status != 0:
+			return nil, &SMPPError{Command: command, Status: status}
+		default:
+			return respBody, nil
+		}
+	}
+}
+
+func (s *smppSession) write(command, status, sequence uint32, body []byte) error {
+	pdu := make([]byte, smppHeaderLength, smppHeaderLength+len(body))
+	binary.BigEndian.PutUint32(pdu[0:], uint32(smppHeaderLength+len(body)))
+	binary.BigEndian.PutUint32(pdu[4:], command)
+	binary.BigEndian.PutUint32(pdu[8:], status)
+	binary.BigEndian.PutUint32(pdu[12:], sequence)
+	_, err := s.conn.Write(append(pdu, body...))
+	return err
+}
+
+func (s *smppSession) read() (command, status, sequence uint32, body []byte, err error) {
+	header := make([]byte, smppHeaderLength)
+	if _, err = io.ReadFull(s.r, header); err != nil {
+		return 0, 0, 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:])
+	if length < smppHeaderLength || length > smppMaxPDULength {
+		return 0, 0, 0, nil, fmt.Errorf("invalid smpp pdu length %d", length)
+	}
+	body = make([]byte, length-smppHeaderLength)
+	if _, err = io.ReadFull(s.r, body); err != nil {
+		return 0, 0, 0, nil, err
+	}
+	return binary.BigEndian.Uint32(header[4:]), binary.BigEndian.Uint32(header[8:]),
+		binary.BigEndian.Uint32(header[12:]), body, nil
+}
+
+func smppBindBody(systemID, password, systemType string) []byte {
+	var b bytes.Buffer
+	writeCString(&b, systemID)
+	writeCString(&b, password)
+	writeCString(&b, systemType)
+	b.WriteByte(smppInterfaceVersion)
+	b.WriteByte(0) // addr_ton
+	b.WriteByte(0) // addr_npi
+	writeCString(&b, "")
+	return b.Bytes()
+}
+
+// smppParts собирает тела submit_sm для всех частей сообщения.
+func smppParts(msg SMSMessage) ([][]byte, error) {
+	encoding, parts := splitSMS(msg.Text)
+	dataCoding := byte(smppDataCodingDefault)
+	if encoding == SMSEncodingUCS2 {
+		dataCoding = smppDataCodingUCS2
+	}
+	if len(parts) > 255 {
+		return nil, errors.New("sms message is too long")
+	}
+
+	sourceTON, sourceNPI := byte(smppTONAlphanumeric), byte(0)
+	source := msg.From
+	if digits := strings.TrimPrefix(source, "+"); digits != "" && strings.Trim(digits, "0123456789") == "" {
+		sourceTON, sourceNPI, source = smppTONInternational, smppNPIISDN, digits
+	}
+
+	ref := byte(rand.IntN(256))
+	bodies := make([][]byte, 0, len(parts))
+	for i, part := range parts {
+		esmClass := byte(0)
+		short := part
+		if len(parts) > 1 {
+			// UDH склейки: IEI 0x00, длина 3, ссылка, всего частей, номер части
+			esmClass = smppESMClassUDHI
+			short = append([]byte{0x05, 0x00, 0x03, ref, byte(len(parts)), byte(i + 1)}, part...)
+		}
+
+		var b bytes.Buffer
+		writeCString(&b, "") // service_type
+		b.WriteByte(sourceTON)
+		b.WriteByte(sourceNPI)
+		writeCString(&b, source)
+		b.WriteByte(smppTONInternational)
+		b.WriteByte(smppNPIISDN)
+		writeCString(&b, strings.TrimPrefix(msg.To, "+"))
+		b.WriteByte(esmClass)
+		b.WriteByte(0)       // protocol_id
+		b.WriteByte(0)       // priority_flag
+		writeCString(&b, "") // schedule_delivery_time
+		writeCString(&b, "") // validity_period
+		b.WriteByte(0)       // registered_delivery
+		b.WriteByte(0)       // replace_if_present_flag
+		b.WriteByte(dataCoding)
+		b.WriteByte(0) // sm_default_msg_id
+		b.WriteByte(byte(len(short)))
+		b.Write(short)
+		bodies = append(bodies, b.Bytes())
+	}
+	return bodies, nil
+}
+
+func writeCString(b *bytes.Buffer, s string) {
+	b.WriteString(s)
+	b.WriteByte(0)
+}
+
+func readCString(r io.ByteReader) (string, error) {
+	var b []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return string(b), err
+		}
+		if c == 0 {
+			return string(b), nil
+		}
+		b = append(b, c)
+	}
+}
@@ -0,0 +1,117 @@
+package sender
+
+import (
+	"unicode/utf16"
+)
+
+// SMSEncoding кодировка текста SMS.
+type SMSEncoding string
+
+const (
+	// SMSEncodingGSM7 7-битный алфавит GSM 03.38
+	SMSEncodingGSM7 SMSEncoding = "GSM-7"
+	// SMSEncodingUCS2 UCS-2 (UTF-16BE), используется, если в тексте есть символы вне GSM-7
+	SMSEncodingUCS2 SMSEncoding = "UCS-2"
+)
+
+const (
+	// SMSMaxSegments максимальное количество сегментов одного сообщения
+	SMSMaxSegments = 10
+
+	gsm7SingleLength = 160
+	gsm7PartLength   = 153
+	ucs2SingleLength = 70
+	ucs2PartLength   = 67
+
+	// gsm7Escape префикс символов расширенной таблицы GSM-7
+	gsm7Escape = 0x1B
+)
+
+// gsm7Basic основная таблица GSM 03.38: индекс символа — его код.
+// Позиция 0x1B занята префиксом расширенной таблицы.
+var gsm7Basic = []rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// gsm7Extension расширенная таблица GSM 03.38: символ кодируется как 0x1B и код из таблицы.
+var gsm7Extension = map[rune]byte{
+	'\f': 0x0A, '^': 0x14, '{': 0x28, '}': 0x29, '\\': 0x2F,
+	'[': 0x3C, '~': 0x3D, ']': 0x3E, '|': 0x40, '€': 0x65,
+}
+
+var gsm7Codes = func() map[rune]byte {
+	codes := make(map[rune]byte, len(gsm7Basic))
+	for i, r := range gsm7Basic {
+		if i != gsm7Escape {
+			codes[r] = byte(i)
+		}
+	}
+	return codes
+}()
+
+// SMSSegments определяет кодировку текста и количество сегментов, на которые он будет разбит.
+func SMSSegments(text string) (SMSEncoding, int) {
+	encoding, parts := splitSMS(text)
+	return encoding, len(parts)
+}
+
+// splitSMS разбивает текст на части для отправки. Для GSM-7 части содержат септеты
+// (символы расширенной таблицы — два байта, префикс и код), для UCS-2 — байты UTF-16BE.
+// Символ расширенной таблицы и суррогатная пара не разрываются между частями.
+func splitSMS(text string) (SMSEncoding, [][]byte) {
+	if septets, ok := encodeGSM7(text); ok {
+		if len(septets) <= gsm7SingleLength {
+			return SMSEncodingGSM7, [][]byte{septets}
+		}
+		var parts [][]byte
+		for len(septets) > 0 {
+			n := min(gsm7PartLength, len(septets))
+			if n < len(septets) && septets[n-1] == gsm7Escape {
+				n--
+			}
+			parts = append(parts, septets[:n])
+			septets = septets[n:]
+		}
+		return SMSEncodingGSM7, parts
+	}
+
+	units := utf16.Encode([]rune(text))
+	if len(units) <= ucs2SingleLength {
+		return SMSEncodingUCS2, [][]byte{ucs2Bytes(units)}
+	}
+	var parts [][]byte
+	for len(units) > 0 {
+		n := min(ucs2PartLength, len(units))
+		if n < len(units) && utf16.IsSurrogate(rune(units[n-1])) && units[n-1] < 0xDC00 {
+			n--
+		}
+		parts = append(parts, ucs2Bytes(units[:n]))
+		units = units[n:]
+	}
+	return SMSEncodingUCS2, parts
+}
+
+// encodeGSM7 кодирует текст в септеты GSM-7 (по одному в байте).
+// Возвращает false, если в тексте есть символы вне алфавита GSM-7.
+func encodeGSM7(text string) ([]byte, bool) {
+	septets := make([]byte, 0, len(text))
+	for _, r := range text {
+		if code, ok := gsm7Codes[r]; ok {
+			septets = append(septets, code)
+			continue
+		}
+		if code, ok := gsm7Extension[r]; ok {
+			septets = append(septets, gsm7Escape, code)
+			continue
+		}
+		return nil, false
+	}
+	return septets, true
+}
+
+func ucs2Bytes(units []uint16) []byte {
+	b := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return b
+}
@@ -0,0 +1,71 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPSMSGateway провайдер SMS, отправляющий сообщения JSON-запросом на HTTP-шлюз.
+//
+// Тело запроса: {"from", "to", "text", "encoding", "segments"}. Успешный ответ — код 2xx,
+// идентификатор сообщения читается из поля "id" или "message_id" ответа.
+type HTTPSMSGateway struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTPSMSGateway создает провайдера для HTTP-шлюза по адресу url.
+// token, если задан, передается в заголовке Authorization: Bearer.
+func NewHTTPSMSGateway(url, token string) *HTTPSMSGateway {
+	return &HTTPSMSGateway{url: url, token: token, client: &http.Client{}}
+}
+
+// SendSMS отправляет сообщение на шлюз.
+func (g *HTTPSMSGateway) SendSMS(ctx context.Context, msg SMSMessage) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"from":     msg.From,
+		"to":       msg.To,
+		"text":     msg.Text,
+		"encoding": msg.Encoding,
+		"segments": msg.Segments,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode sms request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("sms gateway request error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sms gateway error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", httpStatusError(resp.StatusCode, fmt.Errorf("sms gateway failed: status %d: %s", resp.StatusCode, string(respBody)))
+	}
+
+	var result struct {
+		ID        string `json:"id"`
+		MessageID string `json:"message_id"`
+	}
+	// шлюз может вернуть пустое тело или тело без идентификатора — это не ошибка доставки
+	_ = json.Unmarshal(respBody, &result)
+	if result.ID != "" {
+		return result.ID, nil
+	}
+	return result.MessageID, nil
+}
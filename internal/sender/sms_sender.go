@@ -0,0 +1,113 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// smsTimeout таймаут отправки одного SMS провайдеру
+const smsTimeout = 30 * time.Second
+
+// SMSMessage сообщение, передаваемое провайдеру SMS.
+type SMSMessage struct {
+	// From имя или номер отправителя
+	From string
+	// To номер получателя в формате E.164
+	To       string
+	Text     string
+	Encoding SMSEncoding
+	Segments int
+}
+
+// SMSProvider отправляет SMS через конкретного провайдера (HTTP-шлюз, SMPP и т.п.).
+// Возвращает идентификатор сообщения у провайдера.
+type SMSProvider interface {
+	SendSMS(ctx context.Context, msg SMSMessage) (string, error)
+}
+
+// SegmentCounter реализуют отправители, доставка которых тарифицируется сегментами.
+type SegmentCounter interface {
+	// Segments возвращает количество сегментов, на которые будет разбито уведомление; 0 — не тарифицируется.
+	Segments(n *models.Notification) int
+}
+
+// SMSSender реализует отправку SMS-уведомлений через провайдера.
+type SMSSender struct {
+	provider SMSProvider
+	from     string
+}
+
+// NewSMSSender создает новый экземпляр SMSSender. from — имя или номер отправителя.
+func NewSMSSender(provider SMSProvider, from string) *SMSSender {
+	return &SMSSender{provider: provider, from: from}
+}
+
+// Send отправляет SMS через провайдера; ProviderMessageID — идентификатор SMS у провайдера.
+func (s *SMSSender) Send(ctx context.Context, n *models.Notification) (*Result, error) {
+	msg, err := s.message(n)
+	if err != nil {
+		return failed(nil, err)
+	}
+	if s.provider == nil {
+		return failed(nil, errors.New("sms provider is not configured"))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smsTimeout)
+	defer cancel()
+	id, err := s.provider.SendSMS(ctx, *msg)
+	if err != nil {
+		return failed(nil, fmt.Errorf("sms send error: %w", err))
+	}
+	log.Printf("SMS %s sent to %s: provider id %s, %d segment(s) %s", n.ID, msg.To, id, msg.Segments, msg.Encoding)
+	return delivered(id, nil), nil
+}
+
+// Render возвращает SMS в том виде, в котором оно будет передано провайдеру.
+func (s *SMSSender) Render(n *models.Notification) (*models.RenderedMessage, error) {
+	msg, err := s.message(n)
+	if err != nil {
+		return nil, err
+	}
+	return &models.RenderedMessage{
+		Type:      n.Type,
+		Recipient: msg.To,
+		Body:      msg.Text,
+		Payload: map[string]any{
+			"from":     msg.From,
+			"to":       msg.To,
+			"text":     msg.Text,
+			"encoding": msg.Encoding,
+			"segments": msg.Segments,
+		},
+	}, nil
+}
+
+// Segments возвращает количество сегментов SMS.
+func (s *SMSSender) Segments(n *models.Notification) int {
+	sms := models.PayloadOf[models.SMSNotification](n)
+	if sms == nil {
+		return 0
+	}
+	_, segments := SMSSegments(sms.Message)
+	return segments
+}
+
+func (s *SMSSender) message(n *models.Notification) (*SMSMessage, error) {
+	sms := models.PayloadOf[models.SMSNotification](n)
+	if sms == nil {
+		return nil, errors.New("sms notification details are missing")
+	}
+	encoding, segments := SMSSegments(sms.Message)
+	return &SMSMessage{
+		From:     s.from,
+		To:       sms.Phone,
+		Text:     sms.Message,
+		Encoding: encoding,
+		Segments: segments,
+	}, nil
+}
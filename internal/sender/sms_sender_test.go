@@ -0,0 +1,173 @@
+package sender
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMSSegments(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		encoding SMSEncoding
+		segments int
+	}{
+		{"GSM7Single", strings.Repeat("a", 160), SMSEncodingGSM7, 1},
+		{"GSM7Multi", strings.Repeat("a", 161), SMSEncodingGSM7, 2},
+		{"GSM7ThreeParts", strings.Repeat("a", 307), SMSEncodingGSM7, 3},
+		// символы расширенной таблицы занимают два септета
+		{"GSM7Extension", strings.Repeat("€", 80), SMSEncodingGSM7, 1},
+		{"GSM7ExtensionOverflow", strings.Repeat("€", 81), SMSEncodingGSM7, 2},
+		// escape-последовательность не разрывается на границе части
+		{"GSM7ExtensionOnBoundary", strings.Repeat("a", 152) + strings.Repeat("€", 5), SMSEncodingGSM7, 2},
+		{"UCS2Single", strings.Repeat("я", 70), SMSEncodingUCS2, 1},
+		{"UCS2Multi", strings.Repeat("я", 71), SMSEncodingUCS2, 2},
+		// эмодзи занимает суррогатную пару и не разрывается между частями
+		{"UCS2SurrogateOnBoundary", strings.Repeat("я", 66) + strings.Repeat("😀", 3), SMSEncodingUCS2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoding, segments := SMSSegments(tt.text)
+			assert.Equal(t, tt.encoding, encoding)
+			assert.Equal(t, tt.segments, segments)
+		})
+	}
+}
+
+func newSMSNotification(message string) *models.Notification {
+	return &models.Notification{
+		ID:      "notif-1",
+		Type:    models.NotificationTypeSMS,
+		Payload: &models.SMSNotification{Phone: "+79991234567", Message: message},
+	}
+}
+
+func TestHTTPSMSGateway(t *testing.T) {
+	var payload map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"id":"gw-42"}`))
+	}))
+	defer srv.Close()
+
+	gw := NewHTTPSMSGateway(srv.URL, "secret")
+	id, err := gw.SendSMS(context.Background(), SMSMessage{From: "Shop", To: "+79991234567", Text: "Привет", Encoding: SMSEncodingUCS2, Segments: 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "gw-42", id)
+	assert.Equal(t, "+79991234567", payload["to"])
+	assert.Equal(t, "UCS-2", payload["encoding"])
+}
+
+// fakeSMSC минимальный SMSC: принимает bind_transmitter, submit_sm и unbind.
+type fakeSMSC struct {
+	listener net.Listener
+	submits  chan []byte
+}
+
+func newFakeSMSC(t *testing.T) *fakeSMSC {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	smsc := &fakeSMSC{listener: l, submits: make(chan []byte, 16)}
+	go smsc.serve()
+	t.Cleanup(func() { l.Close() })
+	return smsc
+}
+
+func (f *fakeSMSC) serve() {
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	s := &smppSession{conn: conn, r: bufio.NewReader(conn)}
+	for n := 0; ; n++ {
+		command, _, sequence, body, err := s.read()
+		if err != nil {
+			close(f.submits)
+			return
+		}
+		var resp []byte
+		switch command {
+		case smppBindTransmitter:
+			id, _ := readCString(bytes.NewReader(body))
+			if id != "client" {
+				s.write(command|smppResponse, 0x0000000E, sequence, nil) // ESME_RINVPASWD
+				continue
+			}
+			// SMSC может проверить соединение посреди сессии
+			s.write(smppEnquireLink, 0, 100, nil)
+			s.read()
+			resp = []byte("\x00")
+		case smppSubmitSM:
+			f.submits <- body
+			resp = []byte("msg-" + string(rune('0'+n)) + "\x00")
+		}
+		s.write(command|smppResponse, 0, sequence, resp)
+	}
+}
+
+func TestSMPPClientSendsConcatenatedMessage(t *testing.T) {
+	smsc := newFakeSMSC(t)
+	s := NewSMSSender(NewSMPPClient(smsc.listener.Addr().String(), "client", "pass"), "Shop")
+
+	_, err := s.Send(context.Background(), newSMSNotification(strings.Repeat("я", 100)))
+	assert.NoError(t, err)
+
+	var parts [][]byte
+	for body := range smsc.submits {
+		parts = append(parts, body)
+	}
+	if assert.Len(t, parts, 2) {
+		r := bytes.NewReader(parts[0])
+		readCString(r) // service_type
+		r.ReadByte()
+		r.ReadByte()
+		source, _ := readCString(r)
+		r.ReadByte()
+		r.ReadByte()
+		dest, _ := readCString(r)
+		esmClass, _ := r.ReadByte()
+		r.Seek(5, io.SeekCurrent) // protocol_id, priority, два пустых времени, registered_delivery
+		r.ReadByte()              // replace_if_present_flag
+		dataCoding, _ := r.ReadByte()
+		r.ReadByte()
+		length, _ := r.ReadByte()
+		short := make([]byte, length)
+		r.Read(short)
+
+		assert.Equal(t, "Shop", source)
+		assert.Equal(t, "79991234567", dest)
+		assert.Equal(t, byte(smppESMClassUDHI), esmClass)
+		assert.Equal(t, byte(smppDataCodingUCS2), dataCoding)
+		assert.Equal(t, []byte{0x05, 0x00, 0x03}, short[:3])
+		assert.Equal(t, []byte{2, 1}, short[4:6])
+		assert.Equal(t, 67*2, len(short)-6)
+		assert.Equal(t, uint16('я'), binary.BigEndian.Uint16(short[6:]))
+	}
+}
+
+func TestSMPPClientBindRejected(t *testing.T) {
+	smsc := newFakeSMSC(t)
+	_, err := NewSMPPClient(smsc.listener.Addr().String(), "other", "pass").
+		SendSMS(context.Background(), SMSMessage{To: "+79991234567", Text: "hi"})
+
+	var smppErr *SMPPError
+	assert.ErrorAs(t, err, &smppErr)
+	assert.Equal(t, uint32(0x0000000E), smppErr.Status)
+}
@@ -0,0 +1,246 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// SMTPTLSMode режим шифрования SMTP-соединения.
+type SMTPTLSMode string
+
+const (
+	// SMTPTLSImplicit соединение сразу устанавливается по TLS (SMTPS, обычно порт 465)
+	SMTPTLSImplicit SMTPTLSMode = "tls"
+	// SMTPTLSStartTLS STARTTLS обязателен: без него письмо не отправляется (обычно порт 587)
+	SMTPTLSStartTLS SMTPTLSMode = "starttls"
+	// SMTPTLSOpportunistic STARTTLS выполняется, если сервер его поддерживает
+	SMTPTLSOpportunistic SMTPTLSMode = "opportunistic"
+	// SMTPTLSNone соединение без шифрования, например с локальным MailHog
+	SMTPTLSNone SMTPTLSMode = "none"
+)
+
+// smtpDialTimeout ограничивает установку TCP-соединения с SMTP-сервером.
+const smtpDialTimeout = 10 * time.Second
+
+// errStartTLSUnsupported возвращается, когда STARTTLS обязателен, а сервер его не поддерживает.
+var errStartTLSUnsupported = errors.New("smtp server does not support STARTTLS")
+
+// smtpDialer открывает SMTP-соединения в выбранном режиме TLS и проходит аутентификацию.
+type smtpDialer struct {
+	addr      string
+	host      string
+	username  string
+	password  string
+	mode      SMTPTLSMode
+	tlsConfig *tls.Config
+	dkim      *DKIMSigner
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func newSMTPDialer(cfg SMTPConfig) *smtpDialer {
+	mode := cfg.TLSMode
+	if mode == "" {
+		mode = SMTPTLSOpportunistic
+	}
+	tlsConfig := &tls.Config{
+		ServerName: cfg.Host,
+		RootCAs:    cfg.RootCAs,
+		// отключать проверку сертификата допустимо только на тестовых стендах
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	return &smtpDialer{
+		addr:      net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		host:      cfg.Host,
+		username:  cfg.Username,
+		password:  cfg.Password,
+		mode:      mode,
+		tlsConfig: tlsConfig,
+		dkim:      cfg.DKIM,
+		dial:      (&net.Dialer{Timeout: smtpDialTimeout}).DialContext,
+	}
+}
+
+// Dial открывает соединение, при необходимости включает TLS и выполняет AUTH.
+// Дедлайн ctx ограничивает подключение и handshake.
+func (d *smtpDialer) Dial(ctx context.Context) (gomail.SendCloser, error) {
+	switch d.mode {
+	case SMTPTLSImplicit, SMTPTLSStartTLS, SMTPTLSOpportunistic, SMTPTLSNone:
+	default:
+		return nil, fmt.Errorf("unsupported smtp tls mode %q", d.mode)
+	}
+
+	conn, err := d.dial(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	raw := conn
+	if d.mode == SMTPTLSImplicit {
+		conn = tls.Client(conn, d.tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, d.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := d.handshake(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &smtpClient{c: c, conn: raw, dkim: d.dkim}, nil
+}
+
+// handshake выполняет EHLO, STARTTLS и AUTH на новом соединении.
+func (d *smtpDialer) handshake(c *smtp.Client) error {
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+
+	if d.mode == SMTPTLSStartTLS || d.mode == SMTPTLSOpportunistic {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(d.tlsConfig); err != nil {
+				return err
+			}
+		} else if d.mode == SMTPTLSStartTLS {
+			return errStartTLSUnsupported
+		}
+	}
+
+	if d.username == "" {
+		return nil
+	}
+	ok, mechanisms := c.Extension("AUTH")
+	if !ok {
+		return errors.New("smtp server does not support AUTH")
+	}
+	return c.Auth(d.auth(mechanisms))
+}
+
+// auth выбирает механизм аутентификации из объявленных сервером. PLAIN из net/smtp
+// отказывается передавать пароль по незашифрованному соединению (кроме localhost).
+func (d *smtpDialer) auth(mechanisms string) smtp.Auth {
+	supported := strings.Fields(strings.ToUpper(mechanisms))
+	has := func(name string) bool {
+		for _, m := range supported {
+			if m == name {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("CRAM-MD5"):
+		return smtp.CRAMMD5Auth(d.username, d.password)
+	case has("LOGIN") && !has("PLAIN"):
+		return &loginAuth{username: d.username, password: d.password, host: d.host}
+	default:
+		return smtp.PlainAuth("", d.username, d.password, d.host)
+	}
+}
+
+// smtpClient открытое SMTP-соединение; при заданном DKIMSigner подписывает каждое письмо.
+type smtpClient struct {
+	c *smtp.Client
+	// conn TCP-соединение под TLS, через него задаются дедлайны
+	conn net.Conn
+	dkim *DKIMSigner
+}
+
+// SetDeadline ограничивает по времени чтение и запись соединения; нулевое время снимает ограничение.
+func (s *smtpClient) SetDeadline(t time.Time) error {
+	return s.conn.SetDeadline(t)
+}
+
+// Send передает письмо в рамках текущей SMTP-сессии.
+func (s *smtpClient) Send(from string, to []string, msg io.WriterTo) error {
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if s.dkim != nil {
+		signed, err := s.dkim.Sign(data)
+		if err != nil {
+			return err
+		}
+		data = signed
+	}
+
+	if err := s.c.Mail(from); err != nil {
+		if isConnError(err) {
+			return &staleConnError{err: err}
+		}
+		return err
+	}
+	for _, addr := range to {
+		if err := s.c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := s.c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Close завершает SMTP-сессию командой QUIT. Пул закрывает соединение и тогда, когда оно уже
+// разорвано или прервано дедлайном; net/smtp при ошибке QUIT сокет не закрывает, поэтому
+// в этом случае соединение закрывается без QUIT.
+func (s *smtpClient) Close() error {
+	if err := s.c.Quit(); err != nil {
+		s.c.Close()
+		return err
+	}
+	return nil
+}
+
+// loginAuth механизм AUTH LOGIN, которого нет в net/smtp; некоторые релеи поддерживают только его.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSuffix(string(fromServer), ":")) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+	}
+}
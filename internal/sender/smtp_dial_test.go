@@ -0,0 +1,120 @@
+package sender
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTPServer простой SMTP-сервер без STARTTLS; сохраняет DATA последнего письма.
+type fakeSMTPServer struct {
+	data chan string
+}
+
+func (s *fakeSMTPServer) dial(_ context.Context, _, _ string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go s.serve(textproto.NewConn(server))
+	return client, nil
+}
+
+func (s *fakeSMTPServer) serve(c *textproto.Conn) {
+	defer c.Close()
+	c.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+		case "EHLO":
+			c.PrintfLine("250-localhost")
+			c.PrintfLine("250 8BITMIME")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			data, _ := c.ReadDotLines()
+			s.data <- strings.Join(data, "\r\n")
+			c.PrintfLine("250 queued")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("250 ok")
+		}
+	}
+}
+
+// closeTrackingConn запоминает, что соединение закрыто.
+type closeTrackingConn struct {
+	net.Conn
+	closed atomic.Bool
+}
+
+func (c *closeTrackingConn) Close() error {
+	c.closed.Store(true)
+	return c.Conn.Close()
+}
+
+func TestSMTPClientCloseBrokenConnection(t *testing.T) {
+	srv := &fakeSMTPServer{data: make(chan string, 1)}
+	var tracked *closeTrackingConn
+	d := newSMTPDialer(SMTPConfig{Host: "localhost", Port: 25, TLSMode: SMTPTLSNone})
+	d.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := srv.dial(ctx, network, addr)
+		tracked = &closeTrackingConn{Conn: conn}
+		return tracked, err
+	}
+
+	conn, err := d.Dial(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	// так пул прерывает соединение при отмене отправки: QUIT не проходит, но сокет закрывается
+	conn.(*smtpClient).SetDeadline(time.Unix(1, 0))
+	assert.Error(t, conn.Close())
+	assert.True(t, tracked.closed.Load())
+}
+
+func TestSMTPDialerTLSModes(t *testing.T) {
+	t.Run("StartTLSRequired", func(t *testing.T) {
+		srv := &fakeSMTPServer{data: make(chan string, 1)}
+		d := newSMTPDialer(SMTPConfig{Host: "localhost", Port: 25, TLSMode: SMTPTLSStartTLS})
+		d.dial = srv.dial
+
+		_, err := d.Dial(context.Background())
+		assert.ErrorIs(t, err, errStartTLSUnsupported)
+	})
+
+	t.Run("UnknownMode", func(t *testing.T) {
+		d := newSMTPDialer(SMTPConfig{Host: "localhost", Port: 25, TLSMode: "ssl"})
+
+		_, err := d.Dial(context.Background())
+		assert.EqualError(t, err, `unsupported smtp tls mode "ssl"`)
+	})
+
+	t.Run("OpportunisticWithDKIM", func(t *testing.T) {
+		srv := &fakeSMTPServer{data: make(chan string, 1)}
+		signer, err := NewDKIMSigner("example.com", "mail", testEd25519KeyPEM(t))
+		if !assert.NoError(t, err) {
+			return
+		}
+		d := newSMTPDialer(SMTPConfig{Host: "localhost", Port: 25, DKIM: signer})
+		d.dial = srv.dial
+
+		conn, err := d.Dial(context.Background())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, conn.Send("no-reply@example.com", []string{"user@example.com"}, testMessage()))
+		assert.NoError(t, conn.Close())
+
+		data := <-srv.data
+		assert.True(t, strings.HasPrefix(data, "DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed; d=example.com; s=mail;"))
+		assert.Contains(t, data, "To: user@example.com")
+	})
+}
@@ -0,0 +1,237 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// errSMTPPoolClosed возвращается при отправке через закрытый пул.
+var errSMTPPoolClosed = errors.New("smtp pool is closed")
+
+// staleConnError ошибка соединения, разорванного до того, как сервер принял команду MAIL FROM:
+// письмо сервер не получал, поэтому его можно отправить через новое соединение.
+type staleConnError struct {
+	err error
+}
+
+func (e *staleConnError) Error() string { return e.err.Error() }
+
+func (e *staleConnError) Unwrap() error { return e.err }
+
+// isConnError сообщает, что ошибка относится к соединению, а не к ответу SMTP-сервера.
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// smtpPool пул постоянных SMTP-соединений. Соединение переиспользуется для следующих писем,
+// поэтому TCP-подключение, EHLO и AUTH выполняются один раз на соединение, а не на каждое письмо.
+// Одновременно открыто не больше size соединений; простаивающее дольше idleTimeout соединение
+// закрывается, а после maxMessages писем соединение открывается заново.
+type smtpPool struct {
+	dial        func(ctx context.Context) (gomail.SendCloser, error)
+	idleTimeout time.Duration
+	maxMessages int
+	// slots ограничивает число соединений, через которые одновременно идет отправка
+	slots chan struct{}
+
+	mu     sync.Mutex
+	idle   []*smtpConn
+	closed bool
+}
+
+// smtpConn открытое SMTP-соединение пула.
+type smtpConn struct {
+	gomail.SendCloser
+	sent     int
+	lastUsed time.Time
+}
+
+func newSMTPPool(dial func(ctx context.Context) (gomail.SendCloser, error), size int, idleTimeout time.Duration, maxMessages int) *smtpPool {
+	if size < 1 {
+		size = 1
+	}
+	return &smtpPool{
+		dial:        dial,
+		idleTimeout: idleTimeout,
+		maxMessages: maxMessages,
+		slots:       make(chan struct{}, size),
+	}
+}
+
+// Send отправляет письмо через свободное соединение пула или открывает новое.
+// Если переиспользованное соединение оказалось разорвано сервером еще до MAIL FROM, письмо
+// один раз повторяется через новое соединение. Ответ сервера с ошибкой и обрыв после начала
+// передачи не повторяются: сервер мог уже принять письмо. Ожидание свободного соединения
+// и сама отправка прерываются при отмене ctx.
+func (p *smtpPool) Send(ctx context.Context, m *gomail.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-p.slots }()
+
+	conn, err := p.get()
+	if err != nil {
+		return err
+	}
+	reused := conn != nil
+	if !reused {
+		if conn, err = p.open(ctx); err != nil {
+			return err
+		}
+	}
+
+	err = p.send(ctx, conn, m)
+	var stale *staleConnError
+	if reused && errors.As(err, &stale) && ctx.Err() == nil {
+		conn.Close()
+		if conn, err = p.open(ctx); err != nil {
+			return err
+		}
+		err = p.send(ctx, conn, m)
+	}
+	if err != nil {
+		// после ошибки состояние SMTP-сессии неизвестно, соединение не переиспользуется
+		conn.Close()
+		return err
+	}
+
+	conn.sent++
+	p.put(conn)
+	return nil
+}
+
+// Close закрывает простаивающие соединения; соединения, занятые отправкой, закрываются после нее.
+func (p *smtpPool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle, p.closed = nil, true
+	p.mu.Unlock()
+
+	var errs []error
+	for _, conn := range idle {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// deadliner реализуют соединения, которым можно задать дедлайн ввода-вывода.
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+// send передает письмо через соединение. Дедлайн ctx переносится на соединение,
+// а отмена ctx прерывает зависшую SMTP-команду.
+func (p *smtpPool) send(ctx context.Context, conn *smtpConn, m *gomail.Message) error {
+	if d, ok := conn.SendCloser.(deadliner); ok {
+		deadline, _ := ctx.Deadline()
+		d.SetDeadline(deadline)
+		stop := context.AfterFunc(ctx, func() { d.SetDeadline(time.Unix(1, 0)) })
+		defer stop()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// gomail.Send оборачивает ошибку через %v, а по исходной ошибке определяются класс ответа
+	// сервера и возможность повтора
+	var sendErr error
+	err := gomail.Send(gomail.SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		sendErr = conn.Send(from, to, msg)
+		return sendErr
+	}), m)
+	if sendErr != nil {
+		return sendErr
+	}
+	return err
+}
+
+// open открывает новое соединение.
+func (p *smtpPool) open(ctx context.Context) (*smtpConn, error) {
+	sc, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &smtpConn{SendCloser: sc}, nil
+}
+
+// get возвращает последнее использованное свободное соединение или nil, если свободных нет.
+// Соединения, простаивавшие дольше idleTimeout, закрываются.
+func (p *smtpPool) get() (*smtpConn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errSMTPPoolClosed
+	}
+	expired := p.expire(time.Now())
+	var conn *smtpConn
+	if n := len(p.idle); n > 0 {
+		conn, p.idle = p.idle[n-1], p.idle[:n-1]
+	}
+	p.mu.Unlock()
+
+	closeAll(expired)
+	return conn, nil
+}
+
+// put возвращает соединение в пул или закрывает его, если лимит писем исчерпан.
+func (p *smtpPool) put(conn *smtpConn) {
+	if p.maxMessages > 0 && conn.sent >= p.maxMessages {
+		conn.Close()
+		return
+	}
+	conn.lastUsed = time.Now()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		conn.Close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+	p.mu.Unlock()
+
+	if p.idleTimeout > 0 {
+		time.AfterFunc(p.idleTimeout, p.reap)
+	}
+}
+
+// reap закрывает простаивающие соединения с истекшим idleTimeout.
+func (p *smtpPool) reap() {
+	p.mu.Lock()
+	expired := p.expire(time.Now())
+	p.mu.Unlock()
+
+	closeAll(expired)
+}
+
+// expire убирает из пула соединения с истекшим idleTimeout и возвращает их. Вызывается под p.mu.
+// Свободные соединения упорядочены по времени возврата, поэтому истекшие находятся в начале.
+func (p *smtpPool) expire(now time.Time) []*smtpConn {
+	if p.idleTimeout <= 0 {
+		return nil
+	}
+	n := 0
+	for n < len(p.idle) && now.Sub(p.idle[n].lastUsed) >= p.idleTimeout {
+		n++
+	}
+	expired := append([]*smtpConn(nil), p.idle[:n]...)
+	p.idle = append(p.idle[:0], p.idle[n:]...)
+	return expired
+}
+
+func closeAll(conns []*smtpConn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
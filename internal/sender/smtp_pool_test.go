@@ -0,0 +1,148 @@
+package sender
+
+import (
+	"context"
+	"io"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/gomail.v2"
+)
+
+// fakeSMTPConn соединение, которое считает письма и может разорваться или вернуть ошибку по команде.
+type fakeSMTPConn struct {
+	mu       sync.Mutex
+	sent     int
+	attempts int
+	broken   bool
+	closed   bool
+	err      error
+}
+
+func (c *fakeSMTPConn) Send(_ string, _ []string, msg io.WriterTo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if c.broken || c.closed {
+		return &staleConnError{err: io.EOF}
+	}
+	if c.err != nil {
+		return c.err
+	}
+	c.sent++
+	return nil
+}
+
+func (c *fakeSMTPConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+type fakeSMTPDialer struct {
+	conns []*fakeSMTPConn
+}
+
+func (d *fakeSMTPDialer) dial(context.Context) (gomail.SendCloser, error) {
+	conn := &fakeSMTPConn{}
+	d.conns = append(d.conns, conn)
+	return conn, nil
+}
+
+func testMessage() *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", "no-reply@example.com")
+	m.SetHeader("To", "user@example.com")
+	m.SetBody("text/plain", "Hello")
+	return m
+}
+
+func TestSMTPPoolReusesConnection(t *testing.T) {
+	d := &fakeSMTPDialer{}
+	pool := newSMTPPool(d.dial, 2, time.Minute, 2)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, pool.Send(context.Background(), testMessage()))
+	}
+
+	// по 2 письма на соединение: 2 + 2 + 1
+	if assert.Len(t, d.conns, 3) {
+		assert.Equal(t, []int{2, 2, 1}, []int{d.conns[0].sent, d.conns[1].sent, d.conns[2].sent})
+		assert.True(t, d.conns[0].closed)
+		assert.True(t, d.conns[1].closed)
+		assert.False(t, d.conns[2].closed)
+	}
+
+	assert.NoError(t, pool.Close())
+	assert.True(t, d.conns[2].closed)
+	assert.ErrorIs(t, pool.Send(context.Background(), testMessage()), errSMTPPoolClosed)
+}
+
+func TestSMTPPoolReconnectsBrokenConnection(t *testing.T) {
+	d := &fakeSMTPDialer{}
+	pool := newSMTPPool(d.dial, 1, time.Minute, 0)
+
+	assert.NoError(t, pool.Send(context.Background(), testMessage()))
+	d.conns[0].broken = true
+
+	assert.NoError(t, pool.Send(context.Background(), testMessage()))
+	if assert.Len(t, d.conns, 2) {
+		assert.True(t, d.conns[0].closed)
+		assert.Equal(t, 1, d.conns[1].sent)
+	}
+}
+
+func TestSMTPPoolDoesNotResend(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		// ответ сервера повторная отправка не изменит
+		{name: "ServerReply", err: &textproto.Error{Code: 550, Msg: "mailbox unavailable"}},
+		// обрыв после DATA: сервер мог уже принять письмо
+		{name: "AfterData", err: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeSMTPDialer{}
+			pool := newSMTPPool(d.dial, 1, time.Minute, 0)
+
+			assert.NoError(t, pool.Send(context.Background(), testMessage()))
+			d.conns[0].err = tt.err
+
+			assert.ErrorIs(t, pool.Send(context.Background(), testMessage()), tt.err)
+			assert.Len(t, d.conns, 1)
+			assert.Equal(t, 2, d.conns[0].attempts)
+			assert.True(t, d.conns[0].closed)
+		})
+	}
+}
+
+func TestSMTPPoolClosesIdleConnections(t *testing.T) {
+	d := &fakeSMTPDialer{}
+	pool := newSMTPPool(d.dial, 1, 20*time.Millisecond, 0)
+
+	assert.NoError(t, pool.Send(context.Background(), testMessage()))
+	assert.Eventually(t, func() bool {
+		d.conns[0].mu.Lock()
+		defer d.conns[0].mu.Unlock()
+		return d.conns[0].closed
+	}, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, pool.Send(context.Background(), testMessage()))
+	assert.Len(t, d.conns, 2)
+}
+
+func TestSMTPPoolCanceledContext(t *testing.T) {
+	d := &fakeSMTPDialer{}
+	pool := newSMTPPool(d.dial, 1, time.Minute, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, pool.Send(ctx, testMessage()), context.Canceled)
+	assert.Empty(t, d.conns)
+}
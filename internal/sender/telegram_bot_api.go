@@ -0,0 +1,85 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// TelegramPollTimeout сколько Bot API держит запрос getUpdates, ожидая новых обновлений.
+// Таймаут HTTP-клиента бота должен быть больше.
+const TelegramPollTimeout = 25 * time.Second
+
+// telegramAllowedUpdates типы обновлений, которые получает бот.
+var telegramAllowedUpdates = []string{"message", "my_chat_member"}
+
+// GetUpdates получает обновления бота long polling-запросом getUpdates, начиная с offset.
+func (s *TelegramSender) GetUpdates(ctx context.Context, offset int64) ([]models.TelegramUpdate, error) {
+	var updates []models.TelegramUpdate
+	err := s.call(ctx, "getUpdates", map[string]any{
+		"offset":          offset,
+		"timeout":         int(TelegramPollTimeout / time.Second),
+		"allowed_updates": telegramAllowedUpdates,
+	}, &updates)
+	return updates, err
+}
+
+// SendText отправляет в чат служебное текстовое сообщение бота (ответ на команду).
+func (s *TelegramSender) SendText(ctx context.Context, chatID, text string) error {
+	return s.call(ctx, "sendMessage", map[string]any{"chat_id": chatID, "text": text}, nil)
+}
+
+// SetWebhook устанавливает адрес, на который Bot API будет отправлять обновления.
+// secret передается Bot API в заголовке X-Telegram-Bot-Api-Secret-Token каждого запроса.
+func (s *TelegramSender) SetWebhook(ctx context.Context, webhookURL, secret string) error {
+	payload := map[string]any{"url": webhookURL, "allowed_updates": telegramAllowedUpdates, "secret_token": secret}
+	return s.call(ctx, "setWebhook", payload, nil)
+}
+
+// DeleteWebhook удаляет вебхук, чтобы обновления можно было получать через getUpdates.
+func (s *TelegramSender) DeleteWebhook(ctx context.Context) error {
+	return s.call(ctx, "deleteWebhook", map[string]any{}, nil)
+}
+
+// call вызывает метод Bot API с JSON-телом и разбирает поле result ответа в result (если он не nil).
+func (s *TelegramSender) call(ctx context.Context, method string, payload, result any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("telegram payload encoding error: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.methodURL(method), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("telegram request error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram %s error: %w", method, redactToken(err, s.botToken))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, telegramErrorBodyLimit))
+		return parseTelegramError(resp.StatusCode, body)
+	}
+	var body struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("telegram %s: invalid response: %w", method, err)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body.Result, result); err != nil {
+		return fmt.Errorf("telegram %s: invalid result: %w", method, err)
+	}
+	return nil
+}
@@ -2,46 +2,468 @@ package sender
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
 )
 
+const (
+	// TelegramMaxMessageLength максимальная длина текста сообщения в Telegram (в символах).
+	TelegramMaxMessageLength = 4096
+	// TelegramMaxCaptionLength максимальная длина подписи к фотографии или документу (в символах).
+	TelegramMaxCaptionLength = 1024
+	// TelegramMaxMediaGroup максимальное количество файлов в альбоме sendMediaGroup.
+	TelegramMaxMediaGroup = 10
+	// telegramEllipsis добавляется в конец обрезанного сообщения.
+	telegramEllipsis = "…"
+	// TelegramDefaultBaseURL адрес облачного Bot API
+	TelegramDefaultBaseURL = "https://api.telegram.org"
+	// TelegramDefaultTimeout ограничивает запрос к Bot API целиком, включая чтение ответа
+	TelegramDefaultTimeout = 10 * time.Second
+	// telegramErrorBodyLimit сколько байт ответа Bot API читается при ошибке
+	telegramErrorBodyLimit = 4096
+)
+
 // TelegramSender реализует отправку уведомлений через Telegram.
 type TelegramSender struct {
-	botToken string
+	botToken    string
+	baseURL     string
+	client      *http.Client
+	attachments AttachmentStore
+}
+
+// NewTelegramSender создает новый экземпляр TelegramSender. baseURL — адрес Bot API
+// (облачный, локальный telegram-bot-api или тестовая заглушка), пустой — TelegramDefaultBaseURL.
+// client выполняет запросы к Bot API; nil — клиент NewTelegramHTTPClient с настройками по умолчанию.
+// attachments загружает содержимое файлов, сохраненных вместе с уведомлением.
+func NewTelegramSender(botToken, baseURL string, client *http.Client, attachments AttachmentStore) *TelegramSender {
+	if baseURL == "" {
+		baseURL = TelegramDefaultBaseURL
+	}
+	if client == nil {
+		client = NewTelegramHTTPClient(TelegramDefaultTimeout, nil)
+	}
+	return &TelegramSender{
+		botToken:    botToken,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		client:      client,
+		attachments: attachments,
+	}
 }
 
-// NewTelegramSender создает новый экземпляр TelegramSender.
-func NewTelegramSender(botToken string) *TelegramSender {
-	return &TelegramSender{botToken: botToken}
+// NewTelegramHTTPClient создает HTTP-клиент для Bot API с общим таймаутом запроса и пулом
+// keep-alive соединений. proxy задает прокси для запросов; nil — прокси из HTTPS_PROXY/NO_PROXY.
+func NewTelegramHTTPClient(timeout time.Duration, proxy *url.URL) *http.Client {
+	proxyFunc := http.ProxyFromEnvironment
+	if proxy != nil {
+		proxyFunc = http.ProxyURL(proxy)
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 proxyFunc,
+			DialContext:           (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
 }
 
-// Send отправляет уведомление через Telegram.
-func (s *TelegramSender) Send(n *models.Notification) error {
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", s.botToken)
+// Send отправляет уведомление через Telegram. Сообщение с загруженными файлами
+// отправляется multipart-запросом, остальные — JSON. ProviderMessageID — message_id
+// отправленного сообщения, для альбома — message_id всех сообщений через запятую.
+func (s *TelegramSender) Send(ctx context.Context, n *models.Notification) (*Result, error) {
+	rendered, files, err := s.render(n)
+	if err != nil {
+		return failed(nil, err)
+	}
 	log.Printf("Sending Telegram message %v\n", n)
-	log.Printf("Chat ID: %s text: %v\n", n.TelegramNotification.ChatID, n.TelegramNotification.Message)
-	payload := map[string]string{
-		"chat_id": n.TelegramNotification.ChatID,
-		"text":    n.TelegramNotification.Message,
+	log.Printf("Chat ID: %s text: %v\n", rendered.Recipient, rendered.Body)
+
+	body, contentType, err := s.encode(ctx, rendered.Payload, files)
+	if err != nil {
+		return failed(nil, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.methodURL(rendered.Method), body)
+	if err != nil {
+		return failed(nil, fmt.Errorf("telegram request error: %w", err))
 	}
+	req.Header.Set("Content-Type", contentType)
 
-	data, _ := json.Marshal(payload)
-	resp, err := http.Post(apiURL, "application/json", bytes.NewReader(data))
+	resp, err := s.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("telegram send error: %w", err)
+		return failed(nil, fmt.Errorf("telegram send error: %w", redactToken(err, s.botToken)))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("telegram send failed: %s", string(body))
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, telegramErrorBodyLimit))
+		return failed(body, classifyTelegramError(rendered.Recipient, parseTelegramError(resp.StatusCode, body)))
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		// сообщение уже принято, повторная отправка его продублирует
+		log.Printf("failed to read telegram response for %s: %v", n.ID, err)
+	}
+	return delivered(telegramMessageID(respBody), respBody), nil
+}
+
+// telegramMessageID достает message_id из успешного ответа Bot API: объекта Message
+// или массива сообщений альбома. Если ответ не разобран, возвращается пустая строка.
+func telegramMessageID(body []byte) string {
+	type message struct {
+		MessageID int64 `json:"message_id"`
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return ""
+	}
+	var one message
+	if json.Unmarshal(resp.Result, &one) == nil && one.MessageID != 0 {
+		return strconv.FormatInt(one.MessageID, 10)
+	}
+	var album []message
+	if json.Unmarshal(resp.Result, &album) != nil {
+		return ""
+	}
+	ids := make([]string, len(album))
+	for i, m := range album {
+		ids[i] = strconv.FormatInt(m.MessageID, 10)
+	}
+	return strings.Join(ids, ",")
+}
+
+// telegramFile файл, который загружается частью multipart-запроса с именем field.
+type telegramFile struct {
+	field string
+	file  models.Attachment
+}
+
+// encode кодирует тело запроса: JSON, если файлов нет, иначе multipart/form-data,
+// где вложенные объекты параметров передаются JSON-строками.
+func (s *TelegramSender) encode(ctx context.Context, payload map[string]any, files []telegramFile) (io.Reader, string, error) {
+	if len(files) == 0 {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("telegram payload encoding error: %w", err)
+		}
+		return bytes.NewReader(data), "application/json", nil
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	keys := make([]string, 0, len(payload))
+	for key := range payload {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		data, err := json.Marshal(payload[key])
+		if err != nil {
+			return nil, "", fmt.Errorf("telegram payload encoding error: %w", err)
+		}
+		value := string(data)
+		var str string
+		if json.Unmarshal(data, &str) == nil {
+			value = str
+		}
+		if err := w.WriteField(key, value); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, f := range files {
+		content, err := loadAttachment(ctx, s.attachments, f.file)
+		if err != nil {
+			return nil, "", err
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": f.field, "filename": f.file.Filename}))
+		if f.file.ContentType != "" {
+			header.Set("Content-Type", f.file.ContentType)
+		}
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
+
+// TelegramAPIError ошибка, которую вернул Bot API.
+type TelegramAPIError struct {
+	StatusCode  int
+	Description string
+	// RetryAfter через сколько секунд можно повторить запрос (для 429)
+	RetryAfter int
+	// MigrateToChatID новый идентификатор группы, преобразованной в супергруппу
+	MigrateToChatID int64
+}
+
+func (e *TelegramAPIError) Error() string {
+	msg := fmt.Sprintf("telegram api error %d: %s", e.StatusCode, e.Description)
+	if e.MigrateToChatID != 0 {
+		msg += fmt.Sprintf(" (chat migrated to %d)", e.MigrateToChatID)
+	}
+	return msg
+}
+
+// telegramErrorResponse тело ответа Bot API с ошибкой.
+type telegramErrorResponse struct {
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter      int   `json:"retry_after"`
+		MigrateToChatID int64 `json:"migrate_to_chat_id"`
+	} `json:"parameters"`
+}
+
+// parseTelegramError разбирает ответ Bot API с ошибкой; если тело не JSON, описанием становится текст ответа.
+func parseTelegramError(statusCode int, body []byte) *TelegramAPIError {
+	apiErr := &TelegramAPIError{StatusCode: statusCode, Description: strings.TrimSpace(string(body))}
+	var resp telegramErrorResponse
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Description != "" {
+		apiErr.Description = resp.Description
+		apiErr.RetryAfter = resp.Parameters.RetryAfter
+		apiErr.MigrateToChatID = resp.Parameters.MigrateToChatID
+	}
+	return apiErr
+}
+
+// classifyTelegramError определяет, как поступить с ошибкой Bot API:
+// 429 — повторить после retry_after, 403 (бот заблокирован, исключен из чата, пользователь удален) —
+// подавить получателя, остальные 400 (чат не найден, ошибка разметки и т.п.) — сразу завершить неудачей.
+// Ошибки сервера временные, остальные коды — постоянные ошибки запроса.
+func classifyTelegramError(chatID string, apiErr *TelegramAPIError) error {
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		retryAfter := time.Duration(apiErr.RetryAfter) * time.Second
+		if retryAfter <= 0 {
+			retryAfter = time.Second
+		}
+		return &RateLimitError{Channel: models.NotificationTypeTelegram, RetryAfter: retryAfter}
+	case http.StatusForbidden:
+		return &BlockedError{Recipient: chatID, Reason: apiErr.Description}
+	case http.StatusBadRequest:
+		return fmt.Errorf("%w: %w", ErrPermanent, apiErr)
+	default:
+		return httpStatusError(apiErr.StatusCode, apiErr)
+	}
+}
+
+// methodURL возвращает адрес метода Bot API.
+func (s *TelegramSender) methodURL(method string) string {
+	return s.baseURL + "/bot" + s.botToken + "/" + method
+}
+
+// redactToken убирает токен бота из адреса запроса в url.Error, чтобы он не попал в логи и в БД.
+func redactToken(err error, token string) error {
+	var urlErr *url.Error
+	if token != "" && errors.As(err, &urlErr) {
+		urlErr.URL = strings.ReplaceAll(urlErr.URL, token, "<token>")
+	}
+	return err
+}
+
+// Render возвращает метод и тело запроса к Bot API в том виде, в котором они будут отправлены.
+// Текст приводится к корректному UTF-8 и обрезается до TelegramMaxMessageLength символов,
+// а подпись к медиа — до TelegramMaxCaptionLength. Загружаемые файлы передаются отдельными
+// частями multipart-запроса и в Payload не входят; в альбоме на них ссылается attach://<имя>.
+func (s *TelegramSender) Render(n *models.Notification) (*models.RenderedMessage, error) {
+	rendered, _, err := s.render(n)
+	return rendered, err
+}
+
+// render собирает запрос к Bot API и список загружаемых файлов.
+func (s *TelegramSender) render(n *models.Notification) (*models.RenderedMessage, []telegramFile, error) {
+	tg := models.PayloadOf[models.TelegramNotification](n)
+	if tg == nil {
+		return nil, nil, errors.New("telegram notification details are missing")
+	}
+
+	limit := TelegramMaxMessageLength
+	if len(tg.Media) > 0 {
+		limit = TelegramMaxCaptionLength
+	}
+	text, truncated := truncateText(strings.ToValidUTF8(tg.Message, "�"), limit)
+	if truncated && tg.ParseMode == models.TelegramParseModeMarkdownV2 {
+		// обрезка не должна оставить экранирующий символ без пары
+		text = trimDanglingEscape(text)
+	}
+
+	method := "sendMessage"
+	payload := map[string]any{
+		"chat_id": tg.ChatID,
+	}
+	var files []telegramFile
+	switch len(tg.Media) {
+	case 0:
+		payload["text"] = text
+		if tg.DisableWebPagePreview {
+			payload["link_preview_options"] = map[string]any{"is_disabled": true}
+		}
+	case 1:
+		m := tg.Media[0]
+		method = telegramMediaMethod(m.Type)
+		if m.File != nil {
+			files = append(files, telegramFile{field: string(m.Type), file: *m.File})
+		} else {
+			payload[string(m.Type)] = m.URL
+		}
+		if text != "" {
+			payload["caption"] = text
+		}
+	default:
+		// подпись альбома — подпись первого файла, клавиатура в альбоме не поддерживается
+		method = "sendMediaGroup"
+		media := make([]map[string]any, len(tg.Media))
+		for i, m := range tg.Media {
+			item := map[string]any{"type": m.Type, "media": m.URL}
+			if m.File != nil {
+				name := fmt.Sprintf("file%d", i)
+				item["media"] = "attach://" + name
+				files = append(files, telegramFile{field: name, file: *m.File})
+			}
+			if i == 0 && text != "" {
+				item["caption"] = text
+				if tg.ParseMode != "" {
+					item["parse_mode"] = tg.ParseMode
+				}
+			}
+			media[i] = item
+		}
+		payload["media"] = media
+	}
+
+	if tg.ParseMode != "" && method != "sendMediaGroup" {
+		payload["parse_mode"] = tg.ParseMode
+	}
+	if tg.DisableNotification {
+		payload["disable_notification"] = true
+	}
+	if tg.MessageThreadID != 0 {
+		payload["message_thread_id"] = tg.MessageThreadID
 	}
+	if markup := telegramReplyMarkup(tg.TelegramOptions); markup != nil && method != "sendMediaGroup" {
+		payload["reply_markup"] = markup
+	}
+
+	return &models.RenderedMessage{
+		Type:      n.Type,
+		Recipient: tg.ChatID,
+		Body:      text,
+		Method:    method,
+		Payload:   payload,
+		Truncated: truncated,
+	}, files, nil
+}
 
+// telegramMediaMethod возвращает метод Bot API для отправки одного файла.
+func telegramMediaMethod(t models.TelegramMediaType) string {
+	if t == models.TelegramMediaPhoto {
+		return "sendPhoto"
+	}
+	return "sendDocument"
+}
+
+// telegramReplyMarkup возвращает reply_markup сообщения: встроенную клавиатуру из Buttons
+// или произвольную разметку из ReplyMarkup.
+func telegramReplyMarkup(opts models.TelegramOptions) any {
+	if len(opts.Buttons) > 0 {
+		return map[string]any{"inline_keyboard": opts.Buttons}
+	}
+	if len(opts.ReplyMarkup) > 0 {
+		return opts.ReplyMarkup
+	}
 	return nil
 }
+
+// telegramMarkdownV2Special символы, которые в MarkdownV2 нужно экранировать вне разметки.
+const telegramMarkdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// EscapeTelegramMarkdownV2 экранирует текст, чтобы он отображался в MarkdownV2 как есть.
+func EscapeTelegramMarkdownV2(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if strings.ContainsRune(telegramMarkdownV2Special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// EscapeTelegramHTML экранирует текст для режима HTML: Telegram требует заменять только <, > и &.
+func EscapeTelegramHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// TelegramEscaper возвращает функцию экранирования для режима разметки mode или nil для обычного текста.
+// Ею шаблоны экранируют подставляемые переменные.
+func TelegramEscaper(mode models.TelegramParseMode) func(string) string {
+	switch mode {
+	case models.TelegramParseModeMarkdownV2, models.TelegramParseModeHTML:
+		return func(s string) string { return EscapeTelegram(mode, s) }
+	default:
+		return nil
+	}
+}
+
+// EscapeTelegram экранирует текст для режима разметки mode; обычный текст возвращается без изменений.
+func EscapeTelegram(mode models.TelegramParseMode, s string) string {
+	switch mode {
+	case models.TelegramParseModeMarkdownV2:
+		return EscapeTelegramMarkdownV2(s)
+	case models.TelegramParseModeHTML:
+		return EscapeTelegramHTML(s)
+	default:
+		return s
+	}
+}
+
+// trimDanglingEscape убирает непарную обратную косую черту перед многоточием обрезанного текста.
+func trimDanglingEscape(text string) string {
+	body := strings.TrimSuffix(text, telegramEllipsis)
+	n := len(body) - len(strings.TrimRight(body, "\\"))
+	if n%2 == 1 {
+		body = body[:len(body)-1]
+	}
+	return body + telegramEllipsis
+}
+
+// truncateText обрезает текст до limit символов, заменяя хвост многоточием.
+func truncateText(text string, limit int) (string, bool) {
+	if utf8.RuneCountInString(text) <= limit {
+		return text, false
+	}
+	runes := []rune(text)
+	return string(runes[:limit-utf8.RuneCountInString(telegramEllipsis)]) + telegramEllipsis, true
+}
@@ -0,0 +1,356 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelegramSenderRenderOptions(t *testing.T) {
+	s := NewTelegramSender("token", "", nil, nil)
+
+	rendered, err := s.Render(&models.Notification{
+		Type: models.NotificationTypeTelegram,
+		Payload: &models.TelegramNotification{
+			ChatID:  "-1001234567890",
+			Message: "*Заказ готов*",
+			TelegramOptions: models.TelegramOptions{
+				ParseMode:             models.TelegramParseModeMarkdownV2,
+				DisableNotification:   true,
+				DisableWebPagePreview: true,
+				MessageThreadID:       7,
+				Buttons: [][]models.TelegramButton{
+					{{Text: "Открыть", URL: "https://shop.example.com/orders/42"}, {Text: "Отменить", CallbackData: "cancel:42"}},
+				},
+			},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	payload, err := json.Marshal(rendered.Payload)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"chat_id": "-1001234567890",
+		"text": "*Заказ готов*",
+		"parse_mode": "MarkdownV2",
+		"disable_notification": true,
+		"link_preview_options": {"is_disabled": true},
+		"message_thread_id": 7,
+		"reply_markup": {"inline_keyboard": [[
+			{"text": "Открыть", "url": "https://shop.example.com/orders/42"},
+			{"text": "Отменить", "callback_data": "cancel:42"}
+		]]}
+	}`, string(payload))
+
+	// обычный текст отправляется без дополнительных полей
+	rendered, err = s.Render(&models.Notification{
+		Type:    models.NotificationTypeTelegram,
+		Payload: &models.TelegramNotification{ChatID: "42", Message: "Hello"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"chat_id": "42", "text": "Hello"}, rendered.Payload)
+}
+
+func TestTelegramSenderRenderMedia(t *testing.T) {
+	s := NewTelegramSender("token", "", nil, nil)
+
+	rendered, err := s.Render(&models.Notification{
+		Type: models.NotificationTypeTelegram,
+		Payload: &models.TelegramNotification{
+			ChatID: "42", Message: "<b>Счет</b>",
+			TelegramOptions: models.TelegramOptions{
+				ParseMode: models.TelegramParseModeHTML,
+				Buttons:   [][]models.TelegramButton{{{Text: "Оплатить", URL: "https://shop.example.com/pay"}}},
+				Media:     []models.TelegramMedia{{Type: models.TelegramMediaPhoto, URL: "https://shop.example.com/a.png"}},
+			},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "sendPhoto", rendered.Method)
+	payload, _ := json.Marshal(rendered.Payload)
+	assert.JSONEq(t, `{
+		"chat_id": "42",
+		"photo": "https://shop.example.com/a.png",
+		"caption": "<b>Счет</b>",
+		"parse_mode": "HTML",
+		"reply_markup": {"inline_keyboard": [[{"text": "Оплатить", "url": "https://shop.example.com/pay"}]]}
+	}`, string(payload))
+
+	// в альбоме подпись и разметка задаются первому файлу, а загруженные файлы передаются через attach://
+	rendered, err = s.Render(&models.Notification{
+		Type: models.NotificationTypeTelegram,
+		Payload: &models.TelegramNotification{
+			ChatID: "42", Message: strings.Repeat("a", TelegramMaxCaptionLength+1),
+			TelegramOptions: models.TelegramOptions{
+				ParseMode: models.TelegramParseModeMarkdownV2,
+				Media: []models.TelegramMedia{
+					{Type: models.TelegramMediaPhoto, URL: "https://shop.example.com/a.png"},
+					{Type: models.TelegramMediaPhoto, File: &models.Attachment{Filename: "b.png", Content: []byte("png")}},
+				},
+			},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "sendMediaGroup", rendered.Method)
+	assert.True(t, rendered.Truncated)
+	caption := strings.Repeat("a", TelegramMaxCaptionLength-1) + telegramEllipsis
+	payload, _ = json.Marshal(rendered.Payload)
+	assert.JSONEq(t, `{
+		"chat_id": "42",
+		"media": [
+			{"type": "photo", "media": "https://shop.example.com/a.png", "caption": "`+caption+`", "parse_mode": "MarkdownV2"},
+			{"type": "photo", "media": "attach://file1"}
+		]
+	}`, string(payload))
+}
+
+func TestTelegramSenderRenderTruncatedMarkdownV2(t *testing.T) {
+	s := NewTelegramSender("token", "", nil, nil)
+	message := strings.Repeat("a", TelegramMaxMessageLength-2) + `\.` + "tail"
+
+	rendered, err := s.Render(&models.Notification{
+		Type: models.NotificationTypeTelegram,
+		Payload: &models.TelegramNotification{
+			ChatID: "42", Message: message,
+			TelegramOptions: models.TelegramOptions{ParseMode: models.TelegramParseModeMarkdownV2},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, rendered.Truncated)
+	assert.Equal(t, strings.Repeat("a", TelegramMaxMessageLength-2)+telegramEllipsis, rendered.Body)
+}
+
+func TestEscapeTelegram(t *testing.T) {
+	assert.Equal(t, `Цена: 1\.5 \* 2 \= 3 \(скидка\_10%\)\!`, EscapeTelegram(models.TelegramParseModeMarkdownV2, "Цена: 1.5 * 2 = 3 (скидка_10%)!"))
+	assert.Equal(t, `a \\ b`, EscapeTelegramMarkdownV2(`a \ b`))
+	assert.Equal(t, "&lt;b&gt;Tom &amp; Jerry&lt;/b&gt;", EscapeTelegram(models.TelegramParseModeHTML, "<b>Tom & Jerry</b>"))
+	assert.Equal(t, "<b>", EscapeTelegram("", "<b>"))
+}
+
+func TestTelegramSenderSend(t *testing.T) {
+	var path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+	}))
+	defer srv.Close()
+
+	s := NewTelegramSender("123:secret", srv.URL+"/", srv.Client(), nil)
+	res, err := s.Send(context.Background(), &models.Notification{
+		Type:    models.NotificationTypeTelegram,
+		Payload: &models.TelegramNotification{ChatID: "42", Message: "Hello"},
+	})
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "1", res.ProviderMessageID)
+	assert.Empty(t, res.ErrorClass)
+	assert.Equal(t, "/bot123:secret/sendMessage", path)
+	assert.JSONEq(t, `{"chat_id":"42","text":"Hello"}`, body)
+}
+
+// attachmentStoreStub хранилище вложений в памяти.
+type attachmentStoreStub map[string][]byte
+
+func (s attachmentStoreStub) Content(_ context.Context, id string) ([]byte, error) {
+	content, ok := s[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return content, nil
+}
+
+func TestTelegramSenderSendMedia(t *testing.T) {
+	var path string
+	var form *multipart.Form
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := r.ParseMultipartForm(1 << 20); err == nil {
+			form = r.MultipartForm
+		}
+		w.Write([]byte(`{"ok":true,"result":[{"message_id":5},{"message_id":6}]}`))
+	}))
+	defer srv.Close()
+
+	store := attachmentStoreStub{"att-1": []byte("%PDF-1.4"), "att-2": []byte("PK")}
+	s := NewTelegramSender("token", srv.URL, srv.Client(), store)
+	res, err := s.Send(context.Background(), &models.Notification{
+		Type: models.NotificationTypeTelegram,
+		Payload: &models.TelegramNotification{
+			ChatID: "42", Message: "Документы по заказу",
+			TelegramOptions: models.TelegramOptions{
+				DisableNotification: true,
+				Media: []models.TelegramMedia{
+					{Type: models.TelegramMediaDocument, File: &models.Attachment{ID: "att-1", Filename: "invoice.pdf", ContentType: "application/pdf"}},
+					{Type: models.TelegramMediaDocument, File: &models.Attachment{ID: "att-2", Filename: "act.zip", ContentType: "application/zip"}},
+				},
+			},
+		},
+	})
+
+	if !assert.NoError(t, err) || !assert.NotNil(t, form) {
+		return
+	}
+	assert.Equal(t, "5,6", res.ProviderMessageID)
+	assert.Equal(t, "/bottoken/sendMediaGroup", path)
+	assert.Equal(t, []string{"42"}, form.Value["chat_id"])
+	assert.Equal(t, []string{"true"}, form.Value["disable_notification"])
+	assert.JSONEq(t, `[
+		{"type": "document", "media": "attach://file0", "caption": "Документы по заказу"},
+		{"type": "document", "media": "attach://file1"}
+	]`, form.Value["media"][0])
+
+	for name, want := range map[string]string{"file0": "%PDF-1.4", "file1": "PK"} {
+		if !assert.Len(t, form.File[name], 1) {
+			continue
+		}
+		f, err := form.File[name][0].Open()
+		if !assert.NoError(t, err) {
+			continue
+		}
+		data, _ := io.ReadAll(f)
+		f.Close()
+		assert.Equal(t, want, string(data))
+	}
+	assert.Equal(t, "invoice.pdf", form.File["file0"][0].Filename)
+	assert.Equal(t, "application/pdf", form.File["file0"][0].Header.Get("Content-Type"))
+}
+
+func TestTelegramSenderSendTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	s := NewTelegramSender("123:secret", srv.URL, NewTelegramHTTPClient(50*time.Millisecond, nil), nil)
+	_, err := s.Send(context.Background(), &models.Notification{
+		Type:    models.NotificationTypeTelegram,
+		Payload: &models.TelegramNotification{ChatID: "42", Message: "Hello"},
+	})
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Client.Timeout exceeded")
+		assert.NotContains(t, err.Error(), "secret")
+	}
+}
+
+func TestTelegramSenderSendErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		check  func(t *testing.T, err error)
+	}{
+		{
+			name:   "RateLimited",
+			status: http.StatusTooManyRequests,
+			body:   `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 35","parameters":{"retry_after":35}}`,
+			check: func(t *testing.T, err error) {
+				var rl *RateLimitError
+				if assert.ErrorAs(t, err, &rl) {
+					assert.Equal(t, 35*time.Second, rl.RetryAfter)
+				}
+				assert.False(t, errors.Is(err, ErrPermanent))
+			},
+		},
+		{
+			name:   "BotBlocked",
+			status: http.StatusForbidden,
+			body:   `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`,
+			check: func(t *testing.T, err error) {
+				var blocked *BlockedError
+				if assert.ErrorAs(t, err, &blocked) {
+					assert.Equal(t, "42", blocked.Recipient)
+					assert.Equal(t, "Forbidden: bot was blocked by the user", blocked.Reason)
+				}
+				assert.ErrorIs(t, err, ErrPermanent)
+			},
+		},
+		{
+			name:   "ChatNotFound",
+			status: http.StatusBadRequest,
+			body:   `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`,
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrPermanent)
+				var apiErr *TelegramAPIError
+				if assert.ErrorAs(t, err, &apiErr) {
+					assert.Equal(t, "Bad Request: chat not found", apiErr.Description)
+				}
+				var blocked *BlockedError
+				assert.False(t, errors.As(err, &blocked))
+			},
+		},
+		{
+			name:   "ServerError",
+			status: http.StatusBadGateway,
+			body:   `<html>Bad Gateway</html>`,
+			check: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "telegram api error 502: <html>Bad Gateway</html>")
+				assert.False(t, errors.Is(err, ErrPermanent))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			s := NewTelegramSender("token", srv.URL, srv.Client(), nil)
+			res, err := s.Send(context.Background(), &models.Notification{
+				Type:    models.NotificationTypeTelegram,
+				Payload: &models.TelegramNotification{ChatID: "42", Message: "Hello"},
+			})
+			tt.check(t, err)
+			if assert.NotNil(t, res) {
+				assert.Equal(t, Classify(err), res.ErrorClass)
+				assert.Equal(t, tt.body, res.Response)
+			}
+		})
+	}
+}
+
+func TestTelegramSenderGetUpdates(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"ok":true,"result":[{"update_id":10,"message":{"message_id":1,"chat":{"id":42,"type":"private"},"text":"/start abc"}}]}`))
+	}))
+	defer srv.Close()
+
+	s := NewTelegramSender("token", srv.URL, srv.Client(), nil)
+	updates, err := s.GetUpdates(context.Background(), 10)
+
+	if !assert.NoError(t, err) || !assert.Len(t, updates, 1) {
+		return
+	}
+	assert.JSONEq(t, `{"offset":10,"timeout":25,"allowed_updates":["message","my_chat_member"]}`, body)
+	assert.Equal(t, int64(10), updates[0].UpdateID)
+	assert.Equal(t, int64(42), updates[0].Message.Chat.ID)
+	assert.Equal(t, "/start abc", updates[0].Message.Text)
+}
@@ -0,0 +1,195 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+const (
+	// WebhookSignatureHeader заголовок с HMAC-SHA256 подписью "<timestamp>.<тело запроса>"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	// WebhookTimestampHeader заголовок с unix-временем подписи
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	// WebhookIDHeader заголовок с ID уведомления, по нему получатель может отбрасывать повторы
+	WebhookIDHeader = "X-Webhook-Id"
+
+	// webhookDefaultTimeout таймаут запроса, если он не задан в уведомлении
+	webhookDefaultTimeout = 10 * time.Second
+	// webhookErrorBodyLimit сколько байт ответа попадает в текст ошибки
+	webhookErrorBodyLimit = 512
+)
+
+// WebhookSender реализует доставку уведомлений POST-запросом на URL из уведомления.
+type WebhookSender struct {
+	client *http.Client
+	secret string
+}
+
+// NewWebhookSender создает новый экземпляр WebhookSender.
+// secret используется для подписи, если у уведомления нет собственного ключа.
+func NewWebhookSender(secret string) *WebhookSender {
+	return &WebhookSender{
+		client: &http.Client{
+			// перенаправления не выполняем: 3xx считается ответом получателя
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		secret: secret,
+	}
+}
+
+// Send отправляет уведомление на URL вебхука. Таймаут из уведомления сокращает дедлайн ctx,
+// но не продлевает его. Response — начало тела ответа получателя.
+func (s *WebhookSender) Send(ctx context.Context, n *models.Notification) (*Result, error) {
+	wr, err := s.request(n, time.Now())
+	if err != nil {
+		return failed(nil, err)
+	}
+
+	w := models.PayloadOf[models.WebhookNotification](n)
+	timeout := webhookDefaultTimeout
+	if w.Timeout > 0 {
+		timeout = time.Duration(w.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(wr.body))
+	if err != nil {
+		return failed(nil, fmt.Errorf("webhook request error: %w", err))
+	}
+	req.Header = wr.header
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return failed(nil, fmt.Errorf("webhook send error: %w", err))
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+	if !webhookSuccess(resp.StatusCode, w.SuccessCodes) {
+		return failed(body, httpStatusError(resp.StatusCode, fmt.Errorf("webhook send failed: status %d: %s", resp.StatusCode, string(body))))
+	}
+	// дочитываем ответ, чтобы соединение вернулось в пул
+	io.Copy(io.Discard, resp.Body)
+	return delivered("", body), nil
+}
+
+// Render возвращает запрос вебхука в том виде, в котором он будет отправлен.
+// Подпись рассчитывается на текущий момент и при отправке будет другой.
+func (s *WebhookSender) Render(n *models.Notification) (*models.RenderedMessage, error) {
+	wr, err := s.request(n, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(wr.header))
+	for name := range wr.header {
+		headers[name] = wr.header.Get(name)
+	}
+	w := models.PayloadOf[models.WebhookNotification](n)
+	return &models.RenderedMessage{
+		Type:      n.Type,
+		Recipient: w.URL,
+		Subject:   w.Subject,
+		Body:      string(wr.body),
+		Headers:   headers,
+		Payload:   wr.fields,
+	}, nil
+}
+
+// webhookRequest подготовленный запрос вебхука.
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+	fields map[string]any
+}
+
+// request собирает тело и заголовки запроса. Поля тела: data из уведомления,
+// затем id, subject и message (они не перезаписываются полями data).
+func (s *WebhookSender) request(n *models.Notification, now time.Time) (*webhookRequest, error) {
+	w := models.PayloadOf[models.WebhookNotification](n)
+	if w == nil {
+		return nil, errors.New("webhook notification details are missing")
+	}
+
+	fields := make(map[string]any, len(w.Data)+3)
+	for k, v := range w.Data {
+		fields[k] = v
+	}
+	fields["id"] = n.ID
+	fields["message"] = w.Message
+	if w.Subject != "" {
+		fields["subject"] = w.Subject
+	}
+
+	header := http.Header{}
+	for name, value := range w.Headers {
+		header.Set(name, value)
+	}
+
+	var body []byte
+	switch w.Format {
+	case models.WebhookFormatForm:
+		form := url.Values{}
+		for k, v := range fields {
+			form.Set(k, fmt.Sprint(v))
+		}
+		body = []byte(form.Encode())
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	default:
+		var err error
+		if body, err = json.Marshal(fields); err != nil {
+			return nil, fmt.Errorf("failed to encode webhook body: %w", err)
+		}
+		header.Set("Content-Type", "application/json")
+	}
+
+	header.Set(WebhookIDHeader, n.ID)
+	secret := w.Secret
+	if secret == "" {
+		secret = s.secret
+	}
+	if secret != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		header.Set(WebhookTimestampHeader, timestamp)
+		header.Set(WebhookSignatureHeader, "sha256="+WebhookSignature(secret, timestamp, body))
+	}
+
+	return &webhookRequest{header: header, body: body, fields: fields}, nil
+}
+
+// WebhookSignature вычисляет подпись вебхука: hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// Получатель проверяет ее тем же способом и сравнивает с заголовком X-Webhook-Signature без префикса "sha256=".
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSuccess проверяет, считается ли код ответа успешной доставкой.
+func webhookSuccess(status int, codes []int) bool {
+	if len(codes) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, code := range codes {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
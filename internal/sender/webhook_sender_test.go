@@ -0,0 +1,95 @@
+package sender
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWebhookNotification(rawURL string, opts models.WebhookOptions) *models.Notification {
+	opts.URL = rawURL
+	return &models.Notification{
+		ID:   "notif-1",
+		Type: models.NotificationTypeWebhook,
+		Payload: &models.WebhookNotification{
+			WebhookOptions: opts,
+			Message:        "Hello",
+		},
+	}
+}
+
+func TestWebhookSenderSend(t *testing.T) {
+	t.Run("SignedJSON", func(t *testing.T) {
+		var (
+			header http.Header
+			body   []byte
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Clone()
+			body, _ = io.ReadAll(r.Body)
+		}))
+		defer srv.Close()
+
+		n := newWebhookNotification(srv.URL, models.WebhookOptions{
+			Headers: map[string]string{"Authorization": "Bearer token"},
+			Data:    map[string]string{"order_id": "42", "id": "spoofed"},
+		})
+		_, err := NewWebhookSender("secret").Send(context.Background(), n)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"id":"notif-1","message":"Hello","order_id":"42"}`, string(body))
+		assert.Equal(t, "application/json", header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", header.Get("Authorization"))
+		assert.Equal(t, "notif-1", header.Get(WebhookIDHeader))
+		assert.Equal(t, "sha256="+WebhookSignature("secret", header.Get(WebhookTimestampHeader), body), header.Get(WebhookSignatureHeader))
+	})
+
+	t.Run("FormWithOwnSecret", func(t *testing.T) {
+		var form url.Values
+		var signature string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			form, _ = url.ParseQuery(string(body))
+			signature = "sha256=" + WebhookSignature("own", r.Header.Get(WebhookTimestampHeader), body)
+			assert.Equal(t, signature, r.Header.Get(WebhookSignatureHeader))
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer srv.Close()
+
+		n := newWebhookNotification(srv.URL, models.WebhookOptions{Format: models.WebhookFormatForm, Secret: "own"})
+		_, err := NewWebhookSender("secret").Send(context.Background(), n)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello", form.Get("message"))
+	})
+
+	t.Run("SuccessCodes", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, "duplicate")
+		}))
+		defer srv.Close()
+
+		s := NewWebhookSender("")
+		_, err := s.Send(context.Background(), newWebhookNotification(srv.URL, models.WebhookOptions{}))
+		assert.ErrorContains(t, err, "status 409: duplicate")
+
+		_, err = s.Send(context.Background(), newWebhookNotification(srv.URL, models.WebhookOptions{SuccessCodes: []int{200, 409}}))
+		assert.NoError(t, err)
+	})
+
+	t.Run("NoRedirects", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		}))
+		defer srv.Close()
+
+		_, err := NewWebhookSender("").Send(context.Background(), newWebhookNotification(srv.URL, models.WebhookOptions{}))
+		assert.ErrorContains(t, err, "status 302")
+	})
+}
@@ -0,0 +1,380 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+const (
+	// WebPushMaxPayloadSize максимальный размер открытого текста push-сообщения в байтах:
+	// push-сервисы принимают тело до 4096 байт, из них 86 — заголовок aes128gcm,
+	// 16 — тег AES-GCM и 1 — разделитель записи.
+	WebPushMaxPayloadSize = 3993
+	// WebPushMaxTTL максимальный TTL сообщения в секундах (4 недели)
+	WebPushMaxTTL = 4 * 7 * 24 * 3600
+
+	// webPushDefaultTTL TTL, если он не задан в уведомлении
+	webPushDefaultTTL = 24 * 3600
+	// webPushRecordSize размер записи aes128gcm (rs)
+	webPushRecordSize = 4096
+	// webPushTimeout таймаут запроса к push-сервису
+	webPushTimeout = 10 * time.Second
+	// vapidTokenLifetime срок действия VAPID JWT (не больше 24 часов по RFC 8292)
+	vapidTokenLifetime = 12 * time.Hour
+)
+
+// errSubscriptionGone push-сервис сообщил, что подписка больше не действует (404/410).
+var errSubscriptionGone = errors.New("push subscription has expired")
+
+// PushSubscriptionStore хранилище подписок, которым пользуется отправитель Web Push.
+type PushSubscriptionStore interface {
+	ListBySubscriber(ctx context.Context, subscriber string) ([]models.PushSubscription, error)
+	DeleteByEndpoint(ctx context.Context, endpoint string) error
+}
+
+// VAPIDKeys ключи сервера приложений для подписи запросов к push-сервисам (RFC 8292).
+type VAPIDKeys struct {
+	private *ecdsa.PrivateKey
+	// PublicKey несжатый открытый ключ P-256 в base64url — applicationServerKey для браузера
+	PublicKey string
+}
+
+// ParseVAPIDKeys разбирает закрытый ключ P-256 (32 байта в base64url) и вычисляет открытый.
+func ParseVAPIDKeys(privateKey string) (*VAPIDKeys, error) {
+	raw, err := decodeBase64URL(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	pub := key.PublicKey().Bytes()
+	return &VAPIDKeys{
+		private: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:]),
+			},
+			D: new(big.Int).SetBytes(raw),
+		},
+		PublicKey: base64.RawURLEncoding.EncodeToString(pub),
+	}, nil
+}
+
+// GenerateVAPIDKeys создает новую пару ключей VAPID в base64url.
+func GenerateVAPIDKeys() (publicKey, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// token возвращает подписанный ES256 JWT для origin push-сервиса.
+func (k *VAPIDKeys) token(audience, subject string, now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"exp": now.Add(vapidTokenLifetime).Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+	// подпись JWS ES256 — r и s по 32 байта подряд
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// WebPushSender реализует доставку браузерных push-уведомлений по всем подпискам подписчика.
+type WebPushSender struct {
+	store   PushSubscriptionStore
+	vapid   *VAPIDKeys
+	subject string
+	client  *http.Client
+}
+
+// NewWebPushSender создает новый экземпляр WebPushSender.
+// subject — контакт владельца сервера приложений (mailto: или https: URL) для push-сервисов.
+func NewWebPushSender(store PushSubscriptionStore, vapid *VAPIDKeys, subject string) *WebPushSender {
+	return &WebPushSender{
+		store:   store,
+		vapid:   vapid,
+		subject: subject,
+		client:  &http.Client{Timeout: webPushTimeout},
+	}
+}
+
+// Send шифрует сообщение для каждой подписки подписчика и отправляет его push-сервису.
+// Подписки, на которые push-сервис ответил 404 или 410, удаляются. Доставка считается
+// успешной, если сообщение принято хотя бы для одной подписки. ProviderMessageID — адреса
+// принятых сообщений из заголовка Location ответов push-сервисов через запятую.
+func (s *WebPushSender) Send(ctx context.Context, n *models.Notification) (*Result, error) {
+	payload, err := webPushPayload(n)
+	if err != nil {
+		return failed(nil, err)
+	}
+	if s.store == nil {
+		return failed(nil, errors.New("push subscription store is not configured"))
+	}
+
+	p := models.PayloadOf[models.WebPushNotification](n)
+	subs, err := s.store.ListBySubscriber(ctx, p.Subscriber)
+	if err != nil {
+		return failed(nil, err)
+	}
+	// без подписок повтор не поможет: новые подписки получат только следующие уведомления
+	if len(subs) == 0 {
+		return failed(nil, fmt.Errorf("%w: no push subscriptions for subscriber %q", ErrPermanent, p.Subscriber))
+	}
+
+	accepted := 0
+	var locations []string
+	var errs []error
+	for _, sub := range subs {
+		location, err := s.push(ctx, sub, payload, &p.WebPushOptions)
+		switch {
+		case errors.Is(err, errSubscriptionGone):
+			log.Printf("push subscription %s of %q has expired, removing it", sub.ID, p.Subscriber)
+			if err := s.store.DeleteByEndpoint(ctx, sub.Endpoint); err != nil {
+				log.Printf("failed to remove push subscription %s: %v", sub.ID, err)
+			}
+		case err != nil:
+			log.Printf("failed to push notification %s to subscription %s: %v", n.ID, sub.ID, err)
+			errs = append(errs, err)
+		default:
+			if location != "" {
+				locations = append(locations, location)
+			}
+			accepted++
+		}
+	}
+	if accepted > 0 {
+		return &Result{ProviderMessageID: strings.Join(locations, ",")}, nil
+	}
+	if len(errs) == 0 {
+		return failed(nil, fmt.Errorf("%w: all push subscriptions of %q have expired", ErrPermanent, p.Subscriber))
+	}
+	return failed(nil, errors.Join(errs...))
+}
+
+// Render возвращает открытый текст push-сообщения; шифруется оно отдельно для каждой подписки.
+func (s *WebPushSender) Render(n *models.Notification) (*models.RenderedMessage, error) {
+	payload, err := webPushPayload(n)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	json.Unmarshal(payload, &fields)
+	p := models.PayloadOf[models.WebPushNotification](n)
+	return &models.RenderedMessage{
+		Type:      n.Type,
+		Recipient: p.Subscriber,
+		Subject:   p.Subject,
+		Body:      string(payload),
+		Payload:   fields,
+	}, nil
+}
+
+// push отправляет зашифрованное сообщение на endpoint подписки (RFC 8030) и возвращает адрес
+// принятого сообщения из заголовка Location.
+func (s *WebPushSender) push(ctx context.Context, sub models.PushSubscription, payload []byte, opts *models.WebPushOptions) (string, error) {
+	body, err := encryptWebPush(sub.Keys, payload)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("push request error: %w", err)
+	}
+
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = webPushDefaultTTL
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(ttl))
+	if opts.Urgency != "" {
+		req.Header.Set("Urgency", opts.Urgency)
+	}
+	if s.vapid != nil {
+		u, err := url.Parse(sub.Endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid push endpoint: %w", err)
+		}
+		token, err := s.vapid.token(u.Scheme+"://"+u.Host, s.subject, time.Now())
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "vapid t="+token+", k="+s.vapid.PublicKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("push send error: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp.Header.Get("Location"), nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return "", errSubscriptionGone
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "", &RateLimitError{Channel: models.NotificationTypeWebPush, RetryAfter: parseSeconds(resp.Header.Get("Retry-After"))}
+	default:
+		return "", httpStatusError(resp.StatusCode, fmt.Errorf("push send failed: status %d: %s", resp.StatusCode, string(respBody)))
+	}
+}
+
+// webPushPayload собирает JSON, который получит service worker браузера.
+func webPushPayload(n *models.Notification) ([]byte, error) {
+	p := models.PayloadOf[models.WebPushNotification](n)
+	if p == nil {
+		return nil, errors.New("webpush notification details are missing")
+	}
+	payload, err := WebPushPayload(n.ID, p.Subject, p.Message, p.URL)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > WebPushMaxPayloadSize {
+		return nil, fmt.Errorf("push payload exceeds %d bytes", WebPushMaxPayloadSize)
+	}
+	return payload, nil
+}
+
+// WebPushPayload возвращает открытый текст push-сообщения: {"id", "title", "body", "url"}.
+func WebPushPayload(id, title, body, link string) ([]byte, error) {
+	fields := map[string]string{"id": id, "body": body}
+	if title != "" {
+		fields["title"] = title
+	}
+	if link != "" {
+		fields["url"] = link
+	}
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode push payload: %w", err)
+	}
+	return payload, nil
+}
+
+// encryptWebPush шифрует сообщение для подписки по RFC 8291 (кодировка aes128gcm, RFC 8188):
+// одна запись, ключи выводятся из общего секрета ECDH с одноразовым ключом сервера и auth-секрета подписки.
+func encryptWebPush(keys models.PushSubscriptionKeys, payload []byte) ([]byte, error) {
+	uaPublicRaw, err := decodeBase64URL(keys.P256DH)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := decodeBase64URL(keys.Auth)
+	if err != nil || len(authSecret) == 0 {
+		return nil, errors.New("invalid auth secret")
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ecdhSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	cek, nonce, err := webPushContentKeys(ecdhSecret, authSecret, uaPublicRaw, asPublic, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// заголовок: salt, rs, длина keyid и keyid — открытый ключ сервера
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	// 0x02 — разделитель последней записи
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// webPushContentKeys выводит ключ шифрования и nonce записи (RFC 8291, раздел 3.4; RFC 8188, раздел 2.2).
+func webPushContentKeys(ecdhSecret, authSecret, uaPublic, asPublic, salt []byte) (cek, nonce []byte, err error) {
+	prkKey, err := hkdf.Extract(sha256.New, ecdhSecret, authSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cek, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16); err != nil {
+		return nil, nil, err
+	}
+	if nonce, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12); err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
+
+// decodeBase64URL декодирует base64url с выравниванием "=" или без него.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
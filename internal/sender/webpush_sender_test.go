@@ -0,0 +1,189 @@
+package sender
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePushStore struct {
+	subs    []models.PushSubscription
+	deleted []string
+}
+
+func (f *fakePushStore) ListBySubscriber(_ context.Context, _ string) ([]models.PushSubscription, error) {
+	return f.subs, nil
+}
+
+func (f *fakePushStore) DeleteByEndpoint(_ context.Context, endpoint string) error {
+	f.deleted = append(f.deleted, endpoint)
+	return nil
+}
+
+// pushClient ключи браузера: закрытый ключ подписки и auth-секрет.
+type pushClient struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newPushClient(t *testing.T) *pushClient {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return &pushClient{key: key, auth: auth}
+}
+
+func (c *pushClient) keys() models.PushSubscriptionKeys {
+	return models.PushSubscriptionKeys{
+		P256DH: base64.RawURLEncoding.EncodeToString(c.key.PublicKey().Bytes()),
+		Auth:   base64.RawURLEncoding.EncodeToString(c.auth),
+	}
+}
+
+// decrypt расшифровывает тело aes128gcm так, как это делает браузер.
+func (c *pushClient) decrypt(t *testing.T, body []byte) []byte {
+	salt, rs, idLen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	asPublicRaw := body[21 : 21+idLen]
+	assert.Equal(t, uint32(webPushRecordSize), rs)
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := c.key.ECDH(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cek, nonce, err := webPushContentKeys(secret, c.auth, c.key.PublicKey().Bytes(), asPublicRaw, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, byte(0x02), plaintext[len(plaintext)-1])
+	return plaintext[:len(plaintext)-1]
+}
+
+// verifyVAPID проверяет подпись JWT из заголовка Authorization и возвращает его claims.
+func verifyVAPID(t *testing.T, header string) map[string]any {
+	parts := strings.Split(strings.TrimPrefix(header, "vapid "), ", ")
+	token, key := strings.TrimPrefix(parts[0], "t="), strings.TrimPrefix(parts[1], "k=")
+
+	pub, _ := base64.RawURLEncoding.DecodeString(key)
+	segments := strings.Split(token, ".")
+	sig, _ := base64.RawURLEncoding.DecodeString(segments[2])
+	digest := sha256.Sum256([]byte(segments[0] + "." + segments[1]))
+	verified := ecdsa.Verify(&ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(pub[1:33]),
+		Y:     new(big.Int).SetBytes(pub[33:]),
+	}, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	assert.True(t, verified, "VAPID signature must be valid")
+
+	var claims map[string]any
+	raw, _ := base64.RawURLEncoding.DecodeString(segments[1])
+	json.Unmarshal(raw, &claims)
+	return claims
+}
+
+func TestWebPushSenderSend(t *testing.T) {
+	client := newPushClient(t)
+	var payload map[string]string
+	var claims map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/expired" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		assert.Equal(t, "aes128gcm", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "60", r.Header.Get("TTL"))
+		assert.Equal(t, "high", r.Header.Get("Urgency"))
+		claims = verifyVAPID(t, r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(client.decrypt(t, body), &payload)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	_, private, err := GenerateVAPIDKeys()
+	assert.NoError(t, err)
+	vapid, err := ParseVAPIDKeys(private)
+	assert.NoError(t, err)
+
+	store := &fakePushStore{subs: []models.PushSubscription{
+		{ID: "1", Endpoint: srv.URL + "/expired", Keys: client.keys()},
+		{ID: "2", Endpoint: srv.URL + "/push/abc", Keys: client.keys()},
+	}}
+	s := NewWebPushSender(store, vapid, "mailto:admin@example.com")
+
+	_, err = s.Send(context.Background(), &models.Notification{
+		ID:   "notif-1",
+		Type: models.NotificationTypeWebPush,
+		Payload: &models.WebPushNotification{
+			WebPushOptions: models.WebPushOptions{Subscriber: "user-1", URL: "https://example.com/orders/42", TTL: 60, Urgency: "high"},
+			Subject:        "Напоминание",
+			Message:        "Заказ 42 готов",
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"id": "notif-1", "title": "Напоминание", "body": "Заказ 42 готов", "url": "https://example.com/orders/42",
+	}, payload)
+	assert.Equal(t, srv.URL, claims["aud"])
+	assert.Equal(t, "mailto:admin@example.com", claims["sub"])
+	assert.Equal(t, []string{srv.URL + "/expired"}, store.deleted)
+}
+
+func TestWebPushSenderAllSubscriptionsExpired(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	store := &fakePushStore{subs: []models.PushSubscription{{ID: "1", Endpoint: srv.URL, Keys: newPushClient(t).keys()}}}
+	_, err := NewWebPushSender(store, nil, "").Send(context.Background(), &models.Notification{
+		ID:      "notif-1",
+		Type:    models.NotificationTypeWebPush,
+		Payload: &models.WebPushNotification{WebPushOptions: models.WebPushOptions{Subscriber: "user-1"}, Message: "hi"},
+	})
+
+	assert.ErrorContains(t, err, "have expired")
+	assert.Equal(t, ErrorClassPermanent, Classify(err))
+	assert.Equal(t, []string{srv.URL}, store.deleted)
+}
+
+func TestWebPushSenderNoSubscriptions(t *testing.T) {
+	_, err := NewWebPushSender(&fakePushStore{}, nil, "").Send(context.Background(), &models.Notification{
+		ID:      "notif-1",
+		Type:    models.NotificationTypeWebPush,
+		Payload: &models.WebPushNotification{WebPushOptions: models.WebPushOptions{Subscriber: "user-1"}, Message: "hi"},
+	})
+
+	assert.ErrorIs(t, err, ErrPermanent)
+	assert.Equal(t, ErrorClassPermanent, Classify(err))
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/statuscache"
+)
+
+// BounceProcessor обрабатывает сообщения о недоставке писем: помечает уведомление как bounced
+// и добавляет адрес с постоянной ошибкой в список подавления, чтобы воркер больше не писал на него.
+type BounceProcessor struct {
+	svc         NotificationService
+	statusCache *statuscache.Cache
+}
+
+// NewBounceProcessor создает обработчик сообщений о недоставке. statusCache может быть nil.
+func NewBounceProcessor(svc NotificationService, cache *statuscache.Cache) *BounceProcessor {
+	return &BounceProcessor{svc: svc, statusCache: cache}
+}
+
+// ProcessAll обрабатывает сообщения о недоставке по очереди и возвращает первую ошибку.
+// Повторная обработка того же сообщения безопасна, поэтому при ошибке отправитель может
+// повторить доставку всего отчета.
+func (p *BounceProcessor) ProcessAll(ctx context.Context, bounces []models.Bounce) error {
+	for i := range bounces {
+		if err := p.Process(ctx, &bounces[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Process обрабатывает одно сообщение о недоставке. Письмо находится по Message-ID;
+// если оно не найдено (отчет без заголовков исходного письма или письмо отправлено не сервисом),
+// обновляется только список подавления.
+func (p *BounceProcessor) Process(ctx context.Context, b *models.Bounce) error {
+	reason := bounceReason(b)
+	if b.Hard {
+		sup := &models.Suppression{Type: models.NotificationTypeEmail, Recipient: b.Recipient, Reason: reason}
+		if err := p.svc.Suppress(ctx, sup); err != nil {
+			return fmt.Errorf("failed to suppress %s: %w", b.Recipient, err)
+		}
+	}
+
+	messageID := normalizeMessageID(b.MessageID)
+	if messageID == "" {
+		log.Printf("bounce for %s without message id, notification is not updated", b.Recipient)
+		return nil
+	}
+	class := sender.ErrorClassTransient
+	if b.Hard {
+		class = sender.ErrorClassPermanent
+	}
+	n, err := p.svc.RecordBounce(ctx, messageID, string(class), truncateReason(reason))
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Printf("bounce for %s: no sent notification with message id %s", b.Recipient, messageID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("notification id=%v bounced: %s", n.ID, reason)
+
+	p.setStatus(ctx, n.ID, models.StatusBounced)
+	if n.ParentID != "" {
+		// в режиме fallback вернувшееся письмо запускает следующий канал
+		status, err := p.svc.SyncGroup(ctx, n.ParentID)
+		if err != nil {
+			return fmt.Errorf("failed to sync group status for id=%v: %w", n.ParentID, err)
+		}
+		p.setStatus(ctx, n.ParentID, status)
+	}
+	return nil
+}
+
+// setStatus обновляет статус уведомления в Redis, если кэш подключен.
+func (p *BounceProcessor) setStatus(ctx context.Context, id string, status models.Status) {
+	if p.statusCache == nil {
+		return
+	}
+	if err := p.statusCache.SetStatus(ctx, id, status); err != nil {
+		log.Printf("failed to set status in redis for id=%v: %v", id, err)
+	}
+}
+
+// bounceReason возвращает текст причины недоставки, например
+// "hard bounce 5.1.1: 550 5.1.1 User unknown".
+func bounceReason(b *models.Bounce) string {
+	reason := "soft bounce"
+	if b.Hard {
+		reason = "hard bounce"
+	}
+	if b.Status != "" {
+		reason += " " + b.Status
+	}
+	if b.Diagnostic != "" {
+		reason += ": " + b.Diagnostic
+	}
+	return reason
+}
+
+// normalizeMessageID приводит Message-ID к виду "<id@domain>", в котором его сохраняет EmailSender.
+func normalizeMessageID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return ""
+	}
+	return "<" + strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">") + ">"
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBounceProcessorProcess(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	p := NewBounceProcessor(NewNotificationService(mockRepo, testChannels), nil)
+	ctx := context.Background()
+
+	hard := &models.Suppression{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Reason: "hard bounce 5.1.1: 550 User unknown"}
+	mockRepo.On("Suppress", ctx, hard).Return(nil)
+	mockRepo.On("RecordBounce", ctx, "<abc@example.com>", "permanent", "hard bounce 5.1.1: 550 User unknown").
+		Return(&models.Notification{ID: "child-1", Type: models.NotificationTypeEmail, Status: models.StatusBounced, ParentID: "parent-1"}, nil)
+	mockRepo.On("SyncGroup", ctx, "parent-1").Return(models.StatusProcessing, nil)
+	// временная ошибка не добавляет адрес в список подавления
+	mockRepo.On("RecordBounce", ctx, "<def@example.com>", "transient", "soft bounce 4.2.2").Return(nil, repository.ErrNotFound)
+
+	err := p.ProcessAll(ctx, []models.Bounce{
+		{Recipient: "User@Example.com", MessageID: "abc@example.com", Hard: true, Status: "5.1.1", Diagnostic: "550 User unknown"},
+		{Recipient: "full@example.com", MessageID: " <def@example.com> ", Status: "4.2.2"},
+	})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNumberOfCalls(t, "Suppress", 1)
+}
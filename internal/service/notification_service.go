@@ -2,61 +2,93 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
 
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
-	"github.com/google/uuid"
 )
 
-// NotificationService описывает методы для работы с уведомлениями.
-type NotificationService interface {
+// Notifications описывает создание уведомлений и доступ к ним из API.
+type Notifications interface {
 	Create(ctx context.Context, req *models.CreateNotificationRequest) (string, error)
 	Get(ctx context.Context, id string) (*models.Notification, error)
-	GetAll(ctx context.Context) ([]*models.Notification, error)
+	GetAll(ctx context.Context, filter models.NotificationFilter) ([]*models.Notification, error)
+	Export(ctx context.Context, filter models.NotificationFilter, fn func(n *models.Notification) error) error
+	CountByStatus(ctx context.Context, filter models.NotificationFilter) (map[models.Status]int, error)
 	Cancel(ctx context.Context, id string) error
+	ResolveRecipients(ctx context.Context, req *models.CreateNotificationRequest) error
+}
+
+// DeliveryService описывает учет доставки уведомлений планировщиком и воркером.
+type DeliveryService interface {
 	ReservePending(ctx context.Context, limit int) ([]*models.Notification, error)
 	UpdateStatus(ctx context.Context, id string, status models.Status) error
 	IncrementRetries(ctx context.Context, id string) error
+	RecordSegments(ctx context.Context, id string, segments int) error
+	RecordFailure(ctx context.Context, id, class, reason string) error
+	RecordProviderMessageID(ctx context.Context, id, messageID string) error
+	RecordBounce(ctx context.Context, messageID, class, reason string) (*models.Notification, error)
+	SyncGroup(ctx context.Context, parentID string) (models.Status, error)
+	PendingGroups(ctx context.Context) ([]string, error)
+	Reschedule(ctx context.Context, id string, at time.Time) error
+}
+
+// SuppressionService описывает работу со списком подавления получателей.
+type SuppressionService interface {
+	Suppress(ctx context.Context, s *models.Suppression) error
+	Suppressed(ctx context.Context, n *models.Notification) (*models.Suppression, error)
+	GetSuppression(ctx context.Context, t models.NotificationType, recipient string) (*models.Suppression, error)
+	Unsuppress(ctx context.Context, t models.NotificationType, recipient string) error
+}
+
+// PreferenceService описывает подписки получателей на категории уведомлений.
+type PreferenceService interface {
+	SetPreference(ctx context.Context, p *models.Preference) error
+	GetPreferences(ctx context.Context, t models.NotificationType, recipient string) ([]models.Preference, error)
+	OptedOut(ctx context.Context, n *models.Notification) (*models.Preference, error)
+}
+
+// TelegramLinkService описывает связь чатов Telegram с пользователями через deep link.
+type TelegramLinkService interface {
+	CreateTelegramLinkToken(ctx context.Context, userID string) (*models.TelegramLinkToken, error)
+	LinkTelegramChat(ctx context.Context, token string, link *models.TelegramLink) error
+	GetTelegramLink(ctx context.Context, userID string) (*models.TelegramLink, error)
+	DeleteTelegramLink(ctx context.Context, userID string) error
 }
 
+// NotificationService описывает методы для работы с уведомлениями.
+type NotificationService interface {
+	Notifications
+	DeliveryService
+	SuppressionService
+	PreferenceService
+	TelegramLinkService
+	TemplateService
+}
+
+// TelegramLinkTTL срок действия ссылки для связи чата Telegram с пользователем.
+const TelegramLinkTTL = 24 * time.Hour
+
 type notificationService struct {
-	repo repository.NotificationRepository
+	repo     repository.NotificationRepository
+	channels *channel.Registry
 }
 
 // NewNotificationService создает новый экземпляр NotificationService.
-func NewNotificationService(repo repository.NotificationRepository) NotificationService {
-	return &notificationService{repo: repo}
+func NewNotificationService(repo repository.NotificationRepository, channels *channel.Registry) NotificationService {
+	return &notificationService{repo: repo, channels: channels}
 }
 
 // Create создает новое уведомление.
 func (s *notificationService) Create(ctx context.Context, req *models.CreateNotificationRequest) (string, error) {
-	var n *models.Notification
-	switch req.Type {
-	case "email":
-		n = &models.Notification{
-			ID:          uuid.NewString(),
-			Type:        req.Type,
-			ScheduledAt: req.ScheduledAt,
-			Status:      "scheduled",
-			Retries:     0,
-			EmailNotification: &models.EmailNotification{
-				Email:   req.Email,
-				Message: req.Message,
-				Subject: req.Subject,
-			},
-		}
-	case "telegram":
-		n = &models.Notification{
-			ID:          uuid.NewString(),
-			Type:        req.Type,
-			ScheduledAt: req.ScheduledAt,
-			Status:      "scheduled",
-			Retries:     0,
-			TelegramNotification: &models.TelegramNotification{
-				ChatID:  req.ChatID,
-				Message: req.Message,
-			},
-		}
+	n, err := s.channels.Build(req)
+	if err != nil {
+		return "", err
 	}
 	return s.repo.Create(ctx, n)
 }
@@ -66,9 +98,19 @@ func (s *notificationService) Get(ctx context.Context, id string) (*models.Notif
 	return s.repo.GetByID(ctx, id)
 }
 
-// GetAll возвращает все уведомления.
-func (s *notificationService) GetAll(ctx context.Context) ([]*models.Notification, error) {
-	return s.repo.GetAll(ctx)
+// GetAll возвращает уведомления, подходящие под фильтр.
+func (s *notificationService) GetAll(ctx context.Context, filter models.NotificationFilter) ([]*models.Notification, error) {
+	return s.repo.GetAll(ctx, filter)
+}
+
+// Export потоково передает в fn уведомления с историей статусов, подходящие под фильтр.
+func (s *notificationService) Export(ctx context.Context, filter models.NotificationFilter, fn func(n *models.Notification) error) error {
+	return s.repo.Export(ctx, filter, fn)
+}
+
+// CountByStatus возвращает количество уведомлений по статусам, подходящих под фильтр.
+func (s *notificationService) CountByStatus(ctx context.Context, filter models.NotificationFilter) (map[models.Status]int, error) {
+	return s.repo.CountByStatus(ctx, filter)
 }
 
 // Cancel отменяет запланированное уведомление.
@@ -90,3 +132,178 @@ func (s *notificationService) UpdateStatus(ctx context.Context, id string, statu
 func (s *notificationService) IncrementRetries(ctx context.Context, id string) error {
 	return s.repo.IncrementRetries(ctx, id)
 }
+
+// RecordSegments сохраняет количество отправленных сегментов уведомления для учета стоимости.
+func (s *notificationService) RecordSegments(ctx context.Context, id string, segments int) error {
+	return s.repo.RecordSegments(ctx, id, segments)
+}
+
+// RecordFailure сохраняет класс и текст ошибки, после которой доставка завершилась неудачей.
+func (s *notificationService) RecordFailure(ctx context.Context, id, class, reason string) error {
+	return s.repo.RecordFailure(ctx, id, class, reason)
+}
+
+// RecordProviderMessageID сохраняет идентификатор сообщения у провайдера.
+func (s *notificationService) RecordProviderMessageID(ctx context.Context, id, messageID string) error {
+	return s.repo.RecordProviderMessageID(ctx, id, messageID)
+}
+
+// RecordBounce помечает письмо с Message-ID messageID как вернувшееся и сохраняет причину.
+func (s *notificationService) RecordBounce(ctx context.Context, messageID, class, reason string) (*models.Notification, error) {
+	return s.repo.RecordBounce(ctx, messageID, class, reason)
+}
+
+// SyncGroup пересчитывает статус уведомления с несколькими каналами и запускает
+// следующий канал в режиме fallback. Возвращает новый статус уведомления.
+func (s *notificationService) SyncGroup(ctx context.Context, parentID string) (models.Status, error) {
+	return s.repo.SyncGroup(ctx, parentID)
+}
+
+// PendingGroups возвращает ID уведомлений с несколькими каналами, статус которых нужно пересчитать.
+func (s *notificationService) PendingGroups(ctx context.Context) ([]string, error) {
+	return s.repo.PendingGroups(ctx)
+}
+
+// Reschedule переносит отправку уведомления на время at.
+func (s *notificationService) Reschedule(ctx context.Context, id string, at time.Time) error {
+	return s.repo.Reschedule(ctx, id, at)
+}
+
+// Suppress добавляет получателя в список подавления.
+func (s *notificationService) Suppress(ctx context.Context, sup *models.Suppression) error {
+	sup.Recipient = normalizeRecipient(sup.Type, sup.Recipient)
+	return s.repo.Suppress(ctx, sup)
+}
+
+// Suppressed возвращает запись списка подавления для получателя уведомления или nil,
+// если получателю можно отправлять.
+func (s *notificationService) Suppressed(ctx context.Context, n *models.Notification) (*models.Suppression, error) {
+	recipient := s.channels.Describe(n).Recipient
+	if recipient == "" {
+		return nil, nil
+	}
+	sup, err := s.repo.GetSuppression(ctx, n.Type, normalizeRecipient(n.Type, recipient))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	return sup, err
+}
+
+// GetSuppression возвращает запись списка подавления получателя канала.
+func (s *notificationService) GetSuppression(ctx context.Context, t models.NotificationType, recipient string) (*models.Suppression, error) {
+	return s.repo.GetSuppression(ctx, t, normalizeRecipient(t, recipient))
+}
+
+// Unsuppress удаляет получателя из списка подавления, например после того как пользователь разблокировал бота.
+func (s *notificationService) Unsuppress(ctx context.Context, t models.NotificationType, recipient string) error {
+	return s.repo.Unsuppress(ctx, t, normalizeRecipient(t, recipient))
+}
+
+// SetPreference сохраняет подписку получателя на категорию уведомлений канала.
+func (s *notificationService) SetPreference(ctx context.Context, p *models.Preference) error {
+	p.Recipient = normalizeRecipient(p.Type, p.Recipient)
+	return s.repo.SetPreference(ctx, p)
+}
+
+// GetPreferences возвращает настройки получателя канала.
+func (s *notificationService) GetPreferences(ctx context.Context, t models.NotificationType, recipient string) ([]models.Preference, error) {
+	return s.repo.GetPreferences(ctx, t, normalizeRecipient(t, recipient))
+}
+
+// OptedOut возвращает настройку, по которой получатель отказался от уведомления, или nil,
+// если отправлять можно. Настройка категории уведомления важнее общей настройки канала;
+// без настроек получатель считается подписанным.
+func (s *notificationService) OptedOut(ctx context.Context, n *models.Notification) (*models.Preference, error) {
+	recipient := s.channels.Describe(n).Recipient
+	if recipient == "" {
+		return nil, nil
+	}
+	prefs, err := s.repo.GetPreferences(ctx, n.Type, normalizeRecipient(n.Type, recipient))
+	if err != nil {
+		return nil, err
+	}
+	var channelPref, categoryPref *models.Preference
+	for i := range prefs {
+		switch prefs[i].Category {
+		case "":
+			channelPref = &prefs[i]
+		case n.Category:
+			categoryPref = &prefs[i]
+		}
+	}
+	switch {
+	case categoryPref != nil:
+		if categoryPref.Enabled {
+			return nil, nil
+		}
+		return categoryPref, nil
+	case channelPref != nil && !channelPref.Enabled:
+		return channelPref, nil
+	}
+	return nil, nil
+}
+
+// normalizeRecipient приводит получателя к виду, в котором он хранится в списке подавления
+// и настройках: адреса email сравниваются без учета регистра, так как почтовые серверы
+// и отчеты о недоставке могут менять регистр.
+func normalizeRecipient(t models.NotificationType, recipient string) string {
+	if t == models.NotificationTypeEmail {
+		return strings.ToLower(recipient)
+	}
+	return recipient
+}
+
+// ResolveRecipients подставляет chat_id в telegram-запросы с user_id (в том числе в каналы доставки
+// уведомления с несколькими каналами). Если пользователь не связал чат, chat_id остается пустым
+// и запрос не проходит валидацию.
+func (s *notificationService) ResolveRecipients(ctx context.Context, req *models.CreateNotificationRequest) error {
+	if req.Type == models.NotificationTypeTelegram && req.ChatID == "" && req.UserID != "" {
+		link, err := s.repo.GetTelegramLink(ctx, req.UserID)
+		switch {
+		case err == nil:
+			req.ChatID = link.ChatID
+		case !errors.Is(err, repository.ErrNotFound):
+			return err
+		}
+	}
+	for i := range req.Targets {
+		if err := s.ResolveRecipients(ctx, &req.Targets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateTelegramLinkToken выдает пользователю одноразовый токен для deep link /start <token>.
+// Токен действует TelegramLinkTTL.
+func (s *notificationService) CreateTelegramLinkToken(ctx context.Context, userID string) (*models.TelegramLinkToken, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	t := &models.TelegramLinkToken{
+		// Telegram допускает в параметре start только A-Z, a-z, 0-9, _ и -
+		Token:     base64.RawURLEncoding.EncodeToString(b),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(TelegramLinkTTL).UTC(),
+	}
+	if err := s.repo.CreateTelegramLinkToken(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LinkTelegramChat связывает чат с пользователем по токену deep link.
+func (s *notificationService) LinkTelegramChat(ctx context.Context, token string, link *models.TelegramLink) error {
+	return s.repo.LinkTelegramChat(ctx, token, link)
+}
+
+// GetTelegramLink возвращает чат Telegram пользователя.
+func (s *notificationService) GetTelegramLink(ctx context.Context, userID string) (*models.TelegramLink, error) {
+	return s.repo.GetTelegramLink(ctx, userID)
+}
+
+// DeleteTelegramLink отвязывает чат Telegram от пользователя.
+func (s *notificationService) DeleteTelegramLink(ctx context.Context, userID string) error {
+	return s.repo.DeleteTelegramLink(ctx, userID)
+}
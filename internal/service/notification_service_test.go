@@ -6,12 +6,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/PavelBradnitski/WbTechL3.1/internal/channel"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+// testChannels реестр встроенных каналов без отправителей.
+var testChannels = channel.NewRegistry(channel.Email(nil), channel.Telegram(nil))
+
 // MockNotificationRepository is a mock implementation of the NotificationRepository interface.
 type MockNotificationRepository struct {
 	mock.Mock
@@ -34,11 +40,26 @@ func (m *MockNotificationRepository) GetByID(ctx context.Context, id string) (*m
 }
 
 // GetAll mocks the GetAll method.
-func (m *MockNotificationRepository) GetAll(ctx context.Context) ([]*models.Notification, error) {
-	args := m.Called(ctx)
+func (m *MockNotificationRepository) GetAll(ctx context.Context, filter models.NotificationFilter) ([]*models.Notification, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]*models.Notification), args.Error(1)
 }
 
+// Export mocks the Export method.
+func (m *MockNotificationRepository) Export(ctx context.Context, filter models.NotificationFilter, fn func(n *models.Notification) error) error {
+	args := m.Called(ctx, filter, fn)
+	return args.Error(0)
+}
+
+// CountByStatus mocks the CountByStatus method.
+func (m *MockNotificationRepository) CountByStatus(ctx context.Context, filter models.NotificationFilter) (map[models.Status]int, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[models.Status]int), args.Error(1)
+}
+
 // Cancel mocks the Cancel method.
 func (m *MockNotificationRepository) Cancel(ctx context.Context, id string) error {
 	args := m.Called(ctx, id)
@@ -63,9 +84,174 @@ func (m *MockNotificationRepository) IncrementRetries(ctx context.Context, id st
 	return args.Error(0)
 }
 
+// RecordSegments mocks the RecordSegments method.
+func (m *MockNotificationRepository) RecordSegments(ctx context.Context, id string, segments int) error {
+	args := m.Called(ctx, id, segments)
+	return args.Error(0)
+}
+
+// RecordFailure mocks the RecordFailure method.
+func (m *MockNotificationRepository) RecordFailure(ctx context.Context, id, class, reason string) error {
+	args := m.Called(ctx, id, class, reason)
+	return args.Error(0)
+}
+
+// RecordProviderMessageID mocks the RecordProviderMessageID method.
+func (m *MockNotificationRepository) RecordProviderMessageID(ctx context.Context, id, messageID string) error {
+	args := m.Called(ctx, id, messageID)
+	return args.Error(0)
+}
+
+// RecordBounce mocks the RecordBounce method.
+func (m *MockNotificationRepository) RecordBounce(ctx context.Context, messageID, class, reason string) (*models.Notification, error) {
+	args := m.Called(ctx, messageID, class, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+// SyncGroup mocks the SyncGroup method.
+func (m *MockNotificationRepository) SyncGroup(ctx context.Context, parentID string) (models.Status, error) {
+	args := m.Called(ctx, parentID)
+	return args.Get(0).(models.Status), args.Error(1)
+}
+
+// PendingGroups mocks the PendingGroups method.
+func (m *MockNotificationRepository) PendingGroups(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// Reschedule mocks the Reschedule method.
+func (m *MockNotificationRepository) Reschedule(ctx context.Context, id string, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+// Suppress mocks the Suppress method.
+func (m *MockNotificationRepository) Suppress(ctx context.Context, s *models.Suppression) error {
+	args := m.Called(ctx, s)
+	return args.Error(0)
+}
+
+// GetSuppression mocks the GetSuppression method.
+func (m *MockNotificationRepository) GetSuppression(ctx context.Context, t models.NotificationType, recipient string) (*models.Suppression, error) {
+	args := m.Called(ctx, t, recipient)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Suppression), args.Error(1)
+}
+
+// SetPreference mocks the SetPreference method.
+func (m *MockNotificationRepository) SetPreference(ctx context.Context, p *models.Preference) error {
+	args := m.Called(ctx, p)
+	return args.Error(0)
+}
+
+// GetPreferences mocks the GetPreferences method.
+func (m *MockNotificationRepository) GetPreferences(ctx context.Context, t models.NotificationType, recipient string) ([]models.Preference, error) {
+	args := m.Called(ctx, t, recipient)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Preference), args.Error(1)
+}
+
+// Unsuppress mocks the Unsuppress method.
+func (m *MockNotificationRepository) Unsuppress(ctx context.Context, t models.NotificationType, recipient string) error {
+	args := m.Called(ctx, t, recipient)
+	return args.Error(0)
+}
+
+// CreateTelegramLinkToken mocks the CreateTelegramLinkToken method.
+func (m *MockNotificationRepository) CreateTelegramLinkToken(ctx context.Context, t *models.TelegramLinkToken) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+// LinkTelegramChat mocks the LinkTelegramChat method.
+func (m *MockNotificationRepository) LinkTelegramChat(ctx context.Context, token string, link *models.TelegramLink) error {
+	args := m.Called(ctx, token, link)
+	return args.Error(0)
+}
+
+// GetTelegramLink mocks the GetTelegramLink method.
+func (m *MockNotificationRepository) GetTelegramLink(ctx context.Context, userID string) (*models.TelegramLink, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TelegramLink), args.Error(1)
+}
+
+// DeleteTelegramLink mocks the DeleteTelegramLink method.
+func (m *MockNotificationRepository) DeleteTelegramLink(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// CreateTemplate mocks the CreateTemplate method.
+func (m *MockNotificationRepository) CreateTemplate(ctx context.Context, t *models.Template) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+// UpdateTemplate mocks the UpdateTemplate method.
+func (m *MockNotificationRepository) UpdateTemplate(ctx context.Context, t *models.Template) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+// GetTemplate mocks the GetTemplate method.
+func (m *MockNotificationRepository) GetTemplate(ctx context.Context, id string, version int) (*models.Template, error) {
+	args := m.Called(ctx, id, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Template), args.Error(1)
+}
+
+// GetTemplateVersion mocks the GetTemplateVersion method.
+func (m *MockNotificationRepository) GetTemplateVersion(ctx context.Context, id string, version int) (*models.Template, error) {
+	args := m.Called(ctx, id, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Template), args.Error(1)
+}
+
+// ListTemplates mocks the ListTemplates method.
+func (m *MockNotificationRepository) ListTemplates(ctx context.Context) ([]models.Template, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Template), args.Error(1)
+}
+
+// ListTemplateVersions mocks the ListTemplateVersions method.
+func (m *MockNotificationRepository) ListTemplateVersions(ctx context.Context, id string) ([]models.Template, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Template), args.Error(1)
+}
+
+// DeleteTemplate mocks the DeleteTemplate method.
+func (m *MockNotificationRepository) DeleteTemplate(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func TestNotificationServiceCreate(t *testing.T) {
 	mockRepo := new(MockNotificationRepository)
-	service := NewNotificationService(mockRepo)
+	service := NewNotificationService(mockRepo, testChannels)
 
 	req := &models.CreateNotificationRequest{
 		ChatID:      "user123",
@@ -89,7 +275,7 @@ func TestNotificationServiceCreate(t *testing.T) {
 
 func TestNotificationServiceGet(t *testing.T) {
 	mockRepo := new(MockNotificationRepository)
-	service := NewNotificationService(mockRepo)
+	service := NewNotificationService(mockRepo, testChannels)
 
 	notificationID := "123"
 	expectedNotification := &models.Notification{
@@ -99,7 +285,7 @@ func TestNotificationServiceGet(t *testing.T) {
 		Status:      models.StatusScheduled,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
-		EmailNotification: &models.EmailNotification{
+		Payload: &models.EmailNotification{
 			Email:   "test@example.com",
 			Message: "Test message",
 			Subject: "Test subject",
@@ -117,7 +303,7 @@ func TestNotificationServiceGet(t *testing.T) {
 
 func TestNotificationServiceGetAll(t *testing.T) {
 	mockRepo := new(MockNotificationRepository)
-	service := NewNotificationService(mockRepo)
+	service := NewNotificationService(mockRepo, testChannels)
 
 	expectedNotifications := []*models.Notification{
 		{
@@ -127,7 +313,7 @@ func TestNotificationServiceGetAll(t *testing.T) {
 			Status:      models.StatusScheduled,
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
-			EmailNotification: &models.EmailNotification{
+			Payload: &models.EmailNotification{
 				Email:   "test1@example.com",
 				Message: "Test message 1",
 				Subject: "Test subject 1",
@@ -140,16 +326,17 @@ func TestNotificationServiceGetAll(t *testing.T) {
 			Status:      models.StatusScheduled,
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
-			TelegramNotification: &models.TelegramNotification{
+			Payload: &models.TelegramNotification{
 				ChatID:  "user2",
 				Message: "Test message 2",
 			},
 		},
 	}
 
-	mockRepo.On("GetAll", mock.Anything).Return(expectedNotifications, nil)
+	filter := models.NotificationFilter{Status: models.StatusScheduled}
+	mockRepo.On("GetAll", mock.Anything, filter).Return(expectedNotifications, nil)
 
-	notifications, err := service.GetAll(context.Background())
+	notifications, err := service.GetAll(context.Background(), filter)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedNotifications, notifications)
@@ -158,7 +345,7 @@ func TestNotificationServiceGetAll(t *testing.T) {
 
 func TestNotificationServiceCancel(t *testing.T) {
 	mockRepo := new(MockNotificationRepository)
-	service := NewNotificationService(mockRepo)
+	service := NewNotificationService(mockRepo, testChannels)
 
 	notificationID := "123"
 
@@ -172,7 +359,7 @@ func TestNotificationServiceCancel(t *testing.T) {
 
 func TestNotificationServiceReservePending(t *testing.T) {
 	mockRepo := new(MockNotificationRepository)
-	service := NewNotificationService(mockRepo)
+	service := NewNotificationService(mockRepo, testChannels)
 
 	limit := 10
 	expectedNotifications := []*models.Notification{
@@ -183,7 +370,7 @@ func TestNotificationServiceReservePending(t *testing.T) {
 			Status:      models.StatusScheduled,
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
-			EmailNotification: &models.EmailNotification{
+			Payload: &models.EmailNotification{
 				Email:   "test1@example.com",
 				Message: "Test message 1",
 				Subject: "Test subject 1",
@@ -202,7 +389,7 @@ func TestNotificationServiceReservePending(t *testing.T) {
 
 func TestNotificationServiceUpdateStatus(t *testing.T) {
 	mockRepo := new(MockNotificationRepository)
-	service := NewNotificationService(mockRepo)
+	service := NewNotificationService(mockRepo, testChannels)
 
 	notificationID := "123"
 	newStatus := models.StatusSent
@@ -217,7 +404,7 @@ func TestNotificationServiceUpdateStatus(t *testing.T) {
 
 func TestNotificationServiceIncrementRetries(t *testing.T) {
 	mockRepo := new(MockNotificationRepository)
-	service := NewNotificationService(mockRepo)
+	service := NewNotificationService(mockRepo, testChannels)
 
 	notificationID := "123"
 
@@ -233,7 +420,7 @@ func TestNotificationServiceIncrementRetries(t *testing.T) {
 
 func TestNotificationServiceGetError(t *testing.T) {
 	mockRepo := new(MockNotificationRepository)
-	service := NewNotificationService(mockRepo)
+	service := NewNotificationService(mockRepo, testChannels)
 
 	notificationID := "123"
 	expectedError := errors.New("not found")
@@ -250,7 +437,7 @@ func TestNotificationServiceGetError(t *testing.T) {
 
 func TestNotificationServiceCreateError(t *testing.T) {
 	mockRepo := new(MockNotificationRepository)
-	service := NewNotificationService(mockRepo)
+	service := NewNotificationService(mockRepo, testChannels)
 
 	req := &models.CreateNotificationRequest{
 		ChatID:      "user123",
@@ -271,3 +458,126 @@ func TestNotificationServiceCreateError(t *testing.T) {
 	assert.Equal(t, expectedError, err)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestNotificationServiceCreateUnsupportedType(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, testChannels)
+
+	req := &models.CreateNotificationRequest{
+		Type:        "pigeon",
+		Message:     "Test message",
+		ScheduledAt: time.Now().Add(time.Hour),
+	}
+
+	id, err := service.Create(context.Background(), req)
+
+	assert.ErrorContains(t, err, "unsupported notification type")
+	assert.Empty(t, id)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestNotificationServiceOptedOut(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, testChannels)
+	ctx := context.Background()
+
+	newsletter := &models.Notification{
+		Type:     models.NotificationTypeEmail,
+		Category: "newsletter",
+		Payload:  &models.EmailNotification{Email: "User@Example.com", Subject: "Новости"},
+	}
+	billing := &models.Notification{
+		Type:     models.NotificationTypeEmail,
+		Category: "billing",
+		Payload:  &models.EmailNotification{Email: "user@example.com", Subject: "Счет"},
+	}
+	// получатель отписан от канала, но подписан на счета
+	mockRepo.On("GetPreferences", ctx, models.NotificationTypeEmail, "user@example.com").Return([]models.Preference{
+		{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Enabled: false},
+		{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Category: "billing", Enabled: true},
+	}, nil)
+
+	pref, err := service.OptedOut(ctx, newsletter)
+	assert.NoError(t, err)
+	if assert.NotNil(t, pref) {
+		assert.Equal(t, "", pref.Category)
+	}
+
+	pref, err = service.OptedOut(ctx, billing)
+	assert.NoError(t, err)
+	assert.Nil(t, pref)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestNotificationServiceResolveTemplate(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, testChannels)
+	ctx := context.Background()
+
+	tmpl := &models.Template{ID: "tmpl-1", Name: "order", Version: 4}
+	mockRepo.On("GetTemplate", ctx, "tmpl-1", 0).Return(tmpl, nil)
+	mockRepo.On("GetTemplate", ctx, "deleted", 0).Return(nil, repository.ErrNotFound)
+
+	req := &models.CreateNotificationRequest{
+		Type: models.NotificationTypeMulti,
+		Targets: []models.CreateNotificationRequest{
+			{Type: models.NotificationTypeEmail, TemplateID: "tmpl-1"},
+			{Type: models.NotificationTypeTelegram, TemplateID: "deleted"},
+		},
+	}
+	assert.NoError(t, service.ResolveTemplate(ctx, req))
+	// версия закрепляется за уведомлением, чтобы правка шаблона не меняла его текст
+	assert.Equal(t, tmpl, req.Targets[0].Template)
+	assert.Equal(t, 4, req.Targets[0].TemplateVersion)
+	assert.Nil(t, req.Targets[1].Template)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestNotificationServiceRenderTemplate(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, testChannels)
+	ctx := context.Background()
+
+	mockRepo.On("GetTemplateVersion", ctx, "tmpl-1", 2).Return(&models.Template{ID: "tmpl-1", Version: 2, Channels: map[models.NotificationType]models.TemplateContent{
+		models.NotificationTypeEmail: {Subject: "Заказ {{.order}}", HTML: "<p>Здравствуйте, {{.name}}!</p>"},
+	}}, nil)
+
+	n := &models.Notification{
+		Type:            models.NotificationTypeEmail,
+		TemplateID:      "tmpl-1",
+		TemplateVersion: 2,
+		Variables:       map[string]any{"order": 42, "name": "<Анна>"},
+		Payload:         &models.EmailNotification{Email: "user@example.com"},
+	}
+	assert.NoError(t, service.RenderTemplate(ctx, n))
+	assert.Equal(t, "Заказ 42", models.PayloadOf[models.EmailNotification](n).Subject)
+	assert.Equal(t, "<p>Здравствуйте, &lt;Анна&gt;!</p>", models.PayloadOf[models.EmailNotification](n).HTML)
+	assert.Equal(t, "Здравствуйте, <Анна>!", models.PayloadOf[models.EmailNotification](n).Message)
+
+	// переменная пропала или канала нет в шаблоне — повтор отправки не поможет
+	n.Variables = map[string]any{"order": 42}
+	err := service.RenderTemplate(ctx, n)
+	assert.Error(t, err)
+	assert.Equal(t, sender.ErrorClassPermanent, sender.Classify(err))
+
+	n = &models.Notification{Type: models.NotificationTypeTelegram, TemplateID: "tmpl-1", TemplateVersion: 2}
+	err = service.RenderTemplate(ctx, n)
+	assert.Error(t, err)
+	assert.Equal(t, sender.ErrorClassPermanent, sender.Classify(err))
+
+	// переменные в тексте с разметкой экранируются по parse_mode
+	mockRepo.On("GetTemplateVersion", ctx, "tmpl-2", 1).Return(&models.Template{ID: "tmpl-2", Version: 1, Channels: map[models.NotificationType]models.TemplateContent{
+		models.NotificationTypeTelegram: {Message: "*Заказ {{.order}}* на {{.total}} руб\\."},
+	}}, nil)
+	n = &models.Notification{
+		Type:            models.NotificationTypeTelegram,
+		TemplateID:      "tmpl-2",
+		TemplateVersion: 1,
+		Variables:       map[string]any{"order": "A-1", "total": 99.5},
+		Payload: &models.TelegramNotification{ChatID: "42",
+			TelegramOptions: models.TelegramOptions{ParseMode: models.TelegramParseModeMarkdownV2}},
+	}
+	assert.NoError(t, service.RenderTemplate(ctx, n))
+	assert.Equal(t, "*Заказ A\\-1* на 99\\.5 руб\\.", models.PayloadOf[models.TelegramNotification](n).Message)
+	mockRepo.AssertExpectations(t)
+}
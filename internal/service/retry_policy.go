@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+
+	"github.com/wb-go/wbf/retry"
+)
+
+// retryEnvPrefix префикс переменных окружения с политиками повторов.
+const retryEnvPrefix = "RETRY_"
+
+// RetryPolicies стратегии повторов отправки по классам ошибок, общие и для отдельных каналов.
+//
+// Attempts — сколько всего попыток делается, прежде чем уведомление завершается неудачей;
+// между попытками воркер ждет Delay, умножая задержку на Backoff после каждой попытки.
+// Для класса rate_limited ожидание задает провайдер: уведомление переносится на время
+// RetryAfter, а Attempts ограничивает общее число неудачных попыток уведомления.
+type RetryPolicies struct {
+	defaults map[sender.ErrorClass]retry.Strategy
+	channels map[models.NotificationType]map[sender.ErrorClass]retry.Strategy
+}
+
+// DefaultRetryPolicies возвращает политики по умолчанию: временные ошибки повторяются трижды
+// с экспоненциальной задержкой, неизвестные — дважды, постоянные не повторяются,
+// а ограничение частоты переносит отправку до 10 раз.
+func DefaultRetryPolicies() *RetryPolicies {
+	return &RetryPolicies{
+		defaults: map[sender.ErrorClass]retry.Strategy{
+			sender.ErrorClassTransient:   {Attempts: 3, Delay: time.Second, Backoff: 2},
+			sender.ErrorClassUnknown:     {Attempts: 2, Delay: time.Second, Backoff: 2},
+			sender.ErrorClassPermanent:   {Attempts: 1},
+			sender.ErrorClassRateLimited: {Attempts: 10},
+		},
+		channels: map[models.NotificationType]map[sender.ErrorClass]retry.Strategy{},
+	}
+}
+
+// Set задает стратегию для класса ошибок канала t; пустой t — для всех каналов без своей стратегии.
+func (p *RetryPolicies) Set(t models.NotificationType, class sender.ErrorClass, s retry.Strategy) {
+	if t == "" {
+		p.defaults[class] = s
+		return
+	}
+	if p.channels[t] == nil {
+		p.channels[t] = map[sender.ErrorClass]retry.Strategy{}
+	}
+	p.channels[t][class] = s
+}
+
+// Get возвращает стратегию для класса ошибок канала t. Для неизвестного класса
+// используется стратегия класса unknown.
+func (p *RetryPolicies) Get(t models.NotificationType, class sender.ErrorClass) retry.Strategy {
+	if s, ok := p.channels[t][class]; ok {
+		return s
+	}
+	if s, ok := p.defaults[class]; ok {
+		return s
+	}
+	if s, ok := p.channels[t][sender.ErrorClassUnknown]; ok {
+		return s
+	}
+	return p.defaults[sender.ErrorClassUnknown]
+}
+
+// retryDelay возвращает задержку перед следующей попыткой после attempt неудачных.
+func retryDelay(s retry.Strategy, attempt int) time.Duration {
+	delay := float64(s.Delay)
+	for i := 1; i < attempt; i++ {
+		delay *= s.Backoff
+	}
+	return time.Duration(delay)
+}
+
+// LoadRetryPolicies дополняет политики по умолчанию переменными окружения вида
+// RETRY_<CLASS>=<attempts>[,<delay>[,<backoff>]] для всех каналов и RETRY_<CHANNEL>_<CLASS> для одного
+// канала, например RETRY_TRANSIENT=5,2s,2 или RETRY_EMAIL_UNKNOWN=1. environ — строки "KEY=value",
+// как в os.Environ(). Некорректные переменные пропускаются и возвращаются ошибкой.
+func LoadRetryPolicies(environ []string) (*RetryPolicies, error) {
+	p := DefaultRetryPolicies()
+	var errs []error
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, retryEnvPrefix) {
+			continue
+		}
+		t, class, ok := parseRetryKey(strings.TrimPrefix(key, retryEnvPrefix))
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown error class, expected one of %v", key, sender.ErrorClasses))
+			continue
+		}
+		s, err := parseRetryStrategy(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		p.Set(t, class, s)
+	}
+	return p, errors.Join(errs...)
+}
+
+// parseRetryKey разбирает имя переменной без префикса: <CLASS> или <CHANNEL>_<CLASS>.
+func parseRetryKey(key string) (models.NotificationType, sender.ErrorClass, bool) {
+	for _, class := range sender.ErrorClasses {
+		suffix := strings.ToUpper(string(class))
+		if key == suffix {
+			return "", class, true
+		}
+		if channel, ok := strings.CutSuffix(key, "_"+suffix); ok && channel != "" {
+			return models.NotificationType(strings.ToLower(channel)), class, true
+		}
+	}
+	return "", "", false
+}
+
+// parseRetryStrategy разбирает значение "<attempts>[,<delay>[,<backoff>]]".
+// Без задержки повтор выполняется сразу, без множителя задержка не растет.
+func parseRetryStrategy(value string) (retry.Strategy, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > 3 {
+		return retry.Strategy{}, errors.New("expected <attempts>[,<delay>[,<backoff>]]")
+	}
+	s := retry.Strategy{Backoff: 1}
+	attempts, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || attempts < 1 {
+		return retry.Strategy{}, fmt.Errorf("invalid attempts %q, expected a positive integer", parts[0])
+	}
+	s.Attempts = attempts
+	if len(parts) > 1 {
+		delay, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || delay < 0 {
+			return retry.Strategy{}, fmt.Errorf("invalid delay %q", parts[1])
+		}
+		s.Delay = delay
+	}
+	if len(parts) > 2 {
+		backoff, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+		if err != nil || backoff < 1 {
+			return retry.Strategy{}, fmt.Errorf("invalid backoff %q, expected a number >= 1", parts[2])
+		}
+		s.Backoff = backoff
+	}
+	return s, nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/stretchr/testify/assert"
+	"github.com/wb-go/wbf/retry"
+)
+
+func TestLoadRetryPolicies(t *testing.T) {
+	policies, err := LoadRetryPolicies([]string{
+		"PATH=/usr/bin",
+		"RETRY_TRANSIENT=5,2s,3",
+		"RETRY_EMAIL_PERMANENT=2,1m",
+		"RETRY_TELEGRAM_RATE_LIMITED=20",
+		"RETRY_SOMETIMES=1",
+		"RETRY_UNKNOWN=0",
+	})
+
+	assert.ErrorContains(t, err, "RETRY_SOMETIMES: unknown error class")
+	assert.ErrorContains(t, err, `RETRY_UNKNOWN: invalid attempts "0"`)
+
+	assert.Equal(t, retry.Strategy{Attempts: 5, Delay: 2 * time.Second, Backoff: 3}, policies.Get(models.NotificationTypeSMS, sender.ErrorClassTransient))
+	assert.Equal(t, retry.Strategy{Attempts: 2, Delay: time.Minute, Backoff: 1}, policies.Get(models.NotificationTypeEmail, sender.ErrorClassPermanent))
+	assert.Equal(t, retry.Strategy{Attempts: 1}, policies.Get(models.NotificationTypeSMS, sender.ErrorClassPermanent))
+	assert.Equal(t, 20, policies.Get(models.NotificationTypeTelegram, sender.ErrorClassRateLimited).Attempts)
+	assert.Equal(t, 10, policies.Get(models.NotificationTypeEmail, sender.ErrorClassRateLimited).Attempts)
+	// некорректная переменная не меняет политику по умолчанию
+	assert.Equal(t, 2, policies.Get(models.NotificationTypeEmail, sender.ErrorClassUnknown).Attempts)
+	assert.Equal(t, 2, policies.Get(models.NotificationTypeEmail, "").Attempts)
+}
+
+func TestRetryDelay(t *testing.T) {
+	s := retry.Strategy{Attempts: 4, Delay: time.Second, Backoff: 2}
+
+	assert.Equal(t, time.Second, retryDelay(s, 1))
+	assert.Equal(t, 2*time.Second, retryDelay(s, 2))
+	assert.Equal(t, 4*time.Second, retryDelay(s, 3))
+}
@@ -20,7 +20,7 @@ type Scheduler interface {
 
 // NotificationScheduler отвечает за периодическую проверку базы данных на наличие новых уведомлений
 type NotificationScheduler struct {
-	svc         NotificationService
+	svc         DeliveryService
 	publisher   *rabbitmq.Publisher
 	statusCache *statuscache.Cache
 	queueName   string
@@ -30,7 +30,7 @@ type NotificationScheduler struct {
 }
 
 // NewNotificationScheduler создает новый экземпляр NotificationScheduler.
-func NewNotificationScheduler(svc NotificationService, conn *rabbitmq.Connection, statusCache *statuscache.Cache, queueName string, interval time.Duration) (*NotificationScheduler, error) {
+func NewNotificationScheduler(svc DeliveryService, conn *rabbitmq.Connection, statusCache *statuscache.Cache, queueName string, interval time.Duration) (*NotificationScheduler, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	ch, err := conn.Channel()
@@ -79,6 +79,7 @@ func (s *NotificationScheduler) Start() {
 		for {
 			select {
 			case <-ticker.C:
+				s.syncGroups()
 				s.processPending()
 			case <-s.ctx.Done():
 				ticker.Stop()
@@ -93,6 +94,26 @@ func (s *NotificationScheduler) Stop() {
 	s.cancel()
 }
 
+// syncGroups пересчитывает статусы уведомлений с несколькими каналами и в режиме fallback
+// запускает следующий канал, если текущий не доставил уведомление за отведенное время.
+func (s *NotificationScheduler) syncGroups() {
+	ids, err := s.svc.PendingGroups(s.ctx)
+	if err != nil {
+		log.Println("scheduler: failed to get pending groups:", err)
+		return
+	}
+	for _, id := range ids {
+		status, err := s.svc.SyncGroup(s.ctx, id)
+		if err != nil {
+			log.Printf("scheduler: failed to sync group id=%v: %v", id, err)
+			continue
+		}
+		if err := s.statusCache.SetStatus(s.ctx, id, status); err != nil {
+			log.Printf("failed to set status in redis for id=%v: %v", id, err)
+		}
+	}
+}
+
 func (s *NotificationScheduler) processPending() {
 	// резервируем пачку уведомлений
 	notifications, err := s.svc.ReservePending(s.ctx, 50)
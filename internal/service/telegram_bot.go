@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+)
+
+// telegramPollRetryDelay пауза перед повтором getUpdates или обновления после ошибки.
+const telegramPollRetryDelay = 5 * time.Second
+
+// Ответы бота на команды.
+const (
+	telegramBotHelpText    = "Чтобы получать уведомления, откройте ссылку для подключения Telegram в личном кабинете."
+	telegramBotLinkedText  = "Готово! Уведомления будут приходить в этот чат. Чтобы отписаться, отправьте /stop."
+	telegramBotExpiredText = "Ссылка недействительна или устарела. Получите новую ссылку в личном кабинете."
+	telegramBotStoppedText = "Уведомления отключены. Чтобы снова их получать, отправьте /start."
+)
+
+// TelegramBotAPI методы Bot API, которые использует обработчик обновлений бота.
+type TelegramBotAPI interface {
+	GetUpdates(ctx context.Context, offset int64) ([]models.TelegramUpdate, error)
+	DeleteWebhook(ctx context.Context) error
+	SendText(ctx context.Context, chatID, text string) error
+}
+
+// TelegramBot обрабатывает обновления бота: связывает чат с пользователем клиента по /start <token>
+// и добавляет чат в список подавления по /stop или после блокировки бота.
+type TelegramBot struct {
+	svc NotificationService
+	api TelegramBotAPI
+	// retryDelay пауза перед повтором getUpdates или необработанного обновления
+	retryDelay time.Duration
+}
+
+// NewTelegramBot создает обработчик обновлений бота.
+func NewTelegramBot(svc NotificationService, api TelegramBotAPI) *TelegramBot {
+	return &TelegramBot{svc: svc, api: api, retryDelay: telegramPollRetryDelay}
+}
+
+// Poll получает обновления методом getUpdates, пока не отменен ctx. Перед началом удаляет вебхук:
+// пока он установлен, Bot API не отдает обновления через getUpdates. Обновление, которое не удалось
+// обработать (например, из-за ошибки БД), запрашивается повторно, как и при ответе 500 на вебхук.
+func (b *TelegramBot) Poll(ctx context.Context) {
+	if err := b.api.DeleteWebhook(ctx); err != nil {
+		log.Printf("telegram bot: failed to delete webhook: %v", err)
+	}
+	var offset int64
+	for ctx.Err() == nil {
+		updates, err := b.api.GetUpdates(ctx, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("telegram bot: getUpdates failed: %v", err)
+			b.wait(ctx)
+			continue
+		}
+		for i := range updates {
+			if err := b.HandleUpdate(ctx, &updates[i]); err != nil {
+				log.Printf("telegram bot: failed to handle update %d: %v", updates[i].UpdateID, err)
+				// offset не сдвигается: следующий getUpdates вернет это обновление снова
+				b.wait(ctx)
+				break
+			}
+			offset = updates[i].UpdateID + 1
+		}
+	}
+}
+
+// wait ждет retryDelay или отмены ctx.
+func (b *TelegramBot) wait(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(b.retryDelay):
+	}
+}
+
+// HandleUpdate обрабатывает одно обновление. Учитываются только личные чаты с ботом:
+// команды /start и /stop и блокировка бота пользователем.
+func (b *TelegramBot) HandleUpdate(ctx context.Context, u *models.TelegramUpdate) error {
+	switch {
+	case u.MyChatMember != nil && u.MyChatMember.Chat.Type == "private":
+		if u.MyChatMember.NewChatMember.Status == "kicked" {
+			return b.optOut(ctx, telegramChatID(u.MyChatMember.Chat), "bot was blocked by the user")
+		}
+	case u.Message != nil && u.Message.Chat.Type == "private":
+		return b.handleCommand(ctx, u.Message)
+	}
+	return nil
+}
+
+// handleCommand выполняет команду из сообщения пользователя.
+func (b *TelegramBot) handleCommand(ctx context.Context, m *models.TelegramMessage) error {
+	command, arg, _ := strings.Cut(strings.TrimSpace(m.Text), " ")
+	command, _, _ = strings.Cut(command, "@")
+	chatID := telegramChatID(m.Chat)
+
+	switch command {
+	case "/start":
+		// пользователь снова разрешил боту писать
+		if err := b.svc.Unsuppress(ctx, models.NotificationTypeTelegram, chatID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		token := strings.TrimSpace(arg)
+		if token == "" {
+			b.reply(ctx, chatID, telegramBotHelpText)
+			return nil
+		}
+		err := b.svc.LinkTelegramChat(ctx, token, &models.TelegramLink{ChatID: chatID, Username: m.Chat.Username})
+		if errors.Is(err, repository.ErrNotFound) {
+			b.reply(ctx, chatID, telegramBotExpiredText)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b.reply(ctx, chatID, telegramBotLinkedText)
+	case "/stop":
+		if err := b.optOut(ctx, chatID, "user sent /stop"); err != nil {
+			return err
+		}
+		b.reply(ctx, chatID, telegramBotStoppedText)
+	}
+	return nil
+}
+
+// optOut добавляет чат в список подавления: воркер больше не отправляет в него уведомления.
+func (b *TelegramBot) optOut(ctx context.Context, chatID, reason string) error {
+	return b.svc.Suppress(ctx, &models.Suppression{Type: models.NotificationTypeTelegram, Recipient: chatID, Reason: reason})
+}
+
+// reply отвечает пользователю; ошибка ответа не мешает обработке команды.
+func (b *TelegramBot) reply(ctx context.Context, chatID, text string) {
+	if err := b.api.SendText(ctx, chatID, text); err != nil {
+		log.Printf("telegram bot: failed to reply to chat %s: %v", chatID, err)
+	}
+}
+
+// telegramChatID возвращает идентификатор чата в том виде, в котором он хранится в уведомлениях.
+func telegramChatID(c models.TelegramChat) string {
+	return strconv.FormatInt(c.ID, 10)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeTelegramBotAPI запоминает ответы бота по чатам и отдает обновления с номером не меньше offset.
+// После того как все обновления запрошены, вызывает stop.
+type fakeTelegramBotAPI struct {
+	replies map[string]string
+	updates []models.TelegramUpdate
+	offsets []int64
+	stop    func()
+}
+
+func (f *fakeTelegramBotAPI) GetUpdates(ctx context.Context, offset int64) ([]models.TelegramUpdate, error) {
+	f.offsets = append(f.offsets, offset)
+	var updates []models.TelegramUpdate
+	for _, u := range f.updates {
+		if u.UpdateID >= offset {
+			updates = append(updates, u)
+		}
+	}
+	if len(updates) == 0 && f.stop != nil {
+		f.stop()
+	}
+	return updates, nil
+}
+
+func (f *fakeTelegramBotAPI) DeleteWebhook(ctx context.Context) error { return nil }
+
+func (f *fakeTelegramBotAPI) SendText(ctx context.Context, chatID, text string) error {
+	f.replies[chatID] = text
+	return nil
+}
+
+func privateMessage(chatID int64, text string) *models.TelegramUpdate {
+	return &models.TelegramUpdate{Message: &models.TelegramMessage{
+		Chat: models.TelegramChat{ID: chatID, Type: "private", Username: "alice"},
+		Text: text,
+	}}
+}
+
+func TestTelegramBotHandleUpdate(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	api := &fakeTelegramBotAPI{replies: map[string]string{}}
+	bot := NewTelegramBot(NewNotificationService(mockRepo, testChannels), api)
+	ctx := context.Background()
+
+	mockRepo.On("Unsuppress", ctx, models.NotificationTypeTelegram, "42").Return(repository.ErrNotFound)
+	mockRepo.On("LinkTelegramChat", ctx, "token-1", &models.TelegramLink{ChatID: "42", Username: "alice"}).Return(nil)
+	mockRepo.On("Unsuppress", ctx, models.NotificationTypeTelegram, "43").Return(nil)
+	mockRepo.On("LinkTelegramChat", ctx, "expired", mock.Anything).Return(repository.ErrNotFound)
+	mockRepo.On("Suppress", ctx, &models.Suppression{Type: models.NotificationTypeTelegram, Recipient: "44", Reason: "user sent /stop"}).Return(nil)
+	mockRepo.On("Suppress", ctx, &models.Suppression{Type: models.NotificationTypeTelegram, Recipient: "45", Reason: "bot was blocked by the user"}).Return(nil)
+
+	assert.NoError(t, bot.HandleUpdate(ctx, privateMessage(42, "/start token-1")))
+	assert.Equal(t, telegramBotLinkedText, api.replies["42"])
+
+	assert.NoError(t, bot.HandleUpdate(ctx, privateMessage(43, "/start expired")))
+	assert.Equal(t, telegramBotExpiredText, api.replies["43"])
+
+	assert.NoError(t, bot.HandleUpdate(ctx, privateMessage(44, "/stop")))
+	assert.Equal(t, telegramBotStoppedText, api.replies["44"])
+
+	kicked := &models.TelegramUpdate{MyChatMember: &models.TelegramChatMemberUpdated{Chat: models.TelegramChat{ID: 45, Type: "private"}}}
+	kicked.MyChatMember.NewChatMember.Status = "kicked"
+	assert.NoError(t, bot.HandleUpdate(ctx, kicked))
+
+	// команды в группах игнорируются
+	group := privateMessage(-100, "/stop")
+	group.Message.Chat.Type = "group"
+	assert.NoError(t, bot.HandleUpdate(ctx, group))
+	assert.NotContains(t, api.replies, "-100")
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestNotificationServiceResolveRecipients(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockRepo, testChannels)
+	ctx := context.Background()
+
+	mockRepo.On("GetTelegramLink", ctx, "user-1").Return(&models.TelegramLink{UserID: "user-1", ChatID: "42"}, nil)
+	mockRepo.On("GetTelegramLink", ctx, "user-2").Return(nil, repository.ErrNotFound)
+
+	req := &models.CreateNotificationRequest{
+		Targets: []models.CreateNotificationRequest{
+			{Type: models.NotificationTypeTelegram, UserID: "user-1"},
+			{Type: models.NotificationTypeTelegram, UserID: "user-2"},
+			{Type: models.NotificationTypeTelegram, UserID: "user-3", ChatID: "7"},
+		},
+	}
+	assert.NoError(t, service.ResolveRecipients(ctx, req))
+	assert.Equal(t, "42", req.Targets[0].ChatID)
+	assert.Empty(t, req.Targets[1].ChatID)
+	assert.Equal(t, "7", req.Targets[2].ChatID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTelegramBotPollRetriesFailedUpdate(t *testing.T) {
+	mockRepo := new(MockNotificationRepository)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := privateMessage(42, "/start token-1")
+	start.UpdateID = 7
+	api := &fakeTelegramBotAPI{replies: map[string]string{}, updates: []models.TelegramUpdate{*start}, stop: cancel}
+	bot := NewTelegramBot(NewNotificationService(mockRepo, testChannels), api)
+	bot.retryDelay = 0
+
+	mockRepo.On("Unsuppress", ctx, models.NotificationTypeTelegram, "42").Return(repository.ErrNotFound)
+	mockRepo.On("LinkTelegramChat", ctx, "token-1", mock.Anything).Return(errors.New("connection refused")).Once()
+	mockRepo.On("LinkTelegramChat", ctx, "token-1", mock.Anything).Return(nil).Once()
+
+	bot.Poll(ctx)
+
+	// после ошибки БД обновление запрашивается повторно, и чат все-таки связывается
+	assert.Equal(t, []int64{0, 0, 8}, api.offsets)
+	assert.Equal(t, telegramBotLinkedText, api.replies["42"])
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/repository"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/templating"
+)
+
+// TemplateService описывает работу с шаблонами сообщений и их подстановку в уведомления.
+type TemplateService interface {
+	CreateTemplate(ctx context.Context, t *models.Template) error
+	UpdateTemplate(ctx context.Context, t *models.Template) error
+	GetTemplate(ctx context.Context, id string, version int) (*models.Template, error)
+	ListTemplates(ctx context.Context) ([]models.Template, error)
+	ListTemplateVersions(ctx context.Context, id string) ([]models.Template, error)
+	DeleteTemplate(ctx context.Context, id string) error
+	ResolveTemplate(ctx context.Context, req *models.CreateNotificationRequest) error
+	RenderTemplate(ctx context.Context, n *models.Notification) error
+}
+
+// CreateTemplate сохраняет новый шаблон сообщения.
+func (s *notificationService) CreateTemplate(ctx context.Context, t *models.Template) error {
+	return s.repo.CreateTemplate(ctx, t)
+}
+
+// UpdateTemplate сохраняет новую версию шаблона.
+func (s *notificationService) UpdateTemplate(ctx context.Context, t *models.Template) error {
+	return s.repo.UpdateTemplate(ctx, t)
+}
+
+// GetTemplate возвращает версию шаблона (0 — последнюю).
+func (s *notificationService) GetTemplate(ctx context.Context, id string, version int) (*models.Template, error) {
+	return s.repo.GetTemplate(ctx, id, version)
+}
+
+// ListTemplates возвращает последние версии шаблонов.
+func (s *notificationService) ListTemplates(ctx context.Context) ([]models.Template, error) {
+	return s.repo.ListTemplates(ctx)
+}
+
+// ListTemplateVersions возвращает все версии шаблона.
+func (s *notificationService) ListTemplateVersions(ctx context.Context, id string) ([]models.Template, error) {
+	return s.repo.ListTemplateVersions(ctx, id)
+}
+
+// DeleteTemplate удаляет шаблон.
+func (s *notificationService) DeleteTemplate(ctx context.Context, id string) error {
+	return s.repo.DeleteTemplate(ctx, id)
+}
+
+// ResolveTemplate загружает шаблон запроса (и его каналов доставки) для проверки переменных
+// и закрепляет за запросом версию шаблона, чтобы правка шаблона не меняла текст уже созданного
+// уведомления. Если шаблона нет, Template остается пустым и запрос не проходит валидацию.
+func (s *notificationService) ResolveTemplate(ctx context.Context, req *models.CreateNotificationRequest) error {
+	if req.TemplateID != "" {
+		t, err := s.repo.GetTemplate(ctx, req.TemplateID, req.TemplateVersion)
+		switch {
+		case err == nil:
+			req.Template = t
+			req.TemplateVersion = t.Version
+		case !errors.Is(err, repository.ErrNotFound):
+			return err
+		}
+	}
+	for i := range req.Targets {
+		if err := s.ResolveTemplate(ctx, &req.Targets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenderTemplate подставляет переменные уведомления в его версию шаблона и записывает
+// получившийся текст в уведомление; в сообщениях Telegram с разметкой переменные экранируются
+// по parse_mode. Уведомление без шаблона не меняется. Отсутствие шаблона или ошибка подстановки —
+// постоянная ошибка: повторная отправка ее не исправит.
+func (s *notificationService) RenderTemplate(ctx context.Context, n *models.Notification) error {
+	if n.TemplateID == "" {
+		return nil
+	}
+	t, err := s.repo.GetTemplateVersion(ctx, n.TemplateID, n.TemplateVersion)
+	if errors.Is(err, repository.ErrNotFound) {
+		return sender.WithClass(sender.ErrorClassPermanent,
+			fmt.Errorf("template %s version %d not found", n.TemplateID, n.TemplateVersion))
+	}
+	if err != nil {
+		return err
+	}
+	content, ok := t.Channels[n.Type]
+	if !ok {
+		return sender.WithClass(sender.ErrorClassPermanent,
+			fmt.Errorf("template %s version %d has no content for %s", n.TemplateID, t.Version, n.Type))
+	}
+	var escape func(string) string
+	if tg := models.PayloadOf[models.TelegramNotification](n); tg != nil {
+		escape = sender.TelegramEscaper(tg.ParseMode)
+	}
+	rendered, err := templating.Render(content, n.Variables, escape)
+	if err != nil {
+		return sender.WithClass(sender.ErrorClassPermanent, fmt.Errorf("error rendering template: %w", err))
+	}
+	if err := s.channels.SetContent(n, rendered); err != nil {
+		return sender.WithClass(sender.ErrorClassPermanent, err)
+	}
+	return nil
+}
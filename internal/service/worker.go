@@ -3,29 +3,38 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"os"
 	"time"
+	"unicode/utf8"
 
 	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
 	"github.com/PavelBradnitski/WbTechL3.1/internal/statuscache"
 
 	"github.com/wb-go/wbf/rabbitmq"
-	"github.com/wb-go/wbf/retry"
 )
 
+// DefaultSendTimeout ограничивает одну попытку отправки, если SEND_TIMEOUT не задан.
+const DefaultSendTimeout = 30 * time.Second
+
+// maxFailureReasonLength сколько символов текста ошибки сохраняется как причина неудачи.
+const maxFailureReasonLength = 1000
+
 // Worker отвечает за получение сообщений из очереди и обработку уведомлений.
 type Worker struct {
 	channel     *rabbitmq.Channel
 	sender      sender.Sender
 	service     NotificationService
 	statusCache *statuscache.Cache
+	sendTimeout time.Duration
+	retries     *RetryPolicies
 }
 
 // NewWorker создает новый экземпляр Worker.
 func NewWorker(channel *rabbitmq.Channel, sender sender.Sender, svc NotificationService, cache *statuscache.Cache) *Worker {
-	return &Worker{channel: channel, sender: sender, service: svc, statusCache: cache}
+	return &Worker{channel: channel, sender: sender, service: svc, statusCache: cache, sendTimeout: DefaultSendTimeout, retries: DefaultRetryPolicies()}
 }
 
 // Start запускает обработку сообщений из очереди.
@@ -34,6 +43,18 @@ func (w *Worker) Start() {
 	if queueName == "" {
 		queueName = "notifications"
 	}
+	if s := os.Getenv("SEND_TIMEOUT"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			w.sendTimeout = d
+		} else {
+			log.Printf("invalid SEND_TIMEOUT %q, using %s", s, w.sendTimeout)
+		}
+	}
+	retries, err := LoadRetryPolicies(os.Environ())
+	if err != nil {
+		log.Printf("invalid retry policy, ignored: %v", err)
+	}
+	w.retries = retries
 	conf := &rabbitmq.ConsumerConfig{
 		Queue:    queueName,
 		Consumer: "worker-1",
@@ -67,81 +88,230 @@ func (w *Worker) Start() {
 			continue
 		}
 
-		strat := retry.Strategy{
-			Attempts: 3,
-			Delay:    time.Second,
-			Backoff:  2,
-		}
 		log.Printf("received: %v", n)
-		err := retry.Do(func() error {
-			if n.Type != "email" && n.Type != "telegram" {
-				log.Printf("unsupported notification type: %s", n.Type)
-				// помечаем как failed в БД, чтобы не брать в работу
-				if err := w.service.UpdateStatus(ctx, n.ID, models.StatusFailed); err != nil {
-					log.Printf("failed to update status for id=%v: %v", n.ID, err)
-				}
-				// сохраняем статус в Redis
-				err = w.statusCache.SetStatus(ctx, n.ID, models.StatusFailed)
-				if err != nil {
-					log.Printf("failed to set status in redis for id=%v: %v", n.ID, err)
-				}
-				// удаляем из очереди, чтобы не зацикливать
-				if err := d.Ack(false); err != nil {
-					log.Printf("failed to ack message id=%v: %v", n.ID, err)
-				}
-				return nil
+		// получателю из списка подавления и отписавшемуся получателю не отправляем
+		if reason := w.blocked(ctx, &n); reason != "" {
+			log.Printf("notification id=%v skipped: %s", n.ID, reason)
+			w.suppress(ctx, &n, reason)
+			if err := d.Ack(false); err != nil {
+				log.Printf("failed to ack message id=%v: %v", n.ID, err)
 			}
-			switch n.Type {
-			case "email":
-				// Отправляем email
-				if err := w.sender.Send(&n); err != nil {
-					log.Printf("failed to send email: %v", err)
-					if err := w.service.IncrementRetries(ctx, n.ID); err != nil {
-						log.Printf("failed to increment retries for id=%v: %v", n.ID, err)
-					}
-					return err
-				}
+			continue
+		}
 
-			case "telegram":
-				// Отправляем telegram
-				if err := w.sender.Send(&n); err != nil {
-					log.Printf("failed to send telegram: %v", err)
-					if err := w.service.IncrementRetries(ctx, n.ID); err != nil {
-						log.Printf("failed to increment retries for id=%v: %v", n.ID, err)
-					}
-					return err
-				}
-			}
-			return nil
-		}, strat)
+		class, err := w.render(ctx, &n)
+		if err == nil {
+			class, err = w.deliver(ctx, &n)
+		}
 		if err != nil {
-			// все попытки исчерпаны
-			log.Printf("processing failed for id=%v after retries: %v", n.ID, err)
-			// помечаем как failed в БД, чтобы не брать в работу
-			w.service.UpdateStatus(ctx, n.ID, models.StatusFailed)
-			// сохраняем статус в Redis
-			err = w.statusCache.SetStatus(ctx, n.ID, models.StatusFailed)
-			if err != nil {
-				log.Printf("failed to set status in redis for id=%v: %v", n.ID, err)
-			}
-			// удаляем из очереди, чтобы не зацикливать
-			if err := d.Nack(false, false); err != nil {
-				log.Printf("failed to nack message id=%v: %v", n.ID, err)
+			w.handleFailure(ctx, &n, class, err)
+			// удаляем из очереди, чтобы не зацикливать; сообщение, исчерпавшее повторы, отклоняется
+			if class == sender.ErrorClassTransient || class == sender.ErrorClassUnknown {
+				if err := d.Nack(false, false); err != nil {
+					log.Printf("failed to nack message id=%v: %v", n.ID, err)
+				}
+			} else if err := d.Ack(false); err != nil {
+				log.Printf("failed to ack message id=%v: %v", n.ID, err)
 			}
 			continue
 		}
 
+		// для тарифицируемых сегментами каналов (SMS) сохраняем их количество для учета стоимости
+		if counter, ok := w.sender.(sender.SegmentCounter); ok {
+			if segments := counter.Segments(&n); segments > 0 {
+				if err := w.service.RecordSegments(ctx, n.ID, segments); err != nil {
+					log.Printf("failed to record segments for id=%v: %v", n.ID, err)
+				}
+			}
+		}
 		if err := w.service.UpdateStatus(ctx, n.ID, models.StatusSent); err != nil {
 			log.Printf("failed to mark notification %v as sent: %v", n.ID, err)
 		}
 		// сохраняем статус в Redis
-		err = w.statusCache.SetStatus(ctx, n.ID, models.StatusSent)
-		if err != nil {
+		if err := w.statusCache.SetStatus(ctx, n.ID, models.StatusSent); err != nil {
 			log.Printf("failed to set status in redis for id=%v: %v", n.ID, err)
 		}
+		w.syncGroup(ctx, &n)
 		// подтверждаем успешную обработку
 		if err := d.Ack(false); err != nil {
 			log.Printf("failed to ack message id=%v: %v", n.ID, err)
 		}
 	}
 }
+
+// send выполняет одну попытку отправки с дедлайном sendTimeout.
+func (w *Worker) send(ctx context.Context, n *models.Notification) (*sender.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.sendTimeout)
+	defer cancel()
+	return w.sender.Send(ctx, n)
+}
+
+// errorClass возвращает класс ошибки из результата отправки, а если отправитель его не указал, определяет по err.
+func errorClass(res *sender.Result, err error) sender.ErrorClass {
+	if res != nil && res.ErrorClass != "" {
+		return res.ErrorClass
+	}
+	return sender.Classify(err)
+}
+
+// deliver отправляет уведомление, повторяя попытки по политике канала для класса последней ошибки.
+// Возвращает класс и ошибку, после которой отправка прекращена; nil — уведомление доставлено.
+func (w *Worker) deliver(ctx context.Context, n *models.Notification) (sender.ErrorClass, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := w.send(ctx, n)
+		if err == nil {
+			// по Message-ID письма потом находится уведомление, к которому относится сообщение о недоставке
+			if res != nil && res.ProviderMessageID != "" {
+				if err := w.service.RecordProviderMessageID(ctx, n.ID, res.ProviderMessageID); err != nil {
+					log.Printf("failed to record provider message id for id=%v: %v", n.ID, err)
+				}
+			}
+			return "", nil
+		}
+		class := errorClass(res, err)
+		log.Printf("failed to send %s notification id=%v (%s): %v", n.Type, n.ID, class, err)
+		if errors.Is(err, sender.ErrUnsupportedType) {
+			// для типа нет отправителя — попытка не считается
+			return class, err
+		}
+		if err := w.service.IncrementRetries(ctx, n.ID); err != nil {
+			log.Printf("failed to increment retries for id=%v: %v", n.ID, err)
+		}
+		n.Retries++
+
+		policy := w.retries.Get(n.Type, class)
+		// при ограничении частоты воркер не ждет: уведомление переносится на время, указанное провайдером
+		if class == sender.ErrorClassRateLimited || attempt >= policy.Attempts {
+			return class, err
+		}
+		// остановка воркера прерывает ожидание; уведомление завершается последней ошибкой
+		if wait(ctx, retryDelay(policy, attempt)) != nil {
+			return class, err
+		}
+	}
+}
+
+// wait ждет d или отмены ctx.
+func wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// handleFailure обрабатывает ошибку, после которой отправка прекращена.
+// При ограничении частоты уведомление переносится на время, указанное провайдером, пока
+// число таких переносов не достигнет политики класса rate_limited; другие неудачные попытки
+// в это число не входят. В остальных случаях
+// уведомление завершается неудачей с сохранением причины; заблокировавший отправителя
+// получатель добавляется в список подавления.
+func (w *Worker) handleFailure(ctx context.Context, n *models.Notification, class sender.ErrorClass, err error) {
+	var rateLimit *sender.RateLimitError
+	if errors.As(err, &rateLimit) {
+		if n.RateLimits < w.retries.Get(n.Type, sender.ErrorClassRateLimited).Attempts {
+			at := time.Now().Add(rateLimit.RetryAfter)
+			log.Printf("notification id=%v rate limited, rescheduled to %s", n.ID, at.Format(time.RFC3339))
+			if err := w.service.Reschedule(ctx, n.ID, at); err != nil {
+				log.Printf("failed to reschedule id=%v: %v", n.ID, err)
+			}
+			if err := w.statusCache.SetStatus(ctx, n.ID, models.StatusScheduled); err != nil {
+				log.Printf("failed to set status in redis for id=%v: %v", n.ID, err)
+			}
+			return
+		}
+		log.Printf("notification id=%v rate limited %d times, giving up", n.ID, n.RateLimits)
+	}
+
+	log.Printf("notification id=%v failed (%s): %v", n.ID, class, err)
+	var blocked *sender.BlockedError
+	if errors.As(err, &blocked) {
+		sup := &models.Suppression{Type: n.Type, Recipient: blocked.Recipient, Reason: blocked.Reason}
+		if err := w.service.Suppress(ctx, sup); err != nil {
+			log.Printf("failed to suppress %s recipient %s: %v", n.Type, blocked.Recipient, err)
+		}
+	}
+	w.fail(ctx, n, class, err.Error())
+}
+
+// render готовит текст уведомления по шаблону из закрепленной за уведомлением версии шаблона.
+// Уведомления без шаблона не меняются.
+func (w *Worker) render(ctx context.Context, n *models.Notification) (sender.ErrorClass, error) {
+	if err := w.service.RenderTemplate(ctx, n); err != nil {
+		return sender.Classify(err), err
+	}
+	return "", nil
+}
+
+// blocked возвращает причину, по которой уведомление нельзя отправлять получателю:
+// адрес в списке подавления или получатель отписался от канала или категории.
+// Ошибка проверки не блокирует отправку.
+func (w *Worker) blocked(ctx context.Context, n *models.Notification) string {
+	if sup, err := w.service.Suppressed(ctx, n); err != nil {
+		log.Printf("failed to check suppression for id=%v: %v", n.ID, err)
+	} else if sup != nil {
+		return "recipient is suppressed: " + sup.Reason
+	}
+	if pref, err := w.service.OptedOut(ctx, n); err != nil {
+		log.Printf("failed to check preferences for id=%v: %v", n.ID, err)
+	} else if pref != nil {
+		if pref.Category != "" {
+			return "recipient opted out of category " + pref.Category
+		}
+		return "recipient opted out of channel " + string(n.Type)
+	}
+	return ""
+}
+
+// fail помечает уведомление как failed в БД и в Redis, сохраняет причину неудачи
+// и пересчитывает статус группы.
+func (w *Worker) fail(ctx context.Context, n *models.Notification, class sender.ErrorClass, reason string) {
+	w.finish(ctx, n, models.StatusFailed, class, reason)
+}
+
+// suppress помечает уведомление, которое не отправлялось по решению получателя, как suppressed.
+func (w *Worker) suppress(ctx context.Context, n *models.Notification, reason string) {
+	w.finish(ctx, n, models.StatusSuppressed, sender.ErrorClassPermanent, reason)
+}
+
+// finish сохраняет итоговый статус недоставленного уведомления в БД и в Redis вместе с причиной
+// и пересчитывает статус группы.
+func (w *Worker) finish(ctx context.Context, n *models.Notification, status models.Status, class sender.ErrorClass, reason string) {
+	if err := w.service.RecordFailure(ctx, n.ID, string(class), truncateReason(reason)); err != nil {
+		log.Printf("failed to record failure reason for id=%v: %v", n.ID, err)
+	}
+	if err := w.service.UpdateStatus(ctx, n.ID, status); err != nil {
+		log.Printf("failed to update status for id=%v: %v", n.ID, err)
+	}
+	if err := w.statusCache.SetStatus(ctx, n.ID, status); err != nil {
+		log.Printf("failed to set status in redis for id=%v: %v", n.ID, err)
+	}
+	w.syncGroup(ctx, n)
+}
+
+// truncateReason обрезает текст ошибки до maxFailureReasonLength символов.
+func truncateReason(reason string) string {
+	if utf8.RuneCountInString(reason) <= maxFailureReasonLength {
+		return reason
+	}
+	return string([]rune(reason)[:maxFailureReasonLength]) + "…"
+}
+
+// syncGroup пересчитывает статус родительского уведомления после завершения попытки по каналу.
+// В режиме fallback неудачная попытка запускает следующий канал.
+func (w *Worker) syncGroup(ctx context.Context, n *models.Notification) {
+	if n.ParentID == "" {
+		return
+	}
+	status, err := w.service.SyncGroup(ctx, n.ParentID)
+	if err != nil {
+		log.Printf("failed to sync group status for id=%v: %v", n.ParentID, err)
+		return
+	}
+	if err := w.statusCache.SetStatus(ctx, n.ParentID, status); err != nil {
+		log.Printf("failed to set status in redis for id=%v: %v", n.ParentID, err)
+	}
+}
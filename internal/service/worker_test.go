@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitCanceled(t *testing.T) {
+	assert.NoError(t, wait(context.Background(), time.Millisecond))
+
+	// остановка воркера прерывает паузу между попытками, а не держит ее до конца
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	started := time.Now()
+	assert.ErrorIs(t, wait(ctx, time.Minute), context.Canceled)
+	assert.Less(t, time.Since(started), time.Second)
+}
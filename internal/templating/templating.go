@@ -0,0 +1,153 @@
+// Package templating готовит тексты уведомлений по шаблонам: тема и текст — Go text/template,
+// HTML письма — html/template, который экранирует значения переменных. Текст с разметкой
+// (например, Telegram MarkdownV2) экранируется функцией, переданной в Render.
+package templating
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"regexp"
+	"text/template"
+	tmplparse "text/template/parse"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// missingKey текст ошибки text/template при обращении к отсутствующей переменной (missingkey=error)
+var missingKey = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+// Error ошибка разбора шаблона или подстановки переменных в один из текстов канала.
+type Error struct {
+	// Field текст шаблона: subject, message или html
+	Field string
+	// Variable переменная, которой нет среди переданных (пустая — ошибка другого рода)
+	Variable string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	if e.Variable != "" {
+		return fmt.Sprintf("%s: variable %q is missing", e.Field, e.Variable)
+	}
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// executor общий интерфейс text/template и html/template.
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// Parse проверяет синтаксис текстов шаблона канала.
+func Parse(c models.TemplateContent) error {
+	for _, f := range fields(&c) {
+		if *f.text == "" {
+			continue
+		}
+		if _, err := parse(f.name, *f.text, nil); err != nil {
+			return &Error{Field: f.name, Err: err}
+		}
+	}
+	return nil
+}
+
+// Render подставляет переменные в тексты шаблона канала. Обращение к переменной, которой нет в vars,
+// — ошибка: необязательные переменные проверяются через index, например {{with index . "coupon"}}.
+// escape, если задан, применяется к каждому значению, выводимому в текст сообщения, чтобы данные
+// не ломали разметку; текст самого шаблона не меняется.
+func Render(c models.TemplateContent, vars map[string]any, escape func(string) string) (models.TemplateContent, error) {
+	if vars == nil {
+		vars = map[string]any{}
+	}
+	out := c
+	for _, f := range fields(&out) {
+		if *f.text == "" {
+			continue
+		}
+		var esc func(string) string
+		if f.name == "message" {
+			esc = escape
+		}
+		t, err := parse(f.name, *f.text, esc)
+		if err != nil {
+			return models.TemplateContent{}, &Error{Field: f.name, Err: err}
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, vars); err != nil {
+			e := &Error{Field: f.name, Err: err}
+			if m := missingKey.FindStringSubmatch(err.Error()); m != nil {
+				e.Variable = m[1]
+			}
+			return models.TemplateContent{}, e
+		}
+		*f.text = buf.String()
+	}
+	return out, nil
+}
+
+// field текст шаблона канала и его имя.
+type field struct {
+	name string
+	text *string
+}
+
+// fields возвращает тексты шаблона канала.
+func fields(c *models.TemplateContent) []field {
+	return []field{{"subject", &c.Subject}, {"message", &c.Message}, {"html", &c.HTML}}
+}
+
+// parse разбирает текст шаблона: HTML — пакетом html/template, остальное — text/template.
+// Если задан escape, он дописывается в конец каждого действия {{...}}, как это делает html/template.
+func parse(name, text string, escape func(string) string) (executor, error) {
+	if name == "html" {
+		return htmltemplate.New(name).Option("missingkey=error").Parse(text)
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil || escape == nil {
+		return t, err
+	}
+	t.Funcs(template.FuncMap{escapeFunc: func(v any) string { return escape(fmt.Sprint(v)) }})
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil {
+			escapeActions(tt.Tree, tt.Tree.Root)
+		}
+	}
+	return t, nil
+}
+
+// escapeFunc имя функции шаблона, которой экранируются выводимые значения
+const escapeFunc = "_escape"
+
+// escapeActions дописывает escapeFunc в конвейер каждого действия, которое выводит значение.
+func escapeActions(tree *tmplparse.Tree, n tmplparse.Node) {
+	switch n := n.(type) {
+	case *tmplparse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			escapeActions(tree, c)
+		}
+	case *tmplparse.ActionNode:
+		// {{$x := ...}} ничего не выводит
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		ident := tmplparse.NewIdentifier(escapeFunc).SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &tmplparse.CommandNode{NodeType: tmplparse.NodeCommand, Pos: n.Pos, Args: []tmplparse.Node{ident}})
+	case *tmplparse.IfNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *tmplparse.RangeNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *tmplparse.WithNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	}
+}
@@ -0,0 +1,59 @@
+package templating
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	content := models.TemplateContent{
+		Subject: "Заказ {{.order}}",
+		Message: `Здравствуйте, {{.name}}!{{with index . "coupon"}} Ваш промокод: {{.}}{{end}}`,
+		HTML:    `<p>Здравствуйте, {{.name}}!</p>`,
+	}
+
+	rendered, err := Render(content, map[string]any{"order": 42, "name": "<Анна>"}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "Заказ 42", rendered.Subject)
+	assert.Equal(t, "Здравствуйте, <Анна>!", rendered.Message)
+	assert.Equal(t, "<p>Здравствуйте, &lt;Анна&gt;!</p>", rendered.HTML)
+
+	_, err = Render(content, map[string]any{"order": 42}, nil)
+	var e *Error
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Equal(t, "message", e.Field)
+		assert.Equal(t, "name", e.Variable)
+	}
+	assert.EqualError(t, err, `message: variable "name" is missing`)
+}
+
+func TestRenderEscape(t *testing.T) {
+	content := models.TemplateContent{
+		Subject: "Заказ {{.order}}",
+		Message: `*Заказ {{.order}}*{{$n := .name}}{{if $n}} для {{$n}}{{end}}{{range .items}}, {{.}}{{end}}`,
+	}
+	escape := func(s string) string { return "[" + s + "]" }
+
+	rendered, err := Render(content, map[string]any{"order": 1.5, "name": "Анна", "items": []string{"a", "b"}}, escape)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// экранируются только значения в тексте сообщения, разметка шаблона остается как есть
+	assert.Equal(t, "Заказ 1.5", rendered.Subject)
+	assert.Equal(t, "*Заказ [1.5]* для [Анна], [a], [b]", rendered.Message)
+}
+
+func TestParse(t *testing.T) {
+	assert.NoError(t, Parse(models.TemplateContent{Message: "{{.name}}"}))
+
+	err := Parse(models.TemplateContent{Subject: "ok", HTML: "<p>{{.name</p>"})
+	var e *Error
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Equal(t, "html", e.Field)
+	}
+}
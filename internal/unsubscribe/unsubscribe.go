@@ -0,0 +1,73 @@
+// Package unsubscribe выпускает и проверяет подписанные ссылки отписки от уведомлений.
+package unsubscribe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+)
+
+// Path путь обработчика отписки в API.
+const Path = "/v1/unsubscribe"
+
+// signatureLength сколько байт HMAC-SHA256 хранится в токене.
+const signatureLength = 16
+
+// ErrInvalidToken токен поврежден или подписан другим ключом.
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Signer подписывает токены отписки ключом HMAC. Токен не истекает: ссылка в старом письме
+// должна работать, пока не сменится ключ.
+type Signer struct {
+	secret  []byte
+	baseURL string
+}
+
+// NewSigner создает Signer. baseURL — публичный адрес API, например https://notify.example.com.
+func NewSigner(secret, baseURL string) *Signer {
+	return &Signer{secret: []byte(secret), baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Token возвращает токен отписки получателя канала от категории (пустая — от всего канала).
+func (s *Signer) Token(t models.NotificationType, recipient, category string) string {
+	payload := []byte(string(t) + "\x00" + recipient + "\x00" + category)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// URL возвращает ссылку отписки для заголовка List-Unsubscribe.
+func (s *Signer) URL(t models.NotificationType, recipient, category string) string {
+	return s.baseURL + Path + "?token=" + url.QueryEscape(s.Token(t, recipient, category))
+}
+
+// Parse проверяет подпись токена и возвращает настройку, которую нужно сохранить: Enabled=false.
+func (s *Signer) Parse(token string) (*models.Preference, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.sign(payload)) {
+		return nil, ErrInvalidToken
+	}
+	fields := strings.Split(string(payload), "\x00")
+	if len(fields) != 3 || fields[0] == "" || fields[1] == "" {
+		return nil, ErrInvalidToken
+	}
+	return &models.Preference{Type: models.NotificationType(fields[0]), Recipient: fields[1], Category: fields[2]}, nil
+}
+
+// sign возвращает подпись payload.
+func (s *Signer) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(payload)
+	return h.Sum(nil)[:signatureLength]
+}
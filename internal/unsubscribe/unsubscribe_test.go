@@ -0,0 +1,36 @@
+package unsubscribe
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigner(t *testing.T) {
+	s := NewSigner("s3cret", "https://notify.example.com/")
+
+	link, err := url.Parse(s.URL(models.NotificationTypeEmail, "user@example.com", "newsletter"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "notify.example.com", link.Host)
+	assert.Equal(t, Path, link.Path)
+
+	token := link.Query().Get("token")
+	p, err := s.Parse(token)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &models.Preference{Type: models.NotificationTypeEmail, Recipient: "user@example.com", Category: "newsletter"}, p)
+
+	// токен, подписанный другим ключом, и измененный токен не принимаются
+	_, err = NewSigner("other", "").Parse(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	forged := s.Token(models.NotificationTypeEmail, "victim@example.com", "")
+	_, err = s.Parse(forged[:len(forged)-2] + token[len(token)-2:])
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = s.Parse("garbage")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
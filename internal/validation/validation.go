@@ -0,0 +1,903 @@
+package validation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/templating"
+)
+
+const (
+	// MaxSubjectLength максимальная длина темы письма в символах
+	MaxSubjectLength = 255
+	// MaxEmailMessageSize максимальный размер текста письма в байтах
+	MaxEmailMessageSize = 1 << 20
+	// MaxWebhookMessageSize максимальный размер текста вебхука в байтах
+	MaxWebhookMessageSize = 1 << 20
+	// MaxWebhookTimeout максимальный таймаут запроса вебхука в секундах
+	MaxWebhookTimeout = 60
+	// MaxAttachments максимальное количество вложений письма
+	MaxAttachments = 10
+	// MaxAttachmentSize максимальный размер одного вложения в байтах
+	MaxAttachmentSize = 5 << 20
+	// MaxAttachmentsSize максимальный суммарный размер вложений письма в байтах
+	MaxAttachmentsSize = 10 << 20
+	// MaxTargets максимальное количество каналов доставки одного уведомления
+	MaxTargets = 5
+	// MaxTelegramButtons максимальное количество кнопок встроенной клавиатуры
+	MaxTelegramButtons = 100
+	// MaxTelegramButtonsPerRow максимальное количество кнопок в строке клавиатуры
+	MaxTelegramButtonsPerRow = 8
+	// MaxTelegramCallbackData максимальный размер callback_data кнопки в байтах
+	MaxTelegramCallbackData = 64
+	// MaxUserIDLength максимальная длина идентификатора пользователя клиента в символах
+	MaxUserIDLength = 255
+	// MaxTemplateNameLength максимальная длина названия шаблона в символах
+	MaxTemplateNameLength = 100
+	// MaxTemplateSize максимальный размер одного текста шаблона в байтах
+	MaxTemplateSize = 1 << 20
+)
+
+// reservedWebhookHeaders заголовки, которые выставляет сам отправитель вебхука.
+var reservedWebhookHeaders = map[string]bool{
+	"Content-Type":        true,
+	"Content-Length":      true,
+	"Host":                true,
+	"X-Webhook-Signature": true,
+	"X-Webhook-Timestamp": true,
+	"X-Webhook-Id":        true,
+}
+
+var (
+	// numericChatID числовой идентификатор чата (у групп и каналов он отрицательный)
+	numericChatID = regexp.MustCompile(`^-?[0-9]{1,20}$`)
+	// channelUsername публичное имя канала или группы: @ и 5–32 символа
+	channelUsername = regexp.MustCompile(`^@[A-Za-z][A-Za-z0-9_]{4,31}$`)
+	// headerName имя HTTP-заголовка (token по RFC 9110)
+	headerName = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+	// e164Phone номер телефона в формате E.164: + и до 15 цифр
+	e164Phone = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+	// category категория рассылки: строчные латинские буквы, цифры, _, - и .
+	category = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,49}$`)
+	// contentID идентификатор встроенного вложения, на который HTML ссылается как cid:
+	contentID = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,100}$`)
+	// telegramHTMLTag тег в сообщении с parse_mode HTML
+	telegramHTMLTag = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9-]*)((?:\s[^<>]*)?)>`)
+	// telegramHTMLEntity сущность HTML, которую понимает Telegram
+	telegramHTMLEntity = regexp.MustCompile(`^&(?:lt|gt|amp|quot|#[0-9]+|#x[0-9a-fA-F]+);`)
+)
+
+// telegramHTMLTags теги, которые Telegram поддерживает в режиме HTML.
+var telegramHTMLTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "span": true, "tg-spoiler": true, "tg-emoji": true,
+	"a": true, "code": true, "pre": true, "blockquote": true,
+}
+
+// FieldError ошибка валидации конкретного поля запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors список ошибок валидации по полям.
+type Errors []FieldError
+
+// Error объединяет сообщения всех ошибок.
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *Errors) add(field, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ChannelValidator проверяет поля запроса, относящиеся к конкретному каналу доставки.
+type ChannelValidator func(req *models.CreateNotificationRequest, errs *Errors)
+
+// ValidateCreateRequest проверяет запрос на создание уведомления: поля канала проверяет
+// validate (nil — тип не поддерживается), общие поля проверяются здесь.
+// Используется как HTTP-хендлером, так и импортом, чтобы правила были одинаковыми.
+// Возвращает Errors со всеми найденными ошибками или nil.
+func ValidateCreateRequest(req *models.CreateNotificationRequest, validate ChannelValidator) error {
+	var errs Errors
+
+	if validate == nil {
+		errs.add("type", "unsupported notification type")
+		return errs
+	}
+	validateChannel(req, validate, &errs)
+	validateCategory("category", req.Category, &errs)
+	validateSchedule(req, &errs)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidateGroup проверяет запрос на создание уведомления с несколькими каналами (targets):
+// режим доставки, общие поля и поля каждого канала. Валидатор канала возвращает validator
+// (nil — тип не поддерживается); ошибки каналов возвращаются с префиксом targets[i].
+func ValidateGroup(req *models.CreateNotificationRequest, validator func(models.NotificationType) ChannelValidator) error {
+	var errs Errors
+
+	if req.Type != "" && req.Type != models.NotificationTypeMulti {
+		errs.add("type", "type must be empty or %s when targets are set", models.NotificationTypeMulti)
+	}
+	switch req.Mode {
+	case models.DeliveryModeAll, models.DeliveryModeFallback:
+	default:
+		errs.add("mode", "mode must be all or fallback")
+	}
+	switch {
+	case req.FallbackAfter < 0:
+		errs.add("fallback_after", "fallback_after cannot be negative")
+	case req.FallbackAfter > 0 && req.Mode != models.DeliveryModeFallback:
+		errs.add("fallback_after", "fallback_after is only allowed in fallback mode")
+	}
+	if len(req.Targets) > MaxTargets {
+		errs.add("targets", "at most %d targets allowed", MaxTargets)
+	}
+
+	for i := range req.Targets {
+		prefix := fmt.Sprintf("targets[%d].", i)
+		if len(req.Targets[i].Targets) > 0 {
+			errs.add(prefix+"targets", "nested targets are not allowed")
+			continue
+		}
+		validate := validator(req.Targets[i].Type)
+		if validate == nil {
+			errs.add(prefix+"type", "unsupported notification type")
+			continue
+		}
+		validateCategory(prefix+"category", req.Targets[i].Category, &errs)
+		target := req.Target(i)
+		var targetErrs Errors
+		validateChannel(&target, validate, &targetErrs)
+		for _, fe := range targetErrs {
+			errs = append(errs, FieldError{Field: prefix + fe.Field, Message: fe.Message})
+		}
+	}
+	validateCategory("category", req.Category, &errs)
+	validateSchedule(req, &errs)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateChannel проверяет поля канала. У уведомления по шаблону проверяется текст, полученный
+// подстановкой переменных в шаблон: так ошибки в переменных находятся при создании, а не при отправке.
+func validateChannel(req *models.CreateNotificationRequest, validate ChannelValidator, errs *Errors) {
+	if req.TemplateID == "" {
+		if req.TemplateVersion != 0 || len(req.Variables) > 0 {
+			errs.add("template_id", "template_id is required with template_version and variables")
+		}
+		validate(req, errs)
+		return
+	}
+
+	rendered, ok := renderTemplate(req, errs)
+	var channelErrs Errors
+	validate(&rendered, &channelErrs)
+	for _, fe := range channelErrs {
+		// без текста шаблона ошибки текста не имеют смысла: о причине уже сообщено
+		if !ok && (fe.Field == "subject" || fe.Field == "message" || fe.Field == "html") {
+			continue
+		}
+		*errs = append(*errs, fe)
+	}
+}
+
+// renderTemplate подставляет переменные в шаблон канала, загруженный сервисом в req.Template,
+// и возвращает копию запроса с готовым текстом. false — текст получить не удалось.
+func renderTemplate(req *models.CreateNotificationRequest, errs *Errors) (models.CreateNotificationRequest, bool) {
+	rendered := *req
+	if req.Message != "" || req.Subject != "" || req.HTML != "" {
+		errs.add("template_id", "template_id cannot be combined with message, subject or html")
+		return rendered, false
+	}
+	if req.TemplateVersion < 0 {
+		errs.add("template_version", "template_version cannot be negative")
+		return rendered, false
+	}
+	if req.Template == nil {
+		errs.add("template_id", "template not found")
+		return rendered, false
+	}
+	content, ok := req.Template.Channels[req.Type]
+	if !ok {
+		errs.add("template_id", "template has no content for %s notifications", req.Type)
+		return rendered, false
+	}
+	var escape func(string) string
+	if req.Telegram != nil {
+		escape = sender.TelegramEscaper(req.Telegram.ParseMode)
+	}
+	out, err := templating.Render(content, req.Variables, escape)
+	if err != nil {
+		errs.add("variables", "%v", err)
+		return rendered, false
+	}
+	rendered.Subject, rendered.Message, rendered.HTML = out.Subject, out.Message, out.HTML
+	return rendered, true
+}
+
+// ValidateTemplate проверяет шаблон сообщения: название и тексты каналов. Валидатор канала
+// возвращает validator (nil — тип не поддерживается). Ошибки текстов возвращаются
+// с префиксом channels.<type>.
+func ValidateTemplate(t *models.Template, validator func(models.NotificationType) ChannelValidator) error {
+	var errs Errors
+	switch {
+	case strings.TrimSpace(t.Name) == "":
+		errs.add("name", "name is required")
+	case !utf8.ValidString(t.Name):
+		errs.add("name", "name must be valid UTF-8")
+	case utf8.RuneCountInString(t.Name) > MaxTemplateNameLength:
+		errs.add("name", "name exceeds %d characters", MaxTemplateNameLength)
+	}
+	if len(t.Channels) == 0 {
+		errs.add("channels", "at least one channel is required")
+	}
+
+	types := make([]string, 0, len(t.Channels))
+	for ch := range t.Channels {
+		types = append(types, string(ch))
+	}
+	sort.Strings(types)
+	for _, name := range types {
+		ch := models.NotificationType(name)
+		content := t.Channels[ch]
+		prefix := "channels." + name
+		if validator(ch) == nil || ch == models.NotificationTypeMulti {
+			errs.add(prefix, "unsupported notification type")
+			continue
+		}
+		if content.Message == "" && content.HTML == "" {
+			errs.add(prefix+".message", "%s.message is required", prefix)
+		}
+		if content.HTML != "" && ch != models.NotificationTypeEmail {
+			errs.add(prefix+".html", "%s.html is only supported for email", prefix)
+		}
+		for _, f := range []struct{ name, text string }{{"subject", content.Subject}, {"message", content.Message}, {"html", content.HTML}} {
+			switch {
+			case !utf8.ValidString(f.text):
+				errs.add(prefix+"."+f.name, "%s.%s must be valid UTF-8", prefix, f.name)
+			case len(f.text) > MaxTemplateSize:
+				errs.add(prefix+"."+f.name, "%s.%s exceeds %d bytes", prefix, f.name, MaxTemplateSize)
+			}
+		}
+		var e *templating.Error
+		if err := templating.Parse(content); errors.As(err, &e) {
+			errs.add(prefix+"."+e.Field, "%s.%v", prefix, e)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateCategory проверяет необязательную категорию рассылки.
+func validateCategory(field, value string, errs *Errors) {
+	if value != "" && !category.MatchString(value) {
+		errs.add(field, "%s must be 1-50 characters: lowercase latin letters, digits, _, - or .", field)
+	}
+}
+
+// ValidatePreference проверяет настройку получателя: получателя и необязательную категорию.
+func ValidatePreference(p *models.Preference) error {
+	var errs Errors
+	if strings.TrimSpace(p.Recipient) == "" {
+		errs.add("recipient", "recipient is required")
+	}
+	validateCategory("category", p.Category, &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateSchedule проверяет время отправки.
+func validateSchedule(req *models.CreateNotificationRequest, errs *Errors) {
+	if req.ScheduledAt.IsZero() {
+		errs.add("scheduled_at", "scheduled_at is required")
+	} else if req.ScheduledAt.Before(time.Now()) {
+		errs.add("scheduled_at", "scheduled_at cannot be in the past")
+	}
+}
+
+// ValidateEmail проверяет получателя, тему и текст email-уведомления.
+func ValidateEmail(req *models.CreateNotificationRequest, errs *Errors) {
+	if req.Email == "" {
+		errs.add("email", "email is required for email notifications")
+	} else if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		errs.add("email", "email must be a valid address")
+	}
+
+	switch {
+	case !utf8.ValidString(req.Subject):
+		errs.add("subject", "subject must be valid UTF-8")
+	case strings.ContainsAny(req.Subject, "\r\n"):
+		errs.add("subject", "subject cannot contain line breaks")
+	case utf8.RuneCountInString(req.Subject) > MaxSubjectLength:
+		errs.add("subject", "subject exceeds %d characters", MaxSubjectLength)
+	}
+
+	// у HTML-письма текстовая альтернатива необязательна: она строится из HTML
+	if req.HTML != "" {
+		switch {
+		case !utf8.ValidString(req.HTML):
+			errs.add("html", "html must be valid UTF-8")
+		case len(req.HTML) > MaxEmailMessageSize:
+			errs.add("html", "html exceeds %d bytes", MaxEmailMessageSize)
+		}
+		if req.Message != "" && !utf8.ValidString(req.Message) {
+			errs.add("message", "message must be valid UTF-8")
+		} else if len(req.Message) > MaxEmailMessageSize {
+			errs.add("message", "message exceeds %d bytes", MaxEmailMessageSize)
+		}
+	} else if validateMessage(req, errs) && len(req.Message) > MaxEmailMessageSize {
+		errs.add("message", "message exceeds %d bytes", MaxEmailMessageSize)
+	}
+
+	validateAttachments(req, errs)
+}
+
+// validateAttachments проверяет вложения письма: количество, размер, имя файла и тип.
+// Встроенные вложения (content_id) должны быть изображениями и требуют HTML-версии письма.
+func validateAttachments(req *models.CreateNotificationRequest, errs *Errors) {
+	if len(req.Attachments) > MaxAttachments {
+		errs.add("attachments", "at most %d attachments allowed", MaxAttachments)
+		return
+	}
+
+	total := 0
+	contentIDs := map[string]bool{}
+	for i, a := range req.Attachments {
+		field := fmt.Sprintf("attachments[%d].", i)
+		validateFile(field, a, errs)
+		total += len(a.Content)
+
+		contentType := a.ContentType
+		if contentType != "" {
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil || !strings.Contains(mediaType, "/") {
+				errs.add(field+"content_type", "content_type must be a valid MIME type")
+				continue
+			}
+			contentType = mediaType
+		} else if len(a.Content) > 0 {
+			contentType = http.DetectContentType(a.Content)
+		}
+
+		if a.ContentID == "" {
+			continue
+		}
+		switch {
+		case !contentID.MatchString(a.ContentID):
+			errs.add(field+"content_id", "content_id may contain only letters, digits and ._@-")
+		case contentIDs[a.ContentID]:
+			errs.add(field+"content_id", "content_id must be unique")
+		case req.HTML == "":
+			errs.add(field+"content_id", "inline attachments require html")
+		case !strings.HasPrefix(contentType, "image/"):
+			errs.add(field+"content_id", "only images can be inline")
+		}
+		contentIDs[a.ContentID] = true
+	}
+	if total > MaxAttachmentsSize {
+		errs.add("attachments", "attachments exceed %d bytes in total", MaxAttachmentsSize)
+	}
+}
+
+// validateFile проверяет имя и размер загруженного файла; field — префикс имен полей.
+func validateFile(field string, a models.Attachment, errs *Errors) {
+	switch {
+	case a.Filename == "":
+		errs.add(field+"filename", "filename is required")
+	case !utf8.ValidString(a.Filename) || strings.ContainsAny(a.Filename, "/\\\"\r\n"):
+		errs.add(field+"filename", "filename must be valid UTF-8 without path separators, quotes or line breaks")
+	case utf8.RuneCountInString(a.Filename) > MaxSubjectLength:
+		errs.add(field+"filename", "filename exceeds %d characters", MaxSubjectLength)
+	}
+
+	switch {
+	case len(a.Content) == 0:
+		errs.add(field+"content", "content cannot be empty")
+	case len(a.Content) > MaxAttachmentSize:
+		errs.add(field+"content", "content exceeds %d bytes", MaxAttachmentSize)
+	}
+}
+
+// ValidateTelegram проверяет чат и текст telegram-уведомления.
+func ValidateTelegram(req *models.CreateNotificationRequest, errs *Errors) {
+	if req.ChatID == "" && req.UserID != "" {
+		// ResolveRecipients не нашел чат пользователя
+		errs.add("user_id", "user_id has no linked telegram chat")
+	} else if req.ChatID == "" {
+		errs.add("chat_id", "chat_id is required for telegram notifications")
+	} else if !numericChatID.MatchString(req.ChatID) && !channelUsername.MatchString(req.ChatID) {
+		errs.add("chat_id", "chat_id must be a numeric id or @channel username")
+	}
+
+	// у сообщения с фотографиями или документами текст — необязательная подпись с меньшим лимитом
+	hasMedia := req.Telegram != nil && len(req.Telegram.Media) > 0
+	limit := sender.TelegramMaxMessageLength
+	if hasMedia {
+		limit = sender.TelegramMaxCaptionLength
+	}
+	switch {
+	case hasMedia && req.Message == "":
+	case validateMessage(req, errs) && utf8.RuneCountInString(req.Message) > limit:
+		errs.add("message", "message exceeds %d characters", limit)
+	}
+
+	if req.Telegram != nil {
+		validateTelegramOptions(req.Telegram, req.Message, errs)
+	}
+}
+
+// validateTelegramOptions проверяет разметку, клавиатуру и параметры доставки сообщения Telegram.
+func validateTelegramOptions(opts *models.TelegramOptions, message string, errs *Errors) {
+	switch opts.ParseMode {
+	case "":
+	case models.TelegramParseModeMarkdownV2:
+		if err := checkTelegramMarkdownV2(message); err != nil {
+			errs.add("message", "message is not valid Telegram MarkdownV2: %v", err)
+		}
+	case models.TelegramParseModeHTML:
+		if err := checkTelegramHTML(message); err != nil {
+			errs.add("message", "message is not valid Telegram HTML: %v", err)
+		}
+	default:
+		errs.add("telegram.parse_mode", "telegram.parse_mode must be MarkdownV2 or HTML")
+	}
+
+	validateTelegramMedia(opts, errs)
+
+	if opts.MessageThreadID < 0 {
+		errs.add("telegram.message_thread_id", "telegram.message_thread_id must be positive")
+	}
+
+	if len(opts.ReplyMarkup) > 0 {
+		var markup map[string]any
+		if err := json.Unmarshal(opts.ReplyMarkup, &markup); err != nil {
+			errs.add("telegram.reply_markup", "telegram.reply_markup must be a JSON object")
+		}
+		if len(opts.Buttons) > 0 {
+			errs.add("telegram.reply_markup", "telegram.reply_markup cannot be combined with telegram.buttons")
+		}
+	}
+
+	total := 0
+	for i, row := range opts.Buttons {
+		if len(row) == 0 || len(row) > MaxTelegramButtonsPerRow {
+			errs.add(fmt.Sprintf("telegram.buttons[%d]", i), "telegram.buttons[%d] must contain from 1 to %d buttons", i, MaxTelegramButtonsPerRow)
+		}
+		total += len(row)
+		for j, b := range row {
+			field := fmt.Sprintf("telegram.buttons[%d][%d]", i, j)
+			if strings.TrimSpace(b.Text) == "" {
+				errs.add(field+".text", "%s.text is required", field)
+			}
+			switch {
+			case (b.URL == "") == (b.CallbackData == ""):
+				errs.add(field, "%s must have exactly one of url or callback_data", field)
+			case b.URL != "":
+				if u, err := url.Parse(b.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "tg") {
+					errs.add(field+".url", "%s.url must be an http, https or tg URL", field)
+				}
+			case len(b.CallbackData) > MaxTelegramCallbackData:
+				errs.add(field+".callback_data", "%s.callback_data exceeds %d bytes", field, MaxTelegramCallbackData)
+			}
+		}
+	}
+	if total > MaxTelegramButtons {
+		errs.add("telegram.buttons", "telegram.buttons must contain at most %d buttons", MaxTelegramButtons)
+	}
+}
+
+// validateTelegramMedia проверяет фотографии и документы сообщения Telegram: у каждого файла
+// ровно один источник (url или загруженный file), альбом не смешивает фотографии с документами
+// и не поддерживает клавиатуру.
+func validateTelegramMedia(opts *models.TelegramOptions, errs *Errors) {
+	if len(opts.Media) > sender.TelegramMaxMediaGroup {
+		errs.add("telegram.media", "telegram.media must contain at most %d items", sender.TelegramMaxMediaGroup)
+		return
+	}
+
+	total := 0
+	for i, m := range opts.Media {
+		field := fmt.Sprintf("telegram.media[%d]", i)
+		switch m.Type {
+		case models.TelegramMediaPhoto, models.TelegramMediaDocument:
+			if m.Type != opts.Media[0].Type {
+				errs.add(field+".type", "an album cannot mix photos and documents")
+			}
+		default:
+			errs.add(field+".type", "%s.type must be photo or document", field)
+		}
+
+		switch {
+		case (m.URL == "") == (m.File == nil):
+			errs.add(field, "%s must have exactly one of url or file", field)
+		case m.URL != "":
+			if u, err := url.Parse(m.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs.add(field+".url", "%s.url must be an absolute http or https URL", field)
+			}
+		default:
+			validateFile(field+".file.", *m.File, errs)
+			total += len(m.File.Content)
+			contentType := m.File.ContentType
+			if contentType == "" {
+				contentType = mime.TypeByExtension(filepath.Ext(m.File.Filename))
+			}
+			if contentType == "" && len(m.File.Content) > 0 {
+				contentType = http.DetectContentType(m.File.Content)
+			}
+			if mediaType, _, err := mime.ParseMediaType(contentType); err != nil {
+				errs.add(field+".file.content_type", "content_type must be a valid MIME type")
+			} else if m.Type == models.TelegramMediaPhoto && !strings.HasPrefix(mediaType, "image/") {
+				errs.add(field+".file.content_type", "photo must be an image")
+			}
+		}
+	}
+	if total > MaxAttachmentsSize {
+		errs.add("telegram.media", "telegram.media files exceed %d bytes in total", MaxAttachmentsSize)
+	}
+	if len(opts.Media) > 1 && (len(opts.Buttons) > 0 || len(opts.ReplyMarkup) > 0) {
+		errs.add("telegram.media", "buttons and reply_markup are not supported for albums")
+	}
+}
+
+// checkTelegramHTML проверяет, что текст в режиме HTML содержит только поддерживаемые теги,
+// теги закрыты в правильном порядке, а символы <, > и & вне тегов заменены сущностями.
+func checkTelegramHTML(s string) error {
+	var open []string
+	pos := 0
+	checkText := func(text string) error {
+		if strings.ContainsAny(text, "<>") {
+			return fmt.Errorf("unescaped < or >")
+		}
+		for i := strings.IndexByte(text, '&'); i >= 0; i = strings.IndexByte(text, '&') {
+			if !telegramHTMLEntity.MatchString(text[i:]) {
+				return fmt.Errorf("unescaped &")
+			}
+			text = text[i+1:]
+		}
+		return nil
+	}
+	for _, m := range telegramHTMLTag.FindAllStringSubmatchIndex(s, -1) {
+		if err := checkText(s[pos:m[0]]); err != nil {
+			return err
+		}
+		pos = m[1]
+
+		name := strings.ToLower(s[m[4]:m[5]])
+		if !telegramHTMLTags[name] {
+			return fmt.Errorf("unsupported tag <%s>", name)
+		}
+		if m[3] == m[2] {
+			open = append(open, name)
+			continue
+		}
+		if len(open) == 0 || open[len(open)-1] != name {
+			return fmt.Errorf("unexpected closing tag </%s>", name)
+		}
+		open = open[:len(open)-1]
+	}
+	if err := checkText(s[pos:]); err != nil {
+		return err
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("tag <%s> is not closed", open[len(open)-1])
+	}
+	return nil
+}
+
+// telegramMarkdownV2Reserved служебные символы MarkdownV2, которые вне разметки нужно экранировать.
+const telegramMarkdownV2Reserved = "_*[]()~`>#+-=|{}.!"
+
+// checkTelegramMarkdownV2 проверяет, что служебные символы вне разметки экранированы, а сущности
+// (*жирный*, _курсив_, __подчеркнутый__, ~зачеркнутый~, ||спойлер||, код и ссылки) закрыты:
+// иначе Bot API отклонит сообщение только при отправке. Подставляемый в разметку текст вызывающая
+// сторона экранирует сама (sender.EscapeTelegramMarkdownV2).
+func checkTelegramMarkdownV2(s string) error {
+	counts := map[string]int{}
+	link, expandable := false, 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		lineStart := i == 0 || s[i-1] == '\n'
+		switch {
+		case r == '\\':
+			if i+size == len(s) {
+				return fmt.Errorf("dangling escape character")
+			}
+			_, next := utf8.DecodeRuneInString(s[i+size:])
+			i += size + next
+			continue
+		case r == '`':
+			fence := "`"
+			if strings.HasPrefix(s[i:], "```") {
+				fence = "```"
+			}
+			end := markdownV2Closing(s[i+len(fence):], fence)
+			if end < 0 {
+				return fmt.Errorf("%s is not closed", fence)
+			}
+			i += 2*len(fence) + end
+			continue
+		case r == '[':
+			if link {
+				return fmt.Errorf("nested [")
+			}
+			link = true
+		case r == ']':
+			if !link {
+				return fmt.Errorf("unescaped %q", r)
+			}
+			link = false
+			if !strings.HasPrefix(s[i+size:], "(") {
+				return fmt.Errorf("link text is not followed by (url)")
+			}
+			end := markdownV2Closing(s[i+size+1:], ")")
+			if end < 0 {
+				return fmt.Errorf("link url is not closed")
+			}
+			i += size + 1 + end + 1
+			continue
+		case lineStart && strings.HasPrefix(s[i:], "**>"):
+			// раскрываемая цитата: **> в начале строки, || в конце последней строки
+			expandable++
+			i += 3
+			continue
+		case r == '|':
+			if !strings.HasPrefix(s[i:], "||") {
+				return fmt.Errorf("unescaped %q", r)
+			}
+			if rest := s[i+2:]; expandable > 0 && (rest == "" || rest[0] == '\n') {
+				expandable--
+			} else {
+				counts["||"]++
+			}
+			i += 2
+			continue
+		case r == '*' || r == '_' || r == '~':
+			counts[string(r)]++
+		case r == '>' && lineStart:
+		case r == '!' && strings.HasPrefix(s[i+size:], "["):
+			// ![👍](tg://emoji?id=...) — пользовательский эмодзи
+		case strings.ContainsRune(telegramMarkdownV2Reserved, r):
+			return fmt.Errorf("unescaped %q", r)
+		}
+		i += size
+	}
+	if link {
+		return fmt.Errorf("[ is not closed")
+	}
+	for _, marker := range []string{"*", "_", "~", "||"} {
+		if counts[marker]%2 != 0 {
+			return fmt.Errorf("%s is not closed", marker)
+		}
+	}
+	return nil
+}
+
+// markdownV2Closing возвращает позицию первого неэкранированного delim в s или -1.
+func markdownV2Closing(s, delim string) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case strings.HasPrefix(s[i:], delim):
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateWebhook проверяет адрес, параметры доставки и текст вебхука.
+func ValidateWebhook(req *models.CreateNotificationRequest, errs *Errors) {
+	validateMessage(req, errs)
+	if len(req.Message) > MaxWebhookMessageSize {
+		errs.add("message", "message exceeds %d bytes", MaxWebhookMessageSize)
+	}
+
+	opts := req.Webhook
+	if opts == nil || opts.URL == "" {
+		errs.add("webhook.url", "webhook.url is required for webhook notifications")
+		return
+	}
+	if u, err := url.Parse(opts.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add("webhook.url", "webhook.url must be an absolute http or https URL")
+	}
+
+	switch opts.Format {
+	case "", models.WebhookFormatJSON, models.WebhookFormatForm:
+	default:
+		errs.add("webhook.format", "webhook.format must be json or form")
+	}
+
+	names := make([]string, 0, len(opts.Headers))
+	for name := range opts.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch {
+		case !headerName.MatchString(name):
+			errs.add("webhook.headers", "invalid header name %q", name)
+		case reservedWebhookHeaders[http.CanonicalHeaderKey(name)]:
+			errs.add("webhook.headers", "header %q cannot be overridden", name)
+		case strings.ContainsAny(opts.Headers[name], "\r\n\x00"):
+			errs.add("webhook.headers", "header %q cannot contain line breaks", name)
+		}
+	}
+
+	if opts.Timeout < 0 || opts.Timeout > MaxWebhookTimeout {
+		errs.add("webhook.timeout", "webhook.timeout must be between 0 and %d seconds", MaxWebhookTimeout)
+	}
+	for _, code := range opts.SuccessCodes {
+		if code < 100 || code > 599 {
+			errs.add("webhook.success_codes", "invalid HTTP status code %d", code)
+			break
+		}
+	}
+}
+
+// ValidateSMS проверяет номер и текст SMS-уведомления.
+func ValidateSMS(req *models.CreateNotificationRequest, errs *Errors) {
+	if req.Phone == "" {
+		errs.add("phone", "phone is required for sms notifications")
+	} else if !e164Phone.MatchString(req.Phone) {
+		errs.add("phone", "phone must be in E.164 format, e.g. +79991234567")
+	}
+
+	if validateMessage(req, errs) {
+		if _, segments := sender.SMSSegments(req.Message); segments > sender.SMSMaxSegments {
+			errs.add("message", "message takes %d segments, at most %d allowed", segments, sender.SMSMaxSegments)
+		}
+	}
+}
+
+// webPushUrgency допустимые значения заголовка Urgency (RFC 8030, раздел 5.3)
+var webPushUrgency = map[string]bool{"very-low": true, "low": true, "normal": true, "high": true}
+
+// ValidateWebPush проверяет подписчика, параметры доставки и размер браузерного push-уведомления.
+func ValidateWebPush(req *models.CreateNotificationRequest, errs *Errors) {
+	messageValid := validateMessage(req, errs)
+	if !utf8.ValidString(req.Subject) {
+		errs.add("subject", "subject must be valid UTF-8")
+		messageValid = false
+	}
+
+	opts := req.Push
+	if opts == nil || strings.TrimSpace(opts.Subscriber) == "" {
+		errs.add("push.subscriber", "push.subscriber is required for webpush notifications")
+		return
+	}
+	if opts.URL != "" {
+		if u, err := url.Parse(opts.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("push.url", "push.url must be an absolute http or https URL")
+		}
+	}
+	if opts.TTL < 0 || opts.TTL > sender.WebPushMaxTTL {
+		errs.add("push.ttl", "push.ttl must be between 0 and %d seconds", sender.WebPushMaxTTL)
+	}
+	if opts.Urgency != "" && !webPushUrgency[opts.Urgency] {
+		errs.add("push.urgency", "push.urgency must be very-low, low, normal or high")
+	}
+
+	if messageValid {
+		// размер считается с ID уведомления (UUID), который добавляется при отправке
+		payload, err := sender.WebPushPayload(strings.Repeat("0", 36), req.Subject, req.Message, opts.URL)
+		if err == nil && len(payload) > sender.WebPushMaxPayloadSize {
+			errs.add("message", "push payload exceeds %d bytes", sender.WebPushMaxPayloadSize)
+		}
+	}
+}
+
+// ValidatePushSubscription проверяет подписку браузера: адрес push-сервиса и ключи из PushSubscription.toJSON().
+func ValidatePushSubscription(s *models.PushSubscription) error {
+	var errs Errors
+	if strings.TrimSpace(s.Subscriber) == "" {
+		errs.add("subscriber", "subscriber is required")
+	}
+	if u, err := url.Parse(s.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add("endpoint", "endpoint must be an absolute http or https URL")
+	}
+	// p256dh — несжатая точка P-256 (65 байт), auth — 16 случайных байт
+	if key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s.Keys.P256DH, "=")); err != nil || len(key) != 65 || key[0] != 4 {
+		errs.add("keys.p256dh", "keys.p256dh must be an uncompressed P-256 public key in base64url")
+	}
+	if auth, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s.Keys.Auth, "=")); err != nil || len(auth) != 16 {
+		errs.add("keys.auth", "keys.auth must be 16 bytes in base64url")
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidateTelegramLinkToken проверяет запрос ссылки для связи чата Telegram с пользователем.
+func ValidateTelegramLinkToken(t *models.TelegramLinkToken) error {
+	var errs Errors
+	switch {
+	case strings.TrimSpace(t.UserID) == "":
+		errs.add("user_id", "user_id is required")
+	case !utf8.ValidString(t.UserID) || strings.ContainsFunc(t.UserID, unicode.IsControl):
+		errs.add("user_id", "user_id must be valid UTF-8 without control characters")
+	case utf8.RuneCountInString(t.UserID) > MaxUserIDLength:
+		errs.add("user_id", "user_id exceeds %d characters", MaxUserIDLength)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ChatValidator возвращает проверку уведомления для входящего вебхука Slack, Discord или Mattermost
+// с ограничением длины текста maxLength символов.
+func ChatValidator(maxLength int) ChannelValidator {
+	return func(req *models.CreateNotificationRequest, errs *Errors) {
+		if validateMessage(req, errs) && utf8.RuneCountInString(req.Subject)+utf8.RuneCountInString(req.Message) > maxLength {
+			errs.add("message", "message exceeds %d characters", maxLength)
+		}
+		if !utf8.ValidString(req.Subject) {
+			errs.add("subject", "subject must be valid UTF-8")
+		}
+
+		opts := req.Chat
+		if opts == nil || strings.TrimSpace(opts.Webhook) == "" {
+			errs.add("chat.webhook", "chat.webhook is required for %s notifications", req.Type)
+			return
+		}
+		if u, err := url.Parse(opts.Webhook); err != nil || strings.ContainsAny(opts.Webhook, " \t\r\n") ||
+			(u.IsAbs() && u.Scheme != "http" && u.Scheme != "https") {
+			errs.add("chat.webhook", "chat.webhook must be a webhook path or http(s) URL")
+		}
+		if len(opts.Blocks) > 0 {
+			var blocks []json.RawMessage
+			if err := json.Unmarshal(opts.Blocks, &blocks); err != nil {
+				errs.add("chat.blocks", "chat.blocks must be a JSON array")
+			}
+		}
+	}
+}
+
+// validateMessage проверяет общие для всех каналов требования к тексту.
+// Возвращает false, если текст уже признан некорректным.
+func validateMessage(req *models.CreateNotificationRequest, errs *Errors) bool {
+	if req.Message == "" {
+		errs.add("message", "message cannot be empty")
+		return false
+	}
+	if !utf8.ValidString(req.Message) {
+		errs.add("message", "message must be valid UTF-8")
+		return false
+	}
+	return true
+}
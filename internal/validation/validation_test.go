@@ -0,0 +1,409 @@
+package validation
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PavelBradnitski/WbTechL3.1/internal/models"
+	"github.com/PavelBradnitski/WbTechL3.1/internal/sender"
+	"github.com/stretchr/testify/assert"
+)
+
+// orderTemplate шаблон письма о заказе для проверки запросов с template_id.
+var orderTemplate = &models.Template{ID: "tmpl-1", Name: "order", Version: 1, Channels: map[models.NotificationType]models.TemplateContent{
+	models.NotificationTypeEmail: {Subject: "Заказ {{.order}}", Message: "Здравствуйте, {{.name}}!"},
+}}
+
+func TestValidateCreateRequest(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	validators := map[models.NotificationType]ChannelValidator{
+		models.NotificationTypeEmail:    ValidateEmail,
+		models.NotificationTypeTelegram: ValidateTelegram,
+		models.NotificationTypeWebhook:  ValidateWebhook,
+		models.NotificationTypeDiscord:  ChatValidator(2000),
+		models.NotificationTypeSMS:      ValidateSMS,
+		models.NotificationTypeWebPush:  ValidateWebPush,
+	}
+
+	tests := []struct {
+		name   string
+		req    models.CreateNotificationRequest
+		fields []string
+	}{
+		{
+			name: "ValidEmail",
+			req:  models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", Subject: "Hi", Message: "Hello", ScheduledAt: future},
+		},
+		{
+			name: "ValidTelegramChannel",
+			req:  models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "@my_channel", Message: "Hello", ScheduledAt: future},
+		},
+		{
+			name: "ValidTelegramGroup",
+			req:  models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "-1001234567890", Message: "Hello", ScheduledAt: future},
+		},
+		{
+			name:   "UnsupportedType",
+			req:    models.CreateNotificationRequest{Type: "pigeon"},
+			fields: []string{"type"},
+		},
+		{
+			name:   "InvalidEmailAndSubject",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "not an address", Subject: "Hi\r\nBcc: x@example.com", Message: "Hello", ScheduledAt: future},
+			fields: []string{"email", "subject"},
+		},
+		{
+			name:   "EmailWithDisplayName",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "User <user@example.com>", Message: "Hello", ScheduledAt: future},
+			fields: []string{"email"},
+		},
+		{
+			name: "ValidCategory",
+			req:  models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", Category: "newsletter", Message: "Hello", ScheduledAt: future},
+		},
+		{
+			name:   "InvalidCategory",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", Category: "News Letter", Message: "Hello", ScheduledAt: future},
+			fields: []string{"category"},
+		},
+		{
+			name:   "LongSubjectAndHugeMessage",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", Subject: strings.Repeat("s", MaxSubjectLength+1), Message: strings.Repeat("m", MaxEmailMessageSize+1), ScheduledAt: future},
+			fields: []string{"subject", "message"},
+		},
+		{
+			name:   "InvalidChatIDAndLongMessage",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "abc", Message: strings.Repeat("m", 4097), ScheduledAt: future},
+			fields: []string{"chat_id", "message"},
+		},
+		{
+			name:   "InvalidUTF8",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", Message: "\xff\xfe", ScheduledAt: future},
+			fields: []string{"message"},
+		},
+		{
+			name: "ValidWebhook",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeWebhook, Message: "Hello", ScheduledAt: future, Webhook: &models.WebhookOptions{
+				URL: "https://example.com/hook", Format: models.WebhookFormatForm, Headers: map[string]string{"Authorization": "Bearer x"}, SuccessCodes: []int{202},
+			}},
+		},
+		{
+			name:   "WebhookWithoutURL",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeWebhook, Message: "Hello", ScheduledAt: future},
+			fields: []string{"webhook.url"},
+		},
+		{
+			name: "InvalidWebhookOptions",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeWebhook, Message: "Hello", ScheduledAt: future, Webhook: &models.WebhookOptions{
+				URL:          "ftp://example.com/hook",
+				Format:       "xml",
+				Headers:      map[string]string{"Content-Type": "text/plain", "X-Trace": "a\r\nb"},
+				Timeout:      120,
+				SuccessCodes: []int{999},
+			}},
+			fields: []string{"webhook.url", "webhook.format", "webhook.headers", "webhook.headers", "webhook.timeout", "webhook.success_codes"},
+		},
+		{
+			name: "ValidDiscord",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeDiscord, Message: "**Hello**", ScheduledAt: future, Chat: &models.ChatOptions{
+				Webhook: "https://discord.com/api/webhooks/1/token", Blocks: []byte(`[{"title":"Deploy"}]`),
+			}},
+		},
+		{
+			name: "InvalidDiscord",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeDiscord, Message: strings.Repeat("m", 2001), ScheduledAt: future, Chat: &models.ChatOptions{
+				Webhook: "ftp://discord.com/api/webhooks/1/token", Blocks: []byte(`{"title":"Deploy"}`),
+			}},
+			fields: []string{"message", "chat.webhook", "chat.blocks"},
+		},
+		{
+			name: "ValidSMS",
+			req:  models.CreateNotificationRequest{Type: models.NotificationTypeSMS, Phone: "+79991234567", Message: "Код: 1234", ScheduledAt: future},
+		},
+		{
+			name:   "InvalidSMS",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeSMS, Phone: "89991234567", Message: strings.Repeat("я", 671), ScheduledAt: future},
+			fields: []string{"phone", "message"},
+		},
+		{
+			name: "ValidWebPush",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeWebPush, Subject: "Напоминание", Message: "Заказ готов", ScheduledAt: future,
+				Push: &models.WebPushOptions{Subscriber: "user-1", URL: "https://example.com/orders/42", TTL: 3600, Urgency: "high"}},
+		},
+		{
+			name: "InvalidWebPush",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeWebPush, Message: strings.Repeat("m", 4000), ScheduledAt: future,
+				Push: &models.WebPushOptions{Subscriber: "user-1", URL: "/orders/42", TTL: -1, Urgency: "urgent"}},
+			fields: []string{"push.url", "push.ttl", "push.urgency", "message"},
+		},
+		{
+			name: "ValidHTMLEmail",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", HTML: `<p>Hello</p><img src="cid:logo">`, ScheduledAt: future,
+				Attachments: []models.Attachment{
+					{Filename: "invoice.pdf", Content: []byte("%PDF-1.4")},
+					{Filename: "logo.png", ContentID: "logo", Content: []byte("\x89PNG\r\n\x1a\n")},
+				}},
+		},
+		{
+			name: "InvalidAttachments",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", Message: "Hello", ScheduledAt: future,
+				Attachments: []models.Attachment{
+					{Filename: "../etc/passwd", Content: []byte("x")},
+					{Filename: "empty.txt", ContentType: "text"},
+					{Filename: "logo.png", ContentID: "logo", Content: []byte("\x89PNG\r\n\x1a\n")},
+				}},
+			fields: []string{"attachments[0].filename", "attachments[1].content", "attachments[1].content_type", "attachments[2].content_id"},
+		},
+		{
+			name: "ValidTelegramRich",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "-1001234567890", Message: `<b>Заказ&#160;42</b> готов &amp; <a href="https://shop.example.com">ждет</a>`, ScheduledAt: future,
+				Telegram: &models.TelegramOptions{
+					ParseMode: models.TelegramParseModeHTML, MessageThreadID: 7, DisableNotification: true,
+					Buttons: [][]models.TelegramButton{{{Text: "Открыть", URL: "https://shop.example.com"}, {Text: "Отменить", CallbackData: "cancel:42"}}},
+				}},
+		},
+		{
+			name: "InvalidTelegramOptions",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", Message: "<b>bold <i>x</b></i>", ScheduledAt: future,
+				Telegram: &models.TelegramOptions{
+					ParseMode:   models.TelegramParseModeHTML,
+					ReplyMarkup: json.RawMessage(`{"force_reply":true}`),
+					Buttons: [][]models.TelegramButton{{
+						{Text: "", URL: "javascript:alert(1)"},
+						{Text: "Both", URL: "https://example.com", CallbackData: "x"},
+						{Text: "Long", CallbackData: strings.Repeat("x", MaxTelegramCallbackData+1)},
+					}},
+				}},
+			fields: []string{"message", "telegram.reply_markup", "telegram.buttons[0][0].text", "telegram.buttons[0][0].url", "telegram.buttons[0][1]", "telegram.buttons[0][2].callback_data"},
+		},
+		{
+			name:   "InvalidTelegramParseMode",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", Message: "Hi", ScheduledAt: future, Telegram: &models.TelegramOptions{ParseMode: "Markdown", MessageThreadID: -1}},
+			fields: []string{"telegram.parse_mode", "telegram.message_thread_id"},
+		},
+		{
+			name: "ValidTelegramMarkdownV2",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", ScheduledAt: future,
+				Message:  "*Заказ №1* готов: [открыть](https://example.com/orders/1?a=b\\)) ||код `A-1`||\n>цитата\nИтого 1\\.5\\!",
+				Telegram: &models.TelegramOptions{ParseMode: models.TelegramParseModeMarkdownV2}},
+		},
+		{
+			name: "UnescapedTelegramMarkdownV2",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", Message: "Итого 1.5!", ScheduledAt: future,
+				Telegram: &models.TelegramOptions{ParseMode: models.TelegramParseModeMarkdownV2}},
+			fields: []string{"message"},
+		},
+		{
+			name: "UnclosedTelegramMarkdownV2",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", Message: "*жирный и _курсив*", ScheduledAt: future,
+				Telegram: &models.TelegramOptions{ParseMode: models.TelegramParseModeMarkdownV2}},
+			fields: []string{"message"},
+		},
+		{
+			name: "ValidTelegramAlbum",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", ScheduledAt: future,
+				Telegram: &models.TelegramOptions{Media: []models.TelegramMedia{
+					{Type: models.TelegramMediaDocument, URL: "https://example.com/terms.pdf"},
+					{Type: models.TelegramMediaDocument, File: &models.Attachment{Filename: "invoice.pdf", Content: []byte("%PDF-1.4")}},
+				}}},
+		},
+		{
+			name: "InvalidTelegramMedia",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", Message: strings.Repeat("a", sender.TelegramMaxCaptionLength+1), ScheduledAt: future,
+				Telegram: &models.TelegramOptions{
+					Buttons: [][]models.TelegramButton{{{Text: "Открыть", URL: "https://example.com"}}},
+					Media: []models.TelegramMedia{
+						{Type: models.TelegramMediaPhoto, File: &models.Attachment{Filename: "report.txt", Content: []byte("plain text")}},
+						{Type: models.TelegramMediaDocument, URL: "ftp://example.com/a.pdf"},
+						{Type: "video"},
+					},
+				}},
+			fields: []string{"message", "telegram.media[0].file.content_type", "telegram.media[1].type", "telegram.media[1].url", "telegram.media[2].type", "telegram.media[2]", "telegram.media"},
+		},
+		{
+			name: "ValidTemplate",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", TemplateID: "tmpl-1", Template: orderTemplate,
+				Variables: map[string]any{"order": 42, "name": "Анна"}, ScheduledAt: future},
+		},
+		{
+			name: "TemplateMissingVariable",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", TemplateID: "tmpl-1", Template: orderTemplate,
+				Variables: map[string]any{"order": 42}, ScheduledAt: future},
+			fields: []string{"variables"},
+		},
+		{
+			name: "TemplateWithMessage",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", TemplateID: "tmpl-1", Template: orderTemplate,
+				Message: "Hello", Variables: map[string]any{"order": 42, "name": "Анна"}, ScheduledAt: future},
+			fields: []string{"template_id"},
+		},
+		{
+			name:   "TemplateWithoutChannel",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", TemplateID: "tmpl-1", Template: orderTemplate, ScheduledAt: future},
+			fields: []string{"template_id"},
+		},
+		{
+			name: "TelegramTemplateEscapesVariables",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeTelegram, ChatID: "42", TemplateID: "tmpl-2", ScheduledAt: future,
+				Template: &models.Template{ID: "tmpl-2", Name: "order", Version: 1, Channels: map[models.NotificationType]models.TemplateContent{
+					models.NotificationTypeTelegram: {Message: "*Заказ {{.order}}* готов\\!"},
+				}},
+				Variables: map[string]any{"order": "A-1.2"},
+				Telegram:  &models.TelegramOptions{ParseMode: models.TelegramParseModeMarkdownV2}},
+		},
+		{
+			name:   "TemplateNotFound",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", TemplateID: "deleted", ScheduledAt: future},
+			fields: []string{"template_id"},
+		},
+		{
+			name:   "VariablesWithoutTemplate",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Email: "user@example.com", Message: "Hello", Variables: map[string]any{"name": "Анна"}, ScheduledAt: future},
+			fields: []string{"template_id"},
+		},
+		{
+			name:   "MissingEverything",
+			req:    models.CreateNotificationRequest{Type: models.NotificationTypeTelegram},
+			fields: []string{"chat_id", "message", "scheduled_at"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCreateRequest(&tt.req, validators[tt.req.Type])
+			if len(tt.fields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var errs Errors
+			if assert.ErrorAs(t, err, &errs) {
+				var fields []string
+				for _, fe := range errs {
+					fields = append(fields, fe.Field)
+				}
+				assert.Equal(t, tt.fields, fields)
+			}
+		})
+	}
+}
+
+func TestValidateGroup(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	validators := func(t models.NotificationType) ChannelValidator {
+		switch t {
+		case models.NotificationTypeEmail:
+			return ValidateEmail
+		case models.NotificationTypeTelegram:
+			return ValidateTelegram
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name   string
+		req    models.CreateNotificationRequest
+		fields []string
+	}{
+		{
+			name: "ValidFallback",
+			req: models.CreateNotificationRequest{Mode: models.DeliveryModeFallback, FallbackAfter: 600, Subject: "Hi", Message: "Hello", ScheduledAt: future, Targets: []models.CreateNotificationRequest{
+				{Type: models.NotificationTypeTelegram, ChatID: "123"},
+				{Type: models.NotificationTypeEmail, Email: "user@example.com"},
+			}},
+		},
+		{
+			name: "InvalidMode",
+			req: models.CreateNotificationRequest{Type: models.NotificationTypeEmail, Mode: "any", FallbackAfter: 60, Message: "Hello", ScheduledAt: future, Targets: []models.CreateNotificationRequest{
+				{Type: models.NotificationTypeTelegram, ChatID: "123"},
+			}},
+			fields: []string{"type", "mode", "fallback_after"},
+		},
+		{
+			name: "InvalidTargets",
+			req: models.CreateNotificationRequest{Mode: models.DeliveryModeAll, Message: "Hello", Targets: []models.CreateNotificationRequest{
+				{Type: models.NotificationTypeEmail, Email: "not-an-email"},
+				{Type: "pigeon"},
+				{Type: models.NotificationTypeTelegram, Targets: []models.CreateNotificationRequest{{Type: models.NotificationTypeEmail}}},
+			}},
+			fields: []string{"targets[0].email", "targets[1].type", "targets[2].targets", "scheduled_at"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateGroup(&tt.req, validators)
+			if len(tt.fields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var errs Errors
+			if assert.ErrorAs(t, err, &errs) {
+				var fields []string
+				for _, fe := range errs {
+					fields = append(fields, fe.Field)
+				}
+				assert.Equal(t, tt.fields, fields)
+			}
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	validators := func(t models.NotificationType) ChannelValidator {
+		switch t {
+		case models.NotificationTypeEmail:
+			return ValidateEmail
+		case models.NotificationTypeTelegram:
+			return ValidateTelegram
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name   string
+		tmpl   models.Template
+		fields []string
+	}{
+		{
+			name: "Valid",
+			tmpl: models.Template{Name: "order", Channels: map[models.NotificationType]models.TemplateContent{
+				models.NotificationTypeEmail:    {Subject: "Заказ {{.order}}", HTML: "<p>{{.name}}</p>"},
+				models.NotificationTypeTelegram: {Message: "Заказ {{.order}} {{with index . \"comment\"}}({{.}}){{end}}"},
+			}},
+		},
+		{
+			name:   "MissingEverything",
+			tmpl:   models.Template{},
+			fields: []string{"name", "channels"},
+		},
+		{
+			name: "InvalidChannels",
+			tmpl: models.Template{Name: "order", Channels: map[models.NotificationType]models.TemplateContent{
+				models.NotificationTypeEmail:    {Message: "Заказ {{.order"},
+				models.NotificationTypeTelegram: {HTML: "<p>{{.name}}</p>"},
+				"pigeon":                        {Message: "Hello"},
+			}},
+			fields: []string{"channels.email.message", "channels.pigeon", "channels.telegram.html"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTemplate(&tt.tmpl, validators)
+			if len(tt.fields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var errs Errors
+			if assert.ErrorAs(t, err, &errs) {
+				var fields []string
+				for _, fe := range errs {
+					fields = append(fields, fe.Field)
+				}
+				assert.Equal(t, tt.fields, fields)
+			}
+		})
+	}
+}